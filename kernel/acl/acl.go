@@ -0,0 +1,140 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package acl 实现了笔记本和文档访问控制规则的权限判断、接口白名单和查询过滤。
+//
+// 设置了规则的账号只能访问规则授予的笔记本和文档，没有列出的接口默认都不能访问。
+package acl
+
+import (
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+)
+
+// Permission 描述了规则授予的权限，值越大权限越高，高权限包含低权限。
+type Permission int
+
+const (
+	PermissionNone Permission = iota
+	PermissionRead
+	PermissionComment
+	PermissionEdit
+)
+
+// ToPermission 将规则中的权限转换为 Permission，不支持的权限返回 PermissionNone。
+func ToPermission(permission string) Permission {
+	switch permission {
+	case conf.ACLPermissionRead:
+		return PermissionRead
+	case conf.ACLPermissionComment:
+		return PermissionComment
+	case conf.ACLPermissionEdit:
+		return PermissionEdit
+	}
+	return PermissionNone
+}
+
+// Doc 描述了请求或者消息中涉及的笔记本（Path 为空时）或者文档。
+type Doc struct {
+	Box    string
+	Path   string
+	ByPath bool // 是否通过路径（而不是块 ID）指定，通过路径只读访问时有权限文档的上级文档也可以访问，以便在文档树中展开
+}
+
+// IsValidRule 判断规则的笔记本 ID 和文档路径是否合法，只有合法的规则才会授予权限以及拼接到查询条件中。
+func IsValidRule(rule *conf.ACL) bool {
+	return ast.IsNodeIDPattern(rule.Box) && ("" == rule.Path || DocPathRegexp.MatchString(rule.Path))
+}
+
+// PermissionOf 返回规则授予笔记本中文档（path 为空时为整个笔记本）的权限。
+//
+// 作用于笔记本的规则覆盖笔记本中的所有文档，作用于文档的规则覆盖该文档及其子文档，匹配多条规则时使用最高的权限。
+func PermissionOf(rules []*conf.ACL, boxID, path string) (ret Permission) {
+	for _, rule := range rules {
+		if rule.Box != boxID || !IsValidRule(rule) {
+			continue
+		}
+
+		covered := "" == rule.Path
+		if !covered && "" != path {
+			covered = rule.Path == path || strings.HasPrefix(path, docDir(rule.Path))
+		}
+		if permission := ToPermission(rule.Permission); covered && ret < permission {
+			ret = permission
+		}
+	}
+	return
+}
+
+// IsDocAllowed 判断规则是否授予了笔记本或者文档的权限。
+//
+// 读取整个笔记本（比如列出根目录文档）只需要存在作用于该笔记本的规则，修改整个笔记本需要笔记本级别的规则。
+func IsDocAllowed(rules []*conf.ACL, doc *Doc, required Permission) bool {
+	if "" == doc.Path || "/" == doc.Path {
+		if PermissionRead == required {
+			for _, rule := range rules {
+				if rule.Box == doc.Box && PermissionNone < ToPermission(rule.Permission) && IsValidRule(rule) {
+					return true
+				}
+			}
+			return false
+		}
+		return required <= PermissionOf(rules, doc.Box, "")
+	}
+
+	if required <= PermissionOf(rules, doc.Box, doc.Path) {
+		return true
+	}
+
+	if PermissionRead == required && doc.ByPath {
+		// 有权限文档的上级文档
+		dir := docDir(doc.Path)
+		for _, rule := range rules {
+			if rule.Box == doc.Box && strings.HasPrefix(rule.Path, dir) && PermissionNone < ToPermission(rule.Permission) && IsValidRule(rule) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsPushAllowed 判断是否可以将推送消息发送给设置了规则的账号，消息数据中涉及的文档都需要有读权限，
+// blockDoc 和 attrViewDocs 用于将块 ID 和属性视图 ID 转换为所在的文档。
+func IsPushAllowed(rules []*conf.ACL, msg []byte, blockDoc func(id string) *Doc, attrViewDocs func(avID string) []*Doc) bool {
+	if 1 > len(rules) {
+		return true
+	}
+
+	event := map[string]interface{}{}
+	if err := gulu.JSON.UnmarshalJSON(msg, &event); err != nil {
+		return false
+	}
+
+	for _, doc := range ArgDocs(event["data"], blockDoc, attrViewDocs) {
+		if !IsDocAllowed(rules, doc, PermissionRead) {
+			return false
+		}
+	}
+	return true
+}
+
+// docDir 返回文档的子文档所在的目录，比如 /20200812220555-lj3enxa.sy 返回 /20200812220555-lj3enxa/。
+func docDir(path string) string {
+	return strings.TrimSuffix(path, ".sy") + "/"
+}
@@ -0,0 +1,121 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package acl
+
+import (
+	"testing"
+
+	"github.com/siyuan-note/siyuan/kernel/conf"
+)
+
+const (
+	testBox      = "20200812220555-lj3enxa"
+	testOtherBox = "20200812220555-abcdefg"
+	testDoc      = "/20210808180320-aaaaaaa.sy"
+	testChildDoc = "/20210808180320-aaaaaaa/20210808180320-bbbbbbb.sy"
+	testOtherDoc = "/20210808180320-ccccccc.sy"
+)
+
+func TestPermissionOf(t *testing.T) {
+	tests := []struct {
+		rules []*conf.ACL
+		box   string
+		path  string
+		want  Permission
+	}{
+		// 笔记本规则覆盖所有文档，文档规则覆盖子文档
+		{[]*conf.ACL{{Box: testBox, Permission: "read"}}, testBox, testChildDoc, PermissionRead},
+		{[]*conf.ACL{{Box: testBox, Path: testDoc, Permission: "edit"}}, testBox, testChildDoc, PermissionEdit},
+		{[]*conf.ACL{{Box: testBox, Path: testDoc, Permission: "edit"}}, testBox, testOtherDoc, PermissionNone},
+		{[]*conf.ACL{{Box: testBox, Path: testChildDoc, Permission: "edit"}}, testBox, testDoc, PermissionNone},
+		{[]*conf.ACL{{Box: testBox, Path: testDoc, Permission: "edit"}}, testBox, "", PermissionNone},
+		{[]*conf.ACL{{Box: testBox, Permission: "edit"}}, testOtherBox, testDoc, PermissionNone},
+		// 匹配多条规则时使用最高的权限，与规则的范围和顺序无关
+		{[]*conf.ACL{{Box: testBox, Permission: "read"}, {Box: testBox, Path: testDoc, Permission: "edit"}}, testBox, testChildDoc, PermissionEdit},
+		{[]*conf.ACL{{Box: testBox, Permission: "edit"}, {Box: testBox, Path: testDoc, Permission: "read"}}, testBox, testChildDoc, PermissionEdit},
+		{[]*conf.ACL{{Box: testBox, Path: testDoc, Permission: "comment"}, {Box: testBox, Path: testChildDoc, Permission: "read"}}, testBox, testChildDoc, PermissionComment},
+		// 不合法的规则不授予权限
+		{[]*conf.ACL{{Box: testBox, Permission: "owner"}}, testBox, testDoc, PermissionNone},
+		{[]*conf.ACL{{Box: testBox, Path: "/../x.sy", Permission: "edit"}}, testBox, "/../x/y.sy", PermissionNone},
+	}
+
+	for i, test := range tests {
+		if got := PermissionOf(test.rules, test.box, test.path); test.want != got {
+			t.Fatalf("case [%d] expected [%d], got [%d]", i, test.want, got)
+		}
+	}
+}
+
+func TestIsDocAllowed(t *testing.T) {
+	rules := []*conf.ACL{{Box: testBox, Path: testChildDoc, Permission: "comment"}}
+	tests := []struct {
+		doc      *Doc
+		required Permission
+		want     bool
+	}{
+		{&Doc{Box: testBox, Path: testChildDoc}, PermissionComment, true},
+		{&Doc{Box: testBox, Path: testChildDoc}, PermissionEdit, false},
+		// 通过路径读取有权限文档的上级文档以便在文档树中展开，通过块 ID 不能读取
+		{&Doc{Box: testBox, Path: testDoc, ByPath: true}, PermissionRead, true},
+		{&Doc{Box: testBox, Path: testDoc}, PermissionRead, false},
+		{&Doc{Box: testBox, Path: testDoc, ByPath: true}, PermissionComment, false},
+		{&Doc{Box: testBox, Path: testOtherDoc, ByPath: true}, PermissionRead, false},
+		// 读取笔记本只需要存在作用于该笔记本的规则，修改笔记本需要笔记本级别的规则
+		{&Doc{Box: testBox, ByPath: true}, PermissionRead, true},
+		{&Doc{Box: testBox, Path: "/", ByPath: true}, PermissionComment, false},
+		{&Doc{Box: testOtherBox, ByPath: true}, PermissionRead, false},
+	}
+
+	for i, test := range tests {
+		if got := IsDocAllowed(rules, test.doc, test.required); test.want != got {
+			t.Fatalf("case [%d] doc [%+v] expected [%v], got [%v]", i, test.doc, test.want, got)
+		}
+	}
+}
+
+func TestIsPushAllowed(t *testing.T) {
+	blockDocs := map[string]*Doc{
+		"20210808180320-bbbbbbb": {Box: testBox, Path: testChildDoc},
+		"20210808180320-ccccccc": {Box: testBox, Path: testOtherDoc},
+	}
+	blockDoc := func(id string) *Doc {
+		return blockDocs[id]
+	}
+	rules := []*conf.ACL{{Box: testBox, Path: testDoc, Permission: "read"}}
+
+	tests := []struct {
+		rules []*conf.ACL
+		msg   string
+		want  bool
+	}{
+		{nil, `{"cmd":"transactions","data":[{"doOperations":[{"id":"20210808180320-ccccccc"}]}]}`, true}, // 没有设置规则的账号不过滤
+		{rules, `{"cmd":"transactions","data":[{"doOperations":[{"id":"20210808180320-bbbbbbb"}]}]}`, true},
+		{rules, `{"cmd":"transactions","data":[{"doOperations":[{"id":"20210808180320-bbbbbbb"},{"id":"20210808180320-ccccccc"}]}]}`, false},
+		{rules, `{"cmd":"rename","data":{"box":"` + testBox + `","path":"` + testOtherDoc + `","title":"secret"}}`, false},
+		{rules, `{"cmd":"rename","data":{"box":"` + testBox + `","path":"` + testDoc + `","title":"title"}}`, true},
+		{rules, `{"cmd":"moveDoc","data":{"fromNotebook":"` + testBox + `","toNotebook":"` + testOtherBox + `","id":"20210808180320-bbbbbbb"}}`, false},
+		{rules, `{"cmd":"removeDoc","data":{"ids":["20210808180320-ccccccc"]}}`, false},
+		{rules, `{"cmd":"progress","data":{"msg":"indexing"}}`, true},
+		{rules, `{"cmd":`, false},
+	}
+
+	for i, test := range tests {
+		if got := IsPushAllowed(test.rules, []byte(test.msg), blockDoc, noAttrViewDocs); test.want != got {
+			t.Fatalf("case [%d] message [%s] expected [%v], got [%v]", i, test.msg, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package acl
+
+import (
+	"regexp"
+
+	"github.com/88250/gulu"
+)
+
+var (
+	// DocPathRegexp 用于匹配文档路径，比如 /20200812220555-lj3enxa/20210808180320-abcdefg.sy
+	DocPathRegexp = regexp.MustCompile(`^(/\d{14}-[0-9a-z]{7})+\.sy$`)
+
+	// 请求参数中表示笔记本 ID、块 ID 和属性视图 ID 的键
+	BoxArgKeys      = []string{"notebook", "notebooks", "box", "boxes", "boxID", "toNotebook"}
+	BlockArgKeys    = []string{"id", "ids", "rootID", "parentID", "previousID", "nextID", "blockID", "blockIDs", "defID", "fromID", "fromIDs", "toID", "refTreeID", "srcIDs", "rowID"}
+	AttrViewArgKeys = []string{"avID"}
+)
+
+// ArgDocs 返回参数中涉及的笔记本和文档，块 ID 通过 blockDoc 转换为所在的文档，属性视图 ID 通过 attrViewDocs 转换为镜像块所在的文档，
+// 不能转换的 ID 会被忽略；与笔记本 ID 同级的 path 是文档路径时作为该笔记本中的文档，否则（比如人类可读路径）视为整个笔记本。
+func ArgDocs(arg interface{}, blockDoc func(id string) *Doc, attrViewDocs func(avID string) []*Doc) []*Doc {
+	return argDocs(arg, blockDoc, attrViewDocs, 0)
+}
+
+// IsTransactionResolved 判断事务参数中的每个操作是否都能确定涉及的文档。
+//
+// 事务中的操作分别检查，不能确定涉及文档的操作（比如只有不存在的行 ID 或者没有镜像块的属性视图 ID）无法检查权限，
+// 即使同一批次中的其他操作能够确定文档，设置了规则的账号也不能执行该事务。
+func IsTransactionResolved(arg interface{}, blockDoc func(id string) *Doc, attrViewDocs func(avID string) []*Doc) bool {
+	m, _ := arg.(map[string]interface{})
+	transactions, _ := m["transactions"].([]interface{})
+	for _, transaction := range transactions {
+		tx, _ := transaction.(map[string]interface{})
+		ops, _ := tx["doOperations"].([]interface{})
+		for _, op := range ops {
+			if 1 > len(ArgDocs(op, blockDoc, attrViewDocs)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func argDocs(arg interface{}, blockDoc func(id string) *Doc, attrViewDocs func(avID string) []*Doc, depth int) (ret []*Doc) {
+	if 8 < depth {
+		return
+	}
+
+	switch v := arg.(type) {
+	case map[string]interface{}:
+		var boxIDs []string
+		for key, val := range v {
+			if gulu.Str.Contains(key, BoxArgKeys) {
+				boxIDs = append(boxIDs, argStrings(val)...)
+			} else if gulu.Str.Contains(key, BlockArgKeys) {
+				for _, id := range argStrings(val) {
+					if doc := blockDoc(id); nil != doc {
+						ret = append(ret, doc)
+					}
+				}
+			} else if gulu.Str.Contains(key, AttrViewArgKeys) {
+				for _, avID := range argStrings(val) {
+					ret = append(ret, attrViewDocs(avID)...)
+				}
+			} else if "path" != key {
+				ret = append(ret, argDocs(val, blockDoc, attrViewDocs, depth+1)...)
+			}
+		}
+
+		path, _ := v["path"].(string)
+		if !DocPathRegexp.MatchString(path) {
+			path = ""
+		}
+		for _, boxID := range boxIDs {
+			ret = append(ret, &Doc{Box: boxID, Path: path, ByPath: true})
+		}
+	case []interface{}:
+		for _, val := range v {
+			ret = append(ret, argDocs(val, blockDoc, attrViewDocs, depth+1)...)
+		}
+	}
+	return
+}
+
+// ArgAttrKeys 返回参数中设置的块属性名。
+func ArgAttrKeys(arg interface{}) []string {
+	return argAttrKeys(arg, 0)
+}
+
+func argAttrKeys(arg interface{}, depth int) (ret []string) {
+	if 8 < depth {
+		return
+	}
+
+	switch v := arg.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			if attrs, ok := val.(map[string]interface{}); ok && "attrs" == key {
+				for name := range attrs {
+					ret = append(ret, name)
+				}
+				continue
+			}
+			ret = append(ret, argAttrKeys(val, depth+1)...)
+		}
+	case []interface{}:
+		for _, val := range v {
+			ret = append(ret, argAttrKeys(val, depth+1)...)
+		}
+	}
+	return
+}
+
+func argStrings(arg interface{}) (ret []string) {
+	switch v := arg.(type) {
+	case string:
+		ret = append(ret, v)
+	case []interface{}:
+		for _, val := range v {
+			if s, ok := val.(string); ok {
+				ret = append(ret, s)
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,81 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package acl
+
+import (
+	"testing"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+)
+
+func noAttrViewDocs(string) []*Doc {
+	return nil
+}
+
+func TestIsTransactionResolved(t *testing.T) {
+	blockDocs := map[string]*Doc{
+		"20210808180320-bbbbbbb": {Box: testBox, Path: testChildDoc},
+		"20210808180320-ccccccc": {Box: testBox, Path: testOtherDoc},
+	}
+	blockDoc := func(id string) *Doc {
+		return blockDocs[id]
+	}
+	// 属性视图 ID 转换为镜像块所在的文档
+	attrViewDocs := func(avID string) []*Doc {
+		if "20240101000000-avavava" == avID {
+			return []*Doc{blockDocs["20210808180320-ccccccc"]}
+		}
+		return nil
+	}
+	rules := []*conf.ACL{{Box: testBox, Path: testDoc, Permission: "edit"}}
+
+	parse := func(data string) (ret interface{}) {
+		if err := gulu.JSON.UnmarshalJSON([]byte(data), &ret); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	tests := []struct {
+		data     string
+		resolved bool
+		allowed  bool
+	}{
+		{`{"transactions":[{"doOperations":[{"action":"update","id":"20210808180320-bbbbbbb"}]}]}`, true, true},
+		// 同一批次中夹带无法确定文档的属性视图操作
+		{`{"transactions":[{"doOperations":[{"action":"update","id":"20210808180320-bbbbbbb"},{"action":"updateAttrViewCell","avID":"20240101000000-unknown","rowID":"20240101000000-rowrowr","keyID":"20240101000000-keykeyk"}]}]}`, false, true},
+		{`{"transactions":[{"doOperations":[{"action":"update","id":"20210808180320-bbbbbbb"}]},{"doOperations":[{"action":"removeAttrViewBlock","avID":"20240101000000-unknown","srcIDs":["20240101000000-rowrowr"]}]}]}`, false, true},
+		// 属性视图的镜像块在没有权限的文档中
+		{`{"transactions":[{"doOperations":[{"action":"update","id":"20210808180320-bbbbbbb"},{"action":"setAttrViewName","avID":"20240101000000-avavava"}]}]}`, true, false},
+		{`{"transactions":[{"doOperations":[{"action":"addFlashcards","deckID":"20230218211946-2kw8jgx","blockIDs":["20210808180320-bbbbbbb","20210808180320-ccccccc"]}]}]}`, true, false},
+	}
+
+	for i, test := range tests {
+		arg := parse(test.data)
+		if got := IsTransactionResolved(arg, blockDoc, attrViewDocs); test.resolved != got {
+			t.Fatalf("case [%d] expected resolved [%v], got [%v]", i, test.resolved, got)
+		}
+
+		allowed := true
+		for _, doc := range ArgDocs(arg, blockDoc, attrViewDocs) {
+			allowed = allowed && IsDocAllowed(rules, doc, PermissionEdit)
+		}
+		if test.allowed != allowed {
+			t.Fatalf("case [%d] expected allowed [%v], got [%v]", i, test.allowed, allowed)
+		}
+	}
+}
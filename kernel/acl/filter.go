@@ -0,0 +1,82 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package acl
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+
+	"github.com/88250/lute/ast"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+)
+
+// Filter 返回将 blocks 表的查询范围限制在规则可以读取的笔记本和文档内的 SQL 条件。
+//
+// 笔记本 ID 和文档路径在拼接前使用 IsValidRule 校验，只包含数字、小写字母、- 和 /，不合法的规则不授予任何文档。
+func Filter(rules []*conf.ACL) string {
+	return buildFilter(rules, func(rule *conf.ACL) string {
+		if "" == rule.Path {
+			return "box = '" + rule.Box + "'"
+		}
+		return "(box = '" + rule.Box + "' AND (path = '" + rule.Path + "' OR path LIKE '" + docDir(rule.Path) + "%'))"
+	})
+}
+
+// HistoryFilter 返回将 histories 表的查询范围限制在规则可以读取的笔记本和文档内的 SQL 条件，历史文件路径为 {历史目录}/{时间-操作}/{笔记本 ID}/{文档路径}。
+func HistoryFilter(rules []*conf.ACL) string {
+	return buildFilter(rules, func(rule *conf.ACL) string {
+		if "" == rule.Path {
+			return "path LIKE '%/" + rule.Box + "/%'"
+		}
+		return "(path LIKE '%/" + rule.Box + rule.Path + "' OR path LIKE '%/" + rule.Box + docDir(rule.Path) + "%')"
+	})
+}
+
+// HistoryDoc 返回历史文件对应的文档，historyPath 不在 historyDir 下或者不是文档历史时返回 nil。
+func HistoryDoc(historyDir, historyPath string) *Doc {
+	rel, err := filepath.Rel(historyDir, historyPath)
+	if err != nil {
+		return nil
+	}
+
+	// {时间-操作}/{笔记本 ID}/{文档路径}
+	parts := strings.SplitN(filepath.ToSlash(rel), "/", 3)
+	if 3 != len(parts) || ".." == parts[0] || !ast.IsNodeIDPattern(parts[1]) || !DocPathRegexp.MatchString("/"+parts[2]) {
+		return nil
+	}
+	return &Doc{Box: parts[1], Path: "/" + parts[2]}
+}
+
+func buildFilter(rules []*conf.ACL, condition func(rule *conf.ACL) string) string {
+	var conditions []string
+	for _, rule := range rules {
+		if PermissionNone == ToPermission(rule.Permission) || !IsValidRule(rule) {
+			continue
+		}
+		conditions = append(conditions, condition(rule))
+	}
+	if 1 > len(conditions) {
+		return " AND 1 = 0"
+	}
+
+	builder := bytes.Buffer{}
+	builder.WriteString(" AND (")
+	builder.WriteString(strings.Join(conditions, " OR "))
+	builder.WriteString(")")
+	return builder.String()
+}
@@ -0,0 +1,124 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package acl
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+)
+
+func TestFilter(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err = db.Exec("CREATE TABLE blocks (id, box, path)"); err != nil {
+		t.Fatal(err)
+	}
+	for id, p := range map[string][]string{
+		"doc":      {testBox, testDoc},
+		"child":    {testBox, testChildDoc},
+		"other":    {testBox, testOtherDoc},
+		"otherBox": {testOtherBox, testDoc},
+	} {
+		if _, err = db.Exec("INSERT INTO blocks VALUES (?, ?, ?)", id, p[0], p[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count := func(filter string) (ret int) {
+		if err := db.QueryRow("SELECT COUNT(*) FROM blocks WHERE 1 = 1" + filter).Scan(&ret); err != nil {
+			t.Fatalf("query with filter [%s] failed: %s", filter, err)
+		}
+		return
+	}
+
+	tests := []struct {
+		rules []*conf.ACL
+		want  int
+	}{
+		{[]*conf.ACL{{Box: testBox, Path: testDoc, Permission: "read"}}, 2},
+		{[]*conf.ACL{{Box: testBox, Permission: "read"}}, 3},
+		{[]*conf.ACL{{Box: testBox, Path: testChildDoc, Permission: "edit"}, {Box: testOtherBox, Permission: "comment"}}, 2},
+		// 不合法的规则不会拼接到查询条件中
+		{[]*conf.ACL{{Box: "' OR '1' = '1", Permission: "read"}}, 0},
+		{[]*conf.ACL{{Box: testBox, Path: "/x' OR '1' = '1", Permission: "read"}}, 0},
+		{[]*conf.ACL{{Box: testBox, Permission: "none"}}, 0},
+		{[]*conf.ACL{{Box: "' OR '1' = '1", Permission: "read"}, {Box: testOtherBox, Permission: "read"}}, 1},
+	}
+
+	for i, test := range tests {
+		if got := count(Filter(test.rules)); test.want != got {
+			t.Fatalf("case [%d] expected [%d] blocks, got [%d]", i, test.want, got)
+		}
+	}
+}
+
+func TestHistoryFilterAndDoc(t *testing.T) {
+	historyDir := filepath.Join("workspace", "history")
+	docHistory := filepath.Join(historyDir, "2024-03-04-102030-update", testBox, "20210808180320-aaaaaaa", "20210808180320-bbbbbbb.sy")
+	doc := HistoryDoc(historyDir, docHistory)
+	if nil == doc || testBox != doc.Box || testChildDoc != doc.Path {
+		t.Fatalf("unexpected history doc [%+v]", doc)
+	}
+
+	for _, historyPath := range []string{
+		filepath.Join("workspace", "data", testBox, "20210808180320-aaaaaaa.sy"),
+		filepath.Join(historyDir, "..", "..", "data", testBox, "20210808180320-aaaaaaa.sy"),
+		filepath.Join(historyDir, "2024-03-04-102030-update", "assets", "image.png"),
+		filepath.Join(historyDir, "2024-03-04-102030-delete", testBox, ".siyuan", "conf.json"),
+	} {
+		if doc = HistoryDoc(historyDir, historyPath); nil != doc {
+			t.Fatalf("history path [%s] should not be a doc history, got [%+v]", historyPath, doc)
+		}
+	}
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err = db.Exec("CREATE TABLE histories (path)"); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{
+		"/workspace/history/2024-03-04-102030-update/" + testBox + testDoc,
+		"/workspace/history/2024-03-04-102030-update/" + testBox + testChildDoc,
+		"/workspace/history/2024-03-04-102030-update/" + testBox + testOtherDoc,
+		"/workspace/history/2024-03-04-102030-update/assets/image.png",
+	} {
+		if _, err = db.Exec("INSERT INTO histories VALUES (?)", p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var count int
+	filter := HistoryFilter([]*conf.ACL{{Box: testBox, Path: testChildDoc, Permission: "read"}})
+	if err = db.QueryRow("SELECT COUNT(*) FROM histories WHERE 1 = 1" + filter).Scan(&count); err != nil || 1 != count {
+		t.Fatalf("expected 1 history, got [%d]: %v", count, err)
+	}
+	filter = HistoryFilter([]*conf.ACL{{Box: testBox, Permission: "read"}})
+	if err = db.QueryRow("SELECT COUNT(*) FROM histories WHERE 1 = 1" + filter).Scan(&count); err != nil || 3 != count {
+		t.Fatalf("expected 3 histories, got [%d]: %v", count, err)
+	}
+}
@@ -0,0 +1,84 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package acl
+
+import (
+	"github.com/88250/gulu"
+)
+
+var (
+	// docReadRoutes 为通过参数中的笔记本 ID、文档路径或者块 ID 确定访问范围的读取接口，请求时必须在参数中指定
+	docReadRoutes = []string{
+		"/api/notebook/getNotebookInfo",
+		"/api/filetree/listDocsByPath", "/api/filetree/listDocTree", "/api/filetree/getDoc", "/api/filetree/getHPathByPath", "/api/filetree/getHPathByID",
+		"/api/filetree/getPathByID", "/api/filetree/getFullHPathByID",
+		"/api/outline/getDocOutline",
+		"/api/block/getBlockInfo", "/api/block/getBlockDOM", "/api/block/getBlockKramdown", "/api/block/getChildBlocks", "/api/block/getTailChildBlocks",
+		"/api/block/getBlockBreadcrumb", "/api/block/getBlockIndex", "/api/block/getBlocksIndexes", "/api/block/getRefText", "/api/block/getDOMText",
+		"/api/block/getTreeStat", "/api/block/getBlocksWordCount", "/api/block/getDocInfo", "/api/block/getDocsInfo", "/api/block/checkBlockExist",
+		"/api/block/checkBlockFold", "/api/block/getBlockSiblingID", "/api/block/getBlockTreeInfos", "/api/block/getHeadingChildrenIDs", "/api/block/getHeadingChildrenDOM",
+		"/api/attr/getBlockAttrs", "/api/attr/batchGetBlockAttrs",
+		"/api/ref/getBacklink2", "/api/ref/getBacklinkDoc", "/api/ref/getBackmentionDoc",
+		"/api/graph/getLocalGraph",
+		"/api/export/exportMd", "/api/export/exportMds", "/api/export/exportMdContent", "/api/export/exportSY", "/api/export/preview",
+		"/api/export/exportReStructuredText", "/api/export/exportAsciiDoc", "/api/export/exportTextile", "/api/export/exportOPML", "/api/export/exportOrgMode",
+		"/api/export/exportMediaWiki", "/api/export/exportODT", "/api/export/exportRTF", "/api/export/exportEPUB",
+	}
+
+	// docCommentRoutes 为需要评论权限的接口，评论权限只能修改 CommentAttrs 中的块属性
+	docCommentRoutes = []string{"/api/attr/setBlockAttrs", "/api/attr/batchSetBlockAttrs"}
+	CommentAttrs     = []string{"memo"}
+
+	// docEditRoutes 为需要编辑权限的接口
+	docEditRoutes = []string{
+		"/api/transactions",
+		"/api/block/insertBlock", "/api/block/prependBlock", "/api/block/appendBlock", "/api/block/updateBlock", "/api/block/deleteBlock",
+		"/api/block/moveBlock", "/api/block/foldBlock", "/api/block/unfoldBlock",
+		"/api/filetree/createDocWithMd", "/api/filetree/createDoc", "/api/filetree/renameDoc", "/api/filetree/renameDocByID",
+		"/api/filetree/removeDoc", "/api/filetree/changeSort", "/api/filetree/duplicateDoc",
+		"/api/attr/resetBlockAttrs",
+	}
+
+	// filteredRoutes 为在返回结果时按照规则过滤文档的读取接口，参数中可以不指定笔记本和文档
+	filteredRoutes = []string{
+		"/api/notebook/lsNotebooks", "/api/filetree/searchDocs", "/api/search/fullTextSearchBlock", "/api/search/searchRefBlock",
+		"/api/graph/getGraph", "/api/history/searchHistory", "/api/history/getHistoryItems", "/api/history/getDocHistoryContent",
+		"/api/av/renderAttributeView",
+	}
+
+	// generalRoutes 为不读取笔记本数据的接口，返回的配置中已经隐藏了秘密信息
+	generalRoutes = []string{"/api/system/version", "/api/system/currentTime", "/api/system/bootProgress", "/api/system/getConf"}
+)
+
+// RoutePermission 返回设置了规则的账号访问接口需要的权限，没有列出的接口返回 PermissionNone，即默认不能访问。
+// 接口本身的角色检查仍然生效，比如需要管理员角色的导出接口只有管理员账号可以访问。
+//
+// docScoped 为 true 时接口通过参数中的笔记本 ID、文档路径或者块 ID 确定访问范围，请求时必须在参数中指定；
+// 否则接口在返回结果时按照规则过滤文档，或者不读取笔记本数据。
+func RoutePermission(path string) (ret Permission, docScoped bool) {
+	switch {
+	case gulu.Str.Contains(path, docReadRoutes):
+		return PermissionRead, true
+	case gulu.Str.Contains(path, docCommentRoutes):
+		return PermissionComment, true
+	case gulu.Str.Contains(path, docEditRoutes):
+		return PermissionEdit, true
+	case gulu.Str.Contains(path, filteredRoutes), gulu.Str.Contains(path, generalRoutes):
+		return PermissionRead, false
+	}
+	return PermissionNone, false
+}
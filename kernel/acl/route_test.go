@@ -0,0 +1,51 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package acl
+
+import (
+	"testing"
+)
+
+func TestRoutePermission(t *testing.T) {
+	tests := []struct {
+		path      string
+		want      Permission
+		docScoped bool
+	}{
+		{"/api/block/getBlockInfo", PermissionRead, true},
+		{"/api/filetree/listDocsByPath", PermissionRead, true},
+		{"/api/export/exportMd", PermissionRead, true},
+		{"/api/attr/setBlockAttrs", PermissionComment, true},
+		{"/api/transactions", PermissionEdit, true},
+		{"/api/search/searchRefBlock", PermissionRead, false},
+		{"/api/av/renderAttributeView", PermissionRead, false},
+		{"/api/system/getConf", PermissionRead, false},
+		// 没有列出的接口默认不能访问
+		{"/api/query/sql", PermissionNone, false},
+		{"/api/search/getSearchHistory", PermissionNone, false},
+		{"/api/export/exportNotebookMd", PermissionNone, false},
+		{"/api/notebook/removeNotebook", PermissionNone, false},
+		{"/api/file/getFile", PermissionNone, false},
+		{"/api/block/getBlockInfo/", PermissionNone, false},
+	}
+
+	for _, test := range tests {
+		if got, docScoped := RoutePermission(test.path); test.want != got || test.docScoped != docScoped {
+			t.Fatalf("route [%s] expected [%d, %v], got [%d, %v]", test.path, test.want, test.docScoped, got, docScoped)
+		}
+	}
+}
@@ -17,16 +17,160 @@
 package api
 
 import (
+	"errors"
+	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 
 	"github.com/88250/gulu"
 	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/av"
 	"github.com/siyuan-note/siyuan/kernel/model"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
 
+func getAttributeViewHistory(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	var rowID, keyID string
+	if rowIDArg := arg["rowID"]; nil != rowIDArg {
+		rowID = rowIDArg.(string)
+	}
+	if keyIDArg := arg["keyID"]; nil != keyIDArg {
+		keyID = keyIDArg.(string)
+	}
+	page := 1
+	if pageArg := arg["page"]; nil != pageArg {
+		page = int(pageArg.(float64))
+	}
+	pageSize := 32
+	if pageSizeArg := arg["pageSize"]; nil != pageSizeArg {
+		pageSize = int(pageSizeArg.(float64))
+	}
+
+	changes, total, err := model.GetAttributeViewHistory(avID, rowID, keyID, page, pageSize)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"changes": changes,
+		"total":   total,
+	}
+}
+
+func rollbackAttributeViewChange(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	changeID := arg["changeID"].(string)
+	var app, session string
+	if appArg := arg["app"]; nil != appArg {
+		app = appArg.(string)
+	}
+	if sessionArg := arg["session"]; nil != sessionArg {
+		session = sessionArg.(string)
+	}
+
+	if err := model.RollbackAttributeViewChange(app, session, avID, changeID); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+}
+
+func importAttributeView(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		logging.LogErrorf("parse import attribute view failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	files := form.File["file"]
+	if 1 > len(files) {
+		ret.Code = -1
+		ret.Msg = "no file found"
+		return
+	}
+	file := files[0]
+	reader, err := file.Open()
+	if err != nil {
+		logging.LogErrorf("read import attribute view file failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	defer reader.Close()
+
+	importDir := filepath.Join(util.TempDir, "import")
+	if err = os.MkdirAll(importDir, 0755); err != nil {
+		logging.LogErrorf("make import dir [%s] failed: %s", importDir, err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	writePath := filepath.Join(importDir, filepath.Base(file.Filename))
+	defer os.RemoveAll(writePath)
+	writer, err := os.OpenFile(writePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		logging.LogErrorf("open import attribute view file [%s] failed: %s", writePath, err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+	_, err = io.Copy(writer, reader)
+	writer.Close()
+	if err != nil {
+		logging.LogErrorf("write import attribute view file failed: %s", err)
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	formValue := func(name string) string {
+		if values := form.Value[name]; 0 < len(values) {
+			return values[0]
+		}
+		return ""
+	}
+
+	// avID 为空时新建数据库，notebook 为空时导入为非绑定块行
+	avID, blockID, err := model.ImportAttributeView(c, writePath, formValue("avID"), formValue("notebook"), formValue("toPath"))
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	ret.Data = map[string]interface{}{
+		"avID":    avID,
+		"blockID": blockID,
+	}
+}
+
 func duplicateAttributeViewBlock(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -170,11 +314,34 @@ func appendAttributeViewDetachedBlocksWithValues(c *gin.Context) {
 	}
 
 	err := model.AppendAttributeViewDetachedBlocksWithValues(avID, values)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		var validationErr *av.ValidationError
+		if errors.As(err, &validationErr) {
+			ret.Data = map[string]interface{}{"violations": validationErr.Violations}
+		}
+		return
+	}
+}
+
+func getAttributeViewViolations(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	avID := arg["avID"].(string)
+	violations, err := model.GetAttributeViewViolations(avID)
 	if err != nil {
 		ret.Code = -1
 		ret.Msg = err.Error()
 		return
 	}
+	ret.Data = violations
 }
 
 func addAttributeViewBlocks(c *gin.Context) {
@@ -551,7 +718,22 @@ func renderAttributeView(c *gin.Context) {
 		query = queryArg.(string)
 	}
 
-	view, attrView, err := model.RenderAttributeView(id, viewID, query, page, pageSize)
+	var dateRange *av.DateRange
+	if dateRangeArg := arg["dateRange"]; nil != dateRangeArg {
+		dateRangeMap := dateRangeArg.(map[string]interface{})
+		dateRange = &av.DateRange{}
+		if start, ok := dateRangeMap["start"].(float64); ok {
+			dateRange.Start = int64(start)
+		}
+		if end, ok := dateRangeMap["end"].(float64); ok {
+			dateRange.End = int64(end)
+		}
+		if unit, ok := dateRangeMap["unit"].(string); ok {
+			dateRange.Unit = av.DateUnit(unit)
+		}
+	}
+
+	view, attrView, err := model.RenderAttributeView(c, id, viewID, query, page, pageSize, dateRange)
 	if err != nil {
 		ret.Code = -1
 		ret.Msg = err.Error()
@@ -563,6 +745,12 @@ func renderAttributeView(c *gin.Context) {
 		pSize := 10
 		if nil != v.Table && av.LayoutTypeTable == v.LayoutType {
 			pSize = v.Table.PageSize
+		} else if nil != v.Board && av.LayoutTypeBoard == v.LayoutType {
+			pSize = v.Board.PageSize
+		} else if layout := v.GetCalendarLayout(); nil != layout {
+			pSize = layout.PageSize
+		} else if nil != v.Gallery && av.LayoutTypeGallery == v.LayoutType {
+			pSize = v.Gallery.PageSize
 		}
 
 		view := map[string]interface{}{
@@ -80,7 +80,7 @@ func getGraph(c *gin.Context) {
 	model.Conf.Graph.Global = global
 	model.Conf.Save()
 
-	boxID, nodes, links := model.BuildGraph(query)
+	boxID, nodes, links := model.BuildGraph(c, query)
 	ret.Data = map[string]interface{}{
 		"nodes": nodes,
 		"links": links,
@@ -126,7 +126,7 @@ func getLocalGraph(c *gin.Context) {
 	model.Conf.Graph.Local = local
 	model.Conf.Save()
 
-	boxID, nodes, links := model.BuildTreeGraph(id, keyword)
+	boxID, nodes, links := model.BuildTreeGraph(c, id, keyword)
 	ret.Data = map[string]interface{}{
 		"id":    id,
 		"box":   boxID,
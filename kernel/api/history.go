@@ -53,7 +53,7 @@ func searchHistory(c *gin.Context) {
 	if nil != arg["op"] {
 		op = arg["op"].(string)
 	}
-	histories, pageCount, totalCount := model.FullTextSearchHistory(query, notebook, op, typ, page)
+	histories, pageCount, totalCount := model.FullTextSearchHistory(c, query, notebook, op, typ, page)
 	ret.Data = map[string]interface{}{
 		"histories":  histories,
 		"pageCount":  pageCount,
@@ -61,6 +61,33 @@ func searchHistory(c *gin.Context) {
 	}
 }
 
+func searchHistoryBlocks(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	notebook := "%"
+	if nil != arg["notebook"] && "" != arg["notebook"].(string) {
+		notebook = arg["notebook"].(string)
+	}
+
+	// at：时间点（秒），为空时表示当前
+	var at int64
+	if nil != arg["at"] {
+		at = int64(arg["at"].(float64))
+	}
+
+	query := arg["query"].(string)
+	blocks := model.SearchHistoryBlocks(query, notebook, at)
+	ret.Data = map[string]interface{}{
+		"blocks": blocks,
+	}
+}
+
 func getHistoryItems(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
@@ -86,7 +113,7 @@ func getHistoryItems(c *gin.Context) {
 	if nil != arg["op"] {
 		op = arg["op"].(string)
 	}
-	histories := model.FullTextSearchHistoryItems(created, query, notebook, op, typ)
+	histories := model.FullTextSearchHistoryItems(c, created, query, notebook, op, typ)
 	ret.Data = map[string]interface{}{
 		"items": histories,
 	}
@@ -145,7 +172,7 @@ func getDocHistoryContent(c *gin.Context) {
 	if nil != k {
 		keyword = k.(string)
 	}
-	id, rootID, content, isLargeDoc, err := model.GetDocHistoryContent(historyPath, keyword)
+	id, rootID, content, isLargeDoc, err := model.GetDocHistoryContent(c, historyPath, keyword)
 	if err != nil {
 		ret.Code = -1
 		ret.Msg = err.Error()
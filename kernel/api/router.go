@@ -39,7 +39,12 @@ func ServeAPI(ginServer *gin.Engine) {
 
 	ginServer.Handle("POST", "/api/system/getEmojiConf", model.CheckAuth, getEmojiConf)
 	ginServer.Handle("POST", "/api/system/setAPIToken", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setAPIToken)
+	ginServer.Handle("POST", "/api/system/getAPITokens", model.CheckAuth, model.CheckAdminRole, getAPITokens)
+	ginServer.Handle("POST", "/api/system/createAPIToken", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, createAPIToken)
+	ginServer.Handle("POST", "/api/system/updateAPIToken", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, updateAPIToken)
+	ginServer.Handle("POST", "/api/system/revokeAPIToken", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, revokeAPIToken)
 	ginServer.Handle("POST", "/api/system/setAccessAuthCode", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setAccessAuthCode)
+	ginServer.Handle("POST", "/api/system/setOIDC", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setOIDC)
 	ginServer.Handle("POST", "/api/system/setFollowSystemLockScreen", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setFollowSystemLockScreen)
 	ginServer.Handle("POST", "/api/system/setNetworkServe", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setNetworkServe)
 	ginServer.Handle("POST", "/api/system/setUploadErrLog", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setUploadErrLog)
@@ -93,6 +98,10 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/notebook/setNotebookIcon", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setNotebookIcon)
 	ginServer.Handle("POST", "/api/notebook/getNotebookInfo", model.CheckAuth, model.CheckReadonly, getNotebookInfo)
 
+	ginServer.Handle("POST", "/api/acl/getACLs", model.CheckAuth, model.CheckAdminRole, getACLs)
+	ginServer.Handle("POST", "/api/acl/setACL", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, setACL)
+	ginServer.Handle("POST", "/api/acl/removeACL", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, removeACL)
+
 	ginServer.Handle("POST", "/api/filetree/searchDocs", model.CheckAuth, searchDocs)
 	ginServer.Handle("POST", "/api/filetree/listDocsByPath", model.CheckAuth, listDocsByPath)
 	ginServer.Handle("POST", "/api/filetree/getDoc", model.CheckAuth, getDoc)
@@ -129,12 +138,13 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/history/getNotebookHistory", model.CheckAuth, model.CheckAdminRole, getNotebookHistory)
 	ginServer.Handle("POST", "/api/history/rollbackNotebookHistory", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, rollbackNotebookHistory)
 	ginServer.Handle("POST", "/api/history/rollbackAssetsHistory", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, rollbackAssetsHistory)
-	ginServer.Handle("POST", "/api/history/getDocHistoryContent", model.CheckAuth, model.CheckAdminRole, getDocHistoryContent)
+	ginServer.Handle("POST", "/api/history/getDocHistoryContent", model.CheckAuth, model.CheckHistoryRole, getDocHistoryContent)
 	ginServer.Handle("POST", "/api/history/rollbackDocHistory", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, rollbackDocHistory)
 	ginServer.Handle("POST", "/api/history/clearWorkspaceHistory", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, clearWorkspaceHistory)
 	ginServer.Handle("POST", "/api/history/reindexHistory", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, reindexHistory)
-	ginServer.Handle("POST", "/api/history/searchHistory", model.CheckAuth, model.CheckAdminRole, searchHistory)
-	ginServer.Handle("POST", "/api/history/getHistoryItems", model.CheckAuth, model.CheckAdminRole, getHistoryItems)
+	ginServer.Handle("POST", "/api/history/searchHistory", model.CheckAuth, model.CheckHistoryRole, searchHistory)
+	ginServer.Handle("POST", "/api/history/getHistoryItems", model.CheckAuth, model.CheckHistoryRole, getHistoryItems)
+	ginServer.Handle("POST", "/api/history/searchHistoryBlocks", model.CheckAuth, model.CheckAdminRole, searchHistoryBlocks)
 
 	ginServer.Handle("POST", "/api/outline/getDocOutline", model.CheckAuth, getDocOutline)
 	ginServer.Handle("POST", "/api/bookmark/getBookmark", model.CheckAuth, getBookmark)
@@ -162,6 +172,9 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/search/fullTextSearchBlock", model.CheckAuth, fullTextSearchBlock)
 	ginServer.Handle("POST", "/api/search/searchAsset", model.CheckAuth, searchAsset)
 	ginServer.Handle("POST", "/api/search/findReplace", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, findReplace)
+	ginServer.Handle("POST", "/api/search/findReplacePreview", model.CheckAuth, model.CheckAdminRole, findReplacePreview)
+	ginServer.Handle("POST", "/api/search/replaceMatches", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, replaceMatches)
+	ginServer.Handle("POST", "/api/search/undoReplace", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, undoReplace)
 	ginServer.Handle("POST", "/api/search/fullTextSearchAssetContent", model.CheckAuth, fullTextSearchAssetContent)
 	ginServer.Handle("POST", "/api/search/getAssetContent", model.CheckAuth, getAssetContent)
 	ginServer.Handle("POST", "/api/search/listInvalidBlockRefs", model.CheckAuth, listInvalidBlockRefs)
@@ -440,6 +453,10 @@ func ServeAPI(ginServer *gin.Engine) {
 	ginServer.Handle("POST", "/api/av/getAttributeViewKeysByAvID", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, getAttributeViewKeysByAvID)
 	ginServer.Handle("POST", "/api/av/duplicateAttributeViewBlock", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, duplicateAttributeViewBlock)
 	ginServer.Handle("POST", "/api/av/appendAttributeViewDetachedBlocksWithValues", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, appendAttributeViewDetachedBlocksWithValues)
+	ginServer.Handle("POST", "/api/av/getAttributeViewHistory", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, getAttributeViewHistory)
+	ginServer.Handle("POST", "/api/av/rollbackAttributeViewChange", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, rollbackAttributeViewChange)
+	ginServer.Handle("POST", "/api/av/getAttributeViewViolations", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, getAttributeViewViolations)
+	ginServer.Handle("POST", "/api/av/importAttributeView", model.CheckAuth, model.CheckAdminRole, model.CheckReadonly, importAttributeView)
 
 	ginServer.Handle("POST", "/api/ai/chatGPT", model.CheckAuth, model.CheckAdminRole, chatGPT)
 	ginServer.Handle("POST", "/api/ai/chatGPTWithAction", model.CheckAuth, model.CheckAdminRole, chatGPTWithAction)
@@ -121,23 +121,109 @@ func findReplace(c *gin.Context) {
 		ids = append(ids, id.(string))
 	}
 
-	replaceTypes := map[string]bool{}
-	// text, imgText, imgTitle, imgSrc, aText, aTitle, aHref, code, em, strong, inlineMath, inlineMemo, blockRef, fileAnnotationRef kbd, mark, s, sub, sup, tag, u
-	// docTitle, codeBlock, mathBlock, htmlBlock
-	if nil != arg["replaceTypes"] {
-		replaceTypesArg := arg["replaceTypes"].(map[string]interface{})
-		for t, b := range replaceTypesArg {
-			replaceTypes[t] = b.(bool)
+	replaceTypes := parseReplaceTypes(arg)
+	err := model.FindReplace(k, r, replaceTypes, ids, paths, boxes, types, method, orderBy, groupBy)
+	if err != nil {
+		ret.Code = 1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+	return
+}
+
+func findReplacePreview(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	_, _, _, paths, boxes, types, method, orderBy, groupBy := parseSearchBlockArgs(arg)
+
+	k := arg["k"].(string)
+	r := arg["r"].(string)
+	var ids []string
+	if idsArg, ok := arg["ids"].([]interface{}); ok {
+		for _, id := range idsArg {
+			ids = append(ids, id.(string))
 		}
 	}
 
-	err := model.FindReplace(k, r, replaceTypes, ids, paths, boxes, types, method, orderBy, groupBy)
+	replaceTypes := parseReplaceTypes(arg)
+	preview, err := model.FindReplacePreview(k, r, replaceTypes, ids, paths, boxes, types, method, orderBy, groupBy)
+	if err != nil {
+		ret.Code = 1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+	ret.Data = preview
+}
+
+func replaceMatches(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	k := arg["k"].(string)
+	r := arg["r"].(string)
+	method := 0
+	if methodArg, ok := arg["method"].(float64); ok {
+		method = int(methodArg)
+	}
+	var matchIDs []string
+	if matchIDsArg, ok := arg["matchIDs"].([]interface{}); ok {
+		for _, id := range matchIDsArg {
+			matchIDs = append(matchIDs, id.(string))
+		}
+	}
+
+	replaceTypes := parseReplaceTypes(arg)
+	result, err := model.ReplaceMatches(c, k, r, replaceTypes, matchIDs, method)
 	if err != nil {
 		ret.Code = 1
 		ret.Msg = err.Error()
 		ret.Data = map[string]interface{}{"closeTimeout": 5000}
 		return
 	}
+	ret.Data = result
+}
+
+func undoReplace(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	replaceID := arg["replaceID"].(string)
+	if err := model.UndoReplace(c, replaceID); err != nil {
+		ret.Code = 1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+}
+
+func parseReplaceTypes(arg map[string]interface{}) (ret map[string]bool) {
+	ret = map[string]bool{}
+	// text, imgText, imgTitle, imgSrc, aText, aTitle, aHref, code, em, strong, inlineMath, inlineMemo, blockRef, fileAnnotationRef kbd, mark, s, sub, sup, tag, u
+	// docTitle, codeBlock, mathBlock, htmlBlock
+	if nil != arg["replaceTypes"] {
+		replaceTypesArg := arg["replaceTypes"].(map[string]interface{})
+		for t, b := range replaceTypesArg {
+			ret[t] = b.(bool)
+		}
+	}
 	return
 }
 
@@ -350,7 +436,7 @@ func searchRefBlock(c *gin.Context) {
 	id := arg["id"].(string)
 	keyword := arg["k"].(string)
 	beforeLen := int(arg["beforeLen"].(float64))
-	blocks, newDoc := model.SearchRefBlock(id, rootID, keyword, beforeLen, isSquareBrackets, isDatabase)
+	blocks, newDoc := model.SearchRefBlock(c, id, rootID, keyword, beforeLen, isSquareBrackets, isDatabase)
 	ret.Data = map[string]interface{}{
 		"blocks": blocks,
 		"newDoc": newDoc,
@@ -369,14 +455,35 @@ func fullTextSearchBlock(c *gin.Context) {
 	}
 
 	page, pageSize, query, paths, boxes, types, method, orderBy, groupBy := parseSearchBlockArgs(arg)
-	blocks, matchedBlockCount, matchedRootCount, pageCount, docMode := model.FullTextSearchBlock(query, boxes, paths, types, method, orderBy, groupBy, page, pageSize)
-	ret.Data = map[string]interface{}{
+
+	var facetFilter *model.SearchFacetFilter
+	if facetFiltersArg := arg["facetFilters"]; nil != facetFiltersArg {
+		data, err := gulu.JSON.MarshalJSON(facetFiltersArg)
+		if err != nil {
+			ret.Code = -1
+			ret.Msg = err.Error()
+			return
+		}
+		facetFilter = &model.SearchFacetFilter{}
+		if err = gulu.JSON.UnmarshalJSON(data, facetFilter); err != nil {
+			ret.Code = -1
+			ret.Msg = err.Error()
+			return
+		}
+	}
+
+	blocks, matchedBlockCount, matchedRootCount, pageCount, docMode := model.FullTextSearchBlock(c, query, boxes, paths, types, facetFilter, method, orderBy, groupBy, page, pageSize)
+	data := map[string]interface{}{
 		"blocks":            blocks,
 		"matchedBlockCount": matchedBlockCount,
 		"matchedRootCount":  matchedRootCount,
 		"pageCount":         pageCount,
 		"docMode":           docMode,
 	}
+	if facetsArg, ok := arg["facets"].(bool); ok && facetsArg {
+		data["facets"] = model.SearchBlockFacets(c, query, boxes, paths, types, facetFilter, method)
+	}
+	ret.Data = data
 }
 
 func parseSearchBlockArgs(arg map[string]interface{}) (page, pageSize int, query string, paths, boxes []string, types map[string]bool, method, orderBy, groupBy int) {
@@ -426,7 +533,7 @@ func parseSearchBlockArgs(arg map[string]interface{}) (page, pageSize int, query
 		}
 	}
 
-	// method：0：关键字，1：查询语法，2：SQL，3：正则表达式
+	// method：0：关键字，1：查询语法，2：SQL，3：正则表达式，4：语义，5：模糊，6：结构化查询
 	methodArg := arg["method"]
 	if nil != methodArg {
 		method = int(methodArg.(float64))
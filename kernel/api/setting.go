@@ -19,6 +19,7 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/88250/gulu"
@@ -26,6 +27,7 @@ import (
 	"github.com/siyuan-note/siyuan/kernel/bazaar"
 	"github.com/siyuan-note/siyuan/kernel/conf"
 	"github.com/siyuan-note/siyuan/kernel/model"
+	"github.com/siyuan-note/siyuan/kernel/search"
 	"github.com/siyuan-note/siyuan/kernel/server/proxy"
 	"github.com/siyuan-note/siyuan/kernel/sql"
 	"github.com/siyuan-note/siyuan/kernel/util"
@@ -42,13 +44,14 @@ func setEditorReadOnly(c *gin.Context) {
 
 	readOnly := arg["readonly"].(bool)
 
-	oldReadOnly := model.Conf.Editor.ReadOnly
-	model.Conf.Editor.ReadOnly = readOnly
-	model.Conf.Save()
+	userConf := model.GetUserConf(c)
+	oldReadOnly := userConf.Editor.ReadOnly
+	userConf.Editor.ReadOnly = readOnly
+	userConf.Save()
 
-	if oldReadOnly != model.Conf.Editor.ReadOnly {
-		util.BroadcastByType("protyle", "readonly", 0, "", model.Conf.Editor.ReadOnly)
-		util.BroadcastByType("main", "readonly", 0, "", model.Conf.Editor.ReadOnly)
+	if userConf.IsDefault() && oldReadOnly != readOnly {
+		util.BroadcastByType("protyle", "readonly", 0, "", readOnly)
+		util.BroadcastByType("main", "readonly", 0, "", readOnly)
 	}
 }
 
@@ -98,7 +101,7 @@ func addVirtualBlockRefExclude(c *gin.Context) {
 		keywords = append(keywords, k.(string))
 	}
 
-	model.AddVirtualBlockRefExclude(keywords)
+	model.AddVirtualBlockRefExclude(c, keywords)
 	util.BroadcastByType("main", "setConf", 0, "", model.Conf)
 }
 
@@ -119,7 +122,7 @@ func addVirtualBlockRefInclude(c *gin.Context) {
 		keywords = append(keywords, k.(string))
 	}
 
-	model.AddVirtualBlockRefInclude(keywords)
+	model.AddVirtualBlockRefInclude(c, keywords)
 	util.BroadcastByType("main", "setConf", 0, "", model.Conf)
 }
 
@@ -204,8 +207,22 @@ func setAI(c *gin.Context) {
 		ai.OpenAI.APIMaxContexts = 7
 	}
 
-	model.Conf.AI = ai
-	model.Conf.Save()
+	if nil == ai.Embedding {
+		ai.Embedding = conf.NewEmbedding()
+	}
+	if 5 > ai.Embedding.APITimeout {
+		ai.Embedding.APITimeout = 5
+	}
+	if 600 < ai.Embedding.APITimeout {
+		ai.Embedding.APITimeout = 600
+	}
+	if 0 > ai.Embedding.HybridWeight || 1 < ai.Embedding.HybridWeight {
+		ai.Embedding.HybridWeight = 0.7
+	}
+
+	userConf := model.GetUserConf(c)
+	userConf.AI = ai
+	userConf.Save()
 
 	ret.Data = ai
 }
@@ -241,8 +258,9 @@ func setFlashcard(c *gin.Context) {
 		flashcard.ReviewCardLimit = 200
 	}
 
-	model.Conf.Flashcard = flashcard
-	model.Conf.Save()
+	userConf := model.GetUserConf(c)
+	userConf.Flashcard = flashcard
+	userConf.Save()
 
 	ret.Data = flashcard
 }
@@ -292,7 +310,8 @@ func setEditor(c *gin.Context) {
 		return
 	}
 
-	oldGenerateHistoryInterval := model.Conf.Editor.GenerateHistoryInterval
+	userConf := model.GetUserConf(c)
+	oldGenerateHistoryInterval := userConf.Editor.GenerateHistoryInterval
 
 	editor := conf.NewEditor()
 	if err = gulu.JSON.UnmarshalJSON(param, editor); err != nil {
@@ -309,32 +328,35 @@ func setEditor(c *gin.Context) {
 		editor.KaTexMacros = "{}"
 	}
 
-	oldVirtualBlockRef := model.Conf.Editor.VirtualBlockRef
-	oldVirtualBlockRefInclude := model.Conf.Editor.VirtualBlockRefInclude
-	oldVirtualBlockRefExclude := model.Conf.Editor.VirtualBlockRefExclude
-	oldReadOnly := model.Conf.Editor.ReadOnly
-
-	model.Conf.Editor = editor
-	model.Conf.Save()
+	oldVirtualBlockRef := userConf.Editor.VirtualBlockRef
+	oldVirtualBlockRefInclude := userConf.Editor.VirtualBlockRefInclude
+	oldVirtualBlockRefExclude := userConf.Editor.VirtualBlockRefExclude
+	oldReadOnly := userConf.Editor.ReadOnly
 
-	if oldGenerateHistoryInterval != model.Conf.Editor.GenerateHistoryInterval {
-		model.ChangeHistoryTick(editor.GenerateHistoryInterval)
-	}
+	userConf.Editor = editor
+	userConf.Save()
 
-	if oldVirtualBlockRef != model.Conf.Editor.VirtualBlockRef ||
-		oldVirtualBlockRefInclude != model.Conf.Editor.VirtualBlockRefInclude ||
-		oldVirtualBlockRefExclude != model.Conf.Editor.VirtualBlockRefExclude {
+	if oldVirtualBlockRef != editor.VirtualBlockRef ||
+		oldVirtualBlockRefInclude != editor.VirtualBlockRefInclude ||
+		oldVirtualBlockRefExclude != editor.VirtualBlockRefExclude {
 		model.ResetVirtualBlockRefCache()
 	}
 
-	if oldReadOnly != model.Conf.Editor.ReadOnly {
-		util.BroadcastByType("protyle", "readonly", 0, "", model.Conf.Editor.ReadOnly)
-		util.BroadcastByType("main", "readonly", 0, "", model.Conf.Editor.ReadOnly)
-	}
+	// 数据历史生成间隔、只读模式广播和 Markdown 设置是内核的全局状态，只跟随默认用户的配置
+	if userConf.IsDefault() {
+		if oldGenerateHistoryInterval != editor.GenerateHistoryInterval {
+			model.ChangeHistoryTick(editor.GenerateHistoryInterval)
+		}
 
-	util.MarkdownSettings = model.Conf.Editor.Markdown
+		if oldReadOnly != editor.ReadOnly {
+			util.BroadcastByType("protyle", "readonly", 0, "", editor.ReadOnly)
+			util.BroadcastByType("main", "readonly", 0, "", editor.ReadOnly)
+		}
+
+		util.MarkdownSettings = editor.Markdown
+	}
 
-	ret.Data = model.Conf.Editor
+	ret.Data = userConf.Editor
 }
 
 func setExport(c *gin.Context) {
@@ -361,7 +383,8 @@ func setExport(c *gin.Context) {
 		return
 	}
 
-	if "" != export.PandocBin {
+	userConf := model.GetUserConf(c)
+	if userConf.IsDefault() && "" != export.PandocBin {
 		if !util.IsValidPandocBin(export.PandocBin) {
 			util.PushErrMsg(fmt.Sprintf(model.Conf.Language(117), export.PandocBin), 5000)
 			export.PandocBin = util.PandocBinPath
@@ -370,10 +393,10 @@ func setExport(c *gin.Context) {
 		}
 	}
 
-	model.Conf.Export = export
-	model.Conf.Save()
+	userConf.Export = export
+	userConf.Save()
 
-	ret.Data = model.Conf.Export
+	ret.Data = userConf.Export
 }
 
 func setFiletree(c *gin.Context) {
@@ -448,23 +471,37 @@ func setSearch(c *gin.Context) {
 	if 32 > s.Limit {
 		s.Limit = 32
 	}
+	if 1 > s.FuzzyMaxDistance {
+		s.FuzzyMaxDistance = 2
+	}
+	if 3 < s.FuzzyMaxDistance {
+		s.FuzzyMaxDistance = 3
+	}
+	s.Tokenizers = search.NormalizeTokenizers(s.Tokenizers)
 
-	oldCaseSensitive := model.Conf.Search.CaseSensitive
-	oldIndexAssetPath := model.Conf.Search.IndexAssetPath
+	userConf := model.GetUserConf(c)
+	oldCaseSensitive := userConf.Search.CaseSensitive
+	oldIndexAssetPath := userConf.Search.IndexAssetPath
+	oldTokenizers := userConf.Search.Tokenizers
 
-	oldVirtualRefName := model.Conf.Search.VirtualRefName
-	oldVirtualRefAlias := model.Conf.Search.VirtualRefAlias
-	oldVirtualRefAnchor := model.Conf.Search.VirtualRefAnchor
-	oldVirtualRefDoc := model.Conf.Search.VirtualRefDoc
+	oldVirtualRefName := userConf.Search.VirtualRefName
+	oldVirtualRefAlias := userConf.Search.VirtualRefAlias
+	oldVirtualRefAnchor := userConf.Search.VirtualRefAnchor
+	oldVirtualRefDoc := userConf.Search.VirtualRefDoc
 
-	model.Conf.Search = s
-	model.Conf.Save()
+	userConf.Search = s
+	userConf.Save()
 
-	sql.SetCaseSensitive(s.CaseSensitive)
-	sql.SetIndexAssetPath(s.IndexAssetPath)
+	// 索引由所有用户共用，只有默认用户可以修改影响索引的配置
+	if userConf.IsDefault() {
+		sql.SetCaseSensitive(s.CaseSensitive)
+		sql.SetIndexAssetPath(s.IndexAssetPath)
+		sql.SetTokenizers(s.Tokenizers)
 
-	if needFullReindex := s.CaseSensitive != oldCaseSensitive || s.IndexAssetPath != oldIndexAssetPath; needFullReindex {
-		model.FullReindex()
+		// 分词流水线变化后需要重建索引，重建时通过 sql.InitDatabase(true) 重新创建数据库
+		if needFullReindex := s.CaseSensitive != oldCaseSensitive || s.IndexAssetPath != oldIndexAssetPath || !slices.Equal(s.Tokenizers, oldTokenizers); needFullReindex {
+			model.FullReindex()
+		}
 	}
 
 	if oldVirtualRefName != s.VirtualRefName ||
@@ -499,8 +536,9 @@ func setKeymap(c *gin.Context) {
 		return
 	}
 
-	model.Conf.Keymap = keymap
-	model.Conf.Save()
+	userConf := model.GetUserConf(c)
+	userConf.Keymap = keymap
+	userConf.Save()
 }
 
 func setAppearance(c *gin.Context) {
@@ -526,11 +564,19 @@ func setAppearance(c *gin.Context) {
 		return
 	}
 
-	model.Conf.Appearance = appearance
+	userConf := model.GetUserConf(c)
+	userConf.Appearance = appearance
+	if !userConf.IsDefault() {
+		// 界面语言和主题是内核的全局状态，只跟随默认用户的配置
+		userConf.Save()
+		ret.Data = userConf.Appearance
+		return
+	}
+
 	model.Conf.Lang = appearance.Lang
 	oldLang := util.Lang
 	util.Lang = model.Conf.Lang
-	model.Conf.Save()
+	userConf.Save()
 	model.InitAppearance()
 
 	if oldLang != util.Lang {
@@ -657,5 +703,5 @@ func setEmoji(c *gin.Context) {
 		emoji = append(emoji, ae.(string))
 	}
 
-	model.Conf.Editor.Emoji = emoji
+	model.GetUserConf(c).Editor.Emoji = emoji
 }
@@ -18,6 +18,7 @@ package api
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/88250/gulu"
 	"github.com/gin-gonic/gin"
@@ -44,7 +45,74 @@ func SQL(c *gin.Context) {
 		return
 	}
 
-	stmt := arg["stmt"].(string)
+	if model.IsBoxRestricted(c) {
+		// 查询语句直接访问整个索引库，无法限制笔记本范围
+		ret.Code = -1
+		ret.Msg = model.Conf.Language(269)
+		return
+	}
+
+	invalidArg := func(name string) {
+		ret.Code = -1
+		ret.Msg = "invalid argument [" + name + "]"
+	}
+
+	stmt, ok := arg["stmt"].(string)
+	if !ok {
+		invalidArg("stmt")
+		return
+	}
+	args, ok := arg["args"].([]interface{})
+	if !ok && nil != arg["args"] {
+		invalidArg("args")
+		return
+	}
+	strict, ok := arg["strict"].(bool)
+	if !ok && nil != arg["strict"] {
+		invalidArg("strict")
+		return
+	}
+	strict = strict || nil != args
+
+	if !strict && !model.IsAdminRoleContext(c) {
+		// 非管理员只能使用严格模式
+		ret.Code = -1
+		ret.Msg = model.Conf.Language(272)
+		return
+	}
+
+	if strict {
+		// 严格模式：只允许 SELECT/WITH 语句，支持绑定参数，限制查询时间和返回行数
+		limit := model.Conf.Search.Limit
+		if limitArg := arg["limit"]; nil != limitArg {
+			limitVal, isNum := limitArg.(float64)
+			if !isNum {
+				invalidArg("limit")
+				return
+			}
+			limit = int(limitVal)
+		}
+		var timeout time.Duration
+		if timeoutArg := arg["timeout"]; nil != timeoutArg {
+			timeoutVal, isNum := timeoutArg.(float64)
+			if !isNum {
+				invalidArg("timeout")
+				return
+			}
+			timeout = time.Duration(timeoutVal) * time.Millisecond
+		}
+
+		result, err := sql.QueryReadOnly(stmt, args, limit, timeout)
+		if err != nil {
+			ret.Code = 1
+			ret.Msg = err.Error()
+			return
+		}
+
+		ret.Data = result
+		return
+	}
+
 	result, err := sql.Query(stmt, model.Conf.Search.Limit)
 	if err != nil {
 		ret.Code = 1
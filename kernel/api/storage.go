@@ -79,7 +79,7 @@ func setCriterion(c *gin.Context) {
 		return
 	}
 
-	err = model.SetCriterion(criterion)
+	err = model.SetCriterion(c, criterion)
 	if err != nil {
 		ret.Code = -1
 		ret.Msg = err.Error()
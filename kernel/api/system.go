@@ -423,8 +423,6 @@ func importConf(c *gin.Context) {
 func getConf(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
-	userNo := model.GetGinContextUser(c)
-	util.InitPathUserDir(userNo)
 	maskedConf, err := model.GetMaskedConf()
 	if err != nil {
 		ret.Code = -1
@@ -444,10 +442,15 @@ func getConf(c *gin.Context) {
 	}
 	if !model.IsValidRole(role, []model.Role{
 		model.RoleAdministrator,
-	}) {
+	}) || model.IsACLRestricted(c) || !model.GetTenant(c).IsDefault() {
 		model.HideConfSecret(maskedConf)
 	}
 
+	// 多用户模式下返回用户自己的用户级配置，工作空间级配置中的秘密信息已经隐藏
+	if userConf := model.GetUserConf(c); !userConf.IsDefault() {
+		userConf.ApplyTo(maskedConf)
+	}
+
 	ret.Data = map[string]interface{}{
 		"conf":      maskedConf,
 		"start":     !util.IsUILoaded,
@@ -504,6 +507,97 @@ func setAPIToken(c *gin.Context) {
 	model.Conf.Save()
 }
 
+// getAPITokens 获取所有命名 API token，token 已经脱敏。
+func getAPITokens(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	ret.Data = model.GetAPITokens()
+}
+
+// createAPIToken 创建命名 API token，完整的 token 只在创建时返回一次。
+func createAPIToken(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	name, role, boxes, routes, expired := parseAPITokenArgs(arg)
+	apiToken, err := model.CreateAPIToken(name, role, boxes, routes, expired)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+	ret.Data = apiToken
+}
+
+// updateAPIToken 修改命名 API token 的名称、角色、访问范围和过期时间。
+func updateAPIToken(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	name, role, boxes, routes, expired := parseAPITokenArgs(arg)
+	if err := model.UpdateAPIToken(id, name, role, boxes, routes, expired); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+}
+
+// revokeAPIToken 撤销命名 API token。
+func revokeAPIToken(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	id := arg["id"].(string)
+	if err := model.RevokeAPIToken(id); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+}
+
+func parseAPITokenArgs(arg map[string]interface{}) (name string, role model.Role, boxes, routes []string, expired int64) {
+	name, _ = arg["name"].(string)
+	if roleArg, ok := arg["role"].(float64); ok {
+		role = model.Role(roleArg)
+	} else {
+		role = model.RoleReader
+	}
+	if boxesArg, ok := arg["boxes"].([]interface{}); ok {
+		for _, box := range boxesArg {
+			boxes = append(boxes, box.(string))
+		}
+	}
+	if routesArg, ok := arg["routes"].([]interface{}); ok {
+		for _, route := range routesArg {
+			routes = append(routes, route.(string))
+		}
+	}
+	if expiredArg, ok := arg["expired"].(float64); ok {
+		expired = int64(expiredArg)
+	}
+	return
+}
+
 // setAccessAuthCode 设置访问授权码，并更新相关会话信息。
 // 该函数首先从请求中获取访问授权码，如果与掩码后的授权码相同，则使用配置中的授权码。
 // 更新配置文件中的授权码，并保存会话及工作区会话中的授权码。
@@ -536,6 +630,56 @@ func setAccessAuthCode(c *gin.Context) {
 	return
 }
 
+// setOIDC 设置 OpenID Connect 登录，客户端密钥传入掩码时保持不变。
+func setOIDC(c *gin.Context) {
+	ret := gulu.Ret.NewResult()
+	defer c.JSON(http.StatusOK, ret)
+
+	arg, ok := util.JsonArg(c, ret)
+	if !ok {
+		return
+	}
+
+	param, err := gulu.JSON.MarshalJSON(arg)
+	if err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	oidcConf := conf.NewOIDC()
+	if err = gulu.JSON.UnmarshalJSON(param, oidcConf); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		return
+	}
+
+	if model.MaskedOIDCClientSecret == oidcConf.ClientSecret {
+		oidcConf.ClientSecret = model.Conf.OIDC.ClientSecret
+	}
+	if nil == oidcConf.RoleMapping {
+		oidcConf.RoleMapping = map[string]uint{}
+	}
+
+	if err = model.CheckOIDCConf(oidcConf); err != nil {
+		ret.Code = -1
+		ret.Msg = err.Error()
+		ret.Data = map[string]interface{}{"closeTimeout": 5000}
+		return
+	}
+
+	model.Conf.OIDC = oidcConf
+	model.Conf.Save()
+	model.ResetOIDCProvider()
+
+	oidcConf = &conf.OIDC{}
+	*oidcConf = *model.Conf.OIDC
+	if "" != oidcConf.ClientSecret {
+		oidcConf.ClientSecret = model.MaskedOIDCClientSecret
+	}
+	ret.Data = oidcConf
+}
+
 // setFollowSystemLockScreen 设置系统锁屏模式。
 // 该函数接收一个gin.Context对象，从中解析出锁屏模式的参数，并更新系统配置。
 // 如果解析参数失败，将返回错误信息。
@@ -28,6 +28,7 @@ import (
 	"github.com/88250/gulu"
 	"github.com/88250/lute/ast"
 	jsoniter "github.com/json-iterator/go"
+	"github.com/siyuan-note/eventbus"
 	"github.com/siyuan-note/filelock"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/util"
@@ -100,6 +101,8 @@ const (
 	KeyTypeRelation   KeyType = "relation"
 	KeyTypeRollup     KeyType = "rollup"
 	KeyTypeLineNumber KeyType = "lineNumber"
+	KeyTypeFormula    KeyType = "formula"
+	KeyTypeUniqueID   KeyType = "uniqueID"
 )
 
 // Key 描述了属性视图属性字段的基础结构。
@@ -121,6 +124,9 @@ type Key struct {
 	// 模板
 	Template string `json:"template"` // 模板内容
 
+	// 公式
+	Formula string `json:"formula,omitempty"` // 公式内容
+
 	// 关联
 	Relation *Relation `json:"relation,omitempty"` // 关联信息
 
@@ -129,6 +135,12 @@ type Key struct {
 
 	// 日期
 	Date *Date `json:"date,omitempty"` // 日期设置
+
+	// 唯一 ID
+	UniqueID *UniqueID `json:"uniqueID,omitempty"` // 唯一 ID 设置
+
+	// 校验
+	Validation *Validation `json:"validation,omitempty"` // 校验规则
 }
 
 func NewKey(id, name, icon string, keyType KeyType) *Key {
@@ -185,15 +197,68 @@ type View struct {
 	HideAttrViewName bool   `json:"hideAttrViewName"` // 是否隐藏属性视图名称
 	Desc             string `json:"desc"`             // 视图描述
 
-	LayoutType LayoutType   `json:"type"`            // 当前布局类型
-	Table      *LayoutTable `json:"table,omitempty"` // 表格布局
+	LayoutType LayoutType      `json:"type"`               // 当前布局类型
+	Table      *LayoutTable    `json:"table,omitempty"`    // 表格布局
+	Board      *LayoutBoard    `json:"board,omitempty"`    // 看板布局
+	Calendar   *LayoutCalendar `json:"calendar,omitempty"` // 日历布局
+	Timeline   *LayoutCalendar `json:"timeline,omitempty"` // 时间线布局
+	Gallery    *LayoutGallery  `json:"gallery,omitempty"`  // 画廊布局
+}
+
+// ViewField 描述了非表格布局中字段的显示设置。
+type ViewField struct {
+	ID string `json:"id"` // 字段 ID
+
+	Hidden bool `json:"hidden"` // 是否隐藏
+}
+
+// SetViewFieldHidden 设置字段是否隐藏，字段不存在时追加到最后。
+func SetViewFieldHidden(fields []*ViewField, keyID string, hidden bool) []*ViewField {
+	for _, field := range fields {
+		if field.ID == keyID {
+			field.Hidden = hidden
+			return fields
+		}
+	}
+	return append(fields, &ViewField{ID: keyID, Hidden: hidden})
+}
+
+// SortViewField 将字段移动到 previousKeyID 之后，previousKeyID 为空时移动到最前面。
+func SortViewField(fields []*ViewField, keyID, previousKeyID string) []*ViewField {
+	var field *ViewField
+	var index, previousIndex int
+	for i, f := range fields {
+		if f.ID == keyID {
+			field = f
+			index = i
+			break
+		}
+	}
+	if nil == field {
+		field = &ViewField{ID: keyID, Hidden: true}
+		fields = append(fields, field)
+		index = len(fields) - 1
+	}
+
+	fields = append(fields[:index], fields[index+1:]...)
+	for i, f := range fields {
+		if f.ID == previousKeyID {
+			previousIndex = i + 1
+			break
+		}
+	}
+	return util.InsertElem(fields, previousIndex, field)
 }
 
 // LayoutType 描述了视图布局的类型。
 type LayoutType string
 
 const (
-	LayoutTypeTable LayoutType = "table" // 属性视图类型 - 表格
+	LayoutTypeTable    LayoutType = "table"    // 属性视图类型 - 表格
+	LayoutTypeBoard    LayoutType = "board"    // 属性视图类型 - 看板
+	LayoutTypeCalendar LayoutType = "calendar" // 属性视图类型 - 日历
+	LayoutTypeTimeline LayoutType = "timeline" // 属性视图类型 - 时间线
+	LayoutTypeGallery  LayoutType = "gallery"  // 属性视图类型 - 画廊
 )
 
 func NewTableView() (ret *View) {
@@ -234,6 +299,103 @@ func NewTableViewWithBlockKey(blockKeyID string) (view *View, blockKey, selectKe
 	return
 }
 
+func NewBoardView(groupKeyID string) (ret *View) {
+	ret = &View{
+		ID:         ast.NewNodeID(),
+		Name:       getI18nName("board"),
+		LayoutType: LayoutTypeBoard,
+		Board: &LayoutBoard{
+			Spec:       0,
+			ID:         ast.NewNodeID(),
+			GroupKeyID: groupKeyID,
+			Fields:     []*ViewField{},
+			Lanes:      []*ViewBoardLane{},
+			Filters:    []*ViewFilter{},
+			Sorts:      []*ViewSort{},
+			PageSize:   50,
+		},
+	}
+	return
+}
+
+func NewCalendarView(layoutType LayoutType, startKeyID string) (ret *View) {
+	layout := &LayoutCalendar{
+		Spec:       0,
+		ID:         ast.NewNodeID(),
+		StartKeyID: startKeyID,
+		Unit:       DateUnitDay,
+		Fields:     []*ViewField{},
+		Filters:    []*ViewFilter{},
+		Sorts:      []*ViewSort{},
+		PageSize:   50,
+	}
+
+	ret = &View{
+		ID:         ast.NewNodeID(),
+		LayoutType: layoutType,
+	}
+	switch layoutType {
+	case LayoutTypeTimeline:
+		ret.Name = getI18nName("timeline")
+		layout.Unit = DateUnitWeek
+		ret.Timeline = layout
+	default:
+		ret.LayoutType = LayoutTypeCalendar
+		ret.Name = getI18nName("calendar")
+		ret.Calendar = layout
+	}
+	return
+}
+
+func NewGalleryView(coverFrom CoverFrom, coverKeyID string) (ret *View) {
+	ret = &View{
+		ID:         ast.NewNodeID(),
+		Name:       getI18nName("gallery"),
+		LayoutType: LayoutTypeGallery,
+		Gallery: &LayoutGallery{
+			Spec:       0,
+			ID:         ast.NewNodeID(),
+			CoverFrom:  coverFrom,
+			CoverKeyID: coverKeyID,
+			Fields:     []*ViewField{},
+			Filters:    []*ViewFilter{},
+			Sorts:      []*ViewSort{},
+			PageSize:   50,
+		},
+	}
+	return
+}
+
+// GetFilters 返回视图当前布局的过滤规则。
+func (view *View) GetFilters() (ret []*ViewFilter) {
+	switch view.LayoutType {
+	case LayoutTypeTable:
+		ret = view.Table.Filters
+	case LayoutTypeBoard:
+		ret = view.Board.Filters
+	case LayoutTypeCalendar, LayoutTypeTimeline:
+		ret = view.GetCalendarLayout().Filters
+	case LayoutTypeGallery:
+		ret = view.Gallery.Filters
+	}
+	return
+}
+
+// GetSorts 返回视图当前布局的排序规则。
+func (view *View) GetSorts() (ret []*ViewSort) {
+	switch view.LayoutType {
+	case LayoutTypeTable:
+		ret = view.Table.Sorts
+	case LayoutTypeBoard:
+		ret = view.Board.Sorts
+	case LayoutTypeCalendar, LayoutTypeTimeline:
+		ret = view.GetCalendarLayout().Sorts
+	case LayoutTypeGallery:
+		ret = view.Gallery.Sorts
+	}
+	return
+}
+
 // Viewable 描述了视图的接口。
 type Viewable interface {
 	Filterable
@@ -401,6 +563,9 @@ func SaveAttributeView(av *AttributeView) (err error) {
 					v.Number.IsNotEmpty = true
 				}
 			}
+		case KeyTypeUniqueID:
+			// 为新添加的行分配编号
+			av.fillUniqueIDs(kv, now)
 		}
 
 		for _, v := range kv.Values {
@@ -438,6 +603,11 @@ func SaveAttributeView(av *AttributeView) (err error) {
 					v.Rollup.Contents = nil
 				}
 
+				// 清空公式计算结果
+				if KeyTypeFormula == kv.Key.Type {
+					v.Formula = &ValueFormula{}
+				}
+
 				for _, view := range av.Views {
 					switch view.LayoutType {
 					case LayoutTypeTable:
@@ -477,17 +647,27 @@ func SaveAttributeView(av *AttributeView) (err error) {
 
 	// 补全过滤器 Value
 	for _, view := range av.Views {
-		if nil != view.Table {
-			for _, f := range view.Table.Filters {
-				if nil != f.Value {
-					continue
-				}
+		var filters []*ViewFilter
+		switch view.LayoutType {
+		case LayoutTypeTable:
+			filters = view.Table.Filters
+		case LayoutTypeBoard:
+			filters = view.Board.Filters
+		case LayoutTypeCalendar, LayoutTypeTimeline:
+			filters = view.GetCalendarLayout().Filters
+		case LayoutTypeGallery:
+			filters = view.Gallery.Filters
+		}
 
-				if k, _ := av.GetKey(f.Column); nil != k {
-					f.Value = &Value{Type: k.Type}
-				}
+		WalkFilters(filters, func(f *ViewFilter) {
+			if nil != f.Value {
+				return
 			}
-		}
+
+			if k, _ := av.GetKey(f.Column); nil != k {
+				f.Value = &Value{Type: k.Type}
+			}
+		})
 	}
 
 	// 值去重
@@ -519,6 +699,28 @@ func SaveAttributeView(av *AttributeView) (err error) {
 				view.Table.PageSize = 50
 			}
 		}
+		if nil != view.Board {
+			// 卡片去重
+			for _, lane := range view.Board.Lanes {
+				lane.CardIDs = gulu.Str.RemoveDuplicatedElem(lane.CardIDs)
+			}
+			// 分页大小
+			if 1 > view.Board.PageSize {
+				view.Board.PageSize = 50
+			}
+		}
+		if layout := view.GetCalendarLayout(); nil != layout {
+			// 分页大小
+			if 1 > layout.PageSize {
+				layout.PageSize = 50
+			}
+		}
+		if nil != view.Gallery {
+			// 分页大小
+			if 1 > view.Gallery.PageSize {
+				view.Gallery.PageSize = 50
+			}
+		}
 	}
 
 	var data []byte
@@ -537,6 +739,9 @@ func SaveAttributeView(av *AttributeView) (err error) {
 		logging.LogErrorf("save attribute view [%s] failed: %s", av.ID, err)
 		return
 	}
+
+	// 更新 av_rows 和 av_values 索引
+	eventbus.Publish(util.EvtAttributeViewSaved, av.ID)
 	return
 }
 
@@ -684,17 +889,65 @@ func (av *AttributeView) ShallowClone() (ret *AttributeView) {
 
 	for _, view := range ret.Views {
 		view.ID = ast.NewNodeID()
-		view.Table.ID = ast.NewNodeID()
-		for _, column := range view.Table.Columns {
-			column.ID = keyIDMap[column.ID]
-		}
-		view.Table.RowIDs = []string{}
+		switch view.LayoutType {
+		case LayoutTypeTable:
+			view.Table.ID = ast.NewNodeID()
+			for _, column := range view.Table.Columns {
+				column.ID = keyIDMap[column.ID]
+			}
+			view.Table.RowIDs = []string{}
 
-		for _, f := range view.Table.Filters {
-			f.Column = keyIDMap[f.Column]
-		}
-		for _, s := range view.Table.Sorts {
-			s.Column = keyIDMap[s.Column]
+			WalkFilters(view.Table.Filters, func(f *ViewFilter) {
+				f.Column = keyIDMap[f.Column]
+			})
+			for _, s := range view.Table.Sorts {
+				s.Column = keyIDMap[s.Column]
+			}
+			if nil != view.Table.GroupBy {
+				view.Table.GroupBy.Column = keyIDMap[view.Table.GroupBy.Column]
+			}
+		case LayoutTypeBoard:
+			view.Board.ID = ast.NewNodeID()
+			view.Board.GroupKeyID = keyIDMap[view.Board.GroupKeyID]
+			for _, field := range view.Board.Fields {
+				field.ID = keyIDMap[field.ID]
+			}
+			view.Board.Lanes = []*ViewBoardLane{}
+
+			WalkFilters(view.Board.Filters, func(f *ViewFilter) {
+				f.Column = keyIDMap[f.Column]
+			})
+			for _, s := range view.Board.Sorts {
+				s.Column = keyIDMap[s.Column]
+			}
+		case LayoutTypeCalendar, LayoutTypeTimeline:
+			layout := view.GetCalendarLayout()
+			layout.ID = ast.NewNodeID()
+			layout.StartKeyID = keyIDMap[layout.StartKeyID]
+			layout.EndKeyID = keyIDMap[layout.EndKeyID]
+			for _, field := range layout.Fields {
+				field.ID = keyIDMap[field.ID]
+			}
+
+			WalkFilters(layout.Filters, func(f *ViewFilter) {
+				f.Column = keyIDMap[f.Column]
+			})
+			for _, s := range layout.Sorts {
+				s.Column = keyIDMap[s.Column]
+			}
+		case LayoutTypeGallery:
+			view.Gallery.ID = ast.NewNodeID()
+			view.Gallery.CoverKeyID = keyIDMap[view.Gallery.CoverKeyID]
+			for _, field := range view.Gallery.Fields {
+				field.ID = keyIDMap[field.ID]
+			}
+
+			WalkFilters(view.Gallery.Filters, func(f *ViewFilter) {
+				f.Column = keyIDMap[f.Column]
+			})
+			for _, s := range view.Gallery.Sorts {
+				s.Column = keyIDMap[s.Column]
+			}
 		}
 	}
 	ret.ViewID = ret.Views[0].ID
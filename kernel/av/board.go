@@ -0,0 +1,286 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"github.com/88250/gulu"
+)
+
+// LayoutBoard 描述了看板布局的结构。
+type LayoutBoard struct {
+	Spec int    `json:"spec"` // 布局格式版本
+	ID   string `json:"id"`   // 布局 ID
+
+	GroupKeyID string           `json:"groupKeyID"` // 分组字段 ID，仅支持单选、多选和复选框字段
+	Fields     []*ViewField     `json:"fields"`     // 卡片字段
+	Lanes      []*ViewBoardLane `json:"lanes"`      // 泳道，用于自定义卡片排序
+	Filters    []*ViewFilter    `json:"filters"`    // 过滤规则
+	Sorts      []*ViewSort      `json:"sorts"`      // 排序规则
+	PageSize   int              `json:"pageSize"`   // 每个泳道的卡片数
+}
+
+type ViewBoardLane struct {
+	ID      string   `json:"id"`      // 泳道 ID，单选/多选为选项名称，复选框为 true/false，空值泳道为空字符串
+	CardIDs []string `json:"cardIds"` // 卡片 ID（即行 ID），用于自定义排序
+}
+
+const (
+	BoardLaneChecked   = "true"  // 复选框已勾选泳道
+	BoardLaneUnchecked = "false" // 复选框未勾选泳道
+)
+
+func (layout *LayoutBoard) GetLane(laneID string) (ret *ViewBoardLane) {
+	for _, lane := range layout.Lanes {
+		if lane.ID == laneID {
+			ret = lane
+			return
+		}
+	}
+	return
+}
+
+// MoveCard 将卡片移动到泳道中 previousCardID 之后，previousCardID 为空时移动到泳道最前面。
+func (layout *LayoutBoard) MoveCard(cardID, srcLaneID, laneID, previousCardID string) {
+	if srcLane := layout.GetLane(srcLaneID); nil != srcLane {
+		srcLane.CardIDs = gulu.Str.RemoveElem(srcLane.CardIDs, cardID)
+	}
+
+	lane := layout.GetLane(laneID)
+	if nil == lane {
+		lane = &ViewBoardLane{ID: laneID}
+		layout.Lanes = append(layout.Lanes, lane)
+	}
+	lane.CardIDs = gulu.Str.RemoveElem(lane.CardIDs, cardID)
+
+	previousIndex := 0
+	for i, id := range lane.CardIDs {
+		if id == previousCardID {
+			previousIndex = i + 1
+			break
+		}
+	}
+	lane.CardIDs = append(lane.CardIDs[:previousIndex], append([]string{cardID}, lane.CardIDs[previousIndex:]...)...)
+}
+
+// RemoveCards 从所有泳道中移除卡片。
+func (layout *LayoutBoard) RemoveCards(cardIDs []string) {
+	for _, lane := range layout.Lanes {
+		for _, cardID := range cardIDs {
+			lane.CardIDs = gulu.Str.RemoveElem(lane.CardIDs, cardID)
+		}
+	}
+}
+
+// ReplaceCard 将泳道中的卡片 ID 替换为新的 ID。
+func (layout *LayoutBoard) ReplaceCard(oldCardID, newCardID string) {
+	for _, lane := range layout.Lanes {
+		for i, cardID := range lane.CardIDs {
+			if cardID == oldCardID {
+				lane.CardIDs[i] = newCardID
+				break
+			}
+		}
+	}
+}
+
+// IsGroupKeyType 判断字段类型是否可以作为看板分组字段。
+func IsGroupKeyType(keyType KeyType) bool {
+	return KeyTypeSelect == keyType || KeyTypeMSelect == keyType || KeyTypeCheckbox == keyType
+}
+
+// Board 描述了看板实例的结构。
+type Board struct {
+	ID               string         `json:"id"`               // 看板布局 ID
+	Icon             string         `json:"icon"`             // 看板图标
+	Name             string         `json:"name"`             // 看板名称
+	Desc             string         `json:"desc"`             // 看板描述
+	HideAttrViewName bool           `json:"hideAttrViewName"` // 是否隐藏属性视图名称
+	GroupKeyID       string         `json:"groupKeyID"`       // 分组字段 ID
+	Filters          []*ViewFilter  `json:"filters"`          // 过滤规则
+	Sorts            []*ViewSort    `json:"sorts"`            // 排序规则
+	Fields           []*TableColumn `json:"fields"`           // 卡片字段
+	Lanes            []*BoardLane   `json:"lanes"`            // 泳道
+	CardCount        int            `json:"cardCount"`        // 看板总卡片数
+	PageSize         int            `json:"pageSize"`         // 每个泳道的卡片数
+
+	Rows []*TableRow `json:"-"` // 分组前的行，过滤和排序复用表格的实现
+}
+
+type BoardLane struct {
+	ID        string      `json:"id"`        // 泳道 ID
+	Name      string      `json:"name"`      // 泳道名称
+	Color     string      `json:"color"`     // 泳道颜色
+	Cards     []*TableRow `json:"cards"`     // 卡片，单元格和 Fields 一一对应
+	CardCount int         `json:"cardCount"` // 泳道总卡片数
+}
+
+func (board *Board) GetType() LayoutType {
+	return LayoutTypeBoard
+}
+
+func (board *Board) GetID() string {
+	return board.ID
+}
+
+func (board *Board) GetField(id string) *TableColumn {
+	for _, field := range board.Fields {
+		if field.ID == id {
+			return field
+		}
+	}
+	return nil
+}
+
+func (board *Board) FilterRows(attrView *AttributeView) {
+	table := board.rowsTable()
+	table.FilterRows(attrView)
+	board.Rows = table.Rows
+}
+
+func (board *Board) SortRows(attrView *AttributeView) {
+	table := board.rowsTable()
+	table.SortRows(attrView)
+	board.Rows = table.Rows
+}
+
+func (board *Board) CalcCols() {
+	// 看板不支持字段计算
+}
+
+func (board *Board) rowsTable() *Table {
+	return &Table{Columns: board.Fields, Rows: board.Rows, Filters: board.Filters, Sorts: board.Sorts}
+}
+
+// GroupCards 按照分组字段将行分配到泳道中。
+func (board *Board) GroupCards(layout *LayoutBoard, groupKey *Key) {
+	board.CardCount = len(board.Rows)
+	board.Lanes = []*BoardLane{}
+
+	var laneIDs []string
+	lanes := map[string]*BoardLane{}
+	addLane := func(id, name, color string) {
+		if nil != lanes[id] {
+			return
+		}
+		lane := &BoardLane{ID: id, Name: name, Color: color, Cards: []*TableRow{}}
+		lanes[id] = lane
+		laneIDs = append(laneIDs, id)
+	}
+
+	groupIndex := -1
+	if nil != groupKey {
+		for i, field := range board.Fields {
+			if field.ID == groupKey.ID {
+				groupIndex = i
+				break
+			}
+		}
+
+		switch groupKey.Type {
+		case KeyTypeSelect, KeyTypeMSelect:
+			for _, opt := range groupKey.Options {
+				addLane(opt.Name, opt.Name, opt.Color)
+			}
+		case KeyTypeCheckbox:
+			addLane(BoardLaneUnchecked, BoardLaneUnchecked, "")
+			addLane(BoardLaneChecked, BoardLaneChecked, "")
+		}
+	}
+	if KeyTypeCheckbox != groupKeyType(groupKey) {
+		// 空值泳道
+		addLane("", "", "")
+	}
+
+	for _, row := range board.Rows {
+		var rowLaneIDs []string
+		if 0 <= groupIndex {
+			val := row.Cells[groupIndex].Value
+			if nil != val {
+				switch val.Type {
+				case KeyTypeSelect, KeyTypeMSelect:
+					for _, opt := range val.MSelect {
+						if "" == opt.Content {
+							continue
+						}
+						addLane(opt.Content, opt.Content, opt.Color)
+						rowLaneIDs = append(rowLaneIDs, opt.Content)
+					}
+				case KeyTypeCheckbox:
+					if nil != val.Checkbox && val.Checkbox.Checked {
+						rowLaneIDs = append(rowLaneIDs, BoardLaneChecked)
+					} else {
+						rowLaneIDs = append(rowLaneIDs, BoardLaneUnchecked)
+					}
+				}
+			}
+		}
+		if 1 > len(rowLaneIDs) {
+			if KeyTypeCheckbox == groupKeyType(groupKey) {
+				rowLaneIDs = append(rowLaneIDs, BoardLaneUnchecked)
+			} else {
+				rowLaneIDs = append(rowLaneIDs, "")
+			}
+		}
+
+		for _, laneID := range gulu.Str.RemoveDuplicatedElem(rowLaneIDs) {
+			lanes[laneID].Cards = append(lanes[laneID].Cards, row)
+		}
+	}
+
+	for _, laneID := range laneIDs {
+		lane := lanes[laneID]
+		if 1 > len(board.Sorts) {
+			// 没有排序规则时使用泳道中自定义的卡片排序
+			if viewLane := layout.GetLane(laneID); nil != viewLane {
+				lane.Cards = sortCards(lane.Cards, viewLane.CardIDs)
+			}
+		}
+		lane.CardCount = len(lane.Cards)
+		board.Lanes = append(board.Lanes, lane)
+	}
+}
+
+func groupKeyType(groupKey *Key) KeyType {
+	if nil == groupKey {
+		return ""
+	}
+	return groupKey.Type
+}
+
+func sortCards(cards []*TableRow, cardIDs []string) (ret []*TableRow) {
+	ret = []*TableRow{}
+	cardIndexes := map[string]int{}
+	for i, card := range cards {
+		cardIndexes[card.ID] = i
+	}
+
+	sorted := map[string]bool{}
+	for _, cardID := range cardIDs {
+		if i, ok := cardIndexes[cardID]; ok && !sorted[cardID] {
+			ret = append(ret, cards[i])
+			sorted[cardID] = true
+		}
+	}
+
+	// 未自定义排序的卡片放在最后
+	for _, card := range cards {
+		if !sorted[card.ID] {
+			ret = append(ret, card)
+		}
+	}
+	return
+}
@@ -0,0 +1,140 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newBoardTestRow(id string, val *Value) *TableRow {
+	return &TableRow{ID: id, Cells: []*TableCell{{Value: val}}}
+}
+
+func laneCardIDs(lane *BoardLane) (ret []string) {
+	ret = []string{}
+	for _, card := range lane.Cards {
+		ret = append(ret, card.ID)
+	}
+	return
+}
+
+func TestBoardGroupCardsSelect(t *testing.T) {
+	groupKey := &Key{ID: "key-select", Type: KeyTypeMSelect, Options: []*SelectOption{{Name: "Todo"}, {Name: "Done"}}}
+	board := &Board{
+		Fields: []*TableColumn{{ID: groupKey.ID, Type: KeyTypeMSelect}},
+		Rows: []*TableRow{
+			newBoardTestRow("row-1", &Value{Type: KeyTypeMSelect, MSelect: []*ValueSelect{{Content: "Done"}}}),
+			newBoardTestRow("row-2", &Value{Type: KeyTypeMSelect, MSelect: []*ValueSelect{{Content: "Todo"}, {Content: "Doing"}}}),
+			newBoardTestRow("row-3", &Value{Type: KeyTypeMSelect}),
+			newBoardTestRow("row-4", &Value{Type: KeyTypeMSelect, MSelect: []*ValueSelect{{Content: "Todo"}}}),
+		},
+	}
+	layout := &LayoutBoard{Lanes: []*ViewBoardLane{{ID: "Todo", CardIDs: []string{"row-4", "missing"}}}}
+	board.GroupCards(layout, groupKey)
+
+	var laneIDs []string
+	for _, lane := range board.Lanes {
+		laneIDs = append(laneIDs, lane.ID)
+	}
+	if expected := []string{"Todo", "Done", "", "Doing"}; !reflect.DeepEqual(expected, laneIDs) {
+		t.Fatalf("expected lanes %v, got %v", expected, laneIDs)
+	}
+
+	expected := map[string][]string{
+		"Todo":  {"row-4", "row-2"},
+		"Done":  {"row-1"},
+		"":      {"row-3"},
+		"Doing": {"row-2"},
+	}
+	for _, lane := range board.Lanes {
+		if ids := laneCardIDs(lane); !reflect.DeepEqual(expected[lane.ID], ids) || lane.CardCount != len(ids) {
+			t.Fatalf("lane [%s] expected cards %v, got %v", lane.ID, expected[lane.ID], ids)
+		}
+	}
+	if 4 != board.CardCount {
+		t.Fatalf("expected card count 4, got %d", board.CardCount)
+	}
+}
+
+func TestBoardGroupCardsCheckbox(t *testing.T) {
+	groupKey := &Key{ID: "key-checkbox", Type: KeyTypeCheckbox}
+	board := &Board{
+		Fields: []*TableColumn{{ID: groupKey.ID, Type: KeyTypeCheckbox}},
+		Rows: []*TableRow{
+			newBoardTestRow("row-1", &Value{Type: KeyTypeCheckbox, Checkbox: &ValueCheckbox{Checked: true}}),
+			newBoardTestRow("row-2", &Value{Type: KeyTypeCheckbox}),
+			newBoardTestRow("row-3", nil),
+		},
+	}
+	board.GroupCards(&LayoutBoard{}, groupKey)
+
+	if 2 != len(board.Lanes) {
+		t.Fatalf("checkbox board should not have an empty lane, got %d lanes", len(board.Lanes))
+	}
+	if ids := laneCardIDs(board.Lanes[0]); BoardLaneUnchecked != board.Lanes[0].ID || !reflect.DeepEqual([]string{"row-2", "row-3"}, ids) {
+		t.Fatalf("unexpected unchecked lane [%s] %v", board.Lanes[0].ID, ids)
+	}
+	if ids := laneCardIDs(board.Lanes[1]); BoardLaneChecked != board.Lanes[1].ID || !reflect.DeepEqual([]string{"row-1"}, ids) {
+		t.Fatalf("unexpected checked lane [%s] %v", board.Lanes[1].ID, ids)
+	}
+}
+
+func TestBoardGroupCardsSorted(t *testing.T) {
+	groupKey := &Key{ID: "key-select", Type: KeyTypeSelect}
+	board := &Board{
+		Fields: []*TableColumn{{ID: groupKey.ID, Type: KeyTypeSelect}},
+		Sorts:  []*ViewSort{{Column: groupKey.ID, Order: SortOrderAsc}},
+		Rows: []*TableRow{
+			newBoardTestRow("row-1", nil),
+			newBoardTestRow("row-2", nil),
+		},
+	}
+	// 存在排序规则时忽略泳道中自定义的卡片排序
+	board.GroupCards(&LayoutBoard{Lanes: []*ViewBoardLane{{ID: "", CardIDs: []string{"row-2", "row-1"}}}}, groupKey)
+	if ids := laneCardIDs(board.Lanes[0]); !reflect.DeepEqual([]string{"row-1", "row-2"}, ids) {
+		t.Fatalf("expected view sort order kept, got %v", ids)
+	}
+}
+
+func TestLayoutBoardMoveCard(t *testing.T) {
+	layout := &LayoutBoard{Lanes: []*ViewBoardLane{
+		{ID: "a", CardIDs: []string{"1", "2", "3"}},
+		{ID: "b", CardIDs: []string{"4", "5"}},
+	}}
+
+	layout.MoveCard("2", "a", "b", "4")
+	if !reflect.DeepEqual([]string{"1", "3"}, layout.GetLane("a").CardIDs) || !reflect.DeepEqual([]string{"4", "2", "5"}, layout.GetLane("b").CardIDs) {
+		t.Fatalf("unexpected lanes after move: %v %v", layout.GetLane("a").CardIDs, layout.GetLane("b").CardIDs)
+	}
+
+	layout.MoveCard("5", "b", "b", "")
+	if !reflect.DeepEqual([]string{"5", "4", "2"}, layout.GetLane("b").CardIDs) {
+		t.Fatalf("expected card moved to the top, got %v", layout.GetLane("b").CardIDs)
+	}
+
+	layout.MoveCard("1", "a", "c", "")
+	if nil == layout.GetLane("c") || !reflect.DeepEqual([]string{"1"}, layout.GetLane("c").CardIDs) {
+		t.Fatalf("expected lane created on move")
+	}
+
+	layout.ReplaceCard("4", "6")
+	layout.RemoveCards([]string{"2", "3"})
+	if !reflect.DeepEqual([]string{"5", "6"}, layout.GetLane("b").CardIDs) || 0 != len(layout.GetLane("a").CardIDs) {
+		t.Fatalf("unexpected lanes after replace and remove: %v %v", layout.GetLane("a").CardIDs, layout.GetLane("b").CardIDs)
+	}
+}
@@ -0,0 +1,285 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"sort"
+	"time"
+)
+
+// LayoutCalendar 描述了日历布局的结构，时间线布局也使用该结构。
+type LayoutCalendar struct {
+	Spec int    `json:"spec"` // 布局格式版本
+	ID   string `json:"id"`   // 布局 ID
+
+	StartKeyID string        `json:"startKeyID"` // 开始日期字段 ID
+	EndKeyID   string        `json:"endKeyID"`   // 结束日期字段 ID，为空时使用开始日期字段值中的结束日期
+	Unit       DateUnit      `json:"unit"`       // 分桶单位
+	Fields     []*ViewField  `json:"fields"`     // 条目字段
+	Filters    []*ViewFilter `json:"filters"`    // 过滤规则
+	Sorts      []*ViewSort   `json:"sorts"`      // 排序规则
+	PageSize   int           `json:"pageSize"`   // 未设置日期的条目每页数量
+}
+
+// DateUnit 描述了日历和时间线布局中按日期分桶的单位。
+type DateUnit string
+
+const (
+	DateUnitDay   DateUnit = "day"
+	DateUnitWeek  DateUnit = "week"
+	DateUnitMonth DateUnit = "month"
+)
+
+// DateRange 描述了渲染日历和时间线布局时请求的时间范围。
+type DateRange struct {
+	Start int64    `json:"start"` // 开始时间（毫秒，包含）
+	End   int64    `json:"end"`   // 结束时间（毫秒，不包含）
+	Unit  DateUnit `json:"unit"`  // 分桶单位，为空时使用布局中设置的单位
+}
+
+// Calendar 描述了日历和时间线实例的结构。
+type Calendar struct {
+	LayoutType       LayoutType        `json:"type"`             // 布局类型，日历或时间线
+	ID               string            `json:"id"`               // 布局 ID
+	Icon             string            `json:"icon"`             // 图标
+	Name             string            `json:"name"`             // 名称
+	Desc             string            `json:"desc"`             // 描述
+	HideAttrViewName bool              `json:"hideAttrViewName"` // 是否隐藏属性视图名称
+	StartKeyID       string            `json:"startKeyID"`       // 开始日期字段 ID
+	EndKeyID         string            `json:"endKeyID"`         // 结束日期字段 ID
+	Unit             DateUnit          `json:"unit"`             // 分桶单位
+	RangeStart       int64             `json:"rangeStart"`       // 渲染范围开始时间
+	RangeEnd         int64             `json:"rangeEnd"`         // 渲染范围结束时间
+	Filters          []*ViewFilter     `json:"filters"`          // 过滤规则
+	Sorts            []*ViewSort       `json:"sorts"`            // 排序规则
+	Fields           []*TableColumn    `json:"fields"`           // 条目字段
+	Buckets          []*CalendarBucket `json:"buckets"`          // 按日期分桶的条目
+	EntryCount       int               `json:"entryCount"`       // 范围内的条目数
+	Undated          []*TableRow       `json:"undated"`          // 未设置日期的条目
+	UndatedCount     int               `json:"undatedCount"`     // 未设置日期的条目数
+	PageSize         int               `json:"pageSize"`         // 未设置日期的条目每页数量
+
+	Rows []*TableRow `json:"-"` // 分桶前的行，过滤和排序复用表格的实现
+}
+
+type CalendarBucket struct {
+	Start   int64            `json:"start"`   // 桶开始时间（毫秒，包含）
+	End     int64            `json:"end"`     // 桶结束时间（毫秒，不包含）
+	Entries []*CalendarEntry `json:"entries"` // 和该桶时间有交集的条目
+}
+
+type CalendarEntry struct {
+	ID        string       `json:"id"`        // 条目 ID（即行 ID）
+	Start     int64        `json:"start"`     // 开始时间
+	End       int64        `json:"end"`       // 结束时间
+	IsNotTime bool         `json:"isNotTime"` // 是否不包含时间（全天）
+	Cells     []*TableCell `json:"cells"`     // 单元格，和 Fields 一一对应
+}
+
+const maxCalendarBuckets = 1024 // 单次渲染的最大分桶数
+
+func (view *View) GetCalendarLayout() *LayoutCalendar {
+	switch view.LayoutType {
+	case LayoutTypeCalendar:
+		return view.Calendar
+	case LayoutTypeTimeline:
+		return view.Timeline
+	}
+	return nil
+}
+
+// IsDateKeyType 判断字段类型是否可以作为日历和时间线的日期字段。
+func IsDateKeyType(keyType KeyType) bool {
+	return KeyTypeDate == keyType || KeyTypeCreated == keyType || KeyTypeUpdated == keyType
+}
+
+func (calendar *Calendar) GetType() LayoutType {
+	return calendar.LayoutType
+}
+
+func (calendar *Calendar) GetID() string {
+	return calendar.ID
+}
+
+func (calendar *Calendar) FilterRows(attrView *AttributeView) {
+	table := calendar.rowsTable()
+	table.FilterRows(attrView)
+	calendar.Rows = table.Rows
+}
+
+func (calendar *Calendar) SortRows(attrView *AttributeView) {
+	table := calendar.rowsTable()
+	table.SortRows(attrView)
+	calendar.Rows = table.Rows
+}
+
+func (calendar *Calendar) CalcCols() {
+	// 日历和时间线不支持字段计算
+}
+
+func (calendar *Calendar) rowsTable() *Table {
+	return &Table{Columns: calendar.Fields, Rows: calendar.Rows, Filters: calendar.Filters, Sorts: calendar.Sorts}
+}
+
+// BucketEntries 将范围内的行按照分桶单位分配到桶中，未设置开始日期的行放入 Undated。
+func (calendar *Calendar) BucketEntries(dateRange *DateRange) {
+	unit := calendar.Unit
+	if nil != dateRange && "" != dateRange.Unit {
+		unit = dateRange.Unit
+	}
+	if DateUnitDay != unit && DateUnitWeek != unit && DateUnitMonth != unit {
+		unit = DateUnitDay
+	}
+	calendar.Unit = unit
+
+	var rangeStart, rangeEnd time.Time
+	if nil != dateRange && 0 < dateRange.Start && dateRange.Start < dateRange.End {
+		rangeStart, rangeEnd = time.UnixMilli(dateRange.Start), time.UnixMilli(dateRange.End)
+	} else {
+		// 默认渲染当前月份
+		now := time.Now()
+		rangeStart = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+		rangeEnd = rangeStart.AddDate(0, 1, 0)
+	}
+	rangeStart = truncateDate(rangeStart, unit)
+	calendar.RangeStart, calendar.RangeEnd = rangeStart.UnixMilli(), rangeEnd.UnixMilli()
+
+	calendar.Buckets = []*CalendarBucket{}
+	for start := rangeStart; start.Before(rangeEnd) && maxCalendarBuckets > len(calendar.Buckets); {
+		end := nextDate(start, unit)
+		calendar.Buckets = append(calendar.Buckets, &CalendarBucket{Start: start.UnixMilli(), End: end.UnixMilli(), Entries: []*CalendarEntry{}})
+		start = end
+	}
+
+	startIndex, endIndex := -1, -1
+	for i, field := range calendar.Fields {
+		if field.ID == calendar.StartKeyID {
+			startIndex = i
+		}
+		if "" != calendar.EndKeyID && field.ID == calendar.EndKeyID {
+			endIndex = i
+		}
+	}
+
+	calendar.Undated = []*TableRow{}
+	var entries []*CalendarEntry
+	for _, row := range calendar.Rows {
+		entry := calendar.getEntry(row, startIndex, endIndex)
+		if nil == entry {
+			calendar.Undated = append(calendar.Undated, row)
+			continue
+		}
+
+		if entry.End < calendar.RangeStart || entry.Start >= calendar.RangeEnd {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	calendar.EntryCount = len(entries)
+	calendar.UndatedCount = len(calendar.Undated)
+
+	// 桶内条目按开始时间排序，开始时间相同时保持视图排序
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Start < entries[j].Start })
+	for _, bucket := range calendar.Buckets {
+		for _, entry := range entries {
+			if entry.Start < bucket.End && entry.End >= bucket.Start {
+				bucket.Entries = append(bucket.Entries, entry)
+			}
+		}
+	}
+}
+
+func (calendar *Calendar) getEntry(row *TableRow, startIndex, endIndex int) (ret *CalendarEntry) {
+	if 0 > startIndex {
+		return
+	}
+
+	start, end, isNotTime, ok := getCellDateRange(row.Cells[startIndex])
+	if !ok {
+		return
+	}
+
+	if 0 <= endIndex {
+		if endStart, _, _, endOK := getCellDateRange(row.Cells[endIndex]); endOK && endStart >= start {
+			end = endStart
+		}
+	}
+
+	if isNotTime {
+		// 不包含时间的日期覆盖到当天结束
+		end = nextDate(truncateDate(time.UnixMilli(end), DateUnitDay), DateUnitDay).UnixMilli() - 1
+	}
+
+	ret = &CalendarEntry{ID: row.ID, Start: start, End: end, IsNotTime: isNotTime, Cells: row.Cells}
+	return
+}
+
+func getCellDateRange(cell *TableCell) (start, end int64, isNotTime, ok bool) {
+	if nil == cell || nil == cell.Value {
+		return
+	}
+
+	val := cell.Value
+	switch val.Type {
+	case KeyTypeDate:
+		if nil == val.Date || !val.Date.IsNotEmpty {
+			return
+		}
+		start, end, isNotTime = val.Date.Content, val.Date.Content, val.Date.IsNotTime
+		if val.Date.HasEndDate && val.Date.IsNotEmpty2 && val.Date.Content2 >= start {
+			end = val.Date.Content2
+		}
+	case KeyTypeCreated:
+		if nil == val.Created || !val.Created.IsNotEmpty {
+			return
+		}
+		start, end = val.Created.Content, val.Created.Content
+	case KeyTypeUpdated:
+		if nil == val.Updated || !val.Updated.IsNotEmpty {
+			return
+		}
+		start, end = val.Updated.Content, val.Updated.Content
+	default:
+		return
+	}
+	ok = true
+	return
+}
+
+func truncateDate(t time.Time, unit DateUnit) time.Time {
+	t = t.In(time.Local)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	switch unit {
+	case DateUnitWeek:
+		// 以周一作为一周的开始
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case DateUnitMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+	}
+	return day
+}
+
+func nextDate(t time.Time, unit DateUnit) time.Time {
+	switch unit {
+	case DateUnitWeek:
+		return t.AddDate(0, 0, 7)
+	case DateUnitMonth:
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
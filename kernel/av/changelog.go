@@ -0,0 +1,170 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// Change 描述了属性视图的一次单元格或者行变更。
+type Change struct {
+	ID         string       `json:"id"`                   // 变更 ID
+	Action     ChangeAction `json:"action"`               // 变更类型
+	RowID      string       `json:"rowID"`                // 行 ID
+	KeyID      string       `json:"keyID,omitempty"`      // 字段 ID，仅在更新单元格时使用
+	OldValues  []*Value     `json:"oldValues,omitempty"`  // 变更前的值，更新单元格时为单个值，删除行时为行中所有值
+	NewValues  []*Value     `json:"newValues,omitempty"`  // 变更后的值，更新单元格时为单个值，添加行时为行中所有值
+	App        string       `json:"app"`                  // 发起变更的应用 ID
+	Session    string       `json:"session"`              // 发起变更的会话 ID
+	RollbackOf string       `json:"rollbackOf,omitempty"` // 回滚的变更 ID，仅在回滚产生的变更中使用
+	Created    int64        `json:"created"`              // 变更时间
+}
+
+type ChangeAction string
+
+const (
+	ChangeActionUpdateCell ChangeAction = "updateCell"
+	ChangeActionInsertRow  ChangeAction = "insertRow"
+	ChangeActionRemoveRow  ChangeAction = "removeRow"
+)
+
+var (
+	ErrChangeNotFound = errors.New("attribute view change not found")
+
+	changeLogLock = sync.Mutex{}
+)
+
+// AppendChanges 将变更追加到属性视图的变更日志中。
+func AppendChanges(avID string, changes []*Change) (err error) {
+	if 1 > len(changes) {
+		return
+	}
+
+	changeLogLock.Lock()
+	defer changeLogLock.Unlock()
+
+	buf := bytes.Buffer{}
+	for _, change := range changes {
+		data, marshalErr := gulu.JSON.MarshalJSON(change)
+		if nil != marshalErr {
+			logging.LogErrorf("marshal attribute view [%s] change failed: %s", avID, marshalErr)
+			return marshalErr
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	changeLogPath := getChangeLogPath(avID)
+	if err = os.MkdirAll(filepath.Dir(changeLogPath), 0755); err != nil {
+		logging.LogErrorf("create attribute view change log dir failed: %s", err)
+		return
+	}
+
+	f, err := os.OpenFile(changeLogPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		logging.LogErrorf("open attribute view change log [%s] failed: %s", changeLogPath, err)
+		return
+	}
+	defer f.Close()
+
+	if _, err = f.Write(buf.Bytes()); err != nil {
+		logging.LogErrorf("write attribute view change log [%s] failed: %s", changeLogPath, err)
+	}
+	return
+}
+
+// GetChanges 返回属性视图的变更日志，按变更时间升序排列。
+func GetChanges(avID string) (ret []*Change, err error) {
+	changeLogLock.Lock()
+	defer changeLogLock.Unlock()
+
+	ret = []*Change{}
+	changeLogPath := getChangeLogPath(avID)
+	if !filelock.IsExist(changeLogPath) {
+		return
+	}
+
+	data, err := filelock.ReadFile(changeLogPath)
+	if err != nil {
+		logging.LogErrorf("read attribute view change log [%s] failed: %s", changeLogPath, err)
+		return
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if 1 > len(line) {
+			continue
+		}
+
+		change := &Change{}
+		if unmarshalErr := gulu.JSON.UnmarshalJSON(line, change); nil != unmarshalErr {
+			// 跳过写入不完整的变更
+			logging.LogWarnf("unmarshal attribute view [%s] change failed: %s", avID, unmarshalErr)
+			continue
+		}
+		ret = append(ret, change)
+	}
+	err = scanner.Err()
+	return
+}
+
+// GetChange 返回属性视图中指定 ID 的变更。
+func GetChange(avID, changeID string) (ret *Change, err error) {
+	changes, err := GetChanges(avID)
+	if err != nil {
+		return
+	}
+
+	for _, change := range changes {
+		if change.ID == changeID {
+			ret = change
+			return
+		}
+	}
+	err = ErrChangeNotFound
+	return
+}
+
+// GetRowValues 返回行中所有字段的值。
+func (av *AttributeView) GetRowValues(rowID string) (ret []*Value) {
+	for _, kv := range av.KeyValues {
+		for _, v := range kv.Values {
+			if v.BlockID == rowID {
+				ret = append(ret, v)
+				break
+			}
+		}
+	}
+	return
+}
+
+func getChangeLogPath(avID string) string {
+	// 使用 .jsonl 后缀，避免被当作属性视图数据文件
+	return filepath.Join(util.DataDir, "storage", "av", "changelog", avID+".jsonl")
+}
@@ -28,12 +28,83 @@ type Filterable interface {
 	FilterRows(attrView *AttributeView)
 }
 
+// KeepRows 只保留视图中 keep 返回 true 的行，用于在过滤规则以外按照访问权限过滤行，需要在分组和分页前调用。
+func KeepRows(viewable Viewable, keep func(row *TableRow) bool) {
+	filter := func(rows []*TableRow) (ret []*TableRow) {
+		ret = []*TableRow{}
+		for _, row := range rows {
+			if keep(row) {
+				ret = append(ret, row)
+			}
+		}
+		return
+	}
+
+	switch v := viewable.(type) {
+	case *Table:
+		v.Rows = filter(v.Rows)
+	case *Board:
+		v.Rows = filter(v.Rows)
+	case *Calendar:
+		v.Rows = filter(v.Rows)
+	case *Gallery:
+		v.Rows = filter(v.Rows)
+	}
+}
+
+// ViewFilter 描述了过滤规则，Conjunction 不为空时为过滤组。
+// 视图中的过滤规则列表作为最外层的 AND 过滤组，所以旧的过滤规则列表无需转换。
 type ViewFilter struct {
 	Column        string         `json:"column"`
 	Operator      FilterOperator `json:"operator"`
 	Value         *Value         `json:"value"`
 	RelativeDate  *RelativeDate  `json:"relativeDate"`
 	RelativeDate2 *RelativeDate  `json:"relativeDate2"`
+
+	Conjunction FilterConjunction `json:"conjunction,omitempty"` // 过滤组连接方式
+	Filters     []*ViewFilter     `json:"filters,omitempty"`     // 过滤组中的过滤规则，可以嵌套过滤组
+}
+
+type FilterConjunction string
+
+const (
+	FilterConjunctionAnd FilterConjunction = "and"
+	FilterConjunctionOr  FilterConjunction = "or"
+)
+
+func (filter *ViewFilter) IsGroup() bool {
+	return "" != filter.Conjunction
+}
+
+// WalkFilters 遍历过滤规则（包括过滤组中嵌套的过滤规则），fn 仅处理非过滤组的过滤规则。
+func WalkFilters(filters []*ViewFilter, fn func(filter *ViewFilter)) {
+	for _, filter := range filters {
+		if filter.IsGroup() {
+			WalkFilters(filter.Filters, fn)
+			continue
+		}
+		fn(filter)
+	}
+}
+
+// CloneFilters 复制过滤规则（包括过滤组中嵌套的过滤规则）。
+func CloneFilters(filters []*ViewFilter) (ret []*ViewFilter) {
+	ret = []*ViewFilter{}
+	for _, filter := range filters {
+		clone := &ViewFilter{
+			Column:        filter.Column,
+			Operator:      filter.Operator,
+			Value:         filter.Value,
+			RelativeDate:  filter.RelativeDate,
+			RelativeDate2: filter.RelativeDate2,
+			Conjunction:   filter.Conjunction,
+		}
+		if filter.IsGroup() {
+			clone.Filters = CloneFilters(filter.Filters)
+		}
+		ret = append(ret, clone)
+	}
+	return
 }
 
 type RelativeDateUnit int
@@ -217,6 +288,40 @@ func (value *Value) Filter(filter *ViewFilter, attrView *AttributeView, rowID st
 
 func (value *Value) filter(other *Value, relativeDate, relativeDate2 *RelativeDate, operator FilterOperator) bool {
 	switch value.Type {
+	case KeyTypeFormula:
+		// 按照公式结果类型过滤
+		if nil == value.Formula {
+			return true
+		}
+
+		var otherResult *Value
+		if nil != other && nil != other.Formula {
+			otherResult = other.Formula.GetResult()
+		}
+
+		result := value.Formula.GetResult()
+		if nil == result {
+			if nil == otherResult {
+				return FilterOperatorIsEmpty == operator
+			}
+			result = getFormulaEmptyResult(otherResult.Type)
+		}
+		if nil == otherResult {
+			otherResult = getFormulaEmptyResult(result.Type)
+		}
+		if result.Type != otherResult.Type {
+			// 结果类型和过滤器值类型不匹配，该情况下不过滤
+			return true
+		}
+		return result.filter(otherResult, relativeDate, relativeDate2, operator)
+	case KeyTypeUniqueID:
+		if nil != value.UniqueID {
+			var otherUniqueID *ValueUniqueID
+			if nil != other {
+				otherUniqueID = other.UniqueID
+			}
+			return value.UniqueID.filter(otherUniqueID, operator)
+		}
 	case KeyTypeBlock:
 		if nil != value.Block && nil != other && nil != other.Block {
 			switch operator {
@@ -714,9 +819,79 @@ func calcRelativeTimeRegion(count int, unit RelativeDateUnit, direction Relative
 	return
 }
 
+// FillNewRowFilterValues 将视图（任意布局）最外层的过滤规则应用到新添加的行上，避免新行添加后因为不满足过滤条件而在视图中消失。
+//
+// rows 为视图过滤和排序后的行，previousRowID 对应的行（为空时使用第一行）的值优先作为默认值；
+// 同一个字段上同时存在过滤和排序时不处理。过滤组中的过滤规则不一定需要全部满足，所以仅应用最外层的过滤规则。
+func FillNewRowFilterValues(attrView *AttributeView, view *View, rows []*TableRow, previousRowID string, blockValue *Value) {
+	filters := view.GetFilters()
+	if 1 > len(filters) {
+		return
+	}
+
+	var nearRow *TableRow
+	if "" != previousRowID {
+		for _, row := range rows {
+			if row.ID == previousRowID {
+				nearRow = row
+				break
+			}
+		}
+	} else if 0 < len(rows) {
+		nearRow = rows[0]
+	}
+
+	if sorts := view.GetSorts(); 0 < len(sorts) {
+		filterKeys := map[string]bool{}
+		WalkFilters(filters, func(f *ViewFilter) {
+			filterKeys[f.Column] = true
+		})
+		for _, s := range sorts {
+			if filterKeys[s.Column] {
+				return
+			}
+		}
+	}
+
+	for _, filter := range filters {
+		if filter.IsGroup() {
+			continue
+		}
+
+		for _, keyValues := range attrView.KeyValues {
+			if keyValues.Key.ID != filter.Column {
+				continue
+			}
+
+			var defaultVal *Value
+			if nil != nearRow {
+				defaultVal = nearRow.GetValue(filter.Column)
+			}
+
+			newValue := filter.GetAffectValue(keyValues.Key, defaultVal)
+			if nil == newValue {
+				break
+			}
+
+			if KeyTypeBlock == newValue.Type {
+				// 主键已经添加过了，这里仅修改内容
+				blockValue.Block.Content = newValue.Block.Content
+				break
+			}
+
+			newValue.ID = ast.NewNodeID()
+			newValue.KeyID = keyValues.Key.ID
+			newValue.BlockID = blockValue.BlockID
+			newValue.IsDetached = blockValue.IsDetached
+			keyValues.Values = append(keyValues.Values, newValue)
+			break
+		}
+	}
+}
+
 func (filter *ViewFilter) GetAffectValue(key *Key, defaultVal *Value) (ret *Value) {
 	if nil != filter.Value {
-		if KeyTypeRelation == filter.Value.Type || KeyTypeTemplate == filter.Value.Type || KeyTypeRollup == filter.Value.Type || KeyTypeUpdated == filter.Value.Type || KeyTypeCreated == filter.Value.Type {
+		if KeyTypeRelation == filter.Value.Type || KeyTypeTemplate == filter.Value.Type || KeyTypeRollup == filter.Value.Type || KeyTypeUpdated == filter.Value.Type || KeyTypeCreated == filter.Value.Type || KeyTypeUniqueID == filter.Value.Type {
 			// 所有生成的数据都不设置默认值
 			return nil
 		}
@@ -0,0 +1,104 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"testing"
+)
+
+func newFillTestAttrView() (attrView *AttributeView, blockValue *Value) {
+	blockKey := &Key{ID: "key-block", Name: "Block", Type: KeyTypeBlock}
+	textKey := &Key{ID: "key-text", Name: "Text", Type: KeyTypeText}
+	blockValue = &Value{ID: "value-block", KeyID: blockKey.ID, BlockID: "row-new", Type: KeyTypeBlock, Block: &ValueBlock{ID: "row-new"}}
+	attrView = &AttributeView{
+		KeyValues: []*KeyValues{
+			{Key: blockKey, Values: []*Value{blockValue}},
+			{Key: textKey},
+		},
+	}
+	return
+}
+
+func textFilters() []*ViewFilter {
+	return []*ViewFilter{
+		{Column: "key-text", Operator: FilterOperatorIsEqual, Value: &Value{Type: KeyTypeText, Text: &ValueText{Content: "foo"}}},
+		{Conjunction: FilterConjunctionOr, Filters: []*ViewFilter{
+			{Column: "key-block", Operator: FilterOperatorContains, Value: &Value{Type: KeyTypeBlock, Block: &ValueBlock{Content: "bar"}}},
+		}},
+	}
+}
+
+func TestFillNewRowFilterValues(t *testing.T) {
+	views := []*View{
+		{LayoutType: LayoutTypeTable, Table: &LayoutTable{Filters: textFilters()}},
+		{LayoutType: LayoutTypeBoard, Board: &LayoutBoard{Filters: textFilters()}},
+		{LayoutType: LayoutTypeCalendar, Calendar: &LayoutCalendar{Filters: textFilters()}},
+		{LayoutType: LayoutTypeTimeline, Timeline: &LayoutCalendar{Filters: textFilters()}},
+		{LayoutType: LayoutTypeGallery, Gallery: &LayoutGallery{Filters: textFilters()}},
+	}
+
+	for _, view := range views {
+		attrView, blockValue := newFillTestAttrView()
+		FillNewRowFilterValues(attrView, view, nil, "", blockValue)
+
+		values := attrView.KeyValues[1].Values
+		if 1 != len(values) {
+			t.Fatalf("layout [%s] expected 1 filled value, got %d", view.LayoutType, len(values))
+		}
+		if "foo" != values[0].Text.Content || "row-new" != values[0].BlockID || "key-text" != values[0].KeyID {
+			t.Fatalf("layout [%s] filled unexpected value [%+v]", view.LayoutType, values[0])
+		}
+		if "" != blockValue.Block.Content {
+			t.Fatalf("layout [%s] filter group should not be applied, got block content [%s]", view.LayoutType, blockValue.Block.Content)
+		}
+	}
+}
+
+func TestFillNewRowFilterValuesNearRow(t *testing.T) {
+	view := &View{LayoutType: LayoutTypeGallery, Gallery: &LayoutGallery{Filters: []*ViewFilter{
+		{Column: "key-text", Operator: FilterOperatorContains, Value: &Value{Type: KeyTypeText, Text: &ValueText{Content: "foo"}}},
+	}}}
+	rows := []*TableRow{
+		{ID: "row-1", Cells: []*TableCell{{Value: &Value{KeyID: "key-text", Type: KeyTypeText, Text: &ValueText{Content: "foo 1"}}}}},
+		{ID: "row-2", Cells: []*TableCell{{Value: &Value{KeyID: "key-text", Type: KeyTypeText, Text: &ValueText{Content: "foo 2"}}}}},
+	}
+
+	attrView, blockValue := newFillTestAttrView()
+	FillNewRowFilterValues(attrView, view, rows, "row-2", blockValue)
+	if values := attrView.KeyValues[1].Values; 1 != len(values) || "foo 2" != values[0].Text.Content {
+		t.Fatalf("expected value copied from previous row, got [%+v]", values)
+	}
+
+	attrView, blockValue = newFillTestAttrView()
+	FillNewRowFilterValues(attrView, view, rows, "", blockValue)
+	if values := attrView.KeyValues[1].Values; 1 != len(values) || "foo 1" != values[0].Text.Content {
+		t.Fatalf("expected value copied from first row, got [%+v]", values)
+	}
+}
+
+func TestFillNewRowFilterValuesSameKeySort(t *testing.T) {
+	view := &View{LayoutType: LayoutTypeBoard, Board: &LayoutBoard{
+		Filters: textFilters(),
+		Sorts:   []*ViewSort{{Column: "key-text", Order: SortOrderAsc}},
+	}}
+
+	attrView, blockValue := newFillTestAttrView()
+	FillNewRowFilterValues(attrView, view, nil, "", blockValue)
+	if 0 != len(attrView.KeyValues[1].Values) {
+		t.Fatalf("filter and sort on the same key should not fill values")
+	}
+}
@@ -0,0 +1,1086 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// 公式字段使用的表达式语言：
+//
+//	字面量：数字 1.5、字符串 "abc"、布尔值 true/false
+//	运算符：+ - * / %（字符串使用 + 拼接）、== != < <= > >=、&& || !
+//	引用字段：prop("字段名")，可以引用包括汇总和其他公式在内的所有字段
+//	函数：if、switch、empty、concat、length、lower、upper、trim、contains、replace、toNumber、toText、
+//	     abs、round、floor、ceil、min、max、pow、sqrt、now、today、dateAdd、dateSubtract、dateBetween、
+//	     year、month、day、formatDate
+//
+// 公式结果的类型为数字、文本、日期或复选框，排序和过滤按照结果类型进行。
+
+type ValueFormula struct {
+	Type     KeyType        `json:"type"`               // 结果类型，数字、文本、日期或复选框，结果为空时为空字符串
+	Number   *ValueNumber   `json:"number,omitempty"`   // 数字结果
+	Text     *ValueText     `json:"text,omitempty"`     // 文本结果
+	Date     *ValueDate     `json:"date,omitempty"`     // 日期结果
+	Checkbox *ValueCheckbox `json:"checkbox,omitempty"` // 复选框结果
+	Error    string         `json:"error,omitempty"`    // 解析或计算错误
+}
+
+// GetResult 将公式结果转换为对应结果类型的值，结果为空时返回 nil。
+func (formula *ValueFormula) GetResult() (ret *Value) {
+	if nil == formula {
+		return
+	}
+
+	switch formula.Type {
+	case KeyTypeNumber:
+		ret = &Value{Type: KeyTypeNumber, Number: formula.Number}
+	case KeyTypeText:
+		ret = &Value{Type: KeyTypeText, Text: formula.Text}
+	case KeyTypeDate:
+		ret = &Value{Type: KeyTypeDate, Date: formula.Date}
+	case KeyTypeCheckbox:
+		ret = &Value{Type: KeyTypeCheckbox, Checkbox: formula.Checkbox}
+	}
+	return
+}
+
+// getFormulaEmptyResult 返回指定结果类型的空值，用于和空结果进行比较。
+func getFormulaEmptyResult(typ KeyType) *Value {
+	switch typ {
+	case KeyTypeNumber:
+		return &Value{Type: KeyTypeNumber, Number: &ValueNumber{}}
+	case KeyTypeDate:
+		return &Value{Type: KeyTypeDate, Date: &ValueDate{}}
+	case KeyTypeCheckbox:
+		return &Value{Type: KeyTypeCheckbox, Checkbox: &ValueCheckbox{}}
+	}
+	return &Value{Type: KeyTypeText, Text: &ValueText{}}
+}
+
+// FormulaPropResolver 根据字段名返回当前行的字段值。
+type FormulaPropResolver func(keyName string) (*Value, error)
+
+// EvalFormula 计算公式，numberFormat 用于格式化数字结果。
+func EvalFormula(expr string, numberFormat NumberFormat, resolver FormulaPropResolver) (ret *ValueFormula) {
+	ret = &ValueFormula{}
+	if "" == strings.TrimSpace(expr) {
+		return
+	}
+
+	node, err := parseFormula(expr)
+	if err != nil {
+		ret.Error = err.Error()
+		return
+	}
+
+	result, err := node.eval(resolver)
+	if err != nil {
+		ret.Error = err.Error()
+		return
+	}
+
+	switch result.kind {
+	case formulaKindNumber:
+		if math.IsNaN(result.num) || math.IsInf(result.num, 0) {
+			ret.Error = "invalid number result"
+			return
+		}
+		ret.Type = KeyTypeNumber
+		ret.Number = NewFormattedValueNumber(result.num, numberFormat)
+	case formulaKindText:
+		ret.Type = KeyTypeText
+		ret.Text = &ValueText{Content: result.str}
+	case formulaKindDate:
+		ret.Type = KeyTypeDate
+		ret.Date = NewFormattedValueDate(result.t.UnixMilli(), 0, DateFormatNone, result.isNotTime, false)
+	case formulaKindBool:
+		ret.Type = KeyTypeCheckbox
+		ret.Checkbox = &ValueCheckbox{Checked: result.b}
+	}
+	return
+}
+
+// CheckFormula 检查公式语法是否正确。
+func CheckFormula(expr string) (err error) {
+	if "" == strings.TrimSpace(expr) {
+		return
+	}
+	_, err = parseFormula(expr)
+	return
+}
+
+type formulaKind int
+
+const (
+	formulaKindEmpty formulaKind = iota
+	formulaKindNumber
+	formulaKindText
+	formulaKindDate
+	formulaKindBool
+)
+
+type formulaValue struct {
+	kind      formulaKind
+	num       float64
+	str       string
+	t         time.Time
+	isNotTime bool
+	b         bool
+}
+
+func (v *formulaValue) isEmpty() bool {
+	switch v.kind {
+	case formulaKindEmpty:
+		return true
+	case formulaKindText:
+		return "" == v.str
+	}
+	return false
+}
+
+func (v *formulaValue) truthy() bool {
+	switch v.kind {
+	case formulaKindNumber:
+		return 0 != v.num
+	case formulaKindText:
+		return "" != v.str
+	case formulaKindDate:
+		return true
+	case formulaKindBool:
+		return v.b
+	}
+	return false
+}
+
+func (v *formulaValue) text() string {
+	switch v.kind {
+	case formulaKindNumber:
+		return strconv.FormatFloat(v.num, 'f', -1, 64)
+	case formulaKindText:
+		return v.str
+	case formulaKindDate:
+		if v.isNotTime {
+			return v.t.Format("2006-01-02")
+		}
+		return v.t.Format("2006-01-02 15:04")
+	case formulaKindBool:
+		return strconv.FormatBool(v.b)
+	}
+	return ""
+}
+
+func (v *formulaValue) number() (float64, error) {
+	switch v.kind {
+	case formulaKindEmpty:
+		return 0, nil
+	case formulaKindNumber:
+		return v.num, nil
+	case formulaKindText:
+		if "" == strings.TrimSpace(v.str) {
+			return 0, nil
+		}
+		ret, err := strconv.ParseFloat(strings.TrimSpace(v.str), 64)
+		if err != nil {
+			return 0, fmt.Errorf("cannot convert [%s] to number", v.str)
+		}
+		return ret, nil
+	case formulaKindBool:
+		if v.b {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, errors.New("cannot convert date to number")
+}
+
+func (v *formulaValue) date() (time.Time, error) {
+	if formulaKindDate == v.kind {
+		return v.t, nil
+	}
+	return time.Time{}, fmt.Errorf("[%s] is not a date", v.text())
+}
+
+func newFormulaValue(value *Value) *formulaValue {
+	if nil == value {
+		return &formulaValue{}
+	}
+
+	switch value.Type {
+	case KeyTypeNumber:
+		if nil == value.Number || !value.Number.IsNotEmpty {
+			return &formulaValue{}
+		}
+		return &formulaValue{kind: formulaKindNumber, num: value.Number.Content}
+	case KeyTypeDate:
+		if nil == value.Date || !value.Date.IsNotEmpty {
+			return &formulaValue{}
+		}
+		return &formulaValue{kind: formulaKindDate, t: time.UnixMilli(value.Date.Content), isNotTime: value.Date.IsNotTime}
+	case KeyTypeCreated:
+		if nil == value.Created || !value.Created.IsNotEmpty {
+			return &formulaValue{}
+		}
+		return &formulaValue{kind: formulaKindDate, t: time.UnixMilli(value.Created.Content)}
+	case KeyTypeUpdated:
+		if nil == value.Updated || !value.Updated.IsNotEmpty {
+			return &formulaValue{}
+		}
+		return &formulaValue{kind: formulaKindDate, t: time.UnixMilli(value.Updated.Content)}
+	case KeyTypeCheckbox:
+		return &formulaValue{kind: formulaKindBool, b: nil != value.Checkbox && value.Checkbox.Checked}
+	case KeyTypeRollup:
+		if nil != value.Rollup && 1 == len(value.Rollup.Contents) {
+			// 汇总计算后只有一个值时按照该值的类型参与计算
+			return newFormulaValue(value.Rollup.Contents[0])
+		}
+	case KeyTypeFormula:
+		return newFormulaValue(value.Formula.GetResult())
+	case KeyTypeUniqueID:
+		if nil == value.UniqueID || 1 > value.UniqueID.Number {
+			return &formulaValue{}
+		}
+		if "" == value.UniqueID.Content || strconv.FormatInt(value.UniqueID.Number, 10) == value.UniqueID.Content {
+			// 没有前缀时按照数字参与计算
+			return &formulaValue{kind: formulaKindNumber, num: float64(value.UniqueID.Number)}
+		}
+	}
+
+	content := value.String(false)
+	if "" == content {
+		return &formulaValue{}
+	}
+	return &formulaValue{kind: formulaKindText, str: content}
+}
+
+// 语法树
+
+type formulaNode interface {
+	eval(resolver FormulaPropResolver) (*formulaValue, error)
+}
+
+type formulaLiteral struct {
+	value *formulaValue
+}
+
+func (n *formulaLiteral) eval(FormulaPropResolver) (*formulaValue, error) {
+	return n.value, nil
+}
+
+type formulaUnary struct {
+	op      string
+	operand formulaNode
+}
+
+func (n *formulaUnary) eval(resolver FormulaPropResolver) (*formulaValue, error) {
+	v, err := n.operand.eval(resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	if "!" == n.op {
+		return &formulaValue{kind: formulaKindBool, b: !v.truthy()}, nil
+	}
+
+	num, err := v.number()
+	if err != nil {
+		return nil, err
+	}
+	return &formulaValue{kind: formulaKindNumber, num: -num}, nil
+}
+
+type formulaBinary struct {
+	op          string
+	left, right formulaNode
+}
+
+func (n *formulaBinary) eval(resolver FormulaPropResolver) (*formulaValue, error) {
+	l, err := n.left.eval(resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	// 逻辑运算短路求值
+	switch n.op {
+	case "&&":
+		if !l.truthy() {
+			return &formulaValue{kind: formulaKindBool, b: false}, nil
+		}
+		r, rErr := n.right.eval(resolver)
+		if rErr != nil {
+			return nil, rErr
+		}
+		return &formulaValue{kind: formulaKindBool, b: r.truthy()}, nil
+	case "||":
+		if l.truthy() {
+			return &formulaValue{kind: formulaKindBool, b: true}, nil
+		}
+		r, rErr := n.right.eval(resolver)
+		if rErr != nil {
+			return nil, rErr
+		}
+		return &formulaValue{kind: formulaKindBool, b: r.truthy()}, nil
+	}
+
+	r, err := n.right.eval(resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		cmp := compareFormulaValues(l, r)
+		var b bool
+		switch n.op {
+		case "==":
+			b = 0 == cmp
+		case "!=":
+			b = 0 != cmp
+		case "<":
+			b = 0 > cmp
+		case "<=":
+			b = 0 >= cmp
+		case ">":
+			b = 0 < cmp
+		case ">=":
+			b = 0 <= cmp
+		}
+		return &formulaValue{kind: formulaKindBool, b: b}, nil
+	case "+":
+		if formulaKindText == l.kind || formulaKindText == r.kind {
+			return &formulaValue{kind: formulaKindText, str: l.text() + r.text()}, nil
+		}
+	}
+
+	ln, err := l.number()
+	if err != nil {
+		return nil, err
+	}
+	rn, err := r.number()
+	if err != nil {
+		return nil, err
+	}
+
+	var ret float64
+	switch n.op {
+	case "+":
+		ret = ln + rn
+	case "-":
+		ret = ln - rn
+	case "*":
+		ret = ln * rn
+	case "/":
+		if 0 == rn {
+			return nil, errors.New("division by zero")
+		}
+		ret = ln / rn
+	case "%":
+		if 0 == rn {
+			return nil, errors.New("division by zero")
+		}
+		ret = math.Mod(ln, rn)
+	}
+	return &formulaValue{kind: formulaKindNumber, num: ret}, nil
+}
+
+func compareFormulaValues(l, r *formulaValue) int {
+	if l.isEmpty() || r.isEmpty() {
+		if l.isEmpty() && r.isEmpty() {
+			return 0
+		}
+		if l.isEmpty() {
+			return -1
+		}
+		return 1
+	}
+
+	switch {
+	case formulaKindDate == l.kind && formulaKindDate == r.kind:
+		return compareInt64(l.t.UnixMilli(), r.t.UnixMilli())
+	case formulaKindText == l.kind || formulaKindText == r.kind:
+		return strings.Compare(l.text(), r.text())
+	}
+
+	ln, _ := l.number()
+	rn, _ := r.number()
+	switch {
+	case ln < rn:
+		return -1
+	case ln > rn:
+		return 1
+	}
+	return 0
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+type formulaCall struct {
+	name string
+	args []formulaNode
+}
+
+func (n *formulaCall) eval(resolver FormulaPropResolver) (*formulaValue, error) {
+	// 条件函数需要惰性求值
+	switch n.name {
+	case "if":
+		if 2 > len(n.args) || 3 < len(n.args) {
+			return nil, errors.New("if requires 2 or 3 arguments")
+		}
+		cond, err := n.args[0].eval(resolver)
+		if err != nil {
+			return nil, err
+		}
+		if cond.truthy() {
+			return n.args[1].eval(resolver)
+		}
+		if 3 == len(n.args) {
+			return n.args[2].eval(resolver)
+		}
+		return &formulaValue{}, nil
+	case "switch":
+		if 3 > len(n.args) {
+			return nil, errors.New("switch requires at least 3 arguments")
+		}
+		v, err := n.args[0].eval(resolver)
+		if err != nil {
+			return nil, err
+		}
+		i := 1
+		for ; i+1 < len(n.args); i += 2 {
+			c, cErr := n.args[i].eval(resolver)
+			if cErr != nil {
+				return nil, cErr
+			}
+			if 0 == compareFormulaValues(v, c) {
+				return n.args[i+1].eval(resolver)
+			}
+		}
+		if i < len(n.args) {
+			// 默认值
+			return n.args[i].eval(resolver)
+		}
+		return &formulaValue{}, nil
+	}
+
+	var args []*formulaValue
+	for _, arg := range n.args {
+		v, err := arg.eval(resolver)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	return callFormulaFunc(n.name, args, resolver)
+}
+
+func callFormulaFunc(name string, args []*formulaValue, resolver FormulaPropResolver) (*formulaValue, error) {
+	argc := func(min, max int) error {
+		if len(args) < min || (0 <= max && len(args) > max) {
+			return fmt.Errorf("wrong number of arguments for %s", name)
+		}
+		return nil
+	}
+	num := func(ret float64) (*formulaValue, error) {
+		return &formulaValue{kind: formulaKindNumber, num: ret}, nil
+	}
+	str := func(ret string) (*formulaValue, error) {
+		return &formulaValue{kind: formulaKindText, str: ret}, nil
+	}
+	boolean := func(ret bool) (*formulaValue, error) {
+		return &formulaValue{kind: formulaKindBool, b: ret}, nil
+	}
+
+	switch name {
+	case "prop":
+		if err := argc(1, 1); err != nil {
+			return nil, err
+		}
+		if nil == resolver {
+			return &formulaValue{}, nil
+		}
+		value, err := resolver(args[0].text())
+		if err != nil {
+			return nil, err
+		}
+		return newFormulaValue(value), nil
+	case "empty":
+		if err := argc(1, 1); err != nil {
+			return nil, err
+		}
+		return boolean(args[0].isEmpty())
+	case "concat":
+		buf := strings.Builder{}
+		for _, arg := range args {
+			buf.WriteString(arg.text())
+		}
+		return str(buf.String())
+	case "length":
+		if err := argc(1, 1); err != nil {
+			return nil, err
+		}
+		return num(float64(len([]rune(args[0].text()))))
+	case "lower":
+		if err := argc(1, 1); err != nil {
+			return nil, err
+		}
+		return str(strings.ToLower(args[0].text()))
+	case "upper":
+		if err := argc(1, 1); err != nil {
+			return nil, err
+		}
+		return str(strings.ToUpper(args[0].text()))
+	case "trim":
+		if err := argc(1, 1); err != nil {
+			return nil, err
+		}
+		return str(strings.TrimSpace(args[0].text()))
+	case "contains":
+		if err := argc(2, 2); err != nil {
+			return nil, err
+		}
+		return boolean(strings.Contains(args[0].text(), args[1].text()))
+	case "replace":
+		if err := argc(3, 3); err != nil {
+			return nil, err
+		}
+		return str(strings.ReplaceAll(args[0].text(), args[1].text(), args[2].text()))
+	case "toText":
+		if err := argc(1, 1); err != nil {
+			return nil, err
+		}
+		return str(args[0].text())
+	case "toNumber":
+		if err := argc(1, 1); err != nil {
+			return nil, err
+		}
+		if formulaKindDate == args[0].kind {
+			return num(float64(args[0].t.UnixMilli()))
+		}
+		ret, err := args[0].number()
+		if err != nil {
+			return nil, err
+		}
+		return num(ret)
+	case "abs", "floor", "ceil", "sqrt":
+		if err := argc(1, 1); err != nil {
+			return nil, err
+		}
+		x, err := args[0].number()
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "abs":
+			return num(math.Abs(x))
+		case "floor":
+			return num(math.Floor(x))
+		case "ceil":
+			return num(math.Ceil(x))
+		}
+		if 0 > x {
+			return nil, errors.New("sqrt of negative number")
+		}
+		return num(math.Sqrt(x))
+	case "round":
+		if err := argc(1, 2); err != nil {
+			return nil, err
+		}
+		x, err := args[0].number()
+		if err != nil {
+			return nil, err
+		}
+		precision := 0.0
+		if 2 == len(args) {
+			if precision, err = args[1].number(); err != nil {
+				return nil, err
+			}
+		}
+		return num(Round(x, int(precision)))
+	case "pow":
+		if err := argc(2, 2); err != nil {
+			return nil, err
+		}
+		x, err := args[0].number()
+		if err != nil {
+			return nil, err
+		}
+		y, err := args[1].number()
+		if err != nil {
+			return nil, err
+		}
+		return num(math.Pow(x, y))
+	case "min", "max":
+		if err := argc(1, -1); err != nil {
+			return nil, err
+		}
+		var ret float64
+		for i, arg := range args {
+			x, err := arg.number()
+			if err != nil {
+				return nil, err
+			}
+			if 0 == i || ("min" == name && x < ret) || ("max" == name && x > ret) {
+				ret = x
+			}
+		}
+		return num(ret)
+	case "now":
+		if err := argc(0, 0); err != nil {
+			return nil, err
+		}
+		return &formulaValue{kind: formulaKindDate, t: time.Now()}, nil
+	case "today":
+		if err := argc(0, 0); err != nil {
+			return nil, err
+		}
+		return &formulaValue{kind: formulaKindDate, t: truncateDate(time.Now(), DateUnitDay), isNotTime: true}, nil
+	case "dateAdd", "dateSubtract":
+		if err := argc(3, 3); err != nil {
+			return nil, err
+		}
+		if args[0].isEmpty() {
+			return &formulaValue{}, nil
+		}
+		t, err := args[0].date()
+		if err != nil {
+			return nil, err
+		}
+		n, err := args[1].number()
+		if err != nil {
+			return nil, err
+		}
+		if "dateSubtract" == name {
+			n = -n
+		}
+		t, err = addFormulaDate(t, int(n), args[2].text())
+		if err != nil {
+			return nil, err
+		}
+		return &formulaValue{kind: formulaKindDate, t: t, isNotTime: args[0].isNotTime}, nil
+	case "dateBetween":
+		if err := argc(3, 3); err != nil {
+			return nil, err
+		}
+		if args[0].isEmpty() || args[1].isEmpty() {
+			return &formulaValue{}, nil
+		}
+		t1, err := args[0].date()
+		if err != nil {
+			return nil, err
+		}
+		t2, err := args[1].date()
+		if err != nil {
+			return nil, err
+		}
+		ret, err := betweenFormulaDate(t1, t2, args[2].text())
+		if err != nil {
+			return nil, err
+		}
+		return num(ret)
+	case "year", "month", "day":
+		if err := argc(1, 1); err != nil {
+			return nil, err
+		}
+		if args[0].isEmpty() {
+			return &formulaValue{}, nil
+		}
+		t, err := args[0].date()
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "year":
+			return num(float64(t.Year()))
+		case "month":
+			return num(float64(t.Month()))
+		}
+		return num(float64(t.Day()))
+	case "formatDate":
+		if err := argc(1, 2); err != nil {
+			return nil, err
+		}
+		if args[0].isEmpty() {
+			return str("")
+		}
+		t, err := args[0].date()
+		if err != nil {
+			return nil, err
+		}
+		layout := "2006-01-02"
+		if 2 == len(args) {
+			layout = toGoDateLayout(args[1].text())
+		}
+		return str(t.Format(layout))
+	}
+	return nil, fmt.Errorf("unknown function [%s]", name)
+}
+
+func addFormulaDate(t time.Time, n int, unit string) (time.Time, error) {
+	switch unit {
+	case "years":
+		return t.AddDate(n, 0, 0), nil
+	case "months":
+		return t.AddDate(0, n, 0), nil
+	case "weeks":
+		return t.AddDate(0, 0, 7*n), nil
+	case "days":
+		return t.AddDate(0, 0, n), nil
+	case "hours":
+		return t.Add(time.Duration(n) * time.Hour), nil
+	case "minutes":
+		return t.Add(time.Duration(n) * time.Minute), nil
+	case "seconds":
+		return t.Add(time.Duration(n) * time.Second), nil
+	}
+	return t, fmt.Errorf("unknown date unit [%s]", unit)
+}
+
+func betweenFormulaDate(t1, t2 time.Time, unit string) (float64, error) {
+	switch unit {
+	case "years":
+		return float64(monthsBetween(t1, t2) / 12), nil
+	case "months":
+		return float64(monthsBetween(t1, t2)), nil
+	}
+
+	d := t1.Sub(t2)
+	switch unit {
+	case "weeks":
+		return math.Trunc(d.Hours() / 24 / 7), nil
+	case "days":
+		return math.Trunc(d.Hours() / 24), nil
+	case "hours":
+		return math.Trunc(d.Hours()), nil
+	case "minutes":
+		return math.Trunc(d.Minutes()), nil
+	case "seconds":
+		return math.Trunc(d.Seconds()), nil
+	}
+	return 0, fmt.Errorf("unknown date unit [%s]", unit)
+}
+
+// monthsBetween 返回 t1 和 t2 之间相差的整月数，t1 早于 t2 时为负数。
+func monthsBetween(t1, t2 time.Time) int {
+	sign := 1
+	if t1.Before(t2) {
+		t1, t2 = t2, t1
+		sign = -1
+	}
+
+	months := (t1.Year()-t2.Year())*12 + int(t1.Month()-t2.Month())
+	if t2.AddDate(0, months, 0).After(t1) {
+		months--
+	}
+	return sign * months
+}
+
+// toGoDateLayout 将 YYYY-MM-DD HH:mm:ss 形式的日期格式转换为 Go 的日期格式。
+func toGoDateLayout(layout string) string {
+	return strings.NewReplacer("YYYY", "2006", "MM", "01", "DD", "02", "HH", "15", "mm", "04", "ss", "05").Replace(layout)
+}
+
+// 词法和语法分析
+
+type formulaToken struct {
+	typ string // num、str、ident、op、eof
+	val string
+	pos int
+}
+
+func tokenizeFormula(expr string) (ret []*formulaToken, err error) {
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c) || ('.' == c && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || '.' == runes[i]) {
+				i++
+			}
+			ret = append(ret, &formulaToken{typ: "num", val: string(runes[start:i]), pos: start})
+		case '"' == c || '\'' == c:
+			quote := c
+			start := i
+			i++
+			buf := strings.Builder{}
+			for i < len(runes) && runes[i] != quote {
+				if '\\' == runes[i] && i+1 < len(runes) {
+					i++
+				}
+				buf.WriteRune(runes[i])
+				i++
+			}
+			if i >= len(runes) {
+				err = fmt.Errorf("unterminated string at %d", start)
+				return
+			}
+			i++
+			ret = append(ret, &formulaToken{typ: "str", val: buf.String(), pos: start})
+		case unicode.IsLetter(c) || '_' == c:
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || '_' == runes[i]) {
+				i++
+			}
+			ret = append(ret, &formulaToken{typ: "ident", val: string(runes[start:i]), pos: start})
+		default:
+			start := i
+			if i+1 < len(runes) {
+				two := string(runes[i : i+2])
+				switch two {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					ret = append(ret, &formulaToken{typ: "op", val: two, pos: start})
+					i += 2
+					continue
+				}
+			}
+			switch c {
+			case '+', '-', '*', '/', '%', '<', '>', '!', '(', ')', ',':
+				ret = append(ret, &formulaToken{typ: "op", val: string(c), pos: start})
+				i++
+			default:
+				err = fmt.Errorf("unexpected character [%c] at %d", c, start)
+				return
+			}
+		}
+	}
+	ret = append(ret, &formulaToken{typ: "eof", pos: len(runes)})
+	return
+}
+
+type formulaParser struct {
+	tokens []*formulaToken
+	pos    int
+}
+
+func parseFormula(expr string) (ret formulaNode, err error) {
+	tokens, err := tokenizeFormula(expr)
+	if err != nil {
+		return
+	}
+
+	p := &formulaParser{tokens: tokens}
+	if ret, err = p.parseBinary(0); err != nil {
+		return
+	}
+	if tok := p.peek(); "eof" != tok.typ {
+		err = fmt.Errorf("unexpected [%s] at %d", tok.val, tok.pos)
+	}
+	return
+}
+
+func (p *formulaParser) peek() *formulaToken {
+	return p.tokens[p.pos]
+}
+
+func (p *formulaParser) next() *formulaToken {
+	tok := p.tokens[p.pos]
+	if "eof" != tok.typ {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *formulaParser) expect(val string) error {
+	tok := p.next()
+	if "op" != tok.typ || val != tok.val {
+		return fmt.Errorf("expected [%s] at %d", val, tok.pos)
+	}
+	return nil
+}
+
+// 运算符优先级，数值越大优先级越高
+var formulaBinaryPrecedences = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *formulaParser) parseBinary(level int) (ret formulaNode, err error) {
+	if level >= len(formulaBinaryPrecedences) {
+		return p.parseUnary()
+	}
+
+	if ret, err = p.parseBinary(level + 1); err != nil {
+		return
+	}
+	for {
+		tok := p.peek()
+		if "op" != tok.typ || !isFormulaOp(tok.val, formulaBinaryPrecedences[level]) {
+			return
+		}
+		p.next()
+
+		var right formulaNode
+		if right, err = p.parseBinary(level + 1); err != nil {
+			return
+		}
+		ret = &formulaBinary{op: tok.val, left: ret, right: right}
+	}
+}
+
+func isFormulaOp(op string, ops []string) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *formulaParser) parseUnary() (ret formulaNode, err error) {
+	tok := p.peek()
+	if "op" == tok.typ && ("!" == tok.val || "-" == tok.val) {
+		p.next()
+		var operand formulaNode
+		if operand, err = p.parseUnary(); err != nil {
+			return
+		}
+		ret = &formulaUnary{op: tok.val, operand: operand}
+		return
+	}
+	return p.parsePrimary()
+}
+
+func (p *formulaParser) parsePrimary() (ret formulaNode, err error) {
+	tok := p.next()
+	switch tok.typ {
+	case "num":
+		n, parseErr := strconv.ParseFloat(tok.val, 64)
+		if nil != parseErr {
+			err = fmt.Errorf("invalid number [%s] at %d", tok.val, tok.pos)
+			return
+		}
+		ret = &formulaLiteral{value: &formulaValue{kind: formulaKindNumber, num: n}}
+	case "str":
+		ret = &formulaLiteral{value: &formulaValue{kind: formulaKindText, str: tok.val}}
+	case "ident":
+		switch tok.val {
+		case "true", "false":
+			ret = &formulaLiteral{value: &formulaValue{kind: formulaKindBool, b: "true" == tok.val}}
+			return
+		}
+
+		if err = p.expect("("); err != nil {
+			return
+		}
+		call := &formulaCall{name: tok.val}
+		if next := p.peek(); "op" == next.typ && ")" == next.val {
+			p.next()
+			ret = call
+			return
+		}
+		for {
+			var arg formulaNode
+			if arg, err = p.parseBinary(0); err != nil {
+				return
+			}
+			call.args = append(call.args, arg)
+
+			next := p.next()
+			if "op" == next.typ && ")" == next.val {
+				break
+			}
+			if "op" != next.typ || "," != next.val {
+				err = fmt.Errorf("expected [,] or [)] at %d", next.pos)
+				return
+			}
+		}
+		ret = call
+	case "op":
+		if "(" == tok.val {
+			if ret, err = p.parseBinary(0); err != nil {
+				return
+			}
+			err = p.expect(")")
+			return
+		}
+		err = fmt.Errorf("unexpected [%s] at %d", tok.val, tok.pos)
+	default:
+		err = fmt.Errorf("unexpected end of formula")
+	}
+	return
+}
+
+// EvalRowFormulas 计算一行中所有公式字段的值，keys 和 values 一一对应，公式之间可以相互引用但不能循环引用。
+func EvalRowFormulas(keys []*Key, values []*Value) {
+	keyIndexes := map[string]int{}
+	hasFormula := false
+	for i, key := range keys {
+		if _, ok := keyIndexes[key.Name]; !ok {
+			keyIndexes[key.Name] = i
+		}
+		if KeyTypeFormula == key.Type {
+			hasFormula = true
+		}
+	}
+	if !hasFormula {
+		return
+	}
+
+	evaluating, evaluated := map[int]bool{}, map[int]bool{}
+	var evalValue func(i int)
+	resolver := func(keyName string) (*Value, error) {
+		i, ok := keyIndexes[keyName]
+		if !ok {
+			return nil, fmt.Errorf("key [%s] not found", keyName)
+		}
+
+		if i >= len(values) || nil == values[i] {
+			// 缺失的值按空值参与计算
+			return nil, nil
+		}
+
+		if KeyTypeFormula == keys[i].Type && !evaluated[i] {
+			if evaluating[i] {
+				return nil, fmt.Errorf("circular reference to key [%s]", keyName)
+			}
+			evalValue(i)
+		}
+		return values[i], nil
+	}
+	evalValue = func(i int) {
+		evaluating[i] = true
+		values[i].Formula = EvalFormula(keys[i].Formula, keys[i].NumberFormat, resolver)
+		evaluating[i] = false
+		evaluated[i] = true
+	}
+
+	for i, key := range keys {
+		if KeyTypeFormula == key.Type && !evaluated[i] && i < len(values) && nil != values[i] {
+			evalValue(i)
+		}
+	}
+}
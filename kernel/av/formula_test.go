@@ -0,0 +1,171 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func formulaTestResolver(keyName string) (*Value, error) {
+	switch keyName {
+	case "Price":
+		return numberValue(12.5), nil
+	case "Count":
+		return numberValue(4), nil
+	case "Name":
+		return &Value{Type: KeyTypeText, Text: &ValueText{Content: "Apple"}}, nil
+	case "Due":
+		return &Value{Type: KeyTypeDate, Date: &ValueDate{Content: time.Date(2024, 3, 4, 0, 0, 0, 0, time.Local).UnixMilli(), IsNotEmpty: true, IsNotTime: true}}, nil
+	case "Done":
+		return &Value{Type: KeyTypeCheckbox, Checkbox: &ValueCheckbox{Checked: true}}, nil
+	case "Empty":
+		return nil, nil
+	}
+	return nil, errors.New("key not found")
+}
+
+func TestEvalFormula(t *testing.T) {
+	tests := []struct {
+		expr string
+		typ  KeyType
+		want string
+	}{
+		{`1 + 2 * 3`, KeyTypeNumber, "7"},
+		{`(1 + 2) * 3`, KeyTypeNumber, "9"},
+		{`-2 * -3`, KeyTypeNumber, "6"},
+		{`7 % 4`, KeyTypeNumber, "3"},
+		{`prop("Price") * prop("Count")`, KeyTypeNumber, "50"},
+		{`round(10 / 3, 2)`, KeyTypeNumber, "3.33"},
+		{`max(1, prop("Count"), 3)`, KeyTypeNumber, "4"},
+		{`prop("Empty") + 1`, KeyTypeNumber, "1"},
+		{`"a" + 1`, KeyTypeText, "a1"},
+		{`concat(prop("Name"), "-", 1)`, KeyTypeText, "Apple-1"},
+		{`upper(prop("Name"))`, KeyTypeText, "APPLE"},
+		{`replace("a-b-c", "-", "")`, KeyTypeText, "abc"},
+		{`if(prop("Count") > 3, "many", "few")`, KeyTypeText, "many"},
+		{`switch(prop("Count"), 1, "one", 4, "four", "other")`, KeyTypeText, "four"},
+		{`switch(2, 1, "one", "other")`, KeyTypeText, "other"},
+		{`formatDate(dateAdd(prop("Due"), 1, "months"), "YYYY/MM/DD")`, KeyTypeText, "2024/04/04"},
+		{`dateBetween(dateAdd(prop("Due"), 10, "days"), prop("Due"), "days")`, KeyTypeNumber, "10"},
+		{`month(prop("Due"))`, KeyTypeNumber, "3"},
+		{`prop("Done") && !empty(prop("Name"))`, KeyTypeCheckbox, "true"},
+		{`empty(prop("Empty")) || 1 / 0`, KeyTypeCheckbox, "true"},
+		{`"b" > "a"`, KeyTypeCheckbox, "true"},
+		{`if(false, 1)`, "", ""},
+	}
+
+	for _, test := range tests {
+		result := EvalFormula(test.expr, NumberFormatNone, formulaTestResolver)
+		if "" != result.Error {
+			t.Fatalf("formula [%s] failed: %s", test.expr, result.Error)
+		}
+		if test.typ != result.Type {
+			t.Fatalf("formula [%s] expected type [%s], got [%s]", test.expr, test.typ, result.Type)
+		}
+
+		var got string
+		if value := result.GetResult(); nil != value {
+			if KeyTypeCheckbox == value.Type {
+				got = value.String(false)
+				if value.Checkbox.Checked {
+					got = "true"
+				}
+			} else {
+				got = newFormulaValue(value).text()
+			}
+		}
+		if test.want != got {
+			t.Fatalf("formula [%s] expected [%s], got [%s]", test.expr, test.want, got)
+		}
+	}
+}
+
+func TestEvalFormulaErrors(t *testing.T) {
+	tests := []string{
+		`1 +`,
+		`(1 + 2`,
+		`"abc`,
+		`1 # 2`,
+		`foo(1)`,
+		`prop("Missing")`,
+		`1 / 0`,
+		`sqrt(-1)`,
+		`toNumber("abc")`,
+		`round(1, 2, 3)`,
+		`if(true)`,
+		`dateAdd(prop("Due"), 1, "fortnights")`,
+		`year("2024")`,
+	}
+
+	for _, expr := range tests {
+		if result := EvalFormula(expr, NumberFormatNone, formulaTestResolver); "" == result.Error || "" != result.Type {
+			t.Fatalf("formula [%s] expected error, got [%+v]", expr, result)
+		}
+	}
+
+	if nil != CheckFormula(`prop("Missing") + 1`) {
+		t.Fatalf("check formula should only check syntax")
+	}
+	if nil == CheckFormula(`1 +* 2`) {
+		t.Fatalf("check formula expected syntax error")
+	}
+}
+
+func TestEvalRowFormulas(t *testing.T) {
+	keys := []*Key{
+		{ID: "key-price", Name: "Price", Type: KeyTypeNumber},
+		{ID: "key-total", Name: "Total", Type: KeyTypeFormula, Formula: `prop("Subtotal") + prop("Tax")`},
+		{ID: "key-subtotal", Name: "Subtotal", Type: KeyTypeFormula, Formula: `prop("Price") * 2`},
+		{ID: "key-tax", Name: "Tax", Type: KeyTypeNumber},
+		{ID: "key-a", Name: "A", Type: KeyTypeFormula, Formula: `prop("B")`},
+		{ID: "key-b", Name: "B", Type: KeyTypeFormula, Formula: `prop("A")`},
+	}
+	values := []*Value{
+		numberValue(3),
+		{Type: KeyTypeFormula},
+		{Type: KeyTypeFormula},
+		nil, // 缺失的值按空值参与计算
+		{Type: KeyTypeFormula},
+		{Type: KeyTypeFormula},
+	}
+	EvalRowFormulas(keys, values)
+
+	if "" != values[1].Formula.Error || 6 != values[1].Formula.Number.Content {
+		t.Fatalf("expected total 6, got [%+v]", values[1].Formula)
+	}
+	if 6 != values[2].Formula.Number.Content {
+		t.Fatalf("expected subtotal 6, got [%+v]", values[2].Formula)
+	}
+	// A 引用 B 时 B 再引用 A 形成循环引用
+	if "" == values[5].Formula.Error {
+		t.Fatalf("expected circular reference error")
+	}
+}
+
+func TestEvalRowFormulasMissingFormulaValue(t *testing.T) {
+	keys := []*Key{
+		{ID: "key-a", Name: "A", Type: KeyTypeFormula, Formula: `1`},
+		{ID: "key-b", Name: "B", Type: KeyTypeFormula, Formula: `prop("A") + 1`},
+	}
+	values := []*Value{nil, {Type: KeyTypeFormula}}
+	EvalRowFormulas(keys, values)
+	if "" != values[1].Formula.Error || 1 != values[1].Formula.Number.Content {
+		t.Fatalf("expected missing formula value treated as empty, got [%+v]", values[1].Formula)
+	}
+}
@@ -0,0 +1,142 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+// LayoutGallery 描述了画廊布局的结构。
+type LayoutGallery struct {
+	Spec int    `json:"spec"` // 布局格式版本
+	ID   string `json:"id"`   // 布局 ID
+
+	CoverFrom  CoverFrom     `json:"coverFrom"`  // 封面来源
+	CoverKeyID string        `json:"coverKeyID"` // 封面资源字段 ID，仅在封面来源为资源字段时使用
+	Fields     []*ViewField  `json:"fields"`     // 卡片字段
+	Filters    []*ViewFilter `json:"filters"`    // 过滤规则
+	Sorts      []*ViewSort   `json:"sorts"`      // 排序规则
+	PageSize   int           `json:"pageSize"`   // 每页卡片数
+}
+
+// CoverFrom 描述了画廊卡片封面的来源。
+type CoverFrom string
+
+const (
+	CoverFromNone     CoverFrom = "none"     // 无封面
+	CoverFromAssetKey CoverFrom = "assetKey" // 资源字段中的第一张图片
+	CoverFromContent  CoverFrom = "content"  // 绑定块内容中的第一张图片
+)
+
+// Gallery 描述了画廊实例的结构。
+type Gallery struct {
+	ID               string         `json:"id"`               // 画廊布局 ID
+	Icon             string         `json:"icon"`             // 画廊图标
+	Name             string         `json:"name"`             // 画廊名称
+	Desc             string         `json:"desc"`             // 画廊描述
+	HideAttrViewName bool           `json:"hideAttrViewName"` // 是否隐藏属性视图名称
+	CoverFrom        CoverFrom      `json:"coverFrom"`        // 封面来源
+	CoverKeyID       string         `json:"coverKeyID"`       // 封面资源字段 ID
+	Filters          []*ViewFilter  `json:"filters"`          // 过滤规则
+	Sorts            []*ViewSort    `json:"sorts"`            // 排序规则
+	Fields           []*TableColumn `json:"fields"`           // 卡片字段
+	Cards            []*GalleryCard `json:"cards"`            // 卡片
+	CardCount        int            `json:"cardCount"`        // 画廊总卡片数
+	PageSize         int            `json:"pageSize"`         // 每页卡片数
+
+	Rows []*TableRow `json:"-"` // 生成卡片前的行，过滤和排序复用表格的实现
+}
+
+type GalleryCard struct {
+	ID    string       `json:"id"`    // 卡片 ID（即行 ID）
+	Cover string       `json:"cover"` // 封面图片地址，为空时不显示封面
+	Cells []*TableCell `json:"cells"` // 单元格，和 Fields 一一对应
+}
+
+// GetBlockID 返回卡片绑定的块 ID，未绑定块时返回空字符串。
+func (card *GalleryCard) GetBlockID() string {
+	for _, cell := range card.Cells {
+		if nil != cell.Value && KeyTypeBlock == cell.Value.Type && nil != cell.Value.Block {
+			if cell.Value.IsDetached {
+				return ""
+			}
+			return cell.Value.BlockID
+		}
+	}
+	return ""
+}
+
+func (gallery *Gallery) GetType() LayoutType {
+	return LayoutTypeGallery
+}
+
+func (gallery *Gallery) GetID() string {
+	return gallery.ID
+}
+
+func (gallery *Gallery) FilterRows(attrView *AttributeView) {
+	table := gallery.rowsTable()
+	table.FilterRows(attrView)
+	gallery.Rows = table.Rows
+}
+
+func (gallery *Gallery) SortRows(attrView *AttributeView) {
+	table := gallery.rowsTable()
+	table.SortRows(attrView)
+	gallery.Rows = table.Rows
+}
+
+func (gallery *Gallery) CalcCols() {
+	// 画廊不支持字段计算
+}
+
+func (gallery *Gallery) rowsTable() *Table {
+	return &Table{Columns: gallery.Fields, Rows: gallery.Rows, Filters: gallery.Filters, Sorts: gallery.Sorts}
+}
+
+// BuildCards 将行转换为卡片，封面来源为资源字段时同时解析封面，绑定块内容中的封面需要在 model 中加载块树后解析。
+func (gallery *Gallery) BuildCards() {
+	gallery.CardCount = len(gallery.Rows)
+	gallery.Cards = []*GalleryCard{}
+
+	coverIndex := -1
+	if CoverFromAssetKey == gallery.CoverFrom {
+		for i, field := range gallery.Fields {
+			if field.ID == gallery.CoverKeyID && KeyTypeMAsset == field.Type {
+				coverIndex = i
+				break
+			}
+		}
+	}
+
+	for _, row := range gallery.Rows {
+		card := &GalleryCard{ID: row.ID, Cells: row.Cells}
+		if 0 <= coverIndex {
+			card.Cover = getFirstImageAsset(row.Cells[coverIndex].Value)
+		}
+		gallery.Cards = append(gallery.Cards, card)
+	}
+}
+
+func getFirstImageAsset(val *Value) string {
+	if nil == val {
+		return ""
+	}
+
+	for _, asset := range val.MAsset {
+		if AssetTypeImage == asset.Type && "" != asset.Content {
+			return asset.Content
+		}
+	}
+	return ""
+}
@@ -0,0 +1,188 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 推断为单选字段时不同值的最大数量
+const inferSelectMaxOptions = 16
+
+// InferKeyType 根据导入的列内容推断字段类型，空值不参与推断。
+func InferKeyType(contents []string) KeyType {
+	var nonEmpty []string
+	for _, content := range contents {
+		if content = strings.TrimSpace(content); "" != content {
+			nonEmpty = append(nonEmpty, content)
+		}
+	}
+	if 1 > len(nonEmpty) {
+		return KeyTypeText
+	}
+
+	allOf := func(fn func(string) bool) bool {
+		for _, content := range nonEmpty {
+			if !fn(content) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if allOf(func(s string) bool { _, ok := parseImportCheckbox(s); return ok }) {
+		return KeyTypeCheckbox
+	}
+	if allOf(func(s string) bool { _, ok := parseImportNumber(s); return ok }) {
+		return KeyTypeNumber
+	}
+	if allOf(func(s string) bool { _, _, ok := ParseImportDate(s); return ok }) {
+		return KeyTypeDate
+	}
+	if allOf(isImportURL) {
+		return KeyTypeURL
+	}
+	if allOf(isImportEmail) {
+		return KeyTypeEmail
+	}
+
+	// 重复值较多且不同值较少时推断为单选
+	distinct := map[string]bool{}
+	for _, content := range nonEmpty {
+		distinct[content] = true
+	}
+	if len(distinct) <= inferSelectMaxOptions && len(distinct) < len(nonEmpty) {
+		return KeyTypeSelect
+	}
+	return KeyTypeText
+}
+
+// NewValueFromImport 使用导入的文本内容创建字段值，单选和多选字段中不存在的选项会添加到字段中。
+// 不支持导入的字段类型或者内容无法解析时返回 nil。
+func NewValueFromImport(key *Key, content string) (ret *Value) {
+	content = strings.TrimSpace(content)
+	if "" == content && KeyTypeBlock != key.Type {
+		return
+	}
+
+	ret = &Value{KeyID: key.ID, Type: key.Type}
+	switch key.Type {
+	case KeyTypeBlock:
+		ret.Block = &ValueBlock{Content: content}
+	case KeyTypeText:
+		ret.Text = &ValueText{Content: content}
+	case KeyTypeNumber:
+		number, ok := parseImportNumber(content)
+		if !ok {
+			return nil
+		}
+		ret.Number = NewFormattedValueNumber(number, key.NumberFormat)
+	case KeyTypeDate:
+		t, isNotTime, ok := ParseImportDate(content)
+		if !ok {
+			return nil
+		}
+		ret.Date = NewFormattedValueDate(t.UnixMilli(), 0, DateFormatNone, isNotTime, false)
+	case KeyTypeSelect, KeyTypeMSelect:
+		names := []string{content}
+		if KeyTypeMSelect == key.Type {
+			names = strings.Split(content, ",")
+		}
+		for _, name := range names {
+			if name = strings.TrimSpace(name); "" == name {
+				continue
+			}
+
+			opt := key.GetOption(name)
+			if nil == opt {
+				opt = &SelectOption{Name: name, Color: strconv.Itoa(len(key.Options)%13 + 1)}
+				key.Options = append(key.Options, opt)
+			}
+			ret.MSelect = append(ret.MSelect, &ValueSelect{Content: opt.Name, Color: opt.Color})
+		}
+		if 1 > len(ret.MSelect) {
+			return nil
+		}
+	case KeyTypeURL:
+		ret.URL = &ValueURL{Content: content}
+	case KeyTypeEmail:
+		ret.Email = &ValueEmail{Content: content}
+	case KeyTypePhone:
+		ret.Phone = &ValuePhone{Content: content}
+	case KeyTypeCheckbox:
+		checked, _ := parseImportCheckbox(content)
+		ret.Checkbox = &ValueCheckbox{Checked: checked}
+	default:
+		// 模板、关联、汇总、创建时间、更新时间、行号、公式和资源字段不支持导入
+		return nil
+	}
+	return
+}
+
+var importDateLayouts = []struct {
+	layout    string
+	isNotTime bool
+}{
+	{time.RFC3339, false},
+	{"2006-01-02 15:04:05", false},
+	{"2006-01-02 15:04", false},
+	{"2006/01/02 15:04:05", false},
+	{"2006/01/02 15:04", false},
+	{"2006-01-02", true},
+	{"2006/01/02", true},
+	{"2006.01.02", true},
+	{"20060102", true},
+}
+
+// ParseImportDate 解析导入的日期内容，isNotTime 为 true 时说明内容不包含时间。
+func ParseImportDate(content string) (ret time.Time, isNotTime, ok bool) {
+	for _, l := range importDateLayouts {
+		if t, err := time.ParseInLocation(l.layout, content, time.Local); nil == err {
+			return t, l.isNotTime, true
+		}
+	}
+	return
+}
+
+func parseImportNumber(content string) (ret float64, ok bool) {
+	content = strings.ReplaceAll(content, ",", "")
+	ret, err := strconv.ParseFloat(content, 64)
+	return ret, nil == err
+}
+
+func parseImportCheckbox(content string) (checked, ok bool) {
+	switch strings.ToLower(content) {
+	case "true", "yes", "y", "checked", "✓", "✔", "√":
+		return true, true
+	case "false", "no", "n", "unchecked", "✗", "×":
+		return false, true
+	}
+	return
+}
+
+func isImportURL(content string) bool {
+	lower := strings.ToLower(content)
+	return (strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")) && !strings.ContainsAny(content, " \t\n")
+}
+
+func isImportEmail(content string) bool {
+	addr, err := mail.ParseAddress(content)
+	return nil == err && addr.Address == content
+}
@@ -271,6 +271,34 @@ func (value *Value) Compare(other *Value, attrView *AttributeView) int {
 			}
 			return 1
 		}
+	case KeyTypeFormula:
+		if nil != value.Formula && nil != other.Formula {
+			// 按照公式结果类型比较，空结果排在最后
+			v1, v2 := value.Formula.GetResult(), other.Formula.GetResult()
+			if nil == v1 || v1.IsEmpty() {
+				if nil == v2 || v2.IsEmpty() {
+					return 0
+				}
+				return 1
+			} else if nil == v2 || v2.IsEmpty() {
+				return -1
+			}
+
+			if v1.Type == v2.Type {
+				return v1.Compare(v2, attrView)
+			}
+			return strings.Compare(v1.String(false), v2.String(false))
+		}
+	case KeyTypeUniqueID:
+		if nil != value.UniqueID && nil != other.UniqueID {
+			if value.UniqueID.Number > other.UniqueID.Number {
+				return 1
+			}
+			if value.UniqueID.Number < other.UniqueID.Number {
+				return -1
+			}
+			return 0
+		}
 	case KeyTypeCheckbox:
 		if nil != value.Checkbox && nil != other.Checkbox {
 			if value.Checkbox.Checked && !other.Checkbox.Checked {
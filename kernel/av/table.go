@@ -25,11 +25,12 @@ type LayoutTable struct {
 	Spec int    `json:"spec"` // 布局格式版本
 	ID   string `json:"id"`   // 布局 ID
 
-	Columns  []*ViewTableColumn `json:"columns"`  // 表格列
-	RowIDs   []string           `json:"rowIds"`   // 行 ID，用于自定义排序
-	Filters  []*ViewFilter      `json:"filters"`  // 过滤规则
-	Sorts    []*ViewSort        `json:"sorts"`    // 排序规则
-	PageSize int                `json:"pageSize"` // 每页行数
+	Columns  []*ViewTableColumn `json:"columns"`           // 表格列
+	RowIDs   []string           `json:"rowIds"`            // 行 ID，用于自定义排序
+	Filters  []*ViewFilter      `json:"filters"`           // 过滤规则
+	Sorts    []*ViewSort        `json:"sorts"`             // 排序规则
+	PageSize int                `json:"pageSize"`          // 每页行数
+	GroupBy  *ViewGroupBy       `json:"groupBy,omitempty"` // 分组规则
 }
 
 type ViewTableColumn struct {
@@ -56,6 +57,8 @@ type Table struct {
 	Rows             []*TableRow    `json:"rows"`             // 表格行
 	RowCount         int            `json:"rowCount"`         // 表格总行数
 	PageSize         int            `json:"pageSize"`         // 每页行数
+	GroupBy          *ViewGroupBy   `json:"groupBy"`          // 分组规则
+	Groups           []*TableGroup  `json:"groups,omitempty"` // 分组，设置分组规则后行按分组返回
 }
 
 type TableColumn struct {
@@ -75,6 +78,7 @@ type TableColumn struct {
 	Options      []*SelectOption `json:"options,omitempty"`  // 选项列表
 	NumberFormat NumberFormat    `json:"numberFormat"`       // 数字列格式化
 	Template     string          `json:"template"`           // 模板列内容
+	Formula      string          `json:"formula,omitempty"`  // 公式列内容
 	Relation     *Relation       `json:"relation,omitempty"` // 关联列
 	Rollup       *Rollup         `json:"rollup,omitempty"`   // 汇总列
 	Date         *Date           `json:"date,omitempty"`     // 日期设置
@@ -248,46 +252,60 @@ func (table *Table) FilterRows(attrView *AttributeView) {
 		return
 	}
 
-	var colIndexes []int
-	for _, f := range table.Filters {
-		for i, c := range table.Columns {
-			if c.ID == f.Column {
-				colIndexes = append(colIndexes, i)
-				break
-			}
-		}
+	colIndexes := map[string]int{}
+	for i, c := range table.Columns {
+		colIndexes[c.ID] = i
 	}
 
 	rows := []*TableRow{}
 	attrViewCache := map[string]*AttributeView{}
 	attrViewCache[attrView.ID] = attrView
 	for _, row := range table.Rows {
-		pass := true
-		for j, index := range colIndexes {
-			operator := table.Filters[j].Operator
-
-			if nil == row.Cells[index].Value {
-				if FilterOperatorIsNotEmpty == operator {
-					pass = false
-				} else if FilterOperatorIsEmpty == operator {
-					pass = true
-					break
-				}
+		// 视图中的过滤规则列表作为最外层的 AND 过滤组
+		if pass, _ := table.filterRow(row, table.Filters, FilterConjunctionAnd, colIndexes, attrView, &attrViewCache); pass {
+			rows = append(rows, row)
+		}
+	}
+	table.Rows = rows
+}
 
-				if KeyTypeText != row.Cells[index].ValueType {
-					pass = false
-				}
-				break
-			}
+// filterRow 使用过滤组中的过滤规则过滤行，effective 为 false 时说明过滤组中没有生效的过滤规则。
+func (table *Table) filterRow(row *TableRow, filters []*ViewFilter, conjunction FilterConjunction, colIndexes map[string]int, attrView *AttributeView, attrViewCache *map[string]*AttributeView) (pass, effective bool) {
+	for _, filter := range filters {
+		var filterPass, filterEffective bool
+		if filter.IsGroup() {
+			filterPass, filterEffective = table.filterRow(row, filter.Filters, filter.Conjunction, colIndexes, attrView, attrViewCache)
+		} else if index, ok := colIndexes[filter.Column]; ok {
+			filterPass, filterEffective = filterCell(row.Cells[index], filter, attrView, row.ID, attrViewCache), true
+		}
+		if !filterEffective {
+			continue
+		}
 
-			if !row.Cells[index].Value.Filter(table.Filters[j], attrView, row.ID, &attrViewCache) {
-				pass = false
-				break
-			}
+		effective = true
+		if FilterConjunctionOr == conjunction && filterPass {
+			return true, true
 		}
-		if pass {
-			rows = append(rows, row)
+		if FilterConjunctionOr != conjunction && !filterPass {
+			return false, true
 		}
 	}
-	table.Rows = rows
+
+	if !effective {
+		return true, false
+	}
+	return FilterConjunctionOr != conjunction, true
+}
+
+func filterCell(cell *TableCell, filter *ViewFilter, attrView *AttributeView, rowID string, attrViewCache *map[string]*AttributeView) bool {
+	if nil == cell.Value {
+		switch filter.Operator {
+		case FilterOperatorIsNotEmpty:
+			return false
+		case FilterOperatorIsEmpty:
+			return true
+		}
+		return KeyTypeText == cell.ValueType
+	}
+	return cell.Value.Filter(filter, attrView, rowID, attrViewCache)
 }
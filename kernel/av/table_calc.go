@@ -66,10 +66,56 @@ func (table *Table) CalcCols() {
 			table.calcColRelation(col, i)
 		case KeyTypeRollup:
 			table.calcColRollup(col, i)
+		case KeyTypeFormula:
+			table.calcColFormula(col, i)
+		case KeyTypeUniqueID:
+			table.calcColUniqueID(col, i)
 		}
 	}
 }
 
+// calcColFormula 按照公式结果类型计算，结果类型以第一个非空结果为准。
+func (table *Table) calcColFormula(col *TableColumn, colIndex int) {
+	var resultType KeyType
+	for _, row := range table.Rows {
+		if nil != row.Cells[colIndex] && nil != row.Cells[colIndex].Value {
+			if result := row.Cells[colIndex].Value.Formula.GetResult(); nil != result {
+				resultType = result.Type
+				break
+			}
+		}
+	}
+	if "" == resultType {
+		resultType = KeyTypeText
+	}
+
+	resultTable := &Table{Columns: []*TableColumn{{ID: col.ID, Type: resultType, NumberFormat: col.NumberFormat, Calc: col.Calc}}}
+	for _, row := range table.Rows {
+		var result *Value
+		if nil != row.Cells[colIndex] && nil != row.Cells[colIndex].Value {
+			result = row.Cells[colIndex].Value.Formula.GetResult()
+		}
+		if nil == result || result.Type != resultType {
+			result = getFormulaEmptyResult(resultType)
+		}
+		resultTable.Rows = append(resultTable.Rows, &TableRow{ID: row.ID, Cells: []*TableCell{{Value: result, ValueType: resultType}}})
+	}
+	resultTable.CalcCols()
+}
+
+// calcColUniqueID 按照编号数字计算。
+func (table *Table) calcColUniqueID(col *TableColumn, colIndex int) {
+	numberTable := &Table{Columns: []*TableColumn{{ID: col.ID, Type: KeyTypeNumber, Calc: col.Calc}}}
+	for _, row := range table.Rows {
+		number := &ValueNumber{}
+		if nil != row.Cells[colIndex] && nil != row.Cells[colIndex].Value && nil != row.Cells[colIndex].Value.UniqueID && 0 < row.Cells[colIndex].Value.UniqueID.Number {
+			number = NewFormattedValueNumber(float64(row.Cells[colIndex].Value.UniqueID.Number), NumberFormatNone)
+		}
+		numberTable.Rows = append(numberTable.Rows, &TableRow{ID: row.ID, Cells: []*TableCell{{Value: &Value{Type: KeyTypeNumber, Number: number}, ValueType: KeyTypeNumber}}})
+	}
+	numberTable.CalcCols()
+}
+
 func (table *Table) calcColTemplate(col *TableColumn, colIndex int) {
 	switch col.Calc.Operator {
 	case CalcOperatorCountAll:
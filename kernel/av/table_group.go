@@ -0,0 +1,200 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ViewGroupBy 描述了表格分组规则。
+type ViewGroupBy struct {
+	Column    string    `json:"column"`             // 分组列 ID
+	Order     SortOrder `json:"order"`              // 分组排序顺序
+	HideEmpty bool      `json:"hideEmpty"`          // 是否隐藏空值分组
+	DateUnit  DateUnit  `json:"dateUnit,omitempty"` // 日期分组单位，仅在分组列为日期、创建时间和更新时间时使用
+}
+
+// TableGroup 描述了表格分组实例的结构。
+type TableGroup struct {
+	ID       string        `json:"id"`       // 分组 ID，选项为选项名称，复选框为 true/false，日期为分组开始时间，空值分组为空字符串
+	Name     string        `json:"name"`     // 分组名称
+	Color    string        `json:"color"`    // 分组颜色
+	Rows     []*TableRow   `json:"rows"`     // 分组行
+	RowCount int           `json:"rowCount"` // 分组总行数
+	Calcs    []*ColumnCalc `json:"calcs"`    // 分组计算结果，和 Columns 一一对应
+
+	sortValue *Value // 分组排序使用的值
+	sortIndex int    // 选项分组使用选项顺序排序
+}
+
+// GroupRows 按照分组规则将行分配到分组中，并计算每个分组的列计算结果。
+func (table *Table) GroupRows(attrView *AttributeView, groupBy *ViewGroupBy) {
+	table.Groups = nil
+	if nil == groupBy {
+		return
+	}
+
+	colIndex := -1
+	var col *TableColumn
+	for i, c := range table.Columns {
+		if c.ID == groupBy.Column {
+			colIndex, col = i, c
+			break
+		}
+	}
+	if 0 > colIndex {
+		return
+	}
+
+	table.GroupBy = groupBy
+	var groupIDs []string
+	groups := map[string]*TableGroup{}
+	addGroup := func(id, name, color string, sortValue *Value) *TableGroup {
+		if group := groups[id]; nil != group {
+			return group
+		}
+		group := &TableGroup{ID: id, Name: name, Color: color, Rows: []*TableRow{}, sortValue: sortValue, sortIndex: len(groupIDs)}
+		groups[id] = group
+		groupIDs = append(groupIDs, id)
+		return group
+	}
+
+	switch col.Type {
+	case KeyTypeSelect, KeyTypeMSelect:
+		// 选项分组即使为空也需要显示
+		for _, opt := range col.Options {
+			addGroup(opt.Name, opt.Name, opt.Color, nil)
+		}
+	case KeyTypeCheckbox:
+		addGroup(BoardLaneUnchecked, BoardLaneUnchecked, "", nil)
+		addGroup(BoardLaneChecked, BoardLaneChecked, "", nil)
+	}
+
+	for _, row := range table.Rows {
+		val := row.Cells[colIndex].Value
+		var rowGroups []*TableGroup
+		switch col.Type {
+		case KeyTypeSelect, KeyTypeMSelect:
+			if nil != val {
+				for _, opt := range val.MSelect {
+					if "" != opt.Content {
+						rowGroups = append(rowGroups, addGroup(opt.Content, opt.Content, opt.Color, nil))
+					}
+				}
+			}
+		case KeyTypeCheckbox:
+			if nil != val && nil != val.Checkbox && val.Checkbox.Checked {
+				rowGroups = append(rowGroups, groups[BoardLaneChecked])
+			} else {
+				rowGroups = append(rowGroups, groups[BoardLaneUnchecked])
+			}
+		case KeyTypeDate, KeyTypeCreated, KeyTypeUpdated:
+			if start, _, _, ok := getCellDateRange(row.Cells[colIndex]); ok {
+				unit := groupBy.DateUnit
+				if "" == unit {
+					unit = DateUnitDay
+				}
+				bucket := truncateDate(time.UnixMilli(start), unit)
+				id := strconv.FormatInt(bucket.UnixMilli(), 10)
+				sortValue := &Value{Type: KeyTypeNumber, Number: &ValueNumber{Content: float64(bucket.UnixMilli()), IsNotEmpty: true}}
+				rowGroups = append(rowGroups, addGroup(id, formatGroupDate(bucket, unit), "", sortValue))
+			}
+		default:
+			if nil != val && !val.IsEmpty() {
+				content := val.String(true)
+				if "" != content {
+					rowGroups = append(rowGroups, addGroup(content, content, "", val))
+				}
+			}
+		}
+
+		if 1 > len(rowGroups) {
+			rowGroups = append(rowGroups, addGroup("", "", "", nil))
+		}
+		added := map[*TableGroup]bool{}
+		for _, group := range rowGroups {
+			if !added[group] {
+				added[group] = true
+				group.Rows = append(group.Rows, row)
+			}
+		}
+	}
+
+	for _, id := range groupIDs {
+		group := groups[id]
+		if groupBy.HideEmpty && ("" == group.ID || 1 > len(group.Rows)) {
+			continue
+		}
+		group.RowCount = len(group.Rows)
+		table.Groups = append(table.Groups, group)
+	}
+
+	less := func(gi, gj *TableGroup) bool {
+		if nil != gi.sortValue && nil != gj.sortValue {
+			return 0 > gi.sortValue.Compare(gj.sortValue, attrView)
+		}
+		return gi.sortIndex < gj.sortIndex
+	}
+	sort.SliceStable(table.Groups, func(i, j int) bool {
+		gi, gj := table.Groups[i], table.Groups[j]
+		// 空值分组始终在最后
+		if "" == gi.ID || "" == gj.ID {
+			return "" != gi.ID && "" == gj.ID
+		}
+
+		if SortOrderDesc == groupBy.Order {
+			// 降序时交换比较参数，相等的分组保持原有顺序
+			return less(gj, gi)
+		}
+		return less(gi, gj)
+	})
+
+	table.calcGroups()
+}
+
+// calcGroups 使用分组中的行计算每个分组的列计算结果。
+func (table *Table) calcGroups() {
+	for _, group := range table.Groups {
+		groupTable := &Table{Rows: group.Rows}
+		for _, col := range table.Columns {
+			groupCol := *col
+			if nil != col.Calc {
+				groupCol.Calc = &ColumnCalc{Operator: col.Calc.Operator}
+			}
+			groupTable.Columns = append(groupTable.Columns, &groupCol)
+		}
+		groupTable.CalcCols()
+
+		group.Calcs = []*ColumnCalc{}
+		for _, col := range groupTable.Columns {
+			group.Calcs = append(group.Calcs, col.Calc)
+		}
+	}
+}
+
+func formatGroupDate(t time.Time, unit DateUnit) string {
+	switch unit {
+	case DateUnitWeek:
+		year, week := t.ISOWeek()
+		return strconv.Itoa(year) + "-W" + strconv.Itoa(week)
+	case DateUnitMonth:
+		return t.Format("2006-01")
+	}
+	return t.Format("2006-01-02")
+}
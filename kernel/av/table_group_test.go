@@ -0,0 +1,133 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func groupIDs(table *Table) (ret []string) {
+	ret = []string{}
+	for _, group := range table.Groups {
+		ret = append(ret, group.ID)
+	}
+	return
+}
+
+func numberValue(n float64) *Value {
+	return &Value{Type: KeyTypeNumber, Number: NewFormattedValueNumber(n, NumberFormatNone)}
+}
+
+func millis(year int, month time.Month, day, hour int) int64 {
+	return time.Date(year, month, day, hour, 0, 0, 0, time.Local).UnixMilli()
+}
+
+func dateValue(content int64, isNotTime bool) *Value {
+	return &Value{Type: KeyTypeDate, Date: &ValueDate{Content: content, IsNotEmpty: true, IsNotTime: isNotTime}}
+}
+
+func newNumberGroupTable() *Table {
+	return &Table{
+		Columns: []*TableColumn{{ID: "key-number", Type: KeyTypeNumber, Calc: &ColumnCalc{Operator: CalcOperatorSum}}},
+		Rows: []*TableRow{
+			{ID: "row-1", Cells: []*TableCell{{Value: numberValue(2)}}},
+			{ID: "row-2", Cells: []*TableCell{{Value: nil}}},
+			{ID: "row-3", Cells: []*TableCell{{Value: numberValue(10)}}},
+			{ID: "row-4", Cells: []*TableCell{{Value: numberValue(2)}}},
+			{ID: "row-5", Cells: []*TableCell{{Value: numberValue(1)}}},
+		},
+	}
+}
+
+func TestTableGroupRowsOrder(t *testing.T) {
+	table := newNumberGroupTable()
+	table.GroupRows(&AttributeView{}, &ViewGroupBy{Column: "key-number", Order: SortOrderAsc})
+	if ids := groupIDs(table); !reflect.DeepEqual([]string{"1", "2", "10", ""}, ids) {
+		t.Fatalf("unexpected ascending groups %v", ids)
+	}
+
+	table = newNumberGroupTable()
+	table.GroupRows(&AttributeView{}, &ViewGroupBy{Column: "key-number", Order: SortOrderDesc})
+	if ids := groupIDs(table); !reflect.DeepEqual([]string{"10", "2", "1", ""}, ids) {
+		t.Fatalf("unexpected descending groups %v", ids)
+	}
+
+	group := table.Groups[1]
+	if 2 != group.RowCount || "row-1" != group.Rows[0].ID || "row-4" != group.Rows[1].ID {
+		t.Fatalf("unexpected rows in group [%s]", group.ID)
+	}
+	if 1 != len(group.Calcs) || 4 != group.Calcs[0].Result.Number.Content {
+		t.Fatalf("expected group sum 4, got [%+v]", group.Calcs[0].Result)
+	}
+	if nil != table.Columns[0].Calc.Result {
+		t.Fatalf("group calcs should not change the column calc")
+	}
+}
+
+func TestTableGroupRowsDescendingStable(t *testing.T) {
+	// 降序时值相等的分组（选项分组没有排序值）需要保持选项顺序的逆序，且比较必须满足严格弱序
+	col := &TableColumn{ID: "key-select", Type: KeyTypeSelect, Options: []*SelectOption{{Name: "a"}, {Name: "b"}, {Name: "c"}}}
+	table := &Table{Columns: []*TableColumn{col}, Rows: []*TableRow{
+		{ID: "row-1", Cells: []*TableCell{{Value: &Value{Type: KeyTypeSelect, MSelect: []*ValueSelect{{Content: "b"}}}}}},
+		{ID: "row-2", Cells: []*TableCell{{Value: nil}}},
+	}}
+	table.GroupRows(&AttributeView{}, &ViewGroupBy{Column: col.ID, Order: SortOrderDesc})
+	if ids := groupIDs(table); !reflect.DeepEqual([]string{"c", "b", "a", ""}, ids) {
+		t.Fatalf("unexpected descending select groups %v", ids)
+	}
+
+	table.GroupRows(&AttributeView{}, &ViewGroupBy{Column: col.ID, Order: SortOrderAsc, HideEmpty: true})
+	if ids := groupIDs(table); !reflect.DeepEqual([]string{"b"}, ids) {
+		t.Fatalf("expected empty groups hidden, got %v", ids)
+	}
+}
+
+func TestTableGroupRowsDate(t *testing.T) {
+	table := &Table{
+		Columns: []*TableColumn{{ID: "key-date", Type: KeyTypeDate}},
+		Rows: []*TableRow{
+			{ID: "row-1", Cells: []*TableCell{{Value: dateValue(millis(2024, 3, 20, 10), false)}}},
+			{ID: "row-2", Cells: []*TableCell{{Value: dateValue(millis(2024, 1, 5, 10), false)}}},
+			{ID: "row-3", Cells: []*TableCell{{Value: dateValue(millis(2024, 3, 2, 10), false)}}},
+		},
+	}
+	table.GroupRows(&AttributeView{}, &ViewGroupBy{Column: "key-date", DateUnit: DateUnitMonth})
+
+	if 2 != len(table.Groups) {
+		t.Fatalf("expected 2 month groups, got %d", len(table.Groups))
+	}
+	if "2024-01" != table.Groups[0].Name || "2024-03" != table.Groups[1].Name || 2 != table.Groups[1].RowCount {
+		t.Fatalf("unexpected month groups [%s] [%s]", table.Groups[0].Name, table.Groups[1].Name)
+	}
+}
+
+func TestTableGroupRowsMissingColumn(t *testing.T) {
+	table := newNumberGroupTable()
+	table.GroupRows(&AttributeView{}, &ViewGroupBy{Column: "missing"})
+	if nil != table.Groups || nil != table.GroupBy {
+		t.Fatalf("expected no groups for missing column")
+	}
+}
+
+func TestFormatGroupDate(t *testing.T) {
+	date := time.Date(2024, 3, 4, 0, 0, 0, 0, time.Local)
+	if "2024-W10" != formatGroupDate(date, DateUnitWeek) || "2024-03" != formatGroupDate(date, DateUnitMonth) || "2024-03-04" != formatGroupDate(date, DateUnitDay) {
+		t.Fatalf("unexpected group date names")
+	}
+}
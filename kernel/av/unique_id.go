@@ -0,0 +1,139 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/88250/lute/ast"
+)
+
+// UniqueID 描述了唯一 ID 字段的设置。
+type UniqueID struct {
+	Prefix     string `json:"prefix"`     // 编号前缀，比如 BUG-
+	NextNumber int64  `json:"nextNumber"` // 下一个分配的编号，删除行后已分配的编号不会被重用
+}
+
+type ValueUniqueID struct {
+	Number  int64  `json:"number"`  // 编号
+	Content string `json:"content"` // 带前缀的编号
+}
+
+func NewValueUniqueID(prefix string, number int64) *ValueUniqueID {
+	return &ValueUniqueID{Number: number, Content: prefix + strconv.FormatInt(number, 10)}
+}
+
+// fillUniqueIDs 为还没有编号的行按照行添加顺序分配编号，并使用当前前缀刷新已有编号。
+func (av *AttributeView) fillUniqueIDs(kv *KeyValues, now int64) {
+	if nil == kv.Key.UniqueID {
+		kv.Key.UniqueID = &UniqueID{}
+	}
+	setting := kv.Key.UniqueID
+	if 1 > setting.NextNumber {
+		setting.NextNumber = 1
+	}
+
+	numbered := map[string]bool{}
+	for _, v := range kv.Values {
+		if nil == v.UniqueID || 1 > v.UniqueID.Number {
+			continue
+		}
+
+		numbered[v.BlockID] = true
+		v.UniqueID = NewValueUniqueID(setting.Prefix, v.UniqueID.Number)
+		if setting.NextNumber <= v.UniqueID.Number {
+			// 导入或者复制的数据中可能存在更大的编号
+			setting.NextNumber = v.UniqueID.Number + 1
+		}
+	}
+
+	blockValues := av.GetBlockKeyValues()
+	if nil == blockValues {
+		return
+	}
+
+	values := kv.Values[:0]
+	for _, v := range kv.Values {
+		if nil != v.UniqueID && 0 < v.UniqueID.Number {
+			values = append(values, v)
+		}
+	}
+	kv.Values = values
+
+	for _, blockValue := range blockValues.Values {
+		if numbered[blockValue.BlockID] {
+			continue
+		}
+
+		kv.Values = append(kv.Values, &Value{
+			ID:         ast.NewNodeID(),
+			KeyID:      kv.Key.ID,
+			BlockID:    blockValue.BlockID,
+			Type:       KeyTypeUniqueID,
+			IsDetached: blockValue.IsDetached,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+			UniqueID:   NewValueUniqueID(setting.Prefix, setting.NextNumber),
+		})
+		numbered[blockValue.BlockID] = true
+		setting.NextNumber++
+	}
+}
+
+func (value *ValueUniqueID) filter(other *ValueUniqueID, operator FilterOperator) bool {
+	switch operator {
+	case FilterOperatorIsEmpty:
+		return 1 > value.Number
+	case FilterOperatorIsNotEmpty:
+		return 0 < value.Number
+	}
+
+	if nil == other {
+		return true
+	}
+
+	switch operator {
+	case FilterOperatorIsEqual:
+		if 1 > other.Number {
+			return "" == other.Content || strings.EqualFold(value.Content, other.Content)
+		}
+		return value.Number == other.Number
+	case FilterOperatorIsNotEqual:
+		if 1 > other.Number {
+			return "" == other.Content || !strings.EqualFold(value.Content, other.Content)
+		}
+		return value.Number != other.Number
+	case FilterOperatorIsGreater:
+		return value.Number > other.Number
+	case FilterOperatorIsGreaterOrEqual:
+		return value.Number >= other.Number
+	case FilterOperatorIsLess:
+		return value.Number < other.Number
+	case FilterOperatorIsLessOrEqual:
+		return value.Number <= other.Number
+	case FilterOperatorContains:
+		return strings.Contains(strings.ToLower(value.Content), strings.ToLower(other.Content))
+	case FilterOperatorDoesNotContain:
+		return !strings.Contains(strings.ToLower(value.Content), strings.ToLower(other.Content))
+	case FilterOperatorStartsWith:
+		return strings.HasPrefix(strings.ToLower(value.Content), strings.ToLower(other.Content))
+	case FilterOperatorEndsWith:
+		return strings.HasSuffix(strings.ToLower(value.Content), strings.ToLower(other.Content))
+	}
+	return false
+}
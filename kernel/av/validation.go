@@ -0,0 +1,246 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package av
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Validation 描述了字段的校验规则。
+type Validation struct {
+	Required    bool     `json:"required,omitempty"`    // 是否必填
+	Min         *float64 `json:"min,omitempty"`         // 数字最小值
+	Max         *float64 `json:"max,omitempty"`         // 数字最大值
+	Pattern     string   `json:"pattern,omitempty"`     // 正则表达式，仅用于主键、文本、链接、邮箱和电话字段
+	Unique      bool     `json:"unique,omitempty"`      // 值在数据库中是否唯一
+	OptionsOnly bool     `json:"optionsOnly,omitempty"` // 单选和多选是否仅允许使用已有选项
+}
+
+// ValidationRule 描述了违反的校验规则。
+type ValidationRule string
+
+const (
+	ValidationRuleRequired ValidationRule = "required"
+	ValidationRuleMin      ValidationRule = "min"
+	ValidationRuleMax      ValidationRule = "max"
+	ValidationRulePattern  ValidationRule = "pattern"
+	ValidationRuleUnique   ValidationRule = "unique"
+	ValidationRuleOption   ValidationRule = "option"
+)
+
+// Violation 描述了一个违反校验规则的单元格。
+type Violation struct {
+	RowID   string         `json:"rowID"`   // 行 ID
+	KeyID   string         `json:"keyID"`   // 字段 ID
+	KeyName string         `json:"keyName"` // 字段名
+	Rule    ValidationRule `json:"rule"`    // 违反的校验规则
+	Content string         `json:"content"` // 单元格内容
+}
+
+// ValidationError 描述了校验失败的错误，Violations 返回给前端用于提示。
+type ValidationError struct {
+	Violations []*Violation
+}
+
+func (err *ValidationError) Error() string {
+	var msgs []string
+	for _, v := range err.Violations {
+		msgs = append(msgs, fmt.Sprintf("[%s] violates rule [%s]", v.KeyName, v.Rule))
+	}
+	return strings.Join(msgs, ", ")
+}
+
+// CheckValidation 检查校验规则是否适用于字段类型。
+func CheckValidation(keyType KeyType, validation *Validation) (err error) {
+	if nil == validation {
+		return
+	}
+
+	if !isValidatableKeyType(keyType) {
+		return fmt.Errorf("key type [%s] does not support validation", keyType)
+	}
+	if (nil != validation.Min || nil != validation.Max) && KeyTypeNumber != keyType {
+		return fmt.Errorf("key type [%s] does not support min/max", keyType)
+	}
+	if nil != validation.Min && nil != validation.Max && *validation.Min > *validation.Max {
+		return fmt.Errorf("min [%v] is greater than max [%v]", *validation.Min, *validation.Max)
+	}
+	if "" != validation.Pattern {
+		switch keyType {
+		case KeyTypeBlock, KeyTypeText, KeyTypeURL, KeyTypeEmail, KeyTypePhone:
+		default:
+			return fmt.Errorf("key type [%s] does not support pattern", keyType)
+		}
+		if _, err = regexp.Compile(validation.Pattern); err != nil {
+			return
+		}
+	}
+	if validation.OptionsOnly && KeyTypeSelect != keyType && KeyTypeMSelect != keyType {
+		return fmt.Errorf("key type [%s] does not support options only", keyType)
+	}
+	return
+}
+
+// ValidateValue 使用字段校验规则校验行中的值，value 为空时说明该行还没有该字段的值。
+func (av *AttributeView) ValidateValue(key *Key, rowID string, value *Value) (ret []*Violation) {
+	validation := key.Validation
+	if nil == validation || !isValidatableKeyType(key.Type) {
+		return
+	}
+
+	var content string
+	if nil != value {
+		value.Type = key.Type
+		content = value.String(false)
+	}
+	violate := func(rule ValidationRule) {
+		ret = append(ret, &Violation{RowID: rowID, KeyID: key.ID, KeyName: key.Name, Rule: rule, Content: content})
+	}
+
+	if isValidationEmpty(value) {
+		if validation.Required {
+			violate(ValidationRuleRequired)
+		}
+		return
+	}
+
+	if KeyTypeNumber == key.Type {
+		if nil != validation.Min && value.Number.Content < *validation.Min {
+			violate(ValidationRuleMin)
+		}
+		if nil != validation.Max && value.Number.Content > *validation.Max {
+			violate(ValidationRuleMax)
+		}
+	}
+
+	if "" != validation.Pattern {
+		if re, err := regexp.Compile(validation.Pattern); nil == err && !re.MatchString(content) {
+			violate(ValidationRulePattern)
+		}
+	}
+
+	if validation.OptionsOnly {
+		for _, opt := range value.MSelect {
+			if nil == key.GetOption(opt.Content) {
+				violate(ValidationRuleOption)
+				break
+			}
+		}
+	}
+
+	if validation.Unique {
+		if keyValues, _ := av.GetKeyValues(key.ID); nil != keyValues {
+			for _, v := range keyValues.Values {
+				if v.BlockID != rowID && !isValidationEmpty(v) && v.String(false) == content {
+					violate(ValidationRuleUnique)
+					break
+				}
+			}
+		}
+	}
+	return
+}
+
+// ValidateRows 校验将要添加的行，rowIDs 和 rowsValues 一一对应，唯一性校验同时考虑同一批次中的行。
+func (av *AttributeView) ValidateRows(rowIDs []string, rowsValues [][]*Value) (ret []*Violation) {
+	batchContents := map[string]map[string]bool{}
+	for i, values := range rowsValues {
+		for _, kv := range av.KeyValues {
+			if nil == kv.Key.Validation {
+				continue
+			}
+
+			var value *Value
+			for _, v := range values {
+				if v.KeyID == kv.Key.ID {
+					value = v
+					break
+				}
+			}
+
+			violations := av.ValidateValue(kv.Key, rowIDs[i], value)
+			ret = append(ret, violations...)
+			if !kv.Key.Validation.Unique || 0 < len(violations) || isValidationEmpty(value) {
+				continue
+			}
+
+			contents := batchContents[kv.Key.ID]
+			if nil == contents {
+				contents = map[string]bool{}
+				batchContents[kv.Key.ID] = contents
+			}
+			content := value.String(false)
+			if contents[content] {
+				ret = append(ret, &Violation{RowID: rowIDs[i], KeyID: kv.Key.ID, KeyName: kv.Key.Name, Rule: ValidationRuleUnique, Content: content})
+			}
+			contents[content] = true
+		}
+	}
+	return
+}
+
+// GetViolations 返回数据库中所有违反字段校验规则的单元格。
+func (av *AttributeView) GetViolations() (ret []*Violation) {
+	ret = []*Violation{}
+	blockValues := av.GetBlockKeyValues()
+	if nil == blockValues {
+		return
+	}
+
+	for _, kv := range av.KeyValues {
+		if nil == kv.Key.Validation {
+			continue
+		}
+
+		values := map[string]*Value{}
+		for _, v := range kv.Values {
+			values[v.BlockID] = v
+		}
+		for _, blockValue := range blockValues.Values {
+			ret = append(ret, av.ValidateValue(kv.Key, blockValue.BlockID, values[blockValue.BlockID])...)
+		}
+	}
+	return
+}
+
+// isValidatableKeyType 判断字段类型是否支持校验，自动生成值的字段不支持校验。
+func isValidatableKeyType(keyType KeyType) bool {
+	switch keyType {
+	case KeyTypeBlock, KeyTypeText, KeyTypeNumber, KeyTypeDate, KeyTypeSelect, KeyTypeMSelect, KeyTypeURL, KeyTypeEmail,
+		KeyTypePhone, KeyTypeMAsset, KeyTypeCheckbox, KeyTypeRelation:
+		return true
+	}
+	return false
+}
+
+func isValidationEmpty(value *Value) bool {
+	if nil == value {
+		return true
+	}
+
+	switch value.Type {
+	case KeyTypeCheckbox:
+		// 必填的勾选框需要勾选
+		return nil == value.Checkbox || !value.Checkbox.Checked
+	case KeyTypeRelation:
+		// 关联字段存储时不保存关联内容
+		return nil == value.Relation || 1 > len(value.Relation.BlockIDs)
+	}
+	return value.IsEmpty()
+}
@@ -56,6 +56,8 @@ type Value struct {
 	Checkbox *ValueCheckbox `json:"checkbox,omitempty"`
 	Relation *ValueRelation `json:"relation,omitempty"`
 	Rollup   *ValueRollup   `json:"rollup,omitempty"`
+	Formula  *ValueFormula  `json:"formula,omitempty"`
+	UniqueID *ValueUniqueID `json:"uniqueID,omitempty"`
 }
 
 func (value *Value) SetUpdatedAt(mills int64) {
@@ -174,6 +176,16 @@ func (value *Value) String(format bool) string {
 			ret = append(ret, v.String(format))
 		}
 		return strings.TrimSpace(strings.Join(ret, ", "))
+	case KeyTypeFormula:
+		if nil == value.Formula {
+			return ""
+		}
+		return value.Formula.GetResult().String(format)
+	case KeyTypeUniqueID:
+		if nil == value.UniqueID {
+			return ""
+		}
+		return value.UniqueID.Content
 	default:
 		return ""
 	}
@@ -290,6 +302,11 @@ func (value *Value) IsEmpty() bool {
 		return 1 > len(value.Relation.Contents)
 	case KeyTypeRollup:
 		return 1 > len(value.Rollup.Contents)
+	case KeyTypeFormula:
+		result := value.Formula.GetResult()
+		return nil == result || result.IsEmpty()
+	case KeyTypeUniqueID:
+		return nil == value.UniqueID || 1 > value.UniqueID.Number
 	}
 	return false
 }
@@ -328,6 +345,10 @@ func (value *Value) SetValByType(typ KeyType, val interface{}) {
 		value.Relation = val.(*ValueRelation)
 	case KeyTypeRollup:
 		value.Rollup = val.(*ValueRollup)
+	case KeyTypeFormula:
+		value.Formula = val.(*ValueFormula)
+	case KeyTypeUniqueID:
+		value.UniqueID = val.(*ValueUniqueID)
 	}
 }
 
@@ -365,6 +386,10 @@ func (value *Value) GetValByType(typ KeyType) (ret interface{}) {
 		return value.Relation
 	case KeyTypeRollup:
 		return value.Rollup
+	case KeyTypeFormula:
+		return value.Formula
+	case KeyTypeUniqueID:
+		return value.UniqueID
 	}
 	return
 }
@@ -953,6 +978,10 @@ func GetAttributeViewDefaultValue(valueID, keyID, blockID string, typ KeyType) (
 		ret.Relation = &ValueRelation{}
 	case KeyTypeRollup:
 		ret.Rollup = &ValueRollup{}
+	case KeyTypeFormula:
+		ret.Formula = &ValueFormula{}
+	case KeyTypeUniqueID:
+		ret.UniqueID = &ValueUniqueID{}
 	}
 	return
 }
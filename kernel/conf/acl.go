@@ -0,0 +1,35 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+// ACL 描述了一条笔记本或者文档访问控制规则，为命名账号授予笔记本或者文档子树的权限。
+//
+// 设置了规则的账号只能访问规则授予的笔记本和文档，没有设置规则的账号按角色访问整个工作空间，管理员不受规则限制。
+type ACL struct {
+	ID         string `json:"id"`         // ID，管理规则时使用
+	Account    string `json:"account"`    // 账号：token:{API token 名称}、oidc:{OpenID Connect subject} 或者 user:{userNo}
+	Box        string `json:"box"`        // 笔记本 ID
+	Path       string `json:"path"`       // 文档路径，比如 /20200812220555-lj3enxa/20210808180320-abcdefg.sy，作用于该文档及其子文档，为空时作用于整个笔记本
+	Permission string `json:"permission"` // 权限：read：只读，comment：只读并可以修改块备注，edit：编辑
+	Created    int64  `json:"created"`    // 创建时间（毫秒时间戳）
+}
+
+const (
+	ACLPermissionRead    = "read"
+	ACLPermissionComment = "comment"
+	ACLPermissionEdit    = "edit"
+)
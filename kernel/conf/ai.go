@@ -26,7 +26,8 @@ import (
 )
 
 type AI struct {
-	OpenAI *OpenAI `json:"openAI"`
+	OpenAI    *OpenAI    `json:"openAI"`
+	Embedding *Embedding `json:"embedding"`
 }
 
 type OpenAI struct {
@@ -43,6 +44,45 @@ type OpenAI struct {
 	APIVersion     string  `json:"apiVersion"`  // Azure API version
 }
 
+// Embedding 描述了语义搜索使用的文本向量化接口配置。
+type Embedding struct {
+	Enabled      bool    `json:"enabled"`      // 是否启用语义搜索
+	APIProvider  string  `json:"apiProvider"`  // OpenAI, Azure, Local
+	APIKey       string  `json:"apiKey"`       // 接口密钥，使用 Local 时可以为空
+	APITimeout   int     `json:"apiTimeout"`   // 请求超时时间，单位为秒
+	APIProxy     string  `json:"apiProxy"`     // 代理地址
+	APIModel     string  `json:"apiModel"`     // 向量模型
+	APIBaseURL   string  `json:"apiBaseURL"`   // 接口地址，使用 Local 时为本地服务的完整地址
+	APIUserAgent string  `json:"apiUserAgent"` // User-Agent
+	APIVersion   string  `json:"apiVersion"`   // Azure API version
+	HybridWeight float64 `json:"hybridWeight"` // 混合排序时语义相似度的权重，取值范围 [0, 1]，其余权重分配给全文搜索相关度
+}
+
+func NewEmbedding() *Embedding {
+	embedding := &Embedding{
+		APIProvider:  "OpenAI",
+		APITimeout:   30,
+		APIModel:     string(openai.SmallEmbedding3),
+		APIBaseURL:   "https://api.openai.com/v1",
+		APIUserAgent: util.UserAgent,
+		HybridWeight: 0.7,
+	}
+
+	if apiKey := os.Getenv("SIYUAN_EMBEDDING_API_KEY"); "" != apiKey {
+		embedding.APIKey = apiKey
+		embedding.Enabled = true
+	}
+
+	if baseURL := os.Getenv("SIYUAN_EMBEDDING_API_BASE_URL"); "" != baseURL {
+		embedding.APIBaseURL = baseURL
+	}
+
+	if model := os.Getenv("SIYUAN_EMBEDDING_API_MODEL"); "" != model {
+		embedding.APIModel = model
+	}
+	return embedding
+}
+
 func NewAI() *AI {
 	openAI := &OpenAI{
 		APITemperature: 1.0,
@@ -95,5 +135,5 @@ func NewAI() *AI {
 	if userAgent := os.Getenv("SIYUAN_OPENAI_API_USER_AGENT"); "" != userAgent {
 		openAI.APIUserAgent = userAgent
 	}
-	return &AI{OpenAI: openAI}
+	return &AI{OpenAI: openAI, Embedding: NewEmbedding()}
 }
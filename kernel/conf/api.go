@@ -16,14 +16,138 @@
 
 package conf
 
-import "github.com/88250/gulu"
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+
+	"github.com/88250/gulu"
+)
 
 type API struct {
-	Token string `json:"token"`
+	Token  string      `json:"token"`  // 管理员 token
+	Tokens []*APIToken `json:"tokens"` // 命名 token，可以分别设置角色和访问范围
+}
+
+// APIToken 描述了一个命名 API token，每个 token 可以单独设置角色、访问范围和过期时间，也可以单独撤销。
+//
+// 配置文件中只保存 token 的 SHA-256 摘要，完整的 token 只在创建时返回一次。
+type APIToken struct {
+	ID       string   `json:"id"`              // ID，管理 token 时使用
+	Name     string   `json:"name"`            // 名称，比如使用该 token 的集成
+	Token    string   `json:"token,omitempty"` // token，仅在创建时返回，获取 token 列表时为脱敏后的前缀
+	Hash     string   `json:"hash,omitempty"`  // token 的 SHA-256 摘要（十六进制）
+	Prefix   string   `json:"prefix"`          // token 的前 4 个字符，用于辨认 token
+	Role     uint     `json:"role"`            // 角色：1：编辑者，2：读者
+	Boxes    []string `json:"boxes"`           // 允许访问的笔记本 ID，为空时不限制
+	Routes   []string `json:"routes"`          // 允许访问的 API 路由前缀，比如 /api/block/，为空时不限制
+	Expired  int64    `json:"expired"`         // 过期时间（毫秒时间戳），为 0 时永不过期
+	Created  int64    `json:"created"`         // 创建时间（毫秒时间戳）
+	LastUsed int64    `json:"lastUsed"`        // 最近使用时间（毫秒时间戳），为 0 时还没有使用过
 }
 
 func NewAPI() *API {
 	return &API{
-		Token: gulu.Rand.String(16),
+		Token:  gulu.Rand.String(16),
+		Tokens: []*APIToken{},
+	}
+}
+
+// HashAPIToken 返回 token 的 SHA-256 摘要（十六进制）。
+func HashAPIToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// HashTokens 将旧版本配置中明文保存的命名 token 转换为摘要，返回是否有转换。
+func (api *API) HashTokens() (ret bool) {
+	for _, apiToken := range api.Tokens {
+		if "" == apiToken.Token {
+			continue
+		}
+
+		apiToken.Hash = HashAPIToken(apiToken.Token)
+		apiToken.Prefix = gulu.Str.SubStr(apiToken.Token, 4)
+		apiToken.Token = ""
+		ret = true
+	}
+	return
+}
+
+// IsAdminToken 判断 token 是否是管理员 token，使用常量时间比较避免通过响应时间猜测 token。
+func (api *API) IsAdminToken(token string) bool {
+	if "" == token || "" == api.Token {
+		return false
+	}
+	return 1 == subtle.ConstantTimeCompare([]byte(token), []byte(api.Token))
+}
+
+// GetToken 返回和 token 匹配的命名 token，没有匹配时返回 nil。
+func (api *API) GetToken(token string) *APIToken {
+	if "" == token {
+		return nil
+	}
+
+	hash := []byte(HashAPIToken(token))
+	for _, apiToken := range api.Tokens {
+		if 1 == subtle.ConstantTimeCompare(hash, []byte(apiToken.Hash)) {
+			return apiToken
+		}
+	}
+	return nil
+}
+
+// RemoveToken 移除 ID 对应的命名 token，返回是否移除。
+func (api *API) RemoveToken(id string) bool {
+	for i, apiToken := range api.Tokens {
+		if apiToken.ID == id {
+			api.Tokens = append(api.Tokens[:i], api.Tokens[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// IsExpired 判断 token 在 now（毫秒时间戳）时是否已经过期。
+func (t *APIToken) IsExpired(now int64) bool {
+	return 0 < t.Expired && t.Expired < now
+}
+
+// IsRouteAllowed 判断 token 是否可以访问路由，没有设置路由前缀时可以访问所有路由。
+func (t *APIToken) IsRouteAllowed(path string) bool {
+	if 1 > len(t.Routes) {
+		return true
+	}
+
+	for _, route := range t.Routes {
+		if strings.HasPrefix(path, route) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBoxRestricted 判断 token 是否只能访问部分笔记本。
+func (t *APIToken) IsBoxRestricted() bool {
+	return 0 < len(t.Boxes)
+}
+
+// IsBoxesAllowed 判断 token 是否可以访问请求涉及的所有笔记本。
+//
+// 限制了笔记本时请求必须能够确定涉及的笔记本，boxIDs 为空时不能访问。
+func (t *APIToken) IsBoxesAllowed(boxIDs []string) bool {
+	if !t.IsBoxRestricted() {
+		return true
+	}
+
+	if 1 > len(boxIDs) {
+		return false
+	}
+	for _, boxID := range boxIDs {
+		if !gulu.Str.Contains(boxID, t.Boxes) {
+			return false
+		}
 	}
+	return true
 }
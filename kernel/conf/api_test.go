@@ -0,0 +1,135 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+import (
+	"testing"
+)
+
+func TestAPITokenHash(t *testing.T) {
+	api := &API{Tokens: []*APIToken{
+		{ID: "1", Token: "abcdefghijklmnopqrstuvwxyz012345"},
+		{ID: "2", Hash: HashAPIToken("0123456789abcdefghijklmnopqrstuv"), Prefix: "0123"},
+	}}
+
+	// 明文保存的 token 转换为摘要
+	if !api.HashTokens() {
+		t.Fatalf("expected plaintext token hashed")
+	}
+	if "" != api.Tokens[0].Token || HashAPIToken("abcdefghijklmnopqrstuvwxyz012345") != api.Tokens[0].Hash || "abcd" != api.Tokens[0].Prefix {
+		t.Fatalf("unexpected hashed token [%+v]", api.Tokens[0])
+	}
+	if api.HashTokens() {
+		t.Fatalf("expected no token hashed again")
+	}
+
+	if apiToken := api.GetToken("abcdefghijklmnopqrstuvwxyz012345"); nil == apiToken || "1" != apiToken.ID {
+		t.Fatalf("expected token 1 matched")
+	}
+	if apiToken := api.GetToken("0123456789abcdefghijklmnopqrstuv"); nil == apiToken || "2" != apiToken.ID {
+		t.Fatalf("expected token 2 matched")
+	}
+	for _, token := range []string{"", "abcd", "abcdefghijklmnopqrstuvwxyz01234", api.Tokens[0].Hash} {
+		if nil != api.GetToken(token) {
+			t.Fatalf("expected token [%s] not matched", token)
+		}
+	}
+
+	// 撤销后立即失效
+	if !api.RemoveToken("1") || nil != api.GetToken("abcdefghijklmnopqrstuvwxyz012345") {
+		t.Fatalf("expected revoked token not matched")
+	}
+	if api.RemoveToken("1") || 1 != len(api.Tokens) || nil == api.GetToken("0123456789abcdefghijklmnopqrstuv") {
+		t.Fatalf("expected other tokens kept")
+	}
+}
+
+func TestAPIAdminToken(t *testing.T) {
+	api := &API{Token: "0123456789abcdef"}
+	if !api.IsAdminToken("0123456789abcdef") {
+		t.Fatalf("expected admin token matched")
+	}
+	for _, token := range []string{"", "0123456789abcde", "0123456789abcdef0"} {
+		if api.IsAdminToken(token) {
+			t.Fatalf("expected token [%s] not matched", token)
+		}
+	}
+	if (&API{}).IsAdminToken("") {
+		t.Fatalf("expected empty admin token not matched")
+	}
+}
+
+func TestAPITokenExpired(t *testing.T) {
+	if (&APIToken{}).IsExpired(1000) {
+		t.Fatalf("expected token never expired")
+	}
+	if (&APIToken{Expired: 1000}).IsExpired(1000) {
+		t.Fatalf("expected token not expired at expired time")
+	}
+	if !(&APIToken{Expired: 1000}).IsExpired(1001) {
+		t.Fatalf("expected token expired")
+	}
+}
+
+func TestAPITokenRouteAllowed(t *testing.T) {
+	tests := []struct {
+		routes []string
+		path   string
+		want   bool
+	}{
+		{nil, "/api/query/sql", true},
+		{[]string{"/api/block/"}, "/api/block/getBlockKramdown", true},
+		{[]string{"/api/block/"}, "/api/filetree/getDoc", false},
+		{[]string{"/api/block/", "/api/filetree/getDoc"}, "/api/filetree/getDoc", true},
+		{[]string{"/api/filetree/getDoc"}, "/api/filetree/getDocCreateSavePath", true},
+		{[]string{"/api/block/"}, "/api/blocks", false},
+	}
+
+	for _, test := range tests {
+		if got := (&APIToken{Routes: test.routes}).IsRouteAllowed(test.path); test.want != got {
+			t.Fatalf("routes %v path [%s] expected [%v], got [%v]", test.routes, test.path, test.want, got)
+		}
+	}
+}
+
+func TestAPITokenBoxesAllowed(t *testing.T) {
+	unrestricted := &APIToken{}
+	if unrestricted.IsBoxRestricted() || !unrestricted.IsBoxesAllowed(nil) || !unrestricted.IsBoxesAllowed([]string{"20210808180117-czj9bvb"}) {
+		t.Fatalf("expected token without boxes unrestricted")
+	}
+
+	restricted := &APIToken{Boxes: []string{"20210808180117-czj9bvb", "20210808180117-6v0mkxr"}}
+	tests := []struct {
+		boxIDs []string
+		want   bool
+	}{
+		{[]string{"20210808180117-czj9bvb"}, true},
+		{[]string{"20210808180117-czj9bvb", "20210808180117-6v0mkxr"}, true},
+		{[]string{"20210808180117-czj9bvb", "20210808180117-abcdefg"}, false},
+		{[]string{"20210808180117-abcdefg"}, false},
+		{nil, false}, // 不能确定涉及的笔记本时不能访问
+	}
+
+	if !restricted.IsBoxRestricted() {
+		t.Fatalf("expected token restricted")
+	}
+	for _, test := range tests {
+		if got := restricted.IsBoxesAllowed(test.boxIDs); test.want != got {
+			t.Fatalf("boxes %v expected [%v], got [%v]", test.boxIDs, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+// OIDC 描述了 OpenID Connect 单点登录配置。
+type OIDC struct {
+	Enabled      bool            `json:"enabled"`      // 是否启用
+	Issuer       string          `json:"issuer"`       // 身份提供方地址，通过 {issuer}/.well-known/openid-configuration 发现端点
+	ClientID     string          `json:"clientID"`     // 客户端 ID
+	ClientSecret string          `json:"clientSecret"` // 客户端密钥，公共客户端（仅使用 PKCE）时为空
+	RedirectURL  string          `json:"redirectURL"`  // 回调地址，需要在身份提供方注册，比如 https://siyuan.example.com/auth/oidc/callback
+	Scopes       []string        `json:"scopes"`       // 除 openid 以外请求的 scope
+	RoleClaim    string          `json:"roleClaim"`    // 用于映射角色的声明，支持使用 . 访问嵌套声明，比如 realm_access.roles
+	RoleMapping  map[string]uint `json:"roleMapping"`  // 声明值到角色的映射，0：管理员，1：编辑者，2：读者，匹配多个时使用权限最高的角色
+	DefaultRole  int             `json:"defaultRole"`  // 没有匹配的映射时使用的角色，-1：拒绝登录
+}
+
+func NewOIDC() *OIDC {
+	return &OIDC{
+		Scopes:      []string{"profile", "email"},
+		RoleClaim:   "groups",
+		RoleMapping: map[string]uint{},
+		DefaultRole: -1,
+	}
+}
@@ -46,6 +46,11 @@ type Search struct {
 	Limit         int  `json:"limit"`
 	CaseSensitive bool `json:"caseSensitive"`
 
+	FuzzyMaxDistance int  `json:"fuzzyMaxDistance"` // 模糊搜索允许的最大编辑距离 [1, 3]
+	FuzzyRef         bool `json:"fuzzyRef"`         // 块引搜索没有结果时使用模糊搜索
+
+	Tokenizers []string `json:"tokenizers"` // 分词流水线：cjkBigram、identifier、foldDiacritics、stemEnglish、stemGerman、stemFrench，为空时仅使用单字分词，修改后需要重建索引
+
 	Name  bool `json:"name"`
 	Alias bool `json:"alias"`
 	Memo  bool `json:"memo"`
@@ -88,6 +93,11 @@ func NewSearch() *Search {
 		Limit:         64,
 		CaseSensitive: false,
 
+		FuzzyMaxDistance: 2,
+		FuzzyRef:         false,
+
+		Tokenizers: []string{},
+
 		Name:  true,
 		Alias: true,
 		Memo:  true,
@@ -0,0 +1,96 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+import (
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/logging"
+)
+
+// UserConf 描述了用户级配置。
+//
+// 配置分为工作空间级（服务、同步、网络等）和用户级，多用户模式下每个用户的用户级配置单独保存在用户数据目录下的 storage/conf.json 中，
+// 第一次使用时从工作空间配置复制。默认用户的用户级配置就是工作空间配置中的对应部分。
+//
+// 用户级配置只决定返回给该用户的配置（/api/system/getConf），所以只有前端读取后生效的编辑器、外观和快捷键设置按用户生效；
+// 内核中的搜索、虚拟引用、闪卡、导出和人工智能等功能仍然使用工作空间配置，其他用户保存的这些设置只会保存和返回，不会改变内核的行为。
+type UserConf struct {
+	Editor     *Editor     `json:"editor"`     // 编辑器配置
+	Appearance *Appearance `json:"appearance"` // 外观配置
+	Search     *Search     `json:"search"`     // 搜索配置
+	Flashcard  *Flashcard  `json:"flashcard"`  // 闪卡配置
+	Export     *Export     `json:"export"`     // 导出配置
+	Keymap     *Keymap     `json:"keymap"`     // 快捷键配置
+	AI         *AI         `json:"ai"`         // 人工智能配置
+}
+
+// FillDefaults 使用工作空间配置 workspace 补全缺失的部分，复制后再使用，避免修改用户级配置时影响到工作空间配置。
+func (userConf *UserConf) FillDefaults(workspace *UserConf) {
+	if nil == userConf.Editor {
+		userConf.Editor = cloneConf(workspace.Editor, NewEditor())
+	}
+	if nil == userConf.Appearance {
+		userConf.Appearance = cloneConf(workspace.Appearance, NewAppearance())
+	}
+	if nil == userConf.Search {
+		userConf.Search = cloneConf(workspace.Search, NewSearch())
+	}
+	if nil == userConf.Flashcard {
+		userConf.Flashcard = cloneConf(workspace.Flashcard, NewFlashcard())
+	}
+	if nil == userConf.Export {
+		userConf.Export = cloneConf(workspace.Export, NewExport())
+	}
+	if nil == userConf.Keymap {
+		userConf.Keymap = cloneConf(workspace.Keymap, &Keymap{})
+	}
+	if nil == userConf.AI {
+		userConf.AI = cloneConf(workspace.AI, NewAI())
+	}
+}
+
+// KeepWorkspaceFields 使用工作空间配置 workspace 覆盖用户不能单独修改的字段。
+//
+// 这些字段影响所有用户共用的索引或者会执行本地程序：搜索的大小写敏感、资源文件路径索引和分词流水线，语义搜索的向量模型，以及 Pandoc 可执行文件路径。
+func (userConf *UserConf) KeepWorkspaceFields(workspace *UserConf) {
+	userConf.Search.CaseSensitive = workspace.Search.CaseSensitive
+	userConf.Search.IndexAssetPath = workspace.Search.IndexAssetPath
+	userConf.Search.Tokenizers = workspace.Search.Tokenizers
+	userConf.AI.Embedding = cloneConf(workspace.AI.Embedding, NewEmbedding())
+	userConf.Export.PandocBin = workspace.Export.PandocBin
+}
+
+// Clone 返回用户级配置的深拷贝。
+func (userConf *UserConf) Clone() *UserConf {
+	return cloneConf(userConf, &UserConf{})
+}
+
+func cloneConf[T any](src, dst *T) *T {
+	if nil == src {
+		return dst
+	}
+
+	data, err := gulu.JSON.MarshalJSON(src)
+	if err != nil {
+		logging.LogErrorf("marshal conf failed: %s", err)
+		return dst
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, dst); err != nil {
+		logging.LogErrorf("unmarshal conf failed: %s", err)
+	}
+	return dst
+}
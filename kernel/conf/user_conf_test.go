@@ -0,0 +1,116 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package conf
+
+import (
+	"testing"
+
+	"github.com/88250/gulu"
+)
+
+func newWorkspaceUserConf() *UserConf {
+	search := NewSearch()
+	search.CaseSensitive = true
+	search.Tokenizers = []string{"cjkBigram"}
+	export := NewExport()
+	export.PandocBin = "/usr/bin/pandoc"
+	ai := NewAI()
+	ai.Embedding.APIModel = "workspace-model"
+	return &UserConf{
+		Editor:     NewEditor(),
+		Appearance: NewAppearance(),
+		Search:     search,
+		Flashcard:  NewFlashcard(),
+		Export:     export,
+		Keymap:     &Keymap{"editor": "workspace"},
+		AI:         ai,
+	}
+}
+
+func TestUserConfFillDefaults(t *testing.T) {
+	workspace := newWorkspaceUserConf()
+	workspace.Editor.FontSize = 20
+
+	userEditor := NewEditor()
+	userEditor.FontSize = 14
+	userConf := &UserConf{Editor: userEditor}
+	userConf.FillDefaults(workspace)
+
+	// 已有的用户级配置保持不变，缺失的部分从工作空间配置复制
+	if userEditor != userConf.Editor || 14 != userConf.Editor.FontSize {
+		t.Fatalf("expected user editor kept")
+	}
+	if nil == userConf.Appearance || nil == userConf.Search || nil == userConf.Flashcard || nil == userConf.Export || nil == userConf.Keymap || nil == userConf.AI {
+		t.Fatalf("expected missing parts filled [%+v]", userConf)
+	}
+	if !userConf.Search.CaseSensitive || "workspace" != (*userConf.Keymap)["editor"] {
+		t.Fatalf("expected missing parts copied from workspace")
+	}
+
+	// 修改用户级配置不影响工作空间配置
+	userConf.Search.VirtualRefName = !workspace.Search.VirtualRefName
+	(*userConf.Keymap)["editor"] = "user"
+	userConf.AI.Embedding.APIModel = "user-model"
+	if workspace.Search == userConf.Search || workspace.Search.VirtualRefName == userConf.Search.VirtualRefName || "workspace" != (*workspace.Keymap)["editor"] || "workspace-model" != workspace.AI.Embedding.APIModel {
+		t.Fatalf("user conf should not share workspace conf")
+	}
+}
+
+func TestUserConfKeepWorkspaceFields(t *testing.T) {
+	workspace := newWorkspaceUserConf()
+	userConf := &UserConf{}
+	userConf.FillDefaults(workspace)
+
+	userConf.Search.CaseSensitive = false
+	userConf.Search.IndexAssetPath = !workspace.Search.IndexAssetPath
+	userConf.Search.Tokenizers = []string{"stemEnglish"}
+	userConf.Search.Limit = 128
+	userConf.AI.Embedding.APIModel = "user-model"
+	userConf.Export.PandocBin = "/tmp/evil"
+	userConf.Export.PDFFooter = "user footer"
+	userConf.KeepWorkspaceFields(workspace)
+
+	// 影响共用索引和执行本地程序的字段使用工作空间配置，其他字段保持用户的修改
+	if !userConf.Search.CaseSensitive || userConf.Search.IndexAssetPath != workspace.Search.IndexAssetPath || !gulu.Str.Equal(userConf.Search.Tokenizers, []string{"cjkBigram"}) {
+		t.Fatalf("expected index fields kept [%+v]", userConf.Search)
+	}
+	if "workspace-model" != userConf.AI.Embedding.APIModel || "/usr/bin/pandoc" != userConf.Export.PandocBin {
+		t.Fatalf("expected embedding and pandoc kept")
+	}
+	if 128 != userConf.Search.Limit || "user footer" != userConf.Export.PDFFooter {
+		t.Fatalf("expected user fields kept")
+	}
+
+	userConf.AI.Embedding.APIModel = "user-model"
+	if "workspace-model" != workspace.AI.Embedding.APIModel {
+		t.Fatalf("user embedding should not share workspace embedding")
+	}
+}
+
+func TestUserConfClone(t *testing.T) {
+	cached := &UserConf{}
+	cached.FillDefaults(newWorkspaceUserConf())
+
+	// 并发请求修改各自的副本，不影响缓存的配置
+	cloned := cached.Clone()
+	cloned.Editor.FontSize = 30
+	(*cloned.Keymap)["editor"] = "user"
+	cloned.AI.Embedding.APIModel = "user-model"
+	if cached.Editor.FontSize == cloned.Editor.FontSize || "workspace" != (*cached.Keymap)["editor"] || "workspace-model" != cached.AI.Embedding.APIModel {
+		t.Fatalf("cloned user conf should not share cached user conf")
+	}
+}
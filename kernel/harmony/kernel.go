@@ -56,6 +56,7 @@ func StartKernel(container, appDir, workspaceBaseDir, timezoneID, localIPs, lang
 		sql.InitAssetContentDatabase(false)
 		sql.SetCaseSensitive(model.Conf.Search.CaseSensitive)
 		sql.SetIndexAssetPath(model.Conf.Search.IndexAssetPath)
+		sql.SetTokenizers(model.Conf.Search.Tokenizers)
 
 		model.BootSyncData()
 		model.InitBoxes()
@@ -38,6 +38,8 @@ func StartCron() {
 	go every(util.SQLFlushInterval, sql.FlushHistoryTxJob)
 	go every(util.SQLFlushInterval, sql.FlushAssetContentTxJob)
 	go every(10*time.Minute, model.IndexEmbedBlockJob)
+	go every(30*time.Second, model.IndexBlockVectorJob)
+	go every(time.Minute, model.WatchCriteriaJob)
 	go every(10*time.Minute, model.CacheVirtualBlockRefJob)
 	go every(30*time.Second, model.OCRAssetsJob)
 	go every(30*time.Second, model.FlushAssetsTextsJob)
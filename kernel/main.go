@@ -46,6 +46,7 @@ func main() {
 	sql.InitAssetContentDatabase(false)
 	sql.SetCaseSensitive(model.Conf.Search.CaseSensitive)
 	sql.SetIndexAssetPath(model.Conf.Search.IndexAssetPath)
+	sql.SetTokenizers(model.Conf.Search.Tokenizers)
 
 	model.BootSyncData()
 	model.InitBoxes(nil)
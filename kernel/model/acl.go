@@ -0,0 +1,324 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/acl"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const (
+	AccountContextKey = "account"
+
+	aclContextKey = "acls"
+
+	// 账号前缀
+	ACLAccountToken = "token:" // 命名 API token
+	ACLAccountOIDC  = "oidc:"  // OpenID Connect 登录
+	ACLAccountUser  = "user:"  // 多用户模式下的用户
+)
+
+var aclsLock = sync.RWMutex{}
+
+// GetACLs 返回所有访问控制规则。
+func GetACLs() (ret []*conf.ACL) {
+	aclsLock.RLock()
+	defer aclsLock.RUnlock()
+
+	ret = []*conf.ACL{}
+	for _, rule := range Conf.ACLs {
+		r := *rule
+		ret = append(ret, &r)
+	}
+	return
+}
+
+// SetACL 为账号设置笔记本（path 为空时）或者文档子树的权限，已经存在相同账号、笔记本和路径的规则时修改其权限。
+func SetACL(account, boxID, path, permission string) (ret *conf.ACL, err error) {
+	account = strings.TrimSpace(account)
+	if !isValidACLAccount(account) {
+		return nil, fmt.Errorf(Conf.Language(265), account)
+	}
+	if !acl.IsValidRule(&conf.ACL{Box: boxID, Path: path}) {
+		return nil, fmt.Errorf(Conf.Language(266), boxID+path)
+	}
+	if acl.PermissionNone == acl.ToPermission(permission) {
+		return nil, fmt.Errorf(Conf.Language(267), permission)
+	}
+
+	aclsLock.Lock()
+	defer aclsLock.Unlock()
+
+	for _, rule := range Conf.ACLs {
+		if rule.Account == account && rule.Box == boxID && rule.Path == path {
+			rule.Permission = permission
+			Conf.Save()
+			return rule, nil
+		}
+	}
+
+	ret = &conf.ACL{
+		ID:         ast.NewNodeID(),
+		Account:    account,
+		Box:        boxID,
+		Path:       path,
+		Permission: permission,
+		Created:    time.Now().UnixMilli(),
+	}
+	Conf.ACLs = append(Conf.ACLs, ret)
+	Conf.Save()
+	return
+}
+
+// RemoveACL 删除访问控制规则，删除账号的最后一条规则后该账号恢复按角色访问整个工作空间。
+func RemoveACL(id string) (err error) {
+	aclsLock.Lock()
+	defer aclsLock.Unlock()
+
+	for i, rule := range Conf.ACLs {
+		if rule.ID == id {
+			Conf.ACLs = append(Conf.ACLs[:i], Conf.ACLs[i+1:]...)
+			Conf.Save()
+			return
+		}
+	}
+	return errors.New(Conf.Language(268))
+}
+
+// authACL 检查设置了规则的账号是否可以访问请求的接口以及请求参数中涉及的笔记本和文档，检查失败时中断请求并返回 false。
+//
+// 接口默认不能访问，只能访问 acl.RoutePermission 中列出的接口，接口需要的权限受到角色限制（读者只有读权限）；
+// 通过参数确定访问范围的接口必须在参数中指定笔记本或者文档，其他接口在返回结果时按照规则过滤文档。
+func authACL(c *gin.Context) bool {
+	rules := getAccountACLs(getGinContextAccount(c))
+	if 1 > len(rules) {
+		return true
+	}
+
+	required, docScoped := acl.RoutePermission(c.Request.URL.Path)
+	if acl.PermissionNone == required || roleACLPermission(GetGinContextRole(c)) < required {
+		abortACL(c)
+		return false
+	}
+
+	arg, ok := requestJSONArg(c)
+	if !ok {
+		abortACL(c)
+		return false
+	}
+
+	docs := acl.ArgDocs(arg, blockTreeDoc, attrViewDocs)
+	if docScoped && 1 > len(docs) {
+		// 必须能够确定涉及的文档
+		abortACL(c)
+		return false
+	}
+	if "/api/transactions" == c.Request.URL.Path && !acl.IsTransactionResolved(arg, blockTreeDoc, attrViewDocs) {
+		// 事务中的每个操作都必须能够确定涉及的文档
+		abortACL(c)
+		return false
+	}
+
+	commentOnly := false
+	for _, doc := range docs {
+		if !acl.IsDocAllowed(rules, doc, required) {
+			abortACL(c)
+			return false
+		}
+		if acl.PermissionEdit > acl.PermissionOf(rules, doc.Box, doc.Path) {
+			commentOnly = true
+		}
+	}
+
+	if acl.PermissionComment == required && commentOnly {
+		for _, key := range acl.ArgAttrKeys(arg) {
+			if !gulu.Str.Contains(key, acl.CommentAttrs) {
+				abortACL(c)
+				return false
+			}
+		}
+	}
+
+	c.Set(aclContextKey, rules)
+	return true
+}
+
+// IsACLRestricted 判断当前请求的账号是否设置了访问控制规则。
+func IsACLRestricted(c *gin.Context) bool {
+	return 0 < len(getGinContextACLs(c))
+}
+
+// isACLBoxVisible 判断当前请求的账号是否可以看到笔记本，即存在作用于该笔记本的规则。
+func isACLBoxVisible(c *gin.Context, boxID string) bool {
+	rules := getGinContextACLs(c)
+	return 1 > len(rules) || acl.IsDocAllowed(rules, &acl.Doc{Box: boxID, ByPath: true}, acl.PermissionRead)
+}
+
+// isACLDocVisible 判断当前请求的账号是否可以读取笔记本中的文档。
+func isACLDocVisible(c *gin.Context, boxID, path string) bool {
+	rules := getGinContextACLs(c)
+	return 1 > len(rules) || acl.PermissionRead <= acl.PermissionOf(rules, boxID, path)
+}
+
+// filterACLFiles 过滤文档树中当前请求的账号不能看到的文档，保留有权限文档的上级文档以便在文档树中展开。
+func filterACLFiles(c *gin.Context, boxID string, files []*File) (ret []*File) {
+	rules := getGinContextACLs(c)
+	if 1 > len(rules) {
+		return files
+	}
+
+	for _, file := range files {
+		if acl.IsDocAllowed(rules, &acl.Doc{Box: boxID, Path: file.Path, ByPath: true}, acl.PermissionRead) {
+			ret = append(ret, file)
+		}
+	}
+	return
+}
+
+// filterACLBacklinks 过滤当前请求的账号不能读取的反链文档，返回保留的反链文档和被过滤的引用数。
+func filterACLBacklinks(c *gin.Context, paths []*Path) (ret []*Path, removedCount int) {
+	if !IsACLRestricted(c) {
+		return paths, 0
+	}
+
+	ret = []*Path{}
+	for _, p := range paths {
+		if bt := treenode.GetBlockTree(p.ID); nil != bt && isACLDocVisible(c, bt.BoxID, bt.Path) {
+			ret = append(ret, p)
+			continue
+		}
+		removedCount += p.Count
+	}
+	return
+}
+
+// buildACLFilter 返回将搜索范围限制在当前请求的账号可以读取的笔记本和文档内的 SQL 条件。
+func buildACLFilter(c *gin.Context) string {
+	rules := getGinContextACLs(c)
+	if 1 > len(rules) {
+		return ""
+	}
+	return acl.Filter(rules)
+}
+
+// buildACLHistoryFilter 返回将数据历史搜索范围限制在当前请求的账号可以读取的笔记本和文档内的 SQL 条件。
+func buildACLHistoryFilter(c *gin.Context) string {
+	rules := getGinContextACLs(c)
+	if 1 > len(rules) {
+		return ""
+	}
+	return acl.HistoryFilter(rules)
+}
+
+// isACLPushAllowed 判断是否可以将消息推送给设置了规则的账号的 WebSocket 会话，消息中涉及的文档都需要有读权限。
+func isACLPushAllowed(account string, msg []byte) bool {
+	return acl.IsPushAllowed(getAccountACLs(account), msg, blockTreeDoc, attrViewDocs)
+}
+
+func init() {
+	util.PushFilter = isACLPushAllowed
+}
+
+// blockTreeDoc 通过块树返回块所在的文档，块不存在时返回 nil。
+func blockTreeDoc(id string) *acl.Doc {
+	if bt := treenode.GetBlockTree(id); nil != bt {
+		return &acl.Doc{Box: bt.BoxID, Path: bt.Path}
+	}
+	return nil
+}
+
+// attrViewDocs 返回属性视图的所有镜像块所在的文档。
+func attrViewDocs(avID string) (ret []*acl.Doc) {
+	for _, id := range treenode.GetMirrorAttrViewBlockIDs(avID) {
+		if doc := blockTreeDoc(id); nil != doc {
+			ret = append(ret, doc)
+		}
+	}
+	return
+}
+
+// roleACLPermission 返回角色可以获得的最高权限，规则不能超出角色本身的权限。
+func roleACLPermission(role Role) acl.Permission {
+	switch role {
+	case RoleAdministrator, RoleEditor:
+		return acl.PermissionEdit
+	case RoleReader:
+		return acl.PermissionRead
+	}
+	return acl.PermissionNone
+}
+
+func isValidACLAccount(account string) bool {
+	for _, prefix := range []string{ACLAccountToken, ACLAccountOIDC, ACLAccountUser} {
+		if strings.HasPrefix(account, prefix) && len(prefix) < len(account) {
+			return true
+		}
+	}
+	return false
+}
+
+func abortACL(c *gin.Context) {
+	c.JSON(http.StatusForbidden, map[string]interface{}{"code": -1, "msg": "Auth failed [acl]"})
+	c.Abort()
+}
+
+func getAccountACLs(account string) (ret []*conf.ACL) {
+	if "" == account {
+		return
+	}
+
+	aclsLock.RLock()
+	defer aclsLock.RUnlock()
+
+	for _, rule := range Conf.ACLs {
+		if rule.Account == account {
+			r := *rule
+			ret = append(ret, &r)
+		}
+	}
+	return
+}
+
+func getGinContextAccount(c *gin.Context) string {
+	if nil == c {
+		return ""
+	}
+	return c.GetString(AccountContextKey)
+}
+
+func getGinContextACLs(c *gin.Context) []*conf.ACL {
+	if nil == c {
+		return nil
+	}
+
+	if rules, exists := c.Get(aclContextKey); exists {
+		return rules.([]*conf.ACL)
+	}
+	return nil
+}
@@ -0,0 +1,331 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/acl"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/tenant"
+)
+
+const (
+	APITokenContextKey = "apiToken"
+
+	apiTokenLastUsedSaveInterval = 60 * 1000 // 最近使用时间的持久化间隔（毫秒），避免每次请求都写入配置文件
+)
+
+var apiTokensLock = sync.Mutex{}
+
+// GetAPITokens 返回所有命名 API token，token 只在创建时返回，这里仅返回前 4 个字符。
+func GetAPITokens() (ret []*conf.APIToken) {
+	apiTokensLock.Lock()
+	defer apiTokensLock.Unlock()
+
+	ret = []*conf.APIToken{}
+	for _, apiToken := range Conf.Api.Tokens {
+		t := *apiToken
+		t.Token = t.Prefix + "****"
+		t.Hash = ""
+		ret = append(ret, &t)
+	}
+	return
+}
+
+// CreateAPIToken 创建命名 API token，expired 为过期时间（毫秒时间戳），为 0 时永不过期。
+func CreateAPIToken(name string, role Role, boxes, routes []string, expired int64) (ret *conf.APIToken, err error) {
+	if err = checkAPIToken(name, role, routes); err != nil {
+		return
+	}
+
+	apiTokensLock.Lock()
+	defer apiTokensLock.Unlock()
+
+	token := gulu.Rand.String(32)
+	apiToken := &conf.APIToken{
+		ID:      ast.NewNodeID(),
+		Name:    strings.TrimSpace(name),
+		Hash:    conf.HashAPIToken(token),
+		Prefix:  gulu.Str.SubStr(token, 4),
+		Role:    uint(role),
+		Boxes:   normalizeAPITokenBoxes(boxes),
+		Routes:  gulu.Str.RemoveDuplicatedElem(routes),
+		Expired: expired,
+		Created: time.Now().UnixMilli(),
+	}
+	Conf.m.Lock()
+	Conf.Api.Tokens = append(Conf.Api.Tokens, apiToken)
+	Conf.m.Unlock()
+	Conf.Save()
+
+	// 配置中只保存摘要，完整的 token 只在这里返回一次
+	t := *apiToken
+	t.Token = token
+	t.Hash = ""
+	ret = &t
+	return
+}
+
+// UpdateAPIToken 修改命名 API token 的名称、角色、访问范围和过期时间，token 本身保持不变。
+func UpdateAPIToken(id, name string, role Role, boxes, routes []string, expired int64) (err error) {
+	if err = checkAPIToken(name, role, routes); err != nil {
+		return
+	}
+
+	apiTokensLock.Lock()
+	defer apiTokensLock.Unlock()
+
+	for _, apiToken := range Conf.Api.Tokens {
+		if apiToken.ID == id {
+			Conf.m.Lock()
+			apiToken.Name = strings.TrimSpace(name)
+			apiToken.Role = uint(role)
+			apiToken.Boxes = normalizeAPITokenBoxes(boxes)
+			apiToken.Routes = gulu.Str.RemoveDuplicatedElem(routes)
+			apiToken.Expired = expired
+			Conf.m.Unlock()
+			Conf.Save()
+			return
+		}
+	}
+	return errors.New(Conf.Language(258))
+}
+
+// RevokeAPIToken 撤销命名 API token，撤销后立即失效。
+func RevokeAPIToken(id string) (err error) {
+	apiTokensLock.Lock()
+	defer apiTokensLock.Unlock()
+
+	Conf.m.Lock()
+	removed := Conf.Api.RemoveToken(id)
+	Conf.m.Unlock()
+	if !removed {
+		return errors.New(Conf.Language(258))
+	}
+	Conf.Save()
+	return
+}
+
+// IsAPITokenBoxAllowed 判断当前请求使用的 API token 是否可以访问笔记本，没有使用命名 API token 时总是可以访问。
+func IsAPITokenBoxAllowed(c *gin.Context, boxID string) bool {
+	apiToken := getGinContextAPIToken(c)
+	return nil == apiToken || apiToken.IsBoxesAllowed([]string{boxID})
+}
+
+// IsBoxRestricted 判断当前请求是否只能访问部分笔记本，即使用了限制笔记本的 API token、是多用户模式下的用户或者设置了访问控制规则。
+func IsBoxRestricted(c *gin.Context) bool {
+	apiToken := getGinContextAPIToken(c)
+	return nil != apiToken && apiToken.IsBoxRestricted() || !GetTenant(c).IsDefault() || IsACLRestricted(c)
+}
+
+// restrictAPITokenBoxes 将搜索的笔记本范围限制在当前请求使用的 API token 允许访问的笔记本内。
+func restrictAPITokenBoxes(c *gin.Context, boxes []string) []string {
+	apiToken := getGinContextAPIToken(c)
+	if nil == apiToken || !apiToken.IsBoxRestricted() {
+		return boxes
+	}
+	if 1 > len(boxes) {
+		return apiToken.Boxes
+	}
+
+	ret := []string{}
+	for _, box := range boxes {
+		if gulu.Str.Contains(box, apiToken.Boxes) {
+			ret = append(ret, box)
+		}
+	}
+	if 1 > len(ret) {
+		// 交集为空时使用一个不存在的笔记本，保证搜索不到结果
+		ret = append(ret, "-")
+	}
+	return ret
+}
+
+// authAPIToken 使用 API token 认证，认证失败时中断请求并返回 false。
+func authAPIToken(c *gin.Context, token, source string) bool {
+	if Conf.Api.IsAdminToken(token) {
+		c.Set(RoleContextKey, RoleAdministrator)
+		return true
+	}
+
+	apiToken := useAPIToken(token)
+	if nil == apiToken {
+		c.JSON(http.StatusUnauthorized, map[string]interface{}{"code": -1, "msg": "Auth failed [" + source + "]"})
+		c.Abort()
+		return false
+	}
+
+	if !isAPITokenRequestAllowed(c, apiToken) {
+		c.JSON(http.StatusForbidden, map[string]interface{}{"code": -1, "msg": "Auth failed [token scope]"})
+		c.Abort()
+		return false
+	}
+
+	c.Set(RoleContextKey, Role(apiToken.Role))
+	c.Set(APITokenContextKey, apiToken)
+	c.Set(AccountContextKey, ACLAccountToken+apiToken.Name)
+	return authACL(c)
+}
+
+// useAPIToken 返回未过期的命名 API token 并记录最近使用时间。
+func useAPIToken(token string) (ret *conf.APIToken) {
+	apiTokensLock.Lock()
+	now := time.Now().UnixMilli()
+	save := false
+	if apiToken := Conf.Api.GetToken(token); nil != apiToken && !apiToken.IsExpired(now) {
+		save = apiTokenLastUsedSaveInterval < now-apiToken.LastUsed
+		Conf.m.Lock()
+		apiToken.LastUsed = now
+		Conf.m.Unlock()
+		ret = apiToken
+	}
+	apiTokensLock.Unlock()
+
+	if save {
+		Conf.Save()
+	}
+	return
+}
+
+// isAPITokenRequestAllowed 检查请求的路由和参数中涉及的笔记本是否在 token 的访问范围内。
+//
+// 限制了笔记本的 token 只能访问通过笔记本确定访问范围的接口和按笔记本过滤结果的接口，前者必须在参数中指定可以访问的笔记本或者块。
+func isAPITokenRequestAllowed(c *gin.Context, apiToken *conf.APIToken) bool {
+	path := c.Request.URL.Path
+	if !apiToken.IsRouteAllowed(path) {
+		return false
+	}
+
+	if !apiToken.IsBoxRestricted() {
+		return true
+	}
+
+	boxFiltered := tenant.IsBoxFilteredRoute(path)
+	if !boxFiltered && !tenant.IsBoxScopedRoute(path) {
+		return false
+	}
+
+	boxIDs, ok := requestBoxIDs(c)
+	if !ok {
+		return false
+	}
+	if boxFiltered && 1 > len(boxIDs) {
+		return true
+	}
+	return apiToken.IsBoxesAllowed(boxIDs)
+}
+
+// requestBoxIDs 返回请求 JSON 参数中涉及的笔记本 ID，读取请求体后会将其还原，读取失败时 ok 为 false。
+func requestBoxIDs(c *gin.Context) (ret []string, ok bool) {
+	arg, ok := requestJSONArg(c)
+	if !ok {
+		return
+	}
+	for _, doc := range acl.ArgDocs(arg, blockTreeDoc, attrViewDocs) {
+		ret = append(ret, doc.Box)
+	}
+	return ret, true
+}
+
+// requestJSONArg 返回请求的 JSON 参数，读取请求体后会将其还原，请求体为空时返回 nil，读取失败或者不是 JSON 参数时 ok 为 false。
+//
+// 上传文件等表单请求返回表单字段，每个字段的值为字符串数组。
+func requestJSONArg(c *gin.Context) (ret interface{}, ok bool) {
+	if nil == c.Request.Body {
+		return nil, true
+	}
+
+	if gin.MIMEMultipartPOSTForm == c.ContentType() {
+		form, err := c.MultipartForm()
+		if err != nil {
+			return
+		}
+
+		arg := map[string]interface{}{}
+		for key, values := range form.Value {
+			var vals []interface{}
+			for _, val := range values {
+				vals = append(vals, val)
+			}
+			arg[key] = vals
+		}
+		return arg, true
+	}
+
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(data))
+
+	if 1 > len(bytes.TrimSpace(data)) {
+		return nil, true
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); err != nil {
+		return nil, false
+	}
+	return ret, true
+}
+
+func getGinContextAPIToken(c *gin.Context) *conf.APIToken {
+	if nil == c {
+		return nil
+	}
+
+	if apiToken, exists := c.Get(APITokenContextKey); exists {
+		return apiToken.(*conf.APIToken)
+	}
+	return nil
+}
+
+func checkAPIToken(name string, role Role, routes []string) error {
+	if "" == strings.TrimSpace(name) {
+		return errors.New(Conf.Language(261))
+	}
+
+	if RoleEditor != role && RoleReader != role {
+		return errors.New(Conf.Language(259))
+	}
+
+	for _, route := range routes {
+		if !strings.HasPrefix(route, "/api/") {
+			return fmt.Errorf(Conf.Language(260), route)
+		}
+	}
+	return nil
+}
+
+func normalizeAPITokenBoxes(boxes []string) (ret []string) {
+	ret = []string{}
+	for _, box := range gulu.Str.RemoveDuplicatedElem(boxes) {
+		if ast.IsNodeIDPattern(box) {
+			ret = append(ret, box)
+		}
+	}
+	return
+}
@@ -18,6 +18,7 @@ package model
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -30,6 +31,7 @@ import (
 	"github.com/88250/gulu"
 	"github.com/88250/lute/ast"
 	"github.com/88250/lute/parse"
+	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/copier"
 	"github.com/siyuan-note/dejavu/entity"
 	"github.com/siyuan-note/filelock"
@@ -50,11 +52,32 @@ func AppendAttributeViewDetachedBlocksWithValues(avID string, blocksValues [][]*
 		return
 	}
 
-	now := util.CurrentTimeMillis()
 	var blockIDs []string
-	for _, blockValues := range blocksValues {
-		blockID := ast.NewNodeID()
-		blockIDs = append(blockIDs, blockID)
+	for range blocksValues {
+		blockIDs = append(blockIDs, ast.NewNodeID())
+	}
+	if violations := attrView.ValidateRows(blockIDs, blocksValues); 0 < len(violations) {
+		err = &av.ValidationError{Violations: violations}
+		return
+	}
+	if err = appendAttributeViewBlocksWithValues(attrView, blockIDs, blocksValues, true); err != nil {
+		return
+	}
+
+	if err = av.SaveAttributeView(attrView); err != nil {
+		logging.LogErrorf("save attribute view [%s] failed: %s", avID, err)
+		return
+	}
+
+	ReloadAttrView(avID)
+	return
+}
+
+// appendAttributeViewBlocksWithValues 将行添加到属性视图中，blockIDs 和 blocksValues 一一对应，isDetached 为 false 时 blockIDs 为绑定块 ID。
+func appendAttributeViewBlocksWithValues(attrView *av.AttributeView, blockIDs []string, blocksValues [][]*av.Value, isDetached bool) (err error) {
+	now := util.CurrentTimeMillis()
+	for i, blockValues := range blocksValues {
+		blockID := blockIDs[i]
 		for _, v := range blockValues {
 			keyValues, _ := attrView.GetKeyValues(v.KeyID)
 			if nil == keyValues {
@@ -70,7 +93,7 @@ func AppendAttributeViewDetachedBlocksWithValues(avID string, blocksValues [][]*
 				v.Block.Created = now
 				v.Block.Updated = now
 			}
-			v.IsDetached = true
+			v.IsDetached = isDetached
 			v.CreatedAt = now
 			v.UpdatedAt = now
 
@@ -103,13 +126,6 @@ func AppendAttributeViewDetachedBlocksWithValues(avID string, blocksValues [][]*
 			}
 		}
 	}
-
-	if err = av.SaveAttributeView(attrView); err != nil {
-		logging.LogErrorf("save attribute view [%s] failed: %s", avID, err)
-		return
-	}
-
-	ReloadAttrView(avID)
 	return
 }
 
@@ -261,6 +277,15 @@ func GetAttributeViewFilterSort(avID, blockID string) (filters []*av.ViewFilter,
 	case av.LayoutTypeTable:
 		filters = view.Table.Filters
 		sorts = view.Table.Sorts
+	case av.LayoutTypeBoard:
+		filters = view.Board.Filters
+		sorts = view.Board.Sorts
+	case av.LayoutTypeCalendar, av.LayoutTypeTimeline:
+		filters = view.GetCalendarLayout().Filters
+		sorts = view.GetCalendarLayout().Sorts
+	case av.LayoutTypeGallery:
+		filters = view.Gallery.Filters
+		sorts = view.Gallery.Sorts
 	}
 	return
 }
@@ -276,7 +301,7 @@ func SearchAttributeViewNonRelationKey(avID, keyword string) (ret []*av.Key) {
 	}
 
 	for _, keyValues := range attrView.KeyValues {
-		if av.KeyTypeRelation != keyValues.Key.Type && av.KeyTypeRollup != keyValues.Key.Type && av.KeyTypeTemplate != keyValues.Key.Type && av.KeyTypeCreated != keyValues.Key.Type && av.KeyTypeUpdated != keyValues.Key.Type && av.KeyTypeLineNumber != keyValues.Key.Type {
+		if av.KeyTypeRelation != keyValues.Key.Type && av.KeyTypeRollup != keyValues.Key.Type && av.KeyTypeTemplate != keyValues.Key.Type && av.KeyTypeCreated != keyValues.Key.Type && av.KeyTypeUpdated != keyValues.Key.Type && av.KeyTypeLineNumber != keyValues.Key.Type && av.KeyTypeFormula != keyValues.Key.Type && av.KeyTypeUniqueID != keyValues.Key.Type {
 			if strings.Contains(strings.ToLower(keyValues.Key.Name), strings.ToLower(keyword)) {
 				ret = append(ret, keyValues.Key)
 			}
@@ -533,6 +558,8 @@ func GetBlockAttributeViewKeys(blockID string) (ret []*BlockAttributeViewKeys) {
 				kValues.Values = append(kValues.Values, &av.Value{ID: ast.NewNodeID(), KeyID: kValues.Key.ID, BlockID: blockID, Type: av.KeyTypeRollup, Rollup: &av.ValueRollup{Contents: []*av.Value{}}})
 			case av.KeyTypeTemplate:
 				kValues.Values = append(kValues.Values, &av.Value{ID: ast.NewNodeID(), KeyID: kValues.Key.ID, BlockID: blockID, Type: av.KeyTypeTemplate, Template: &av.ValueTemplate{Content: ""}})
+			case av.KeyTypeFormula:
+				kValues.Values = append(kValues.Values, &av.Value{ID: ast.NewNodeID(), KeyID: kValues.Key.ID, BlockID: blockID, Type: av.KeyTypeFormula, Formula: &av.ValueFormula{}})
 			case av.KeyTypeCreated:
 				kValues.Values = append(kValues.Values, &av.Value{ID: ast.NewNodeID(), KeyID: kValues.Key.ID, BlockID: blockID, Type: av.KeyTypeCreated})
 			case av.KeyTypeUpdated:
@@ -679,6 +706,17 @@ func GetBlockAttributeViewKeys(blockID string) (ret []*BlockAttributeViewKeys) {
 			util.PushErrMsg(fmt.Sprintf(Conf.Language(44), util.EscapeHTML(renderTemplateErr.Error())), 30000)
 		}
 
+		// 最后计算公式
+		var formulaKeys []*av.Key
+		var formulaValues []*av.Value
+		for _, kv := range keyValues {
+			if 0 < len(kv.Values) {
+				formulaKeys = append(formulaKeys, kv.Key)
+				formulaValues = append(formulaValues, kv.Values[0])
+			}
+		}
+		av.EvalRowFormulas(formulaKeys, formulaValues)
+
 		// 字段排序
 		refreshAttrViewKeyIDs(attrView, true)
 		sorts := map[string]int{}
@@ -776,7 +814,7 @@ func RenderRepoSnapshotAttributeView(indexID, avID string) (viewable av.Viewable
 		}
 	}
 
-	viewable, err = renderAttributeView(attrView, "", "", 1, -1)
+	viewable, err = renderAttributeView(nil, attrView, "", "", 1, -1, nil)
 	return
 }
 
@@ -819,13 +857,18 @@ func RenderHistoryAttributeView(avID, created string) (viewable av.Viewable, att
 		}
 	}
 
-	viewable, err = renderAttributeView(attrView, "", "", 1, -1)
+	viewable, err = renderAttributeView(nil, attrView, "", "", 1, -1, nil)
 	return
 }
 
-func RenderAttributeView(avID, viewID, query string, page, pageSize int) (viewable av.Viewable, attrView *av.AttributeView, err error) {
+func RenderAttributeView(c *gin.Context, avID, viewID, query string, page, pageSize int, dateRange *av.DateRange) (viewable av.Viewable, attrView *av.AttributeView, err error) {
 	waitForSyncingStorages()
 
+	if !isACLAttrViewVisible(c, avID) {
+		err = fmt.Errorf(Conf.Language(271), avID)
+		return
+	}
+
 	if avJSONPath := av.GetAttributeViewDataPath(avID); !filelock.IsExist(avJSONPath) {
 		attrView = av.NewAttributeView(avID)
 		if err = av.SaveAttributeView(attrView); err != nil {
@@ -840,11 +883,50 @@ func RenderAttributeView(avID, viewID, query string, page, pageSize int) (viewab
 		return
 	}
 
-	viewable, err = renderAttributeView(attrView, viewID, query, page, pageSize)
+	viewable, err = renderAttributeView(c, attrView, viewID, query, page, pageSize, dateRange)
 	return
 }
 
-func renderAttributeView(attrView *av.AttributeView, viewID, query string, page, pageSize int) (viewable av.Viewable, err error) {
+// isACLAttrViewVisible 判断当前请求的账号是否可以查看数据库，数据库块所在的文档中至少有一个有读权限。
+func isACLAttrViewVisible(c *gin.Context, avID string) bool {
+	if !IsACLRestricted(c) {
+		return true
+	}
+
+	for _, bt := range treenode.GetBlockTrees(treenode.GetMirrorAttrViewBlockIDs(avID)) {
+		if isACLDocVisible(c, bt.BoxID, bt.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterACLAttrViewRows 过滤数据库视图中绑定块所在文档没有读权限的行，未绑定块的行属于数据库本身，不过滤。
+func filterACLAttrViewRows(c *gin.Context, attrView *av.AttributeView, viewable av.Viewable) {
+	blockValues := attrView.GetBlockKeyValues()
+	if !IsACLRestricted(c) || nil == blockValues {
+		return
+	}
+
+	var boundIDs []string
+	for _, v := range blockValues.Values {
+		if !v.IsDetached {
+			boundIDs = append(boundIDs, v.BlockID)
+		}
+	}
+
+	hidden := map[string]bool{}
+	for id, bt := range treenode.GetBlockTrees(boundIDs) {
+		if !isACLDocVisible(c, bt.BoxID, bt.Path) {
+			hidden[id] = true
+		}
+	}
+	av.KeepRows(viewable, func(row *av.TableRow) bool {
+		return !hidden[row.ID]
+	})
+}
+
+func renderAttributeView(c *gin.Context, attrView *av.AttributeView, viewID, query string, page, pageSize int, dateRange *av.DateRange) (viewable av.Viewable, err error) {
 	if 1 > len(attrView.Views) {
 		view, _, _ := av.NewTableViewWithBlockKey(ast.NewNodeID())
 		attrView.Views = append(attrView.Views, view)
@@ -939,41 +1021,55 @@ func renderAttributeView(attrView *av.AttributeView, viewID, query string, page,
 	}
 
 	// 补全过滤器 Value
-	if nil != view.Table {
-		for _, f := range view.Table.Filters {
-			if nil != f.Value {
-				continue
-			}
+	var viewFilters []*av.ViewFilter
+	switch view.LayoutType {
+	case av.LayoutTypeTable:
+		viewFilters = view.Table.Filters
+	case av.LayoutTypeBoard:
+		viewFilters = view.Board.Filters
+	case av.LayoutTypeCalendar, av.LayoutTypeTimeline:
+		viewFilters = view.GetCalendarLayout().Filters
+	case av.LayoutTypeGallery:
+		viewFilters = view.Gallery.Filters
+	}
+	av.WalkFilters(viewFilters, func(f *av.ViewFilter) {
+		if nil != f.Value {
+			return
+		}
 
-			if k, _ := attrView.GetKey(f.Column); nil != k {
-				f.Value = &av.Value{Type: k.Type}
-			}
+		if k, _ := attrView.GetKey(f.Column); nil != k {
+			f.Value = &av.Value{Type: k.Type}
 		}
-	}
+	})
 
+	// 列删除以后需要删除设置的过滤和排序
 	switch view.LayoutType {
 	case av.LayoutTypeTable:
-		// 列删除以后需要删除设置的过滤和排序
-		tmpFilters := []*av.ViewFilter{}
-		for _, f := range view.Table.Filters {
-			if k, _ := attrView.GetKey(f.Column); nil != k {
-				tmpFilters = append(tmpFilters, f)
-			}
-		}
-		view.Table.Filters = tmpFilters
-
-		tmpSorts := []*av.ViewSort{}
-		for _, s := range view.Table.Sorts {
-			if k, _ := attrView.GetKey(s.Column); nil != k {
-				tmpSorts = append(tmpSorts, s)
+		view.Table.Filters = getAttrViewExistKeyFilters(attrView, view.Table.Filters)
+		view.Table.Sorts = getAttrViewExistKeySorts(attrView, view.Table.Sorts)
+		if nil != view.Table.GroupBy {
+			if _, getErr := attrView.GetKey(view.Table.GroupBy.Column); nil != getErr {
+				view.Table.GroupBy = nil
 			}
 		}
-		view.Table.Sorts = tmpSorts
-
 		viewable = sql.RenderAttributeViewTable(attrView, view, query)
+	case av.LayoutTypeBoard:
+		view.Board.Filters = getAttrViewExistKeyFilters(attrView, view.Board.Filters)
+		view.Board.Sorts = getAttrViewExistKeySorts(attrView, view.Board.Sorts)
+		viewable = sql.RenderAttributeViewBoard(attrView, view, query)
+	case av.LayoutTypeCalendar, av.LayoutTypeTimeline:
+		layout := view.GetCalendarLayout()
+		layout.Filters = getAttrViewExistKeyFilters(attrView, layout.Filters)
+		layout.Sorts = getAttrViewExistKeySorts(attrView, layout.Sorts)
+		viewable = sql.RenderAttributeViewCalendar(attrView, view, query)
+	case av.LayoutTypeGallery:
+		view.Gallery.Filters = getAttrViewExistKeyFilters(attrView, view.Gallery.Filters)
+		view.Gallery.Sorts = getAttrViewExistKeySorts(attrView, view.Gallery.Sorts)
+		viewable = sql.RenderAttributeViewGallery(attrView, view, query)
 	}
 
 	viewable.FilterRows(attrView)
+	filterACLAttrViewRows(c, attrView, viewable)
 	viewable.SortRows(attrView)
 	viewable.CalcCols()
 
@@ -990,12 +1086,179 @@ func renderAttributeView(attrView *av.AttributeView, viewID, query string, page,
 			pageSize = table.PageSize
 		}
 
+		table.GroupRows(attrView, view.Table.GroupBy)
+		if 0 < len(table.Groups) {
+			// 分组后按分组分页
+			for _, group := range table.Groups {
+				start := (page - 1) * pageSize
+				if len(group.Rows) < start {
+					start = len(group.Rows)
+				}
+				end := start + pageSize
+				if len(group.Rows) < end {
+					end = len(group.Rows)
+				}
+				group.Rows = group.Rows[start:end]
+			}
+		}
+
 		start := (page - 1) * pageSize
 		end := start + pageSize
 		if len(table.Rows) < end {
 			end = len(table.Rows)
 		}
 		table.Rows = table.Rows[start:end]
+	case av.LayoutTypeBoard:
+		board := viewable.(*av.Board)
+		groupKey, _ := attrView.GetKey(view.Board.GroupKeyID)
+		if nil != groupKey && !av.IsGroupKeyType(groupKey.Type) {
+			groupKey = nil
+		}
+		board.GroupCards(view.Board, groupKey)
+		if 1 > view.Board.PageSize {
+			view.Board.PageSize = 50
+		}
+		board.PageSize = view.Board.PageSize
+		if 1 > pageSize {
+			pageSize = board.PageSize
+		}
+
+		// 看板按泳道分页
+		for _, lane := range board.Lanes {
+			start := (page - 1) * pageSize
+			if len(lane.Cards) < start {
+				start = len(lane.Cards)
+			}
+			end := start + pageSize
+			if len(lane.Cards) < end {
+				end = len(lane.Cards)
+			}
+			lane.Cards = lane.Cards[start:end]
+		}
+	case av.LayoutTypeCalendar, av.LayoutTypeTimeline:
+		calendar := viewable.(*av.Calendar)
+		calendar.BucketEntries(dateRange)
+		layout := view.GetCalendarLayout()
+		if 1 > layout.PageSize {
+			layout.PageSize = 50
+		}
+		calendar.PageSize = layout.PageSize
+		if 1 > pageSize {
+			pageSize = calendar.PageSize
+		}
+
+		// 仅对未设置日期的条目分页
+		start := (page - 1) * pageSize
+		if len(calendar.Undated) < start {
+			start = len(calendar.Undated)
+		}
+		end := start + pageSize
+		if len(calendar.Undated) < end {
+			end = len(calendar.Undated)
+		}
+		calendar.Undated = calendar.Undated[start:end]
+	case av.LayoutTypeGallery:
+		gallery := viewable.(*av.Gallery)
+		gallery.BuildCards()
+		if 1 > view.Gallery.PageSize {
+			view.Gallery.PageSize = 50
+		}
+		gallery.PageSize = view.Gallery.PageSize
+		if 1 > pageSize {
+			pageSize = gallery.PageSize
+		}
+
+		start := (page - 1) * pageSize
+		if len(gallery.Cards) < start {
+			start = len(gallery.Cards)
+		}
+		end := start + pageSize
+		if len(gallery.Cards) < end {
+			end = len(gallery.Cards)
+		}
+		gallery.Cards = gallery.Cards[start:end]
+
+		// 分页后再解析绑定块内容中的封面，避免加载过多的块树
+		if av.CoverFromContent == gallery.CoverFrom {
+			fillGalleryContentCovers(gallery.Cards)
+		}
+	}
+	return
+}
+
+func fillGalleryContentCovers(cards []*av.GalleryCard) {
+	trees := map[string]*parse.Tree{}
+	for _, card := range cards {
+		blockID := card.GetBlockID()
+		if "" == blockID {
+			continue
+		}
+
+		card.Cover = getBlockFirstImageAsset(blockID, trees)
+	}
+}
+
+// getBlockFirstImageAsset 返回块内容中的第一张图片，块为文档块时即文档中的第一张图片。
+func getBlockFirstImageAsset(blockID string, trees map[string]*parse.Tree) (ret string) {
+	bt := treenode.GetBlockTree(blockID)
+	if nil == bt {
+		return
+	}
+
+	tree := trees[bt.RootID]
+	if nil == tree {
+		var err error
+		tree, err = LoadTreeByBlockID(bt.RootID)
+		if err != nil {
+			return
+		}
+		trees[bt.RootID] = tree
+	}
+
+	node := treenode.GetNodeInTree(tree, blockID)
+	if nil == node {
+		return
+	}
+
+	ast.Walk(node, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering || ast.NodeImage != n.Type {
+			return ast.WalkContinue
+		}
+
+		linkDest := n.ChildByType(ast.NodeLinkDest)
+		if nil == linkDest || 1 > len(linkDest.Tokens) {
+			return ast.WalkContinue
+		}
+		ret = string(linkDest.Tokens)
+		return ast.WalkStop
+	})
+	return
+}
+
+func getAttrViewExistKeyFilters(attrView *av.AttributeView, filters []*av.ViewFilter) (ret []*av.ViewFilter) {
+	ret = []*av.ViewFilter{}
+	for _, f := range filters {
+		if f.IsGroup() {
+			// 过滤组中的过滤规则全部被删除后需要删除过滤组
+			if f.Filters = getAttrViewExistKeyFilters(attrView, f.Filters); 0 < len(f.Filters) {
+				ret = append(ret, f)
+			}
+			continue
+		}
+
+		if k, _ := attrView.GetKey(f.Column); nil != k {
+			ret = append(ret, f)
+		}
+	}
+	return
+}
+
+func getAttrViewExistKeySorts(attrView *av.AttributeView, sorts []*av.ViewSort) (ret []*av.ViewSort) {
+	ret = []*av.ViewSort{}
+	for _, s := range sorts {
+		if k, _ := attrView.GetKey(s.Column); nil != k {
+			ret = append(ret, s)
+		}
 	}
 	return
 }
@@ -1066,6 +1329,8 @@ func unbindAttributeViewBlock(operation *Operation, tx *Transaction) (err error)
 			if !replacedRowID {
 				v.Table.RowIDs = append(v.Table.RowIDs, operation.NextID)
 			}
+		case av.LayoutTypeBoard:
+			v.Board.ReplaceCard(operation.ID, operation.NextID)
 		}
 	}
 
@@ -1510,6 +1775,90 @@ func (tx *Transaction) doDuplicateAttrViewView(operation *Operation) (ret *TxErr
 		return
 	}
 
+	if av.LayoutTypeBoard == masterView.LayoutType {
+		view := av.NewBoardView(masterView.Board.GroupKeyID)
+		view.ID = operation.ID
+		attrView.Views = append(attrView.Views, view)
+		attrView.ViewID = view.ID
+
+		view.Icon = masterView.Icon
+		view.Name = util.GetDuplicateName(masterView.Name)
+		view.HideAttrViewName = masterView.HideAttrViewName
+
+		for _, field := range masterView.Board.Fields {
+			view.Board.Fields = append(view.Board.Fields, &av.ViewField{ID: field.ID, Hidden: field.Hidden})
+		}
+		for _, lane := range masterView.Board.Lanes {
+			view.Board.Lanes = append(view.Board.Lanes, &av.ViewBoardLane{ID: lane.ID, CardIDs: lane.CardIDs})
+		}
+		view.Board.Filters = av.CloneFilters(masterView.Board.Filters)
+		for _, s := range masterView.Board.Sorts {
+			view.Board.Sorts = append(view.Board.Sorts, &av.ViewSort{Column: s.Column, Order: s.Order})
+		}
+		view.Board.PageSize = masterView.Board.PageSize
+
+		if err = av.SaveAttributeView(attrView); err != nil {
+			logging.LogErrorf("save attribute view [%s] failed: %s", avID, err)
+			return &TxErr{code: TxErrWriteAttributeView, msg: err.Error(), id: avID}
+		}
+		return
+	}
+
+	if av.LayoutTypeGallery == masterView.LayoutType {
+		view := av.NewGalleryView(masterView.Gallery.CoverFrom, masterView.Gallery.CoverKeyID)
+		view.ID = operation.ID
+		attrView.Views = append(attrView.Views, view)
+		attrView.ViewID = view.ID
+
+		view.Icon = masterView.Icon
+		view.Name = util.GetDuplicateName(masterView.Name)
+		view.HideAttrViewName = masterView.HideAttrViewName
+
+		for _, field := range masterView.Gallery.Fields {
+			view.Gallery.Fields = append(view.Gallery.Fields, &av.ViewField{ID: field.ID, Hidden: field.Hidden})
+		}
+		view.Gallery.Filters = av.CloneFilters(masterView.Gallery.Filters)
+		for _, s := range masterView.Gallery.Sorts {
+			view.Gallery.Sorts = append(view.Gallery.Sorts, &av.ViewSort{Column: s.Column, Order: s.Order})
+		}
+		view.Gallery.PageSize = masterView.Gallery.PageSize
+
+		if err = av.SaveAttributeView(attrView); err != nil {
+			logging.LogErrorf("save attribute view [%s] failed: %s", avID, err)
+			return &TxErr{code: TxErrWriteAttributeView, msg: err.Error(), id: avID}
+		}
+		return
+	}
+
+	if masterLayout := masterView.GetCalendarLayout(); nil != masterLayout {
+		view := av.NewCalendarView(masterView.LayoutType, masterLayout.StartKeyID)
+		view.ID = operation.ID
+		attrView.Views = append(attrView.Views, view)
+		attrView.ViewID = view.ID
+
+		view.Icon = masterView.Icon
+		view.Name = util.GetDuplicateName(masterView.Name)
+		view.HideAttrViewName = masterView.HideAttrViewName
+
+		layout := view.GetCalendarLayout()
+		layout.EndKeyID = masterLayout.EndKeyID
+		layout.Unit = masterLayout.Unit
+		for _, field := range masterLayout.Fields {
+			layout.Fields = append(layout.Fields, &av.ViewField{ID: field.ID, Hidden: field.Hidden})
+		}
+		layout.Filters = av.CloneFilters(masterLayout.Filters)
+		for _, s := range masterLayout.Sorts {
+			layout.Sorts = append(layout.Sorts, &av.ViewSort{Column: s.Column, Order: s.Order})
+		}
+		layout.PageSize = masterLayout.PageSize
+
+		if err = av.SaveAttributeView(attrView); err != nil {
+			logging.LogErrorf("save attribute view [%s] failed: %s", avID, err)
+			return &TxErr{code: TxErrWriteAttributeView, msg: err.Error(), id: avID}
+		}
+		return
+	}
+
 	view := av.NewTableView()
 	view.ID = operation.ID
 	attrView.Views = append(attrView.Views, view)
@@ -1532,15 +1881,7 @@ func (tx *Transaction) doDuplicateAttrViewView(operation *Operation) (ret *TxErr
 		})
 	}
 
-	for _, filter := range masterView.Table.Filters {
-		view.Table.Filters = append(view.Table.Filters, &av.ViewFilter{
-			Column:        filter.Column,
-			Operator:      filter.Operator,
-			Value:         filter.Value,
-			RelativeDate:  filter.RelativeDate,
-			RelativeDate2: filter.RelativeDate2,
-		})
-	}
+	view.Table.Filters = av.CloneFilters(masterView.Table.Filters)
 
 	for _, s := range masterView.Table.Sorts {
 		view.Table.Sorts = append(view.Table.Sorts, &av.ViewSort{
@@ -1550,6 +1891,10 @@ func (tx *Transaction) doDuplicateAttrViewView(operation *Operation) (ret *TxErr
 	}
 
 	view.Table.PageSize = masterView.Table.PageSize
+	if nil != masterView.Table.GroupBy {
+		groupBy := *masterView.Table.GroupBy
+		view.Table.GroupBy = &groupBy
+	}
 	view.Table.RowIDs = masterView.Table.RowIDs
 
 	if err = av.SaveAttributeView(attrView); err != nil {
@@ -1593,17 +1938,71 @@ func (tx *Transaction) doAddAttrViewView(operation *Operation) (ret *TxErr) {
 		return
 	}
 
-	view := av.NewTableView()
+	var view *av.View
+	switch av.LayoutType(operation.Typ) {
+	case av.LayoutTypeBoard:
+		// 默认使用第一个可分组的字段进行分组
+		var groupKeyID string
+		for _, kv := range attrView.KeyValues {
+			if av.IsGroupKeyType(kv.Key.Type) {
+				groupKeyID = kv.Key.ID
+				break
+			}
+		}
+
+		view = av.NewBoardView(groupKeyID)
+		for _, kv := range attrView.KeyValues {
+			hidden := av.KeyTypeBlock != kv.Key.Type
+			view.Board.Fields = append(view.Board.Fields, &av.ViewField{ID: kv.Key.ID, Hidden: hidden})
+		}
+	case av.LayoutTypeCalendar, av.LayoutTypeTimeline:
+		// 默认使用第一个日期字段作为开始日期
+		var startKeyID string
+		for _, kv := range attrView.KeyValues {
+			if av.KeyTypeDate == kv.Key.Type {
+				startKeyID = kv.Key.ID
+				break
+			}
+		}
+
+		view = av.NewCalendarView(av.LayoutType(operation.Typ), startKeyID)
+		layout := view.GetCalendarLayout()
+		for _, kv := range attrView.KeyValues {
+			hidden := av.KeyTypeBlock != kv.Key.Type
+			layout.Fields = append(layout.Fields, &av.ViewField{ID: kv.Key.ID, Hidden: hidden})
+		}
+	case av.LayoutTypeGallery:
+		// 存在资源字段时默认使用第一个资源字段作为封面，否则使用绑定块内容中的第一张图片
+		coverFrom, coverKeyID := av.CoverFromContent, ""
+		for _, kv := range attrView.KeyValues {
+			if av.KeyTypeMAsset == kv.Key.Type {
+				coverFrom, coverKeyID = av.CoverFromAssetKey, kv.Key.ID
+				break
+			}
+		}
+
+		view = av.NewGalleryView(coverFrom, coverKeyID)
+		for _, kv := range attrView.KeyValues {
+			hidden := av.KeyTypeBlock != kv.Key.Type
+			view.Gallery.Fields = append(view.Gallery.Fields, &av.ViewField{ID: kv.Key.ID, Hidden: hidden})
+		}
+	default:
+		view = av.NewTableView()
+		if nil != firstView.Table {
+			for _, col := range firstView.Table.Columns {
+				view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{ID: col.ID})
+			}
+			view.Table.RowIDs = firstView.Table.RowIDs
+		} else {
+			for _, kv := range attrView.KeyValues {
+				view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{ID: kv.Key.ID})
+			}
+		}
+	}
 	view.ID = operation.ID
 	attrView.Views = append(attrView.Views, view)
 	attrView.ViewID = view.ID
 
-	for _, col := range firstView.Table.Columns {
-		view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{ID: col.ID})
-	}
-
-	view.Table.RowIDs = firstView.Table.RowIDs
-
 	if err = av.SaveAttributeView(attrView); err != nil {
 		logging.LogErrorf("save attribute view [%s] failed: %s", avID, err)
 		return &TxErr{code: TxErrWriteAttributeView, msg: err.Error(), id: avID}
@@ -1802,21 +2201,33 @@ func setAttributeViewFilters(operation *Operation) (err error) {
 		if err = gulu.JSON.UnmarshalJSON(data, &view.Table.Filters); err != nil {
 			return
 		}
+	case av.LayoutTypeBoard:
+		if err = gulu.JSON.UnmarshalJSON(data, &view.Board.Filters); err != nil {
+			return
+		}
+	case av.LayoutTypeCalendar, av.LayoutTypeTimeline:
+		if err = gulu.JSON.UnmarshalJSON(data, &view.GetCalendarLayout().Filters); err != nil {
+			return
+		}
+	case av.LayoutTypeGallery:
+		if err = gulu.JSON.UnmarshalJSON(data, &view.Gallery.Filters); err != nil {
+			return
+		}
 	}
 
 	err = av.SaveAttributeView(attrView)
 	return
 }
 
-func (tx *Transaction) doSetAttrViewSorts(operation *Operation) (ret *TxErr) {
-	err := setAttributeViewSorts(operation)
+func (tx *Transaction) doSetAttrViewGroupBy(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewGroupBy(operation)
 	if err != nil {
 		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
 	}
 	return
 }
 
-func setAttributeViewSorts(operation *Operation) (err error) {
+func setAttributeViewGroupBy(operation *Operation) (err error) {
 	attrView, err := av.ParseAttributeView(operation.AvID)
 	if err != nil {
 		return
@@ -1827,60 +2238,61 @@ func setAttributeViewSorts(operation *Operation) (err error) {
 		return
 	}
 
-	operationData := operation.Data.([]interface{})
-	data, err := gulu.JSON.MarshalJSON(operationData)
-	if err != nil {
+	if av.LayoutTypeTable != view.LayoutType {
 		return
 	}
 
-	switch view.LayoutType {
-	case av.LayoutTypeTable:
-		if err = gulu.JSON.UnmarshalJSON(data, &view.Table.Sorts); err != nil {
-			return
-		}
+	if nil == operation.Data {
+		// 取消分组
+		view.Table.GroupBy = nil
+		err = av.SaveAttributeView(attrView)
+		return
 	}
 
-	err = av.SaveAttributeView(attrView)
-	return
-}
-
-func (tx *Transaction) doSetAttrViewPageSize(operation *Operation) (ret *TxErr) {
-	err := setAttributeViewPageSize(operation)
+	data, err := gulu.JSON.MarshalJSON(operation.Data)
 	if err != nil {
-		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+		return
 	}
-	return
-}
 
-func setAttributeViewPageSize(operation *Operation) (err error) {
-	attrView, err := av.ParseAttributeView(operation.AvID)
-	if err != nil {
+	groupBy := &av.ViewGroupBy{}
+	if err = gulu.JSON.UnmarshalJSON(data, groupBy); err != nil {
 		return
 	}
 
-	view, err := getAttrViewViewByBlockID(attrView, operation.BlockID)
+	key, err := attrView.GetKey(groupBy.Column)
 	if err != nil {
 		return
 	}
 
-	switch view.LayoutType {
-	case av.LayoutTypeTable:
-		view.Table.PageSize = int(operation.Data.(float64))
+	switch groupBy.DateUnit {
+	case "", av.DateUnitDay, av.DateUnitWeek, av.DateUnitMonth:
+	default:
+		err = fmt.Errorf("invalid date unit [%s]", groupBy.DateUnit)
+		return
+	}
+	if !av.IsDateKeyType(key.Type) {
+		groupBy.DateUnit = ""
+	} else if "" == groupBy.DateUnit {
+		groupBy.DateUnit = av.DateUnitDay
+	}
+	if av.SortOrderDesc != groupBy.Order {
+		groupBy.Order = av.SortOrderAsc
 	}
 
+	view.Table.GroupBy = groupBy
 	err = av.SaveAttributeView(attrView)
 	return
 }
 
-func (tx *Transaction) doSetAttrViewColCalc(operation *Operation) (ret *TxErr) {
-	err := setAttributeViewColumnCalc(operation)
+func (tx *Transaction) doSetAttrViewSorts(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewSorts(operation)
 	if err != nil {
 		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
 	}
 	return
 }
 
-func setAttributeViewColumnCalc(operation *Operation) (err error) {
+func setAttributeViewSorts(operation *Operation) (err error) {
 	attrView, err := av.ParseAttributeView(operation.AvID)
 	if err != nil {
 		return
@@ -1891,16 +2303,98 @@ func setAttributeViewColumnCalc(operation *Operation) (err error) {
 		return
 	}
 
-	operationData := operation.Data.(interface{})
+	operationData := operation.Data.([]interface{})
 	data, err := gulu.JSON.MarshalJSON(operationData)
 	if err != nil {
 		return
 	}
 
-	calc := &av.ColumnCalc{}
 	switch view.LayoutType {
 	case av.LayoutTypeTable:
-		if err = gulu.JSON.UnmarshalJSON(data, calc); err != nil {
+		if err = gulu.JSON.UnmarshalJSON(data, &view.Table.Sorts); err != nil {
+			return
+		}
+	case av.LayoutTypeBoard:
+		if err = gulu.JSON.UnmarshalJSON(data, &view.Board.Sorts); err != nil {
+			return
+		}
+	case av.LayoutTypeCalendar, av.LayoutTypeTimeline:
+		if err = gulu.JSON.UnmarshalJSON(data, &view.GetCalendarLayout().Sorts); err != nil {
+			return
+		}
+	case av.LayoutTypeGallery:
+		if err = gulu.JSON.UnmarshalJSON(data, &view.Gallery.Sorts); err != nil {
+			return
+		}
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewPageSize(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewPageSize(operation)
+	if err != nil {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewPageSize(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if err != nil {
+		return
+	}
+
+	view, err := getAttrViewViewByBlockID(attrView, operation.BlockID)
+	if err != nil {
+		return
+	}
+
+	switch view.LayoutType {
+	case av.LayoutTypeTable:
+		view.Table.PageSize = int(operation.Data.(float64))
+	case av.LayoutTypeBoard:
+		view.Board.PageSize = int(operation.Data.(float64))
+	case av.LayoutTypeCalendar, av.LayoutTypeTimeline:
+		view.GetCalendarLayout().PageSize = int(operation.Data.(float64))
+	case av.LayoutTypeGallery:
+		view.Gallery.PageSize = int(operation.Data.(float64))
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewColCalc(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewColumnCalc(operation)
+	if err != nil {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewColumnCalc(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if err != nil {
+		return
+	}
+
+	view, err := getAttrViewViewByBlockID(attrView, operation.BlockID)
+	if err != nil {
+		return
+	}
+
+	operationData := operation.Data.(interface{})
+	data, err := gulu.JSON.MarshalJSON(operationData)
+	if err != nil {
+		return
+	}
+
+	calc := &av.ColumnCalc{}
+	switch view.LayoutType {
+	case av.LayoutTypeTable:
+		if err = gulu.JSON.UnmarshalJSON(data, calc); err != nil {
 			return
 		}
 
@@ -1921,6 +2415,17 @@ func (tx *Transaction) doInsertAttrViewBlock(operation *Operation) (ret *TxErr)
 	if err != nil {
 		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
 	}
+
+	if attrView, _ := av.ParseAttributeView(operation.AvID); nil != attrView {
+		var changes []*av.Change
+		for _, src := range operation.Srcs {
+			srcID, _ := src["id"].(string)
+			if values := attrView.GetRowValues(srcID); 0 < len(values) {
+				changes = append(changes, &av.Change{Action: av.ChangeActionInsertRow, RowID: srcID, NewValues: values})
+			}
+		}
+		tx.recordAttrViewChanges(operation.AvID, changes)
+	}
 	return
 }
 
@@ -2011,78 +2516,13 @@ func addAttributeViewBlock(now int64, avID, blockID, previousBlockID, addingBloc
 		Block:      &av.ValueBlock{ID: addingBlockID, Content: addingBlockContent, Created: now, Updated: now}}
 	blockValues.Values = append(blockValues.Values, blockValue)
 
-	// 如果存在过滤条件，则将过滤条件应用到新添加的块上
+	// 如果存在过滤条件，则将过滤条件应用到新添加的块上，看板、日历、时间线和画廊的行同样通过表格过滤和排序
 	view, _ := getAttrViewViewByBlockID(attrView, blockID)
-	if nil != view && 0 < len(view.Table.Filters) && !ignoreFillFilter {
+	if nil != view && 0 < len(view.GetFilters()) && !ignoreFillFilter {
 		viewable := sql.RenderAttributeViewTable(attrView, view, "")
 		viewable.FilterRows(attrView)
 		viewable.SortRows(attrView)
-
-		var nearRow *av.TableRow
-		if 0 < len(viewable.Rows) {
-			if "" != previousBlockID {
-				for _, row := range viewable.Rows {
-					if row.ID == previousBlockID {
-						nearRow = row
-						break
-					}
-				}
-			} else {
-				if 0 < len(viewable.Rows) {
-					nearRow = viewable.Rows[0]
-				}
-			}
-		}
-
-		sameKeyFilterSort := false // 是否在同一个字段上同时存在过滤和排序
-		if 0 < len(viewable.Sorts) {
-			filterKeys, sortKeys := map[string]bool{}, map[string]bool{}
-			for _, f := range view.Table.Filters {
-				filterKeys[f.Column] = true
-			}
-			for _, s := range view.Table.Sorts {
-				sortKeys[s.Column] = true
-			}
-
-			for key := range filterKeys {
-				if sortKeys[key] {
-					sameKeyFilterSort = true
-					break
-				}
-			}
-		}
-
-		if !sameKeyFilterSort {
-			// 如果在同一个字段上仅存在过滤条件，则将过滤条件应用到新添加的块上
-			for _, filter := range view.Table.Filters {
-				for _, keyValues := range attrView.KeyValues {
-					if keyValues.Key.ID == filter.Column {
-						var defaultVal *av.Value
-						if nil != nearRow {
-							defaultVal = nearRow.GetValue(filter.Column)
-						}
-
-						newValue := filter.GetAffectValue(keyValues.Key, defaultVal)
-						if nil == newValue {
-							continue
-						}
-
-						if av.KeyTypeBlock == newValue.Type {
-							// 如果是主键的话前面已经添加过了，这里仅修改内容
-							blockValue.Block.Content = newValue.Block.Content
-							break
-						}
-
-						newValue.ID = ast.NewNodeID()
-						newValue.KeyID = keyValues.Key.ID
-						newValue.BlockID = addingBlockID
-						newValue.IsDetached = isDetached
-						keyValues.Values = append(keyValues.Values, newValue)
-						break
-					}
-				}
-			}
-		}
+		av.FillNewRowFilterValues(attrView, view, viewable.Rows, previousBlockID, blockValue)
 	}
 
 	// 处理日期字段默认填充当前创建时间
@@ -2127,10 +2567,21 @@ func addAttributeViewBlock(now int64, avID, blockID, previousBlockID, addingBloc
 }
 
 func (tx *Transaction) doRemoveAttrViewBlock(operation *Operation) (ret *TxErr) {
+	var changes []*av.Change
+	if attrView, _ := av.ParseAttributeView(operation.AvID); nil != attrView {
+		for _, srcID := range operation.SrcIDs {
+			if values := attrView.GetRowValues(srcID); 0 < len(values) {
+				changes = append(changes, &av.Change{Action: av.ChangeActionRemoveRow, RowID: srcID, OldValues: values})
+			}
+		}
+	}
+
 	err := removeAttributeViewBlock(operation.SrcIDs, operation.AvID, tx)
 	if err != nil {
 		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID}
 	}
+
+	tx.recordAttrViewChanges(operation.AvID, changes)
 	return
 }
 
@@ -2174,8 +2625,13 @@ func removeAttributeViewBlock(srcIDs []string, avID string, tx *Transaction) (er
 	}
 
 	for _, view := range attrView.Views {
-		for _, blockID := range srcIDs {
-			view.Table.RowIDs = gulu.Str.RemoveElem(view.Table.RowIDs, blockID)
+		switch view.LayoutType {
+		case av.LayoutTypeTable:
+			for _, blockID := range srcIDs {
+				view.Table.RowIDs = gulu.Str.RemoveElem(view.Table.RowIDs, blockID)
+			}
+		case av.LayoutTypeBoard:
+			view.Board.RemoveCards(srcIDs)
 		}
 	}
 
@@ -2378,6 +2834,13 @@ func setAttributeViewColHidden(operation *Operation) (err error) {
 				break
 			}
 		}
+	case av.LayoutTypeBoard:
+		view.Board.Fields = av.SetViewFieldHidden(view.Board.Fields, operation.ID, operation.Data.(bool))
+	case av.LayoutTypeCalendar, av.LayoutTypeTimeline:
+		layout := view.GetCalendarLayout()
+		layout.Fields = av.SetViewFieldHidden(layout.Fields, operation.ID, operation.Data.(bool))
+	case av.LayoutTypeGallery:
+		view.Gallery.Fields = av.SetViewFieldHidden(view.Gallery.Fields, operation.ID, operation.Data.(bool))
 	}
 
 	err = av.SaveAttributeView(attrView)
@@ -2491,23 +2954,23 @@ func sortAttributeViewRow(operation *Operation) (err error) {
 		return
 	}
 
-	var rowID string
-	var idx, previousIndex int
-	for i, r := range view.Table.RowIDs {
-		if r == operation.ID {
-			rowID = r
-			idx = i
-			break
-		}
-	}
-	if "" == rowID {
-		rowID = operation.ID
-		view.Table.RowIDs = append(view.Table.RowIDs, rowID)
-		idx = len(view.Table.RowIDs) - 1
-	}
-
 	switch view.LayoutType {
 	case av.LayoutTypeTable:
+		var rowID string
+		var idx, previousIndex int
+		for i, r := range view.Table.RowIDs {
+			if r == operation.ID {
+				rowID = r
+				idx = i
+				break
+			}
+		}
+		if "" == rowID {
+			rowID = operation.ID
+			view.Table.RowIDs = append(view.Table.RowIDs, rowID)
+			idx = len(view.Table.RowIDs) - 1
+		}
+
 		view.Table.RowIDs = append(view.Table.RowIDs[:idx], view.Table.RowIDs[idx+1:]...)
 		for i, r := range view.Table.RowIDs {
 			if r == operation.PreviousID {
@@ -2515,13 +2978,255 @@ func sortAttributeViewRow(operation *Operation) (err error) {
 				break
 			}
 		}
-		view.Table.RowIDs = util.InsertElem(view.Table.RowIDs, previousIndex, rowID)
+		view.Table.RowIDs = util.InsertElem(view.Table.RowIDs, previousIndex, rowID)
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewBoardGroupKey(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewBoardGroupKey(operation)
+	if err != nil {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewBoardGroupKey(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if err != nil {
+		return
+	}
+
+	view, err := getAttrViewViewByBlockID(attrView, operation.BlockID)
+	if err != nil {
+		return
+	}
+
+	if av.LayoutTypeBoard != view.LayoutType {
+		return
+	}
+
+	key, err := attrView.GetKey(operation.KeyID)
+	if err != nil {
+		return
+	}
+
+	if !av.IsGroupKeyType(key.Type) {
+		err = fmt.Errorf("key [%s] type [%s] can not be used to group board", key.ID, key.Type)
+		return
+	}
+
+	if view.Board.GroupKeyID != key.ID {
+		view.Board.GroupKeyID = key.ID
+		view.Board.Lanes = []*av.ViewBoardLane{}
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doSetAttrViewDateKeys(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewDateKeys(operation)
+	if err != nil {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewDateKeys(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if err != nil {
+		return
+	}
+
+	view, err := getAttrViewViewByBlockID(attrView, operation.BlockID)
+	if err != nil {
+		return
+	}
+
+	layout := view.GetCalendarLayout()
+	if nil == layout {
+		return
+	}
+
+	data := operation.Data.(map[string]interface{})
+	if startKeyID, ok := data["startKeyID"].(string); ok {
+		if err = checkAttrViewDateKey(attrView, startKeyID); err != nil {
+			return
+		}
+		layout.StartKeyID = startKeyID
+	}
+	if endKeyID, ok := data["endKeyID"].(string); ok {
+		if "" != endKeyID {
+			if err = checkAttrViewDateKey(attrView, endKeyID); err != nil {
+				return
+			}
+		}
+		layout.EndKeyID = endKeyID
+	}
+	if unit, ok := data["unit"].(string); ok {
+		switch av.DateUnit(unit) {
+		case av.DateUnitDay, av.DateUnitWeek, av.DateUnitMonth:
+			layout.Unit = av.DateUnit(unit)
+		default:
+			err = fmt.Errorf("invalid date unit [%s]", unit)
+			return
+		}
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func checkAttrViewDateKey(attrView *av.AttributeView, keyID string) (err error) {
+	key, err := attrView.GetKey(keyID)
+	if err != nil {
+		return
+	}
+
+	if !av.IsDateKeyType(key.Type) {
+		err = fmt.Errorf("key [%s] type [%s] is not a date type", key.ID, key.Type)
+	}
+	return
+}
+
+func (tx *Transaction) doSetAttrViewGalleryCover(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewGalleryCover(operation)
+	if err != nil {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewGalleryCover(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if err != nil {
+		return
+	}
+
+	view, err := getAttrViewViewByBlockID(attrView, operation.BlockID)
+	if err != nil {
+		return
+	}
+
+	if av.LayoutTypeGallery != view.LayoutType {
+		return
+	}
+
+	data := operation.Data.(map[string]interface{})
+	coverFrom, _ := data["coverFrom"].(string)
+	coverKeyID, _ := data["coverKeyID"].(string)
+	switch av.CoverFrom(coverFrom) {
+	case av.CoverFromAssetKey:
+		key, getErr := attrView.GetKey(coverKeyID)
+		if nil != getErr {
+			err = getErr
+			return
+		}
+		if av.KeyTypeMAsset != key.Type {
+			err = fmt.Errorf("key [%s] type [%s] is not an asset type", key.ID, key.Type)
+			return
+		}
+	case av.CoverFromContent, av.CoverFromNone:
+		coverKeyID = ""
+	default:
+		err = fmt.Errorf("invalid cover from [%s]", coverFrom)
+		return
+	}
+
+	view.Gallery.CoverFrom = av.CoverFrom(coverFrom)
+	view.Gallery.CoverKeyID = coverKeyID
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doMoveAttrViewCard(operation *Operation) (ret *TxErr) {
+	err := moveAttributeViewCard(operation, tx)
+	if err != nil {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func moveAttributeViewCard(operation *Operation, tx *Transaction) (err error) {
+	if operation.ID == operation.PreviousID {
+		return
+	}
+
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if err != nil {
+		return
+	}
+
+	view, err := getAttrViewViewByBlockID(attrView, operation.BlockID)
+	if err != nil {
+		return
+	}
+
+	if av.LayoutTypeBoard != view.LayoutType {
+		return
+	}
+
+	if operation.SrcLaneID != operation.LaneID {
+		// 跨泳道移动时需要改写分组字段的值
+		groupKey, _ := attrView.GetKey(view.Board.GroupKeyID)
+		if nil != groupKey && av.IsGroupKeyType(groupKey.Type) {
+			val := getBoardLaneMovedValue(attrView, groupKey, operation.ID, operation.SrcLaneID, operation.LaneID)
+			if _, err = UpdateAttributeViewCell(tx, attrView.ID, groupKey.ID, operation.ID, val); err != nil {
+				return
+			}
+
+			// 更新单元格时已经保存过属性视图，这里需要重新读取
+			if attrView, err = av.ParseAttributeView(operation.AvID); err != nil {
+				return
+			}
+			if view, err = getAttrViewViewByBlockID(attrView, operation.BlockID); err != nil {
+				return
+			}
+		}
 	}
 
+	view.Board.MoveCard(operation.ID, operation.SrcLaneID, operation.LaneID, operation.PreviousID)
 	err = av.SaveAttributeView(attrView)
 	return
 }
 
+func getBoardLaneMovedValue(attrView *av.AttributeView, groupKey *av.Key, rowID, srcLaneID, laneID string) (ret *av.Value) {
+	ret = &av.Value{Type: groupKey.Type}
+	switch groupKey.Type {
+	case av.KeyTypeSelect:
+		ret.MSelect = []*av.ValueSelect{}
+		if "" != laneID {
+			ret.MSelect = append(ret.MSelect, newBoardLaneSelectValue(groupKey, laneID))
+		}
+	case av.KeyTypeMSelect:
+		ret.MSelect = []*av.ValueSelect{}
+		if oldVal := attrView.GetValue(groupKey.ID, rowID); nil != oldVal {
+			for _, opt := range oldVal.MSelect {
+				if opt.Content != srcLaneID && opt.Content != laneID {
+					ret.MSelect = append(ret.MSelect, opt)
+				}
+			}
+		}
+		if "" != laneID {
+			ret.MSelect = append(ret.MSelect, newBoardLaneSelectValue(groupKey, laneID))
+		}
+	case av.KeyTypeCheckbox:
+		ret.Checkbox = &av.ValueCheckbox{Checked: av.BoardLaneChecked == laneID}
+	}
+	return
+}
+
+func newBoardLaneSelectValue(groupKey *av.Key, laneID string) (ret *av.ValueSelect) {
+	ret = &av.ValueSelect{Content: laneID}
+	if opt := groupKey.GetOption(laneID); nil != opt {
+		ret.Color = opt.Color
+	}
+	return
+}
+
 func (tx *Transaction) doSortAttrViewColumn(operation *Operation) (ret *TxErr) {
 	err := SortAttributeViewViewKey(operation.AvID, operation.BlockID, operation.ID, operation.PreviousID)
 	if err != nil {
@@ -2569,6 +3274,13 @@ func SortAttributeViewViewKey(avID, blockID, keyID, previousKeyID string) (err e
 			}
 		}
 		view.Table.Columns = util.InsertElem(view.Table.Columns, previousIndex, col)
+	case av.LayoutTypeBoard:
+		view.Board.Fields = av.SortViewField(view.Board.Fields, keyID, previousKeyID)
+	case av.LayoutTypeCalendar, av.LayoutTypeTimeline:
+		layout := view.GetCalendarLayout()
+		layout.Fields = av.SortViewField(layout.Fields, keyID, previousKeyID)
+	case av.LayoutTypeGallery:
+		view.Gallery.Fields = av.SortViewField(view.Gallery.Fields, keyID, previousKeyID)
 	}
 
 	err = av.SaveAttributeView(attrView)
@@ -2672,7 +3384,7 @@ func AddAttributeViewKey(avID, keyID, keyName, keyType, keyIcon, previousKeyID s
 	switch keyTyp {
 	case av.KeyTypeText, av.KeyTypeNumber, av.KeyTypeDate, av.KeyTypeSelect, av.KeyTypeMSelect, av.KeyTypeURL, av.KeyTypeEmail,
 		av.KeyTypePhone, av.KeyTypeMAsset, av.KeyTypeTemplate, av.KeyTypeCreated, av.KeyTypeUpdated, av.KeyTypeCheckbox,
-		av.KeyTypeRelation, av.KeyTypeRollup, av.KeyTypeLineNumber:
+		av.KeyTypeRelation, av.KeyTypeRollup, av.KeyTypeLineNumber, av.KeyTypeFormula, av.KeyTypeUniqueID:
 
 		key := av.NewKey(keyID, keyName, keyIcon, keyTyp)
 		if av.KeyTypeRollup == keyTyp {
@@ -2737,6 +3449,125 @@ func updateAttributeViewColTemplate(operation *Operation) (err error) {
 	return
 }
 
+func (tx *Transaction) doUpdateAttrViewColFormula(operation *Operation) (ret *TxErr) {
+	err := updateAttributeViewColFormula(operation)
+	if err != nil {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func updateAttributeViewColFormula(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if err != nil {
+		return
+	}
+
+	formula := operation.Data.(string)
+	if err = av.CheckFormula(formula); err != nil {
+		return
+	}
+
+	for _, keyValues := range attrView.KeyValues {
+		if keyValues.Key.ID == operation.ID && av.KeyTypeFormula == keyValues.Key.Type {
+			keyValues.Key.Formula = formula
+			break
+		}
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func GetAttributeViewViolations(avID string) (ret []*av.Violation, err error) {
+	waitForSyncingStorages()
+
+	attrView, err := av.ParseAttributeView(avID)
+	if err != nil {
+		logging.LogErrorf("parse attribute view [%s] failed: %s", avID, err)
+		return
+	}
+
+	ret = attrView.GetViolations()
+	return
+}
+
+func (tx *Transaction) doSetAttrViewColValidation(operation *Operation) (ret *TxErr) {
+	err := setAttributeViewColValidation(operation)
+	if err != nil {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func setAttributeViewColValidation(operation *Operation) (err error) {
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if err != nil {
+		return
+	}
+
+	key, err := attrView.GetKey(operation.ID)
+	if err != nil {
+		return
+	}
+
+	// Data 为空时删除校验规则
+	var validation *av.Validation
+	if nil != operation.Data {
+		data, marshalErr := gulu.JSON.MarshalJSON(operation.Data)
+		if nil != marshalErr {
+			return marshalErr
+		}
+		validation = &av.Validation{}
+		if err = gulu.JSON.UnmarshalJSON(data, validation); err != nil {
+			return
+		}
+	}
+
+	if err = av.CheckValidation(key.Type, validation); err != nil {
+		return
+	}
+	key.Validation = validation
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
+func (tx *Transaction) doUpdateAttrViewColUniqueIDPrefix(operation *Operation) (ret *TxErr) {
+	err := updateAttributeViewColUniqueIDPrefix(operation)
+	if err != nil {
+		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
+	}
+	return
+}
+
+func updateAttributeViewColUniqueIDPrefix(operation *Operation) (err error) {
+	prefix, ok := operation.Data.(string)
+	if !ok {
+		err = fmt.Errorf("invalid unique ID prefix [%v]", operation.Data)
+		return
+	}
+
+	attrView, err := av.ParseAttributeView(operation.AvID)
+	if err != nil {
+		return
+	}
+
+	for _, keyValues := range attrView.KeyValues {
+		if keyValues.Key.ID == operation.ID && av.KeyTypeUniqueID == keyValues.Key.Type {
+			if nil == keyValues.Key.UniqueID {
+				keyValues.Key.UniqueID = &av.UniqueID{}
+			}
+			// 已分配的编号在保存时使用新前缀刷新
+			keyValues.Key.UniqueID.Prefix = strings.TrimSpace(prefix)
+			break
+		}
+	}
+
+	err = av.SaveAttributeView(attrView)
+	return
+}
+
 func (tx *Transaction) doUpdateAttrViewColNumberFormat(operation *Operation) (ret *TxErr) {
 	err := updateAttributeViewColNumberFormat(operation)
 	if err != nil {
@@ -2753,9 +3584,9 @@ func updateAttributeViewColNumberFormat(operation *Operation) (err error) {
 
 	colType := av.KeyType(operation.Typ)
 	switch colType {
-	case av.KeyTypeNumber:
+	case av.KeyTypeNumber, av.KeyTypeFormula:
 		for _, keyValues := range attrView.KeyValues {
-			if keyValues.Key.ID == operation.ID && av.KeyTypeNumber == keyValues.Key.Type {
+			if keyValues.Key.ID == operation.ID && colType == keyValues.Key.Type {
 				keyValues.Key.NumberFormat = av.NumberFormat(operation.Format)
 				break
 			}
@@ -2784,7 +3615,7 @@ func updateAttributeViewColumn(operation *Operation) (err error) {
 	switch colType {
 	case av.KeyTypeBlock, av.KeyTypeText, av.KeyTypeNumber, av.KeyTypeDate, av.KeyTypeSelect, av.KeyTypeMSelect, av.KeyTypeURL, av.KeyTypeEmail,
 		av.KeyTypePhone, av.KeyTypeMAsset, av.KeyTypeTemplate, av.KeyTypeCreated, av.KeyTypeUpdated, av.KeyTypeCheckbox,
-		av.KeyTypeRelation, av.KeyTypeRollup, av.KeyTypeLineNumber:
+		av.KeyTypeRelation, av.KeyTypeRollup, av.KeyTypeLineNumber, av.KeyTypeFormula, av.KeyTypeUniqueID:
 		for _, keyValues := range attrView.KeyValues {
 			if keyValues.Key.ID == operation.ID {
 				keyValues.Key.Name = strings.TrimSpace(operation.Name)
@@ -3001,6 +3832,8 @@ func replaceAttributeViewBlock(operation *Operation, tx *Transaction) (err error
 			if !replacedRowID {
 				v.Table.RowIDs = append(v.Table.RowIDs, operation.NextID)
 			}
+		case av.LayoutTypeBoard:
+			v.Board.ReplaceCard(operation.PreviousID, operation.NextID)
 		}
 	}
 
@@ -3014,15 +3847,223 @@ func replaceAttributeViewBlock(operation *Operation, tx *Transaction) (err error
 }
 
 func (tx *Transaction) doUpdateAttrViewCell(operation *Operation) (ret *TxErr) {
-	err := updateAttributeViewCell(operation, tx)
+	var oldValue *av.Value
+	if attrView, _ := av.ParseAttributeView(operation.AvID); nil != attrView {
+		oldValue = attrView.GetValue(operation.KeyID, operation.RowID)
+	}
+
+	newValue, err := updateAttributeViewCell(operation, tx)
 	if err != nil {
+		var validationErr *av.ValidationError
+		if errors.As(err, &validationErr) {
+			return &TxErr{code: TxErrAttributeViewValidation, id: operation.AvID, msg: err.Error(), data: validationErr.Violations}
+		}
 		return &TxErr{code: TxErrWriteAttributeView, id: operation.AvID, msg: err.Error()}
 	}
+
+	change := &av.Change{Action: av.ChangeActionUpdateCell, RowID: operation.RowID, KeyID: operation.KeyID, NewValues: []*av.Value{newValue}}
+	if nil != oldValue {
+		change.OldValues = []*av.Value{oldValue}
+	}
+	tx.recordAttrViewChanges(operation.AvID, []*av.Change{change})
+	return
+}
+
+func updateAttributeViewCell(operation *Operation, tx *Transaction) (val *av.Value, err error) {
+	val, err = UpdateAttributeViewCell(tx, operation.AvID, operation.KeyID, operation.RowID, operation.Data)
+	return
+}
+
+// recordAttrViewChanges 记录属性视图变更日志，记录失败不影响事务提交。
+func (tx *Transaction) recordAttrViewChanges(avID string, changes []*av.Change) {
+	now := util.CurrentTimeMillis()
+	for _, change := range changes {
+		change.ID = ast.NewNodeID()
+		change.App = tx.App
+		change.Session = tx.Session
+		change.Created = now
+	}
+
+	if err := av.AppendChanges(avID, changes); err != nil {
+		logging.LogErrorf("record attribute view [%s] changes failed: %s", avID, err)
+	}
+}
+
+// GetAttributeViewHistory 返回属性视图的变更日志，按变更时间降序排列，rowID 和 keyID 不为空时仅返回对应行和字段的变更。
+func GetAttributeViewHistory(avID, rowID, keyID string, page, pageSize int) (ret []*av.Change, total int, err error) {
+	ret = []*av.Change{}
+	changes, err := av.GetChanges(avID)
+	if err != nil {
+		return
+	}
+
+	for i := len(changes) - 1; 0 <= i; i-- {
+		change := changes[i]
+		if "" != rowID && change.RowID != rowID {
+			continue
+		}
+		if "" != keyID && change.KeyID != keyID {
+			continue
+		}
+		ret = append(ret, change)
+	}
+
+	total = len(ret)
+	if 1 > page {
+		page = 1
+	}
+	if 1 > pageSize {
+		pageSize = 32
+	}
+	start := (page - 1) * pageSize
+	if start > total {
+		start = total
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+	ret = ret[start:end]
+	return
+}
+
+// RollbackAttributeViewChange 回滚属性视图中的一次单元格或者行变更，回滚本身也会记录为变更。
+func RollbackAttributeViewChange(app, session, avID, changeID string) (err error) {
+	change, err := av.GetChange(avID, changeID)
+	if err != nil {
+		return
+	}
+
+	attrView, err := av.ParseAttributeView(avID)
+	if err != nil {
+		return
+	}
+
+	var rollback *av.Change
+	switch change.Action {
+	case av.ChangeActionUpdateCell:
+		if rollback, err = rollbackAttributeViewCell(attrView, change); err != nil {
+			return
+		}
+	case av.ChangeActionInsertRow:
+		values := attrView.GetRowValues(change.RowID)
+		if 1 > len(values) {
+			err = fmt.Errorf("row [%s] not found", change.RowID)
+			return
+		}
+
+		if err = removeAttributeViewBlock([]string{change.RowID}, avID, nil); err != nil {
+			return
+		}
+		rollback = &av.Change{Action: av.ChangeActionRemoveRow, RowID: change.RowID, OldValues: values}
+	case av.ChangeActionRemoveRow:
+		if rollback, err = rollbackAttributeViewRow(attrView, change); err != nil {
+			return
+		}
+	default:
+		err = fmt.Errorf("unsupported change action [%s]", change.Action)
+		return
+	}
+
+	rollback.RollbackOf = change.ID
+	tx := &Transaction{App: app, Session: session}
+	tx.recordAttrViewChanges(avID, []*av.Change{rollback})
+	ReloadAttrView(avID)
+	return
+}
+
+func rollbackAttributeViewCell(attrView *av.AttributeView, change *av.Change) (ret *av.Change, err error) {
+	keyValues, err := attrView.GetKeyValues(change.KeyID)
+	if err != nil {
+		return
+	}
+	if nil == attrView.GetValue(attrView.GetBlockKey().ID, change.RowID) {
+		err = fmt.Errorf("row [%s] not found", change.RowID)
+		return
+	}
+
+	ret = &av.Change{Action: av.ChangeActionUpdateCell, RowID: change.RowID, KeyID: change.KeyID}
+	var oldValue *av.Value
+	if 0 < len(change.OldValues) {
+		oldValue = change.OldValues[0]
+	}
+
+	index := -1
+	for i, v := range keyValues.Values {
+		if v.BlockID == change.RowID {
+			index = i
+			ret.OldValues = []*av.Value{v}
+			break
+		}
+	}
+
+	if nil == oldValue {
+		// 变更前单元格为空，回滚时删除单元格的值
+		if av.KeyTypeBlock == keyValues.Key.Type {
+			err = errors.New("can not remove primary key value")
+			return
+		}
+		if 0 <= index {
+			keyValues.Values = append(keyValues.Values[:index], keyValues.Values[index+1:]...)
+		}
+	} else {
+		oldValue.Type = keyValues.Key.Type
+		oldValue.UpdatedAt = util.CurrentTimeMillis()
+		if 0 <= index {
+			keyValues.Values[index] = oldValue
+		} else {
+			keyValues.Values = append(keyValues.Values, oldValue)
+		}
+		ret.NewValues = []*av.Value{oldValue}
+	}
+
+	err = av.SaveAttributeView(attrView)
 	return
 }
 
-func updateAttributeViewCell(operation *Operation, tx *Transaction) (err error) {
-	_, err = UpdateAttributeViewCell(tx, operation.AvID, operation.KeyID, operation.RowID, operation.Data)
+func rollbackAttributeViewRow(attrView *av.AttributeView, change *av.Change) (ret *av.Change, err error) {
+	if 0 < len(attrView.GetRowValues(change.RowID)) {
+		err = fmt.Errorf("row [%s] already exists", change.RowID)
+		return
+	}
+
+	// 绑定块已经被删除的话恢复为非绑定块行
+	isDetached := true
+	for _, v := range change.OldValues {
+		if av.KeyTypeBlock == v.Type {
+			isDetached = v.IsDetached || !treenode.ExistBlockTree(change.RowID)
+			break
+		}
+	}
+
+	for _, v := range change.OldValues {
+		keyValues, _ := attrView.GetKeyValues(v.KeyID)
+		if nil == keyValues {
+			// 字段已经被删除
+			continue
+		}
+
+		v.IsDetached = isDetached
+		keyValues.Values = append(keyValues.Values, v)
+	}
+
+	for _, view := range attrView.Views {
+		switch view.LayoutType {
+		case av.LayoutTypeTable:
+			if !gulu.Str.Contains(change.RowID, view.Table.RowIDs) {
+				view.Table.RowIDs = append(view.Table.RowIDs, change.RowID)
+			}
+		}
+	}
+
+	if err = av.SaveAttributeView(attrView); err != nil {
+		return
+	}
+
+	if !isDetached {
+		bindBlockAv(nil, attrView.ID, change.RowID)
+	}
+	ret = &av.Change{Action: av.ChangeActionInsertRow, RowID: change.RowID, NewValues: attrView.GetRowValues(change.RowID)}
 	return
 }
 
@@ -3032,6 +4073,12 @@ func UpdateAttributeViewCell(tx *Transaction, avID, keyID, rowID string, valueDa
 		return
 	}
 
+	if key, _ := attrView.GetKey(keyID); nil != key && av.KeyTypeUniqueID == key.Type {
+		// 唯一 ID 在添加行时自动分配，不支持修改
+		err = fmt.Errorf("unique ID key [%s] is read-only", key.Name)
+		return
+	}
+
 	var blockVal *av.Value
 	for _, kv := range attrView.KeyValues {
 		if av.KeyTypeBlock == kv.Key.Type {
@@ -3090,6 +4137,14 @@ func UpdateAttributeViewCell(tx *Transaction, avID, keyID, rowID string, valueDa
 
 	key, _ := attrView.GetKey(keyID)
 
+	// 需要在保存新选项之前校验，否则仅允许使用已有选项的规则不会生效
+	if nil != key {
+		if violations := attrView.ValidateValue(key, rowID, val); 0 < len(violations) {
+			err = &av.ValidationError{Violations: violations}
+			return
+		}
+	}
+
 	if av.KeyTypeNumber == val.Type {
 		if nil != val.Number {
 			if !val.Number.IsNotEmpty {
@@ -3538,11 +4593,55 @@ func updateAttributeViewColumnOption(operation *Operation) (err error) {
 	// Database select field filters follow option editing changes https://github.com/siyuan-note/siyuan/issues/10881
 	for _, view := range attrView.Views {
 		switch view.LayoutType {
+		case av.LayoutTypeCalendar, av.LayoutTypeTimeline, av.LayoutTypeGallery:
+			av.WalkFilters(view.GetFilters(), func(filter *av.ViewFilter) {
+				if filter.Column != key.ID {
+					return
+				}
+
+				if nil != filter.Value && (av.KeyTypeSelect == filter.Value.Type || av.KeyTypeMSelect == filter.Value.Type) {
+					for i, opt := range filter.Value.MSelect {
+						if oldName == opt.Content {
+							filter.Value.MSelect[i].Content = newName
+							filter.Value.MSelect[i].Color = newColor
+							break
+						}
+					}
+				}
+			})
+		case av.LayoutTypeBoard:
+			// 看板泳道跟随选项名称变更
+			if view.Board.GroupKeyID == key.ID && rename {
+				if lane := view.Board.GetLane(oldName); nil != lane {
+					if newLane := view.Board.GetLane(newName); nil != newLane {
+						newLane.CardIDs = append(newLane.CardIDs, lane.CardIDs...)
+						view.Board.Lanes = slices.DeleteFunc(view.Board.Lanes, func(l *av.ViewBoardLane) bool { return l == lane })
+					} else {
+						lane.ID = newName
+					}
+				}
+			}
+
+			av.WalkFilters(view.Board.Filters, func(filter *av.ViewFilter) {
+				if filter.Column != key.ID {
+					return
+				}
+
+				if nil != filter.Value && (av.KeyTypeSelect == filter.Value.Type || av.KeyTypeMSelect == filter.Value.Type) {
+					for i, opt := range filter.Value.MSelect {
+						if oldName == opt.Content {
+							filter.Value.MSelect[i].Content = newName
+							filter.Value.MSelect[i].Color = newColor
+							break
+						}
+					}
+				}
+			})
 		case av.LayoutTypeTable:
 			table := view.Table
-			for _, filter := range table.Filters {
+			av.WalkFilters(table.Filters, func(filter *av.ViewFilter) {
 				if filter.Column != key.ID {
-					continue
+					return
 				}
 
 				if nil != filter.Value && (av.KeyTypeSelect == filter.Value.Type || av.KeyTypeMSelect == filter.Value.Type) {
@@ -3554,7 +4653,7 @@ func updateAttributeViewColumnOption(operation *Operation) (err error) {
 						}
 					}
 				}
-			}
+			})
 		}
 	}
 
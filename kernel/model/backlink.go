@@ -348,6 +348,11 @@ func GetBacklink2(c *gin.Context, id, keyword, mentionKeyword string, sortMode,
 		return backmentions[i].ID > backmentions[j].ID
 	})
 
+	// 过滤没有读权限的文档
+	backlinks, removedCount := filterACLBacklinks(c, backlinks)
+	linkRefsCount -= removedCount
+	backmentions, _ = filterACLBacklinks(c, backmentions)
+
 	for _, backmention := range backmentions {
 		mentionsCount += backmention.Count
 	}
@@ -702,7 +707,7 @@ func searchBackmention(mentionKeywords []string, keyword string, excludeBacklink
 	}
 
 	buf := bytes.Buffer{}
-	buf.WriteString("SELECT * FROM " + table + " WHERE " + table + " MATCH '" + columnFilter() + ":(")
+	buf.WriteString("SELECT id, parent_id, root_id, hash, box, path, hpath, name, alias, memo, tag, content, fcontent, markdown, length, type, subtype, ial, sort, created, updated FROM " + table + " WHERE " + table + " MATCH '" + columnFilter() + ":(")
 	for i, mentionKeyword := range mentionKeywords {
 		if Conf.Search.BacklinkMentionKeywordsLimit < i {
 			util.PushMsg(fmt.Sprintf(Conf.Language(38), len(mentionKeywords)), 5000)
@@ -89,11 +89,11 @@ func StatJob() {
 
 func ListNotebooks(c *gin.Context) (ret []*Box, err error) {
 	ret = []*Box{}
-	userNo := GetGinContextUser(c)
+	dataDir := GetDataDir(c)
 
-	dirs, err := os.ReadDir(filepath.Join(util.DataDir, userNo))
+	dirs, err := os.ReadDir(dataDir)
 	if err != nil {
-		logging.LogErrorf("read dir [%s], user[%s] failed: %s", util.DataDir, userNo, err)
+		logging.LogErrorf("read dir [%s] failed: %s", dataDir, err)
 		return ret, err
 	}
 	for _, dir := range dirs {
@@ -109,8 +109,12 @@ func ListNotebooks(c *gin.Context) (ret []*Box, err error) {
 			continue
 		}
 
+		if !IsAPITokenBoxAllowed(c, dir.Name()) || !isACLBoxVisible(c, dir.Name()) {
+			continue
+		}
+
 		boxConf := conf.NewBoxConf()
-		boxDirPath := filepath.Join(filepath.Join(util.DataDir, userNo), dir.Name())
+		boxDirPath := filepath.Join(dataDir, dir.Name())
 		boxConfPath := filepath.Join(boxDirPath, ".siyuan", "conf.json")
 		isExistConf := filelock.IsExist(boxConfPath)
 		if !isExistConf {
@@ -181,9 +185,8 @@ func ListNotebooks(c *gin.Context) (ret []*Box, err error) {
 
 func (box *Box) GetConf(c *gin.Context) (ret *conf.BoxConf) {
 	ret = conf.NewBoxConf()
-	userNo := GetGinContextUser(c)
 
-	confPath := filepath.Join(util.DataDir, userNo, box.ID, ".siyuan/conf.json")
+	confPath := filepath.Join(GetDataDir(c), box.ID, ".siyuan/conf.json")
 	if !filelock.IsExist(confPath) {
 		return
 	}
@@ -202,8 +205,7 @@ func (box *Box) GetConf(c *gin.Context) (ret *conf.BoxConf) {
 }
 
 func (box *Box) SaveConf(c *gin.Context, conf *conf.BoxConf) {
-	userNo := GetGinContextUser(c)
-	confPath := filepath.Join(util.DataDir, userNo, box.ID, ".siyuan/conf.json")
+	confPath := filepath.Join(GetDataDir(c), box.ID, ".siyuan/conf.json")
 	newData, err := gulu.JSON.MarshalIndentJSON(conf, "", "  ")
 	if err != nil {
 		logging.LogErrorf("marshal box conf [%s] failed: %s", confPath, err)
@@ -212,7 +214,7 @@ func (box *Box) SaveConf(c *gin.Context, conf *conf.BoxConf) {
 
 	oldData, err := filelock.ReadFile(confPath)
 	if err != nil {
-		box.saveConf0(newData)
+		box.saveConf0(confPath, newData)
 		return
 	}
 
@@ -220,12 +222,11 @@ func (box *Box) SaveConf(c *gin.Context, conf *conf.BoxConf) {
 		return
 	}
 
-	box.saveConf0(newData)
+	box.saveConf0(confPath, newData)
 }
 
-func (box *Box) saveConf0(data []byte) {
-	confPath := filepath.Join(util.DataDir, box.ID, ".siyuan/conf.json")
-	if err := os.MkdirAll(filepath.Join(util.DataDir, box.ID, ".siyuan"), 0755); err != nil {
+func (box *Box) saveConf0(confPath string, data []byte) {
+	if err := os.MkdirAll(filepath.Dir(confPath), 0755); err != nil {
 		logging.LogErrorf("save box conf [%s] failed: %s", confPath, err)
 	}
 	if err := filelock.WriteFile(confPath, data); err != nil {
@@ -681,6 +682,7 @@ func fullReindex() {
 	for _, openedBox := range openedBoxes {
 		index(openedBox.ID)
 	}
+	indexAttributeViews()
 	LoadFlashcards()
 	debug.FreeOSMemory()
 }
@@ -40,6 +40,7 @@ import (
 	"github.com/siyuan-note/filelock"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/search"
 	"github.com/siyuan-note/siyuan/kernel/sql"
 	"github.com/siyuan-note/siyuan/kernel/task"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
@@ -79,6 +80,8 @@ type AppConf struct {
 	Api            *conf.API        `json:"api"`            // API
 	Repo           *conf.Repo       `json:"repo"`           // 数据仓库
 	Publish        *conf.Publish    `json:"publish"`        // 发布服务
+	OIDC           *conf.OIDC       `json:"oidc"`           // OpenID Connect 单点登录
+	ACLs           []*conf.ACL      `json:"acls"`           // 笔记本和文档访问控制规则
 	OpenHelp       bool             `json:"openHelp"`       // 启动后是否需要打开用户指南
 	ShowChangelog  bool             `json:"showChangelog"`  // 是否显示版本更新日志
 	CloudRegion    int              `json:"cloudRegion"`    // 云端区域，0：中国大陆，1：北美
@@ -327,6 +330,7 @@ func InitConf() {
 	Conf.System.HomeDir = util.HomeDir
 	Conf.System.WorkspaceDir = util.WorkspaceDir
 	Conf.System.DataDir = util.DataDir
+	InitTenants()
 	Conf.System.Container = util.Container
 	Conf.System.IsMicrosoftStore = util.ISMicrosoftStore
 	if util.ISMicrosoftStore {
@@ -367,6 +371,23 @@ func InitConf() {
 	if nil == Conf.Api {
 		Conf.Api = conf.NewAPI()
 	}
+	if nil == Conf.Api.Tokens {
+		Conf.Api.Tokens = []*conf.APIToken{}
+	}
+	if Conf.Api.HashTokens() {
+		logging.LogInfof("hashed plaintext API tokens")
+	}
+
+	if nil == Conf.OIDC {
+		Conf.OIDC = conf.NewOIDC()
+	}
+	if nil == Conf.OIDC.RoleMapping {
+		Conf.OIDC.RoleMapping = map[string]uint{}
+	}
+
+	if nil == Conf.ACLs {
+		Conf.ACLs = []*conf.ACL{}
+	}
 
 	if nil == Conf.Bazaar {
 		Conf.Bazaar = conf.NewBazaar()
@@ -407,6 +428,13 @@ func InitConf() {
 	if 32 > Conf.Search.Limit {
 		Conf.Search.Limit = 32
 	}
+	if 1 > Conf.Search.FuzzyMaxDistance {
+		Conf.Search.FuzzyMaxDistance = 2
+	}
+	if 3 < Conf.Search.FuzzyMaxDistance {
+		Conf.Search.FuzzyMaxDistance = 3
+	}
+	Conf.Search.Tokenizers = search.NormalizeTokenizers(Conf.Search.Tokenizers)
 	if 1 > Conf.Search.BacklinkMentionKeywordsLimit {
 		Conf.Search.BacklinkMentionKeywordsLimit = 512
 	}
@@ -437,6 +465,15 @@ func InitConf() {
 	if nil == Conf.AI {
 		Conf.AI = conf.NewAI()
 	}
+	if nil == Conf.AI.Embedding {
+		Conf.AI.Embedding = conf.NewEmbedding()
+	}
+	if "" == Conf.AI.Embedding.APIProvider {
+		Conf.AI.Embedding.APIProvider = "OpenAI"
+	}
+	if 0 > Conf.AI.Embedding.HybridWeight || 1 < Conf.AI.Embedding.HybridWeight {
+		Conf.AI.Embedding.HybridWeight = 0.7
+	}
 	if "" == Conf.AI.OpenAI.APIModel {
 		Conf.AI.OpenAI.APIModel = openai.GPT3Dot5Turbo
 	}
@@ -881,8 +918,9 @@ func IsPaidUser() bool {
 }
 
 const (
-	MaskedUserData       = ""
-	MaskedAccessAuthCode = "*******"
+	MaskedUserData         = ""
+	MaskedAccessAuthCode   = "*******"
+	MaskedOIDCClientSecret = "*******"
 )
 
 // GetMaskedConf 获取并返回脱敏后的配置信息。
@@ -903,9 +941,13 @@ func GetMaskedConf() (ret *AppConf, err error) {
 	}
 
 	ret.UserData = MaskedUserData
+	ret.Api.Tokens = GetAPITokens()
 	if "" != ret.AccessAuthCode {
 		ret.AccessAuthCode = MaskedAccessAuthCode
 	}
+	if "" != ret.OIDC.ClientSecret {
+		ret.OIDC.ClientSecret = MaskedOIDCClientSecret
+	}
 	return
 }
 
@@ -914,6 +956,8 @@ func GetMaskedConf() (ret *AppConf, err error) {
 func HideConfSecret(c *AppConf) {
 	c.AI = &conf.AI{}
 	c.Api = &conf.API{}
+	c.OIDC = &conf.OIDC{}
+	c.ACLs = []*conf.ACL{}
 	c.Flashcard = &conf.Flashcard{}
 	c.LocalIPs = []string{}
 	c.Publish = &conf.Publish{}
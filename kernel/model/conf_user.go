@@ -0,0 +1,148 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/88250/gulu"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/tenant"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// UserConf 描述了当前请求用户的用户级配置。
+type UserConf struct {
+	*conf.UserConf
+
+	tenant *tenant.Tenant
+}
+
+var (
+	userConfs     = map[string]*conf.UserConf{}
+	userConfsLock = sync.Mutex{}
+)
+
+// GetUserConf 返回当前请求用户的用户级配置，修改后需要调用 Save 保存。
+//
+// 其他用户返回的是缓存配置的副本，并发请求各自修改自己的副本，调用 Save 后才会更新缓存。
+func GetUserConf(c *gin.Context) (ret *UserConf) {
+	t := GetTenant(c)
+	if t.IsDefault() {
+		return &UserConf{UserConf: workspaceUserConf(), tenant: t}
+	}
+
+	userConfsLock.Lock()
+	defer userConfsLock.Unlock()
+
+	if cached := userConfs[t.UserNo]; nil != cached {
+		return &UserConf{UserConf: cached.Clone(), tenant: t}
+	}
+
+	userConf := &conf.UserConf{}
+	if data, err := filelock.ReadFile(t.ConfPath); err == nil {
+		if err = gulu.JSON.UnmarshalJSON(data, userConf); err != nil {
+			logging.LogErrorf("parse user conf [%s] failed: %s", t.ConfPath, err)
+		}
+	} else if !os.IsNotExist(err) {
+		logging.LogErrorf("read user conf [%s] failed: %s", t.ConfPath, err)
+	}
+	workspace := workspaceUserConf()
+	userConf.FillDefaults(workspace)
+	userConf.KeepWorkspaceFields(workspace)
+	userConfs[t.UserNo] = userConf
+	return &UserConf{UserConf: userConf.Clone(), tenant: t}
+}
+
+// IsDefault 判断是否是默认用户的配置，只有默认用户修改配置时才会改变内核的全局状态（比如界面语言和数据历史生成间隔）。
+func (userConf *UserConf) IsDefault() bool {
+	return userConf.tenant.IsDefault()
+}
+
+// Save 保存用户级配置，默认用户保存到工作空间配置中。
+func (userConf *UserConf) Save() {
+	if userConf.IsDefault() {
+		Conf.Editor = userConf.Editor
+		Conf.Appearance = userConf.Appearance
+		Conf.Search = userConf.Search
+		Conf.Flashcard = userConf.Flashcard
+		Conf.Export = userConf.Export
+		Conf.Keymap = userConf.Keymap
+		Conf.AI = userConf.AI
+		Conf.Save()
+		return
+	}
+
+	if util.ReadOnly {
+		return
+	}
+
+	userConfsLock.Lock()
+	defer userConfsLock.Unlock()
+
+	userConf.KeepWorkspaceFields(workspaceUserConf())
+	newData, err := gulu.JSON.MarshalIndentJSON(userConf.UserConf, "", "  ")
+	if err != nil {
+		logging.LogErrorf("marshal user conf failed: %s", err)
+		return
+	}
+	userConfs[userConf.tenant.UserNo] = userConf.UserConf.Clone()
+
+	confPath := userConf.tenant.ConfPath
+	if oldData, readErr := filelock.ReadFile(confPath); nil == readErr && bytes.Equal(newData, oldData) {
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(confPath), 0755); err != nil {
+		logging.LogErrorf("create user conf dir [%s] failed: %s", filepath.Dir(confPath), err)
+		return
+	}
+	if err = filelock.WriteFile(confPath, newData); err != nil {
+		logging.LogErrorf("write user conf [%s] failed: %s", confPath, err)
+	}
+}
+
+// ApplyTo 使用用户级配置覆盖 appConf 中的对应部分，用于返回当前用户看到的配置。
+func (userConf *UserConf) ApplyTo(appConf *AppConf) {
+	appConf.Editor = userConf.Editor
+	appConf.Appearance = userConf.Appearance
+	appConf.Search = userConf.Search
+	appConf.Flashcard = userConf.Flashcard
+	appConf.Export = userConf.Export
+	appConf.Keymap = userConf.Keymap
+	appConf.AI = userConf.AI
+	appConf.Lang = userConf.Appearance.Lang
+}
+
+// workspaceUserConf 返回工作空间配置中的用户级部分，也就是默认用户的用户级配置。
+func workspaceUserConf() *conf.UserConf {
+	return &conf.UserConf{
+		Editor:     Conf.Editor,
+		Appearance: Conf.Appearance,
+		Search:     Conf.Search,
+		Flashcard:  Conf.Flashcard,
+		Export:     Conf.Export,
+		Keymap:     Conf.Keymap,
+		AI:         Conf.AI,
+	}
+}
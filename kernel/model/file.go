@@ -41,6 +41,7 @@ import (
 	"github.com/siyuan-note/filelock"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/riff"
+	"github.com/siyuan-note/siyuan/kernel/acl"
 	"github.com/siyuan-note/siyuan/kernel/av"
 	"github.com/siyuan-note/siyuan/kernel/cache"
 	"github.com/siyuan-note/siyuan/kernel/filesys"
@@ -218,6 +219,16 @@ func SearchDocsByKeyword(c *gin.Context, keyword string, flashcard bool) (ret []
 		}
 	}
 
+	if rules := getGinContextACLs(c); 0 < len(rules) {
+		docs := []map[string]string{}
+		for _, doc := range ret {
+			if acl.IsDocAllowed(rules, &acl.Doc{Box: doc["box"], Path: doc["path"], ByPath: true}, acl.PermissionRead) {
+				docs = append(docs, doc)
+			}
+		}
+		ret = docs
+	}
+
 	sort.Slice(ret, func(i, j int) bool {
 		return ret[i]["hPath"] < ret[j]["hPath"]
 	})
@@ -356,6 +367,7 @@ func ListDocTree(c *gin.Context, boxID, listPath string, sortMode int, flashcard
 	if 500 < elapsed {
 		logging.LogWarnf("build docs [%d] elapsed [%dms]", len(docs), elapsed)
 	}
+	docs = filterACLFiles(c, box.ID, docs)
 
 	start = time.Now()
 	refCount := sql.QueryRootBlockRefCount()
@@ -790,13 +802,23 @@ func GetDoc(startID, endID, id string, index int, query string, queryTypes map[s
 	subTree := &parse.Tree{ID: rootID, Root: &ast.Node{Type: ast.NodeDocument}, Marks: tree.Marks}
 
 	var keywords []string
-	if "" != query && (0 == queryMethod || 1 == queryMethod || 3 == queryMethod) { // 只有关键字、查询语法和正则表达式搜索支持高亮
+	if "" != query && (0 == queryMethod || 1 == queryMethod || 3 == queryMethod || 5 == queryMethod || 6 == queryMethod) { // 只有关键字、查询语法、正则表达式、模糊搜索和结构化查询支持高亮
 		if 0 == queryMethod {
 			query = stringQuery(query)
+		} else if 5 == queryMethod {
+			query, _ = buildFuzzyMatch(query)
+		} else if 6 == queryMethod {
+			q, _ := buildStructuredQuery(nil, query)
+			query = ""
+			if nil != q {
+				query = q.match // 没有全文关键字时不需要高亮
+			}
 		}
 		typeFilter := buildTypeFilter(queryTypes)
-		if 0 == queryMethod || 1 == queryMethod {
-			keywords = highlightByFTS(query, typeFilter, rootID)
+		if 0 == queryMethod || 1 == queryMethod || 5 == queryMethod || 6 == queryMethod {
+			if "" != query {
+				keywords = highlightByFTS(query, typeFilter, rootID)
+			}
 		} else {
 			keywords = highlightByRegexp(query, typeFilter, rootID)
 		}
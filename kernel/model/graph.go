@@ -26,6 +26,7 @@ import (
 	"github.com/88250/lute/ast"
 	"github.com/88250/lute/html"
 	"github.com/88250/lute/parse"
+	"github.com/gin-gonic/gin"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/sql"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
@@ -59,7 +60,7 @@ type GraphArrowsTo struct {
 	Enabled bool `json:"enabled"`
 }
 
-func BuildTreeGraph(id, query string) (boxID string, nodes []*GraphNode, links []*GraphLink) {
+func BuildTreeGraph(c *gin.Context, id, query string) (boxID string, nodes []*GraphNode, links []*GraphLink) {
 	nodes = []*GraphNode{}
 	links = []*GraphLink{}
 
@@ -160,10 +161,11 @@ func BuildTreeGraph(id, query string) (boxID string, nodes []*GraphNode, links [
 	}
 	markLinkedNodes(&nodes, &links, true)
 	nodes = removeDuplicatedUnescape(nodes)
+	nodes, links = filterACLGraph(c, nodes, links)
 	return
 }
 
-func BuildGraph(query string) (boxID string, nodes []*GraphNode, links []*GraphLink) {
+func BuildGraph(c *gin.Context, query string) (boxID string, nodes []*GraphNode, links []*GraphLink) {
 	nodes = []*GraphNode{}
 	links = []*GraphLink{}
 
@@ -212,6 +214,43 @@ func BuildGraph(query string) (boxID string, nodes []*GraphNode, links []*GraphL
 	markLinkedNodes(&nodes, &links, false)
 	pruneUnref(&nodes, &links)
 	nodes = removeDuplicatedUnescape(nodes)
+	nodes, links = filterACLGraph(c, nodes, links)
+	return
+}
+
+// filterACLGraph 过滤关系图中当前请求的账号不能读取的块节点及其连线，标签节点只在连接了保留的块节点时保留。
+func filterACLGraph(c *gin.Context, nodes []*GraphNode, links []*GraphLink) (retNodes []*GraphNode, retLinks []*GraphLink) {
+	if !IsACLRestricted(c) {
+		return nodes, links
+	}
+
+	tags, kept := map[string]bool{}, map[string]bool{}
+	for _, node := range nodes {
+		if "" == node.Box {
+			tags[node.ID] = true
+		} else if isACLDocVisible(c, node.Box, node.Path) {
+			kept[node.ID] = true
+		}
+	}
+	for _, link := range links {
+		if tags[link.From] && kept[link.To] {
+			kept[link.From] = true
+		} else if tags[link.To] && kept[link.From] {
+			kept[link.To] = true
+		}
+	}
+
+	retNodes, retLinks = []*GraphNode{}, []*GraphLink{}
+	for _, node := range nodes {
+		if kept[node.ID] {
+			retNodes = append(retNodes, node)
+		}
+	}
+	for _, link := range links {
+		if kept[link.From] && kept[link.To] {
+			retLinks = append(retLinks, link)
+		}
+	}
 	return
 }
 
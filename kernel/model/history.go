@@ -33,9 +33,11 @@ import (
 	"github.com/88250/lute/ast"
 	"github.com/88250/lute/parse"
 	"github.com/88250/lute/render"
+	"github.com/gin-gonic/gin"
 	"github.com/siyuan-note/eventbus"
 	"github.com/siyuan-note/filelock"
 	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/acl"
 	"github.com/siyuan-note/siyuan/kernel/cache"
 	"github.com/siyuan-note/siyuan/kernel/conf"
 	"github.com/siyuan-note/siyuan/kernel/filesys"
@@ -146,7 +148,15 @@ func ClearWorkspaceHistory() (err error) {
 	return
 }
 
-func GetDocHistoryContent(historyPath, keyword string) (id, rootID, content string, isLargeDoc bool, err error) {
+func GetDocHistoryContent(c *gin.Context, historyPath, keyword string) (id, rootID, content string, isLargeDoc bool, err error) {
+	if IsACLRestricted(c) {
+		// 只能查看有读权限的文档的历史
+		if doc := acl.HistoryDoc(util.HistoryDir, historyPath); nil == doc || !isACLDocVisible(c, doc.Box, doc.Path) {
+			err = fmt.Errorf(Conf.Language(271), filepath.Base(historyPath))
+			return
+		}
+	}
+
 	if !gulu.File.IsExist(historyPath) {
 		logging.LogWarnf("doc history [%s] not exist", historyPath)
 		return
@@ -291,6 +301,7 @@ func RollbackDocHistory(boxID, historyPath string) (err error) {
 
 	// 刷新属性视图
 	for _, avID := range avIDs {
+		sql.IndexAttributeViewQueue(avID)
 		ReloadAttrView(avID)
 	}
 
@@ -404,7 +415,7 @@ type HistoryItem struct {
 
 const fileHistoryPageSize = 32
 
-func FullTextSearchHistory(query, box, op string, typ, page int) (ret []string, pageCount, totalCount int) {
+func FullTextSearchHistory(c *gin.Context, query, box, op string, typ, page int) (ret []string, pageCount, totalCount int) {
 	query = gulu.Str.RemoveInvisible(query)
 	if "" != query && HistoryTypeDocID != typ {
 		query = stringQuery(query)
@@ -414,7 +425,7 @@ func FullTextSearchHistory(query, box, op string, typ, page int) (ret []string,
 
 	table := "histories_fts_case_insensitive"
 	stmt := "SELECT DISTINCT created FROM " + table + " WHERE "
-	stmt += buildSearchHistoryQueryFilter(query, op, box, table, typ)
+	stmt += buildSearchHistoryQueryFilter(query, op, box, table, typ) + buildACLHistoryFilter(c)
 	countStmt := strings.ReplaceAll(stmt, "SELECT DISTINCT created", "SELECT COUNT(DISTINCT created) AS total")
 	stmt += " ORDER BY created DESC LIMIT " + strconv.Itoa(fileHistoryPageSize) + " OFFSET " + strconv.Itoa(offset)
 	result, err := sql.QueryHistory(stmt)
@@ -439,7 +450,7 @@ func FullTextSearchHistory(query, box, op string, typ, page int) (ret []string,
 	return
 }
 
-func FullTextSearchHistoryItems(created, query, box, op string, typ int) (ret []*HistoryItem) {
+func FullTextSearchHistoryItems(c *gin.Context, created, query, box, op string, typ int) (ret []*HistoryItem) {
 	query = gulu.Str.RemoveInvisible(query)
 	if "" != query && HistoryTypeDocID != typ {
 		query = stringQuery(query)
@@ -447,7 +458,7 @@ func FullTextSearchHistoryItems(created, query, box, op string, typ int) (ret []
 
 	table := "histories_fts_case_insensitive"
 	stmt := "SELECT * FROM " + table + " WHERE "
-	stmt += buildSearchHistoryQueryFilter(query, op, box, table, typ)
+	stmt += buildSearchHistoryQueryFilter(query, op, box, table, typ) + buildACLHistoryFilter(c)
 
 	_, parseErr := strconv.Atoi(created)
 	if nil != parseErr {
@@ -0,0 +1,198 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/search"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// HistoryBlock 描述了在文档历史中命中的块。
+type HistoryBlock struct {
+	ID           string          `json:"id"`
+	RootID       string          `json:"rootID"`
+	Box          string          `json:"box"`
+	Title        string          `json:"title"`        // 文档标题
+	Type         string          `json:"type"`         // 块类型
+	Content      string          `json:"content"`      // 块在所选时间点（或者之前最后一次命中时）的内容，关键字已高亮
+	Version      *HistoryVersion `json:"version"`      // 块内容所在的版本
+	ExistsAt     bool            `json:"existsAt"`     // 所选时间点的版本中是否命中
+	IntroducedIn *HistoryVersion `json:"introducedIn"` // 最近一次开始命中的版本
+	RemovedIn    *HistoryVersion `json:"removedIn"`    // 开始命中后第一个不再命中的版本，为空表示当前仍然命中
+}
+
+// HistoryVersion 描述了文档的一个版本。
+type HistoryVersion struct {
+	Created string `json:"created"` // 版本时间（秒）
+	Op      string `json:"op"`      // 生成历史的操作，当前版本为空
+	Path    string `json:"path"`    // 历史文件路径，当前版本为空
+	Current bool   `json:"current"` // 是否为当前版本
+}
+
+const (
+	historyBlockDocLimit     = 16 // 最多搜索的文档数
+	historyBlockVersionLimit = 64 // 每个文档最多比较的历史版本数
+)
+
+// SearchHistoryBlocks 在文档历史中按块搜索 query，返回每个命中的块在 at（秒，为 0 时表示当前）时的内容以及开始命中和不再命中的版本。
+//
+// 候选文档通过 histories 索引查找，然后按时间顺序逐个加载文档的历史版本和当前版本进行比较。
+func SearchHistoryBlocks(query, box string, at int64) (ret []*HistoryBlock) {
+	ret = []*HistoryBlock{}
+	query = gulu.Str.RemoveInvisible(strings.TrimSpace(query))
+	keywords := strings.Fields(query)
+	if 1 > len(keywords) {
+		return
+	}
+
+	now := time.Now().Unix()
+	if 0 >= at || at > now {
+		at = now
+	}
+
+	table := "histories_fts_case_insensitive"
+	stmt := "SELECT DISTINCT id FROM " + table + " WHERE " + buildSearchHistoryQueryFilter(stringQuery(query), "all", box, table, HistoryTypeDoc) +
+		" ORDER BY created DESC LIMIT " + strconv.Itoa(historyBlockDocLimit)
+	result, err := sql.QueryHistory(stmt)
+	if err != nil {
+		return
+	}
+
+	for _, row := range result {
+		rootID, _ := row["id"].(string)
+		if !ast.IsNodeIDPattern(rootID) {
+			continue
+		}
+		ret = append(ret, searchDocHistoryBlocks(rootID, keywords, at, now)...)
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].IntroducedIn.Created > ret[j].IntroducedIn.Created })
+	return
+}
+
+func searchDocHistoryBlocks(rootID string, keywords []string, at, now int64) (ret []*HistoryBlock) {
+	stmt := "SELECT * FROM histories_fts_case_insensitive WHERE id = '" + rootID + "' AND type = " + strconv.Itoa(HistoryTypeDoc) +
+		" ORDER BY created DESC LIMIT " + strconv.Itoa(historyBlockVersionLimit)
+	histories := sql.SelectHistoriesRawStmt(stmt)
+	sort.SliceStable(histories, func(i, j int) bool { return histories[i].Created < histories[j].Created })
+
+	type version struct {
+		*HistoryVersion
+		box  string
+		tree *parse.Tree
+	}
+	var versions []*version
+	luteEngine := util.NewLute()
+	for _, history := range histories {
+		historyPath := filepath.Join(util.HistoryDir, history.Path)
+		tree, loadErr := loadTree(historyPath, luteEngine)
+		if nil != loadErr {
+			logging.LogWarnf("load history tree [%s] failed: %s", historyPath, loadErr)
+			continue
+		}
+		var box string
+		if parts := strings.Split(history.Path, "/"); 2 <= len(parts) {
+			box = parts[1]
+		}
+		versions = append(versions, &version{HistoryVersion: &HistoryVersion{Created: history.Created, Op: history.Op, Path: historyPath}, box: box, tree: tree})
+	}
+
+	// 当前版本，文档已经删除时为空，此时之前命中的块都视为在当前版本中不再命中
+	current := &version{HistoryVersion: &HistoryVersion{Created: strconv.FormatInt(now, 10), Current: true}}
+	if bt := treenode.GetBlockTree(rootID); nil != bt {
+		current.box = bt.BoxID
+		current.tree, _ = loadTreeByBlockTree(bt)
+	}
+	versions = append(versions, current)
+
+	blocks := map[string]*HistoryBlock{}
+	var ids []string
+	for _, v := range versions {
+		matches := map[string]*ast.Node{}
+		if nil != v.tree {
+			ast.Walk(v.tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
+				if !entering || !n.IsBlock() || n.IsContainerBlock() {
+					return ast.WalkContinue
+				}
+				if containsAllKeywords(sql.NodeStaticContent(n, nil, false, false, false), keywords) {
+					matches[n.ID] = n
+				}
+				return ast.WalkContinue
+			})
+		}
+
+		created, _ := strconv.ParseInt(v.Created, 10, 64)
+		visible := created <= at
+		for id, n := range matches {
+			block := blocks[id]
+			if nil == block {
+				block = &HistoryBlock{ID: id, RootID: rootID, Box: v.box, Type: n.Type.String()}
+				blocks[id] = block
+				ids = append(ids, id)
+			}
+			if nil == block.IntroducedIn || nil != block.RemovedIn {
+				block.IntroducedIn, block.RemovedIn = v.HistoryVersion, nil
+			}
+			if visible {
+				block.Box = v.box
+				block.Title = v.tree.Root.IALAttr("title")
+				block.Type = n.Type.String()
+				block.Content, _ = markSearch(sql.NodeStaticContent(n, nil, false, false, false), strings.Join(keywords, search.TermSep), 64)
+				block.Version = v.HistoryVersion
+			}
+		}
+
+		for _, block := range blocks {
+			if _, ok := matches[block.ID]; !ok && nil == block.RemovedIn {
+				block.RemovedIn = v.HistoryVersion
+			}
+			if visible {
+				_, block.ExistsAt = matches[block.ID]
+			}
+		}
+	}
+
+	for _, id := range ids {
+		if block := blocks[id]; nil != block.Version {
+			// 仅返回所选时间点之前命中过的块
+			ret = append(ret, block)
+		}
+	}
+	return
+}
+
+func containsAllKeywords(text string, keywords []string) bool {
+	text = strings.ToLower(text)
+	for _, keyword := range keywords {
+		if !strings.Contains(text, strings.ToLower(keyword)) {
+			return false
+		}
+	}
+	return "" != text
+}
@@ -19,6 +19,7 @@ package model
 import (
 	"bytes"
 	"encoding/base64"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -34,6 +35,7 @@ import (
 	"regexp"
 	"runtime/debug"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/88250/gulu"
@@ -43,6 +45,7 @@ import (
 	"github.com/88250/lute/html/atom"
 	"github.com/88250/lute/parse"
 	"github.com/88250/lute/render"
+	"github.com/gin-gonic/gin"
 	"github.com/siyuan-note/filelock"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/riff"
@@ -51,6 +54,7 @@ import (
 	"github.com/siyuan-note/siyuan/kernel/sql"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
 	"github.com/siyuan-note/siyuan/kernel/util"
+	"github.com/xuri/excelize/v2"
 )
 
 func HTML2Markdown(htmlStr string, luteEngine *lute.Lute) (markdown string, withMath bool, err error) {
@@ -271,6 +275,9 @@ func ImportSY(zipPath, boxID, toPath string) (err error) {
 		if copyErr := filelock.Copy(storageAvDir, targetStorageAvDir); nil != copyErr {
 			logging.LogErrorf("copy storage av dir from [%s] to [%s] failed: %s", storageAvDir, targetStorageAvDir, copyErr)
 		}
+		for _, newAvID := range avIDs {
+			sql.IndexAttributeViewQueue(newAvID)
+		}
 
 		// 重新指向数据库属性值
 		for _, tree := range trees {
@@ -1401,3 +1408,259 @@ func searchLinkID(link string) (id string) {
 	}
 	return
 }
+
+// ImportAttributeView 从 CSV、XLSX 或 JSON 文件导入数据库。
+// avID 为空时新建数据库，否则追加到已有数据库中；boxID 为空时导入为非绑定块行，否则在 boxID 笔记本 parentPath 路径下为每行创建文档并绑定。
+func ImportAttributeView(c *gin.Context, filePath, avID, boxID, parentPath string) (retAvID, blockID string, err error) {
+	header, records, err := readAttributeViewImportRecords(filePath)
+	if err != nil {
+		logging.LogErrorf("read attribute view import file [%s] failed: %s", filePath, err)
+		return
+	}
+	if 1 > len(header) {
+		err = errors.New("no column found")
+		return
+	}
+
+	var attrView *av.AttributeView
+	isNewAv := "" == avID
+	if isNewAv {
+		avID = ast.NewNodeID()
+		attrView = av.NewAttributeView(avID)
+		attrView.Name = strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+		// 新建的数据库仅保留主键，不需要默认的单选字段
+		attrView.KeyValues = attrView.KeyValues[:1]
+		view := attrView.Views[0]
+		view.Table.Columns = view.Table.Columns[:1]
+		attrView.KeyValues[0].Key.Name = header[0]
+	} else {
+		if attrView, err = av.ParseAttributeView(avID); err != nil {
+			logging.LogErrorf("parse attribute view [%s] failed: %s", avID, err)
+			return
+		}
+	}
+	retAvID = avID
+
+	// 按名称将列映射到已有字段，不存在的字段按列内容推断类型后新建
+	keys := make([]*av.Key, len(header))
+	for i, name := range header {
+		for _, keyValues := range attrView.KeyValues {
+			if strings.EqualFold(strings.TrimSpace(keyValues.Key.Name), strings.TrimSpace(name)) {
+				keys[i] = keyValues.Key
+				break
+			}
+		}
+		if nil != keys[i] {
+			continue
+		}
+
+		var contents []string
+		for _, record := range records {
+			if i < len(record) {
+				contents = append(contents, record[i])
+			}
+		}
+		key := av.NewKey(ast.NewNodeID(), name, "", av.InferKeyType(contents))
+		attrView.KeyValues = append(attrView.KeyValues, &av.KeyValues{Key: key})
+		for _, view := range attrView.Views {
+			switch view.LayoutType {
+			case av.LayoutTypeTable:
+				view.Table.Columns = append(view.Table.Columns, &av.ViewTableColumn{ID: key.ID})
+			}
+		}
+		keys[i] = key
+	}
+
+	var blockIDs []string
+	var blocksValues [][]*av.Value
+	for _, record := range records {
+		var blockValue *av.Value
+		var values []*av.Value
+		for i, key := range keys {
+			var content string
+			if i < len(record) {
+				content = record[i]
+			}
+
+			val := av.NewValueFromImport(key, content)
+			if nil == val {
+				continue
+			}
+			if av.KeyTypeBlock == val.Type {
+				if nil != blockValue {
+					// 多列映射到主键时仅使用第一列
+					continue
+				}
+				blockValue = val
+			}
+			values = append(values, val)
+		}
+		if nil == blockValue {
+			blockValue = av.NewValueFromImport(attrView.GetBlockKey(), "")
+			values = append(values, blockValue)
+		}
+
+		rowID := ast.NewNodeID()
+		if "" != boxID {
+			// 为每行创建文档，文档标题为主键内容
+			p := path.Join(strings.TrimSuffix(parentPath, ".sy"), rowID+".sy")
+			if _, err = createDoc(c, boxID, p, blockValue.Block.Content, ""); err != nil {
+				logging.LogErrorf("create doc [%s] for attribute view [%s] failed: %s", p, avID, err)
+				return
+			}
+		}
+		blockIDs = append(blockIDs, rowID)
+		blocksValues = append(blocksValues, values)
+	}
+
+	isDetached := "" == boxID
+	if err = appendAttributeViewBlocksWithValues(attrView, blockIDs, blocksValues, isDetached); err != nil {
+		return
+	}
+
+	if err = av.SaveAttributeView(attrView); err != nil {
+		logging.LogErrorf("save attribute view [%s] failed: %s", avID, err)
+		return
+	}
+
+	if !isDetached {
+		FlushTxQueue()
+		for _, rowID := range blockIDs {
+			bindBlockAv(nil, avID, rowID)
+		}
+	}
+
+	if isNewAv {
+		// 新建的数据库需要由调用方使用 blockID 插入数据库块
+		blockID = ast.NewNodeID()
+		av.UpsertBlockRel(avID, blockID)
+	}
+
+	ReloadAttrView(avID)
+	return
+}
+
+// readAttributeViewImportRecords 读取导入文件，返回表头和数据行。
+func readAttributeViewImportRecords(filePath string) (header []string, records [][]string, err error) {
+	var rows [][]string
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".csv":
+		var data []byte
+		if data, err = os.ReadFile(filePath); err != nil {
+			return
+		}
+		data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.FieldsPerRecord = -1
+		if rows, err = reader.ReadAll(); err != nil {
+			return
+		}
+	case ".xlsx":
+		var x *excelize.File
+		if x, err = excelize.OpenFile(filePath); err != nil {
+			return
+		}
+		defer x.Close()
+
+		// 仅导入第一个工作表
+		if rows, err = x.GetRows(x.GetSheetName(0)); err != nil {
+			return
+		}
+	case ".json":
+		return readAttributeViewImportJSON(filePath)
+	default:
+		err = fmt.Errorf("unsupported import file [%s]", filepath.Base(filePath))
+		return
+	}
+
+	if 1 > len(rows) {
+		return
+	}
+	header, records = rows[0], rows[1:]
+	return
+}
+
+// readAttributeViewImportJSON 读取对象数组格式的 JSON 文件，表头按照属性首次出现的顺序排列。
+func readAttributeViewImportJSON(filePath string) (header []string, records [][]string, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	decoder.UseNumber()
+	if err = expectJSONDelim(decoder, '['); err != nil {
+		return
+	}
+
+	columns := map[string]int{}
+	for decoder.More() {
+		if err = expectJSONDelim(decoder, '{'); err != nil {
+			return
+		}
+
+		record := make([]string, len(header))
+		for decoder.More() {
+			var token json.Token
+			if token, err = decoder.Token(); err != nil {
+				return
+			}
+			name := token.(string)
+
+			var val interface{}
+			if err = decoder.Decode(&val); err != nil {
+				return
+			}
+
+			index, ok := columns[name]
+			if !ok {
+				index = len(header)
+				columns[name] = index
+				header = append(header, name)
+			}
+			for len(record) <= index {
+				record = append(record, "")
+			}
+			record[index] = jsonImportValueString(val)
+		}
+		if _, err = decoder.Token(); err != nil {
+			return
+		}
+		records = append(records, record)
+	}
+	return
+}
+
+func expectJSONDelim(decoder *json.Decoder, delim json.Delim) (err error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return
+	}
+	if d, ok := token.(json.Delim); !ok || delim != d {
+		err = fmt.Errorf("invalid JSON, expected [%s]", delim)
+	}
+	return
+}
+
+func jsonImportValueString(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		// 数组使用逗号连接，导入多选字段时按照逗号拆分
+		var items []string
+		for _, item := range v {
+			items = append(items, jsonImportValueString(item))
+		}
+		return strings.Join(items, ",")
+	}
+	data, _ := gulu.JSON.MarshalJSON(val)
+	return string(data)
+}
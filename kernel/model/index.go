@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"fmt"
 	"io/fs"
+	"os"
 	"path/filepath"
 	"runtime"
 	"runtime/debug"
@@ -355,6 +356,30 @@ func updateEmbedBlockContent(embedBlockID string, queryResultBlocks []*EmbedBloc
 	sql.UpdateBlockContentQueue(embedBlock)
 }
 
+// indexAttributeViews 重建所有属性视图的 av_rows 和 av_values 索引。
+func indexAttributeViews() {
+	avDir := filepath.Join(util.DataDir, "storage", "av")
+	entries, err := os.ReadDir(avDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logging.LogErrorf("read directory [%s] failed: %s", avDir, err)
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || ".json" != filepath.Ext(entry.Name()) {
+			continue
+		}
+
+		avID := strings.TrimSuffix(entry.Name(), ".json")
+		if !ast.IsNodeIDPattern(avID) {
+			continue
+		}
+		sql.IndexAttributeViewQueue(avID)
+	}
+}
+
 func init() {
 	subscribeSQLEvents()
 }
@@ -439,4 +464,8 @@ func subscribeSQLEvents() {
 		Conf.DataIndexState = 0
 		Conf.Save()
 	})
+
+	eventbus.Subscribe(util.EvtAttributeViewSaved, func(avID string) {
+		sql.IndexAttributeViewQueue(avID)
+	})
 }
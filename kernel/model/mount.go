@@ -36,7 +36,6 @@ import (
 )
 
 func CreateBox(c *gin.Context, name string) (id string, err error) {
-	userNo := GetGinContextUser(c)
 	name = gulu.Str.RemoveInvisible(name)
 	if 512 < utf8.RuneCountInString(name) {
 		// 限制笔记本名和文档名最大长度为 `512` https://github.com/siyuan-note/siyuan/issues/6299
@@ -53,16 +52,16 @@ func CreateBox(c *gin.Context, name string) (id string, err error) {
 	defer createDocLock.Unlock()
 
 	id = ast.NewNodeID()
-	boxLocalPath := filepath.Join(util.DataDir, userNo, id)
+	boxLocalPath := filepath.Join(GetDataDir(c), id)
 	err = os.MkdirAll(boxLocalPath, 0755)
 	if err != nil {
 		return
 	}
 
 	box := &Box{ID: id, Name: name}
-	boxConf := box.GetConf()
+	boxConf := box.GetConf(c)
 	boxConf.Name = name
-	box.SaveConf(boxConf)
+	box.SaveConf(c, boxConf)
 	IncSync()
 	logging.LogInfof("created box [%s]", id)
 	return
@@ -0,0 +1,270 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/conf"
+	"github.com/siyuan-note/siyuan/kernel/oidc"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const oidcLoginPath = "/auth/oidc/login"
+
+var (
+	oidcProvider     *oidc.Provider
+	oidcProviderLock = sync.Mutex{}
+
+	oidcHTTPClient = &http.Client{Timeout: 30 * time.Second}
+)
+
+// CheckOIDCConf 校验 OpenID Connect 配置，启用时会请求身份提供方的发现文档。
+func CheckOIDCConf(oidcConf *conf.OIDC) (err error) {
+	for _, role := range oidcConf.RoleMapping {
+		if uint(RoleReader) < role {
+			return errors.New(Conf.Language(264))
+		}
+	}
+	if int(RoleReader) < oidcConf.DefaultRole || -1 > oidcConf.DefaultRole {
+		return errors.New(Conf.Language(264))
+	}
+
+	if !oidcConf.Enabled {
+		return
+	}
+
+	if "" == oidcConf.Issuer || "" == oidcConf.ClientID || "" == oidcConf.RedirectURL {
+		return errors.New(Conf.Language(262))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if _, err = oidc.Discover(ctx, oidcHTTPClient, oidcConf.Issuer); err != nil {
+		return fmt.Errorf(Conf.Language(263), err)
+	}
+	return
+}
+
+// ResetOIDCProvider 清除缓存的身份提供方端点，修改配置后调用。
+func ResetOIDCProvider() {
+	oidcProviderLock.Lock()
+	defer oidcProviderLock.Unlock()
+
+	oidcProvider = nil
+}
+
+// OIDCLogin 生成 state、nonce 和 PKCE code verifier 并保存到会话中，然后跳转到身份提供方进行登录。
+func OIDCLogin(c *gin.Context) {
+	if !isOIDCEnabled() {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	provider, err := getOIDCProvider(c.Request.Context())
+	if err != nil {
+		logging.LogErrorf("discover OIDC provider failed: %s", err)
+		c.String(http.StatusBadGateway, "OIDC discovery failed")
+		return
+	}
+
+	session := util.GetSession(c)
+	workspaceSession := util.GetWorkspaceSession(session)
+	workspaceSession.OIDCState = oidc.RandString()
+	workspaceSession.OIDCNonce = oidc.RandString()
+	workspaceSession.OIDCVerifier = oidc.RandString()
+	workspaceSession.OIDCTo = oidcRedirectTo(c.Query("to"))
+	if err = session.Save(c); err != nil {
+		logging.LogErrorf("save session failed: " + err.Error())
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	authURL := provider.AuthCodeURL(oidcClientConfig(), workspaceSession.OIDCState, workspaceSession.OIDCNonce, workspaceSession.OIDCVerifier)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback 处理身份提供方的回调：校验 state，使用授权码换取并校验 ID token，将声明映射为角色后建立会话。
+func OIDCCallback(c *gin.Context) {
+	if !isOIDCEnabled() {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	session := util.GetSession(c)
+	workspaceSession := util.GetWorkspaceSession(session)
+	state, nonce, verifier, to := workspaceSession.OIDCState, workspaceSession.OIDCNonce, workspaceSession.OIDCVerifier, workspaceSession.OIDCTo
+
+	// 登录状态只能使用一次
+	workspaceSession.OIDCState, workspaceSession.OIDCNonce, workspaceSession.OIDCVerifier, workspaceSession.OIDCTo = "", "", "", ""
+	if err := session.Save(c); err != nil {
+		logging.LogErrorf("save session failed: " + err.Error())
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	if errCode := c.Query("error"); "" != errCode {
+		logging.LogWarnf("OIDC login failed [ip=%s]: %s %s", util.GetRemoteAddr(c.Request), errCode, c.Query("error_description"))
+		c.String(http.StatusUnauthorized, "OIDC login failed: %s", errCode)
+		return
+	}
+
+	if "" == state || c.Query("state") != state {
+		logging.LogWarnf("OIDC login failed [ip=%s]: state mismatch", util.GetRemoteAddr(c.Request))
+		c.String(http.StatusUnauthorized, "OIDC login failed: state mismatch")
+		return
+	}
+
+	ctx := c.Request.Context()
+	provider, err := getOIDCProvider(ctx)
+	if err != nil {
+		logging.LogErrorf("discover OIDC provider failed: %s", err)
+		c.String(http.StatusBadGateway, "OIDC discovery failed")
+		return
+	}
+
+	config := oidcClientConfig()
+	idToken, err := provider.Exchange(ctx, config, c.Query("code"), verifier)
+	if err != nil {
+		logging.LogWarnf("OIDC login failed [ip=%s]: %s", util.GetRemoteAddr(c.Request), err)
+		c.String(http.StatusUnauthorized, "OIDC login failed: token exchange failed")
+		return
+	}
+
+	claims, err := provider.Verify(ctx, config, idToken, nonce)
+	if err != nil {
+		logging.LogWarnf("OIDC login failed [ip=%s]: %s", util.GetRemoteAddr(c.Request), err)
+		c.String(http.StatusUnauthorized, "OIDC login failed: invalid ID token")
+		return
+	}
+
+	subject, _ := claims.GetSubject()
+	role, ok := mapOIDCRole(claims)
+	if "" == subject || !ok {
+		logging.LogWarnf("OIDC login denied [ip=%s, sub=%s]: no role mapped", util.GetRemoteAddr(c.Request), subject)
+		c.String(http.StatusForbidden, "OIDC login denied: no role mapped")
+		return
+	}
+
+	workspaceSession.OIDCIssuer = provider.Issuer
+	workspaceSession.OIDCSubject = subject
+	workspaceSession.OIDCRole = uint(role)
+	if err = session.Save(c); err != nil {
+		logging.LogErrorf("save session failed: " + err.Error())
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	logging.LogInfof("OIDC auth success [ip=%s, sub=%s, role=%d]", util.GetRemoteAddr(c.Request), subject, role)
+	c.Redirect(http.StatusFound, to)
+}
+
+// GetOIDCSessionAccount 返回通过 OpenID Connect 登录的会话的账号，用于访问控制规则。
+func GetOIDCSessionAccount(workspaceSession *util.WorkspaceSession) (ret string, ok bool) {
+	if _, ok = oidcSessionRole(workspaceSession); !ok {
+		return
+	}
+	return ACLAccountOIDC + workspaceSession.OIDCSubject, true
+}
+
+// oidcSessionRole 返回通过 OpenID Connect 登录的会话的角色，身份提供方配置修改或者关闭后会话失效。
+func oidcSessionRole(workspaceSession *util.WorkspaceSession) (ret Role, ok bool) {
+	if !isOIDCEnabled() || nil == workspaceSession {
+		return
+	}
+
+	if "" == workspaceSession.OIDCSubject || strings.TrimSuffix(workspaceSession.OIDCIssuer, "/") != strings.TrimSuffix(Conf.OIDC.Issuer, "/") {
+		return
+	}
+	return Role(workspaceSession.OIDCRole), true
+}
+
+// redirectOIDCLogin 将浏览器跳转到 OpenID Connect 登录，其他请求返回 401。
+func redirectOIDCLogin(c *gin.Context) {
+	if "GET" != c.Request.Method || c.IsWebsocket() || strings.HasPrefix(c.Request.URL.Path, "/api/") {
+		c.JSON(http.StatusUnauthorized, map[string]interface{}{"code": -1, "msg": Conf.Language(156)})
+		c.Abort()
+		return
+	}
+
+	location := url.URL{Path: oidcLoginPath}
+	queryParams := url.Values{}
+	queryParams.Set("to", c.Request.URL.String())
+	location.RawQuery = queryParams.Encode()
+	c.Redirect(http.StatusFound, location.String())
+	c.Abort()
+}
+
+// mapOIDCRole 将声明映射为角色，匹配多个映射时使用权限最高（值最小）的角色，没有匹配时使用默认角色。
+func mapOIDCRole(claims jwt.MapClaims) (ret Role, ok bool) {
+	oidcConf := Conf.OIDC
+	for _, value := range oidc.ClaimStrings(claims, oidcConf.RoleClaim) {
+		if role, exists := oidcConf.RoleMapping[value]; exists && (!ok || Role(role) < ret) {
+			ret, ok = Role(role), true
+		}
+	}
+	if !ok && 0 <= oidcConf.DefaultRole {
+		ret, ok = Role(oidcConf.DefaultRole), true
+	}
+	return
+}
+
+func getOIDCProvider(ctx context.Context) (ret *oidc.Provider, err error) {
+	oidcProviderLock.Lock()
+	defer oidcProviderLock.Unlock()
+
+	if nil != oidcProvider {
+		return oidcProvider, nil
+	}
+
+	if oidcProvider, err = oidc.Discover(ctx, oidcHTTPClient, Conf.OIDC.Issuer); err != nil {
+		oidcProvider = nil
+		return
+	}
+	return oidcProvider, nil
+}
+
+func oidcClientConfig() *oidc.Config {
+	return &oidc.Config{
+		ClientID:     Conf.OIDC.ClientID,
+		ClientSecret: Conf.OIDC.ClientSecret,
+		RedirectURL:  Conf.OIDC.RedirectURL,
+		Scopes:       Conf.OIDC.Scopes,
+	}
+}
+
+// oidcRedirectTo 返回登录成功后跳转的地址，只允许跳转到本服务的路径。
+func oidcRedirectTo(to string) string {
+	if !strings.HasPrefix(to, "/") || strings.HasPrefix(to, "//") || strings.HasPrefix(to, "/\\") || strings.HasPrefix(to, oidcLoginPath) {
+		return "/"
+	}
+	return to
+}
+
+func isOIDCEnabled() bool {
+	return nil != Conf.OIDC && Conf.OIDC.Enabled && "" != Conf.OIDC.Issuer && "" != Conf.OIDC.ClientID
+}
@@ -1569,6 +1569,10 @@ func processSyncMergeResult(exit, byHand bool, mergeResult *dejavu.MergeResult,
 		if strings.HasSuffix(file.Path, ".sy") {
 			upsertTrees++
 		}
+
+		if avID := getSyncAttributeViewID(file.Path); "" != avID {
+			sql.IndexAttributeViewQueue(avID)
+		}
 	}
 
 	removeWidgetDirSet, removePluginSet := hashset.New(), hashset.New()
@@ -1609,6 +1613,10 @@ func processSyncMergeResult(exit, byHand bool, mergeResult *dejavu.MergeResult,
 				removeWidgetDirSet.Add(parts[2])
 			}
 		}
+
+		if avID := getSyncAttributeViewID(file.Path); "" != avID {
+			sql.IndexAttributeViewQueue(avID)
+		}
 	}
 
 	if needReloadFlashcard {
@@ -1730,6 +1738,19 @@ func logSyncMergeResult(mergeResult *dejavu.MergeResult) {
 	}
 }
 
+// getSyncAttributeViewID 返回同步文件对应的属性视图 ID，非属性视图数据文件返回空。
+func getSyncAttributeViewID(p string) string {
+	if !strings.HasPrefix(p, "/storage/av/") || ".json" != path.Ext(p) {
+		return ""
+	}
+
+	avID := strings.TrimSuffix(path.Base(p), ".json")
+	if !ast.IsNodeIDPattern(avID) {
+		return ""
+	}
+	return avID
+}
+
 func needFullReindex(upsertTrees int) bool {
 	return 0.2 < float64(upsertTrees)/float64(treenode.CountTrees())
 }
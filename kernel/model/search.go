@@ -39,6 +39,7 @@ import (
 	"github.com/88250/lute/lex"
 	"github.com/88250/lute/parse"
 	"github.com/88250/vitess-sqlparser/sqlparser"
+	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/copier"
 	"github.com/siyuan-note/filelock"
 	"github.com/siyuan-note/logging"
@@ -311,7 +312,7 @@ func buildEmbedBlock(embedBlockID string, excludeIDs []string, headingMode int,
 	return
 }
 
-func SearchRefBlock(id, rootID, keyword string, beforeLen int, isSquareBrackets, isDatabase bool) (ret []*Block, newDoc bool) {
+func SearchRefBlock(c *gin.Context, id, rootID, keyword string, beforeLen int, isSquareBrackets, isDatabase bool) (ret []*Block, newDoc bool) {
 	cachedTrees := map[string]*parse.Tree{}
 
 	onlyDoc := false
@@ -319,12 +320,14 @@ func SearchRefBlock(id, rootID, keyword string, beforeLen int, isSquareBrackets,
 		onlyDoc = Conf.Editor.OnlySearchForDoc
 	}
 
+	boxFilter := search.BoxesFilter(restrictAPITokenBoxes(c, GetTenant(c).RestrictBoxes(nil)))
+	pathFilter := buildACLFilter(c)
 	if "" == keyword {
 		// 查询为空时默认的块引排序规则按最近使用优先 https://github.com/siyuan-note/siyuan/issues/3218
 
 		typeFilter := Conf.Search.TypeFilter()
 		ignoreLines := getRefSearchIgnoreLines()
-		refs := sql.QueryRefsRecent(onlyDoc, typeFilter, ignoreLines)
+		refs := sql.QueryRefsRecent(onlyDoc, typeFilter, boxFilter+pathFilter, ignoreLines)
 		var btsID []string
 		for _, ref := range refs {
 			btsID = append(btsID, ref.DefBlockRootID)
@@ -365,7 +368,7 @@ func SearchRefBlock(id, rootID, keyword string, beforeLen int, isSquareBrackets,
 		return
 	}
 
-	ret = fullTextSearchRefBlock(keyword, beforeLen, onlyDoc)
+	ret = fullTextSearchRefBlock(keyword, boxFilter, pathFilter, beforeLen, onlyDoc)
 	tmp := ret[:0]
 	var btsID []string
 	for _, b := range ret {
@@ -442,8 +445,8 @@ func prependNotebookNameInHPath(blocks []*Block) {
 }
 
 func FindReplace(keyword, replacement string, replaceTypes map[string]bool, ids []string, paths, boxes []string, types map[string]bool, method, orderBy, groupBy int) (err error) {
-	// method：0：文本，1：查询语法，2：SQL，3：正则表达式
-	if 1 == method || 2 == method {
+	// method：0：文本，1：查询语法，2：SQL，3：正则表达式，4：语义，5：模糊，6：结构化查询
+	if 1 == method || 2 == method || 4 == method || 5 == method || 6 == method {
 		err = errors.New(Conf.Language(132))
 		return
 	}
@@ -476,7 +479,7 @@ func FindReplace(keyword, replacement string, replaceTypes map[string]bool, ids
 
 	if 1 > len(ids) {
 		// `Replace All` is no longer affected by pagination https://github.com/siyuan-note/siyuan/issues/8265
-		blocks, _, _, _, _ := FullTextSearchBlock(keyword, boxes, paths, types, method, orderBy, groupBy, 1, math.MaxInt)
+		blocks, _, _, _, _ := FullTextSearchBlock(nil, keyword, boxes, paths, types, nil, method, orderBy, groupBy, 1, math.MaxInt)
 		for _, block := range blocks {
 			ids = append(ids, block.ID)
 		}
@@ -916,10 +919,11 @@ func replaceNodeTokens(n *ast.Node, method int, keyword string, replacement stri
 
 // FullTextSearchBlock 搜索内容块。
 //
-// method：0：关键字，1：查询语法，2：SQL，3：正则表达式
+// method：0：关键字，1：查询语法，2：SQL，3：正则表达式，4：语义，5：模糊，6：结构化查询
 // orderBy: 0：按块类型（默认），1：按创建时间升序，2：按创建时间降序，3：按更新时间升序，4：按更新时间降序，5：按内容顺序（仅在按文档分组时），6：按相关度升序，7：按相关度降序
 // groupBy：0：不分组，1：按文档分组
-func FullTextSearchBlock(query string, boxes, paths []string, types map[string]bool, method, orderBy, groupBy, page, pageSize int) (ret []*Block, matchedBlockCount, matchedRootCount, pageCount int, docMode bool) {
+// facetFilter：分面筛选条件，为空时不筛选
+func FullTextSearchBlock(c *gin.Context, query string, boxes, paths []string, types map[string]bool, facetFilter *SearchFacetFilter, method, orderBy, groupBy, page, pageSize int) (ret []*Block, matchedBlockCount, matchedRootCount, pageCount int, docMode bool) {
 	ret = []*Block{}
 	if "" == query {
 		return
@@ -930,16 +934,8 @@ func FullTextSearchBlock(query string, boxes, paths []string, types map[string]b
 		query = trimQuery
 	}
 
-	var ignoreFilter string
-	if ignoreLines := getSearchIgnoreLines(); 0 < len(ignoreLines) {
-		// Support ignore search results https://github.com/siyuan-note/siyuan/issues/10089
-		buf := bytes.Buffer{}
-		for _, line := range ignoreLines {
-			buf.WriteString(" AND ")
-			buf.WriteString(line)
-		}
-		ignoreFilter += buf.String()
-	}
+	ignoreFilter := buildSearchIgnoreFilter() + buildFacetFilter(facetFilter) + buildACLFilter(c)
+	boxes = restrictAPITokenBoxes(c, GetTenant(c).RestrictBoxes(boxes))
 
 	beforeLen := 36
 	var blocks []*Block
@@ -947,19 +943,38 @@ func FullTextSearchBlock(query string, boxes, paths []string, types map[string]b
 	switch method {
 	case 1: // 查询语法
 		typeFilter := buildTypeFilter(types)
-		boxFilter := buildBoxesFilter(boxes)
+		boxFilter := search.BoxesFilter(boxes)
 		pathFilter := buildPathsFilter(paths)
 		blocks, matchedBlockCount, matchedRootCount = fullTextSearchByQuerySyntax(query, boxFilter, pathFilter, typeFilter, ignoreFilter, orderByClause, beforeLen, page, pageSize)
 	case 2: // SQL
+		if IsBoxRestricted(c) {
+			// SQL 可以查询所有笔记本，只能访问部分笔记本时不能使用
+			break
+		}
 		blocks, matchedBlockCount, matchedRootCount = searchBySQL(query, beforeLen, page, pageSize)
 	case 3: // 正则表达式
 		typeFilter := buildTypeFilter(types)
-		boxFilter := buildBoxesFilter(boxes)
+		boxFilter := search.BoxesFilter(boxes)
 		pathFilter := buildPathsFilter(paths)
 		blocks, matchedBlockCount, matchedRootCount = fullTextSearchByRegexp(query, boxFilter, pathFilter, typeFilter, ignoreFilter, orderByClause, beforeLen, page, pageSize)
+	case 4: // 语义
+		typeFilter := buildTypeFilter(types)
+		boxFilter := search.BoxesFilter(boxes)
+		pathFilter := buildPathsFilter(paths)
+		blocks, matchedBlockCount, matchedRootCount = fullTextSearchBySemantic(query, boxFilter, pathFilter, typeFilter, ignoreFilter, beforeLen, page, pageSize)
+	case 5: // 模糊
+		typeFilter := buildTypeFilter(types)
+		boxFilter := search.BoxesFilter(boxes)
+		pathFilter := buildPathsFilter(paths)
+		blocks, matchedBlockCount, matchedRootCount = fullTextSearchByFuzzy(query, boxFilter, pathFilter, typeFilter, ignoreFilter, orderByClause, beforeLen, page, pageSize)
+	case 6: // 结构化查询
+		typeFilter := buildTypeFilter(types)
+		boxFilter := search.BoxesFilter(boxes)
+		pathFilter := buildPathsFilter(paths)
+		blocks, matchedBlockCount, matchedRootCount = fullTextSearchByStructuredQuery(c, query, boxFilter, pathFilter, typeFilter, ignoreFilter, orderByClause, beforeLen, page, pageSize)
 	default: // 关键字
 		typeFilter := buildTypeFilter(types)
-		boxFilter := buildBoxesFilter(boxes)
+		boxFilter := search.BoxesFilter(boxes)
 		pathFilter := buildPathsFilter(paths)
 		if 2 > len(strings.Split(strings.TrimSpace(query), " ")) {
 			blocks, matchedBlockCount, matchedRootCount = fullTextSearchByQuerySyntax(query, boxFilter, pathFilter, typeFilter, ignoreFilter, orderByClause, beforeLen, page, pageSize)
@@ -1061,20 +1076,17 @@ func FullTextSearchBlock(query string, boxes, paths []string, types map[string]b
 	return
 }
 
-func buildBoxesFilter(boxes []string) string {
-	if 0 == len(boxes) {
-		return ""
-	}
-	builder := bytes.Buffer{}
-	builder.WriteString(" AND (")
-	for i, box := range boxes {
-		builder.WriteString(fmt.Sprintf("box = '%s'", box))
-		if i < len(boxes)-1 {
-			builder.WriteString(" OR ")
+func buildSearchIgnoreFilter() (ret string) {
+	if ignoreLines := getSearchIgnoreLines(); 0 < len(ignoreLines) {
+		// Support ignore search results https://github.com/siyuan-note/siyuan/issues/10089
+		buf := bytes.Buffer{}
+		for _, line := range ignoreLines {
+			buf.WriteString(" AND ")
+			buf.WriteString(line)
 		}
+		ret = buf.String()
 	}
-	builder.WriteString(")")
-	return builder.String()
+	return
 }
 
 func buildPathsFilter(paths []string) string {
@@ -1104,13 +1116,13 @@ func buildOrderBy(query string, method, orderBy int) string {
 	case 4:
 		return "ORDER BY updated DESC"
 	case 6:
-		if 0 != method && 1 != method {
-			// 只有关键字搜索和查询语法搜索才支持按相关度升序 https://github.com/siyuan-note/siyuan/issues/7861
+		if 0 != method && 1 != method && 5 != method && 6 != method {
+			// 只有关键字搜索、查询语法搜索、模糊搜索和结构化查询才支持按相关度升序 https://github.com/siyuan-note/siyuan/issues/7861
 			return "ORDER BY sort DESC, updated DESC"
 		}
 		return "ORDER BY rank DESC" // 默认是按相关度降序，所以按相关度升序要反过来使用 DESC
 	case 7:
-		if 0 != method && 1 != method {
+		if 0 != method && 1 != method && 5 != method && 6 != method {
 			return "ORDER BY sort ASC, updated DESC"
 		}
 		return "ORDER BY rank" // 默认是按相关度降序
@@ -1215,11 +1227,12 @@ func removeLimitClause(stmt string) string {
 	return stmt
 }
 
-func fullTextSearchRefBlock(keyword string, beforeLen int, onlyDoc bool) (ret []*Block) {
+// fullTextSearchRefBlock 搜索块引候选，boxFilter 和 pathFilter 为当前请求可以访问的笔记本和路径范围。
+func fullTextSearchRefBlock(keyword, boxFilter, pathFilter string, beforeLen int, onlyDoc bool) (ret []*Block) {
 	keyword = filterQueryInvisibleChars(keyword)
 
 	if id := extractID(keyword); "" != id {
-		ret, _, _ = searchBySQL("SELECT * FROM `blocks` WHERE `id` = '"+id+"'", 36, 1, 32)
+		ret, _, _ = searchBySQL("SELECT * FROM `blocks` WHERE `id` = '"+id+"'"+boxFilter+pathFilter, 36, 1, 32)
 		return
 	}
 
@@ -1237,12 +1250,13 @@ func fullTextSearchRefBlock(keyword string, beforeLen int, onlyDoc bool) (ret []
 		"snippet(" + table + ", 10, '" + search.SearchMarkLeft + "', '" + search.SearchMarkRight + "', '...', 64) AS tag, " +
 		"snippet(" + table + ", 11, '" + search.SearchMarkLeft + "', '" + search.SearchMarkRight + "', '...', 64) AS content, " +
 		"fcontent, markdown, length, type, subtype, ial, sort, created, updated"
-	stmt := "SELECT " + projections + " FROM " + table + " WHERE " + table + " MATCH '" + columnFilter() + ":(" + quotedKeyword + ")' AND type"
+	stmt := "SELECT " + projections + " FROM " + table + " WHERE " + table + " MATCH '" + columnFilter() + ":(" + quotedKeyword + ")" + tokensFilter(keyword) + "' AND type"
 	if onlyDoc {
 		stmt += " = 'd'"
 	} else {
 		stmt += " IN " + Conf.Search.TypeFilter()
 	}
+	stmt += boxFilter + pathFilter
 
 	if ignoreLines := getRefSearchIgnoreLines(); 0 < len(ignoreLines) {
 		// Support ignore search results https://github.com/siyuan-note/siyuan/issues/10089
@@ -1273,6 +1287,9 @@ func fullTextSearchRefBlock(keyword string, beforeLen int, onlyDoc bool) (ret []
 	stmt += orderBy + " LIMIT " + strconv.Itoa(Conf.Search.Limit)
 	blocks := sql.SelectBlocksRawStmtNoParse(stmt, Conf.Search.Limit)
 	ret = fromSQLBlocks(&blocks, "", beforeLen)
+	if 1 > len(ret) && Conf.Search.FuzzyRef {
+		ret = fullTextSearchRefBlockByFuzzy(keyword, boxFilter, pathFilter, beforeLen, onlyDoc)
+	}
 	if 1 > len(ret) {
 		ret = []*Block{}
 	}
@@ -1350,7 +1367,7 @@ func fullTextSearchCountByRegexp(exp, boxFilter, pathFilter, typeFilter, ignoreF
 }
 
 func fullTextSearchByFTS(query, boxFilter, pathFilter, typeFilter, ignoreFilter, orderBy string, beforeLen, page, pageSize int) (ret []*Block, matchedBlockCount, matchedRootCount int) {
-	query = stringQuery(query)
+	match := columnFilter() + ":(" + stringQuery(query) + ")" + tokensFilter(query)
 	table := "blocks_fts" // 大小写敏感
 	if !Conf.Search.CaseSensitive {
 		table = "blocks_fts_case_insensitive"
@@ -1364,7 +1381,7 @@ func fullTextSearchByFTS(query, boxFilter, pathFilter, typeFilter, ignoreFilter,
 		"snippet(" + table + ", 10, '" + search.SearchMarkLeft + "', '" + search.SearchMarkRight + "', '...', 64) AS tag, " +
 		"snippet(" + table + ", 11, '" + search.SearchMarkLeft + "', '" + search.SearchMarkRight + "', '...', 512) AS content, " +
 		"fcontent, markdown, length, type, subtype, ial, sort, created, updated"
-	stmt := "SELECT " + projections + " FROM " + table + " WHERE (`" + table + "` MATCH '" + columnFilter() + ":(" + query + ")'"
+	stmt := "SELECT " + projections + " FROM " + table + " WHERE (`" + table + "` MATCH '" + match + "'"
 	stmt += ") AND type IN " + typeFilter
 	stmt += boxFilter + pathFilter + ignoreFilter + " " + orderBy
 	stmt += " LIMIT " + strconv.Itoa(pageSize) + " OFFSET " + strconv.Itoa((page-1)*pageSize)
@@ -1374,17 +1391,18 @@ func fullTextSearchByFTS(query, boxFilter, pathFilter, typeFilter, ignoreFilter,
 		ret = []*Block{}
 	}
 
-	matchedBlockCount, matchedRootCount = fullTextSearchCountByFTS(query, boxFilter, pathFilter, typeFilter, ignoreFilter)
+	matchedBlockCount, matchedRootCount = fullTextSearchCountByFTS(match, boxFilter, pathFilter, typeFilter, ignoreFilter)
 	return
 }
 
-func fullTextSearchCountByFTS(query, boxFilter, pathFilter, typeFilter, ignoreFilter string) (matchedBlockCount, matchedRootCount int) {
+// fullTextSearchCountByFTS 统计匹配的块数和文档数，match 为 FTS5 匹配表达式。
+func fullTextSearchCountByFTS(match, boxFilter, pathFilter, typeFilter, ignoreFilter string) (matchedBlockCount, matchedRootCount int) {
 	table := "blocks_fts" // 大小写敏感
 	if !Conf.Search.CaseSensitive {
 		table = "blocks_fts_case_insensitive"
 	}
 
-	stmt := "SELECT COUNT(id) AS `matches`, COUNT(DISTINCT(root_id)) AS `docs` FROM `" + table + "` WHERE (`" + table + "` MATCH '" + columnFilter() + ":(" + query + ")'"
+	stmt := "SELECT COUNT(id) AS `matches`, COUNT(DISTINCT(root_id)) AS `docs` FROM `" + table + "` WHERE (`" + table + "` MATCH '" + match + "'"
 	stmt += ") AND type IN " + typeFilter
 	stmt += boxFilter + pathFilter + ignoreFilter
 	result, _ := sql.QueryNoLimit(stmt)
@@ -1681,6 +1699,19 @@ func columnFilter() string {
 	return buf.String()
 }
 
+// tokensFilter 返回 tokens 列上的匹配表达式，和其他列上的匹配为 OR 关系，未配置分词流水线时返回空。
+func tokensFilter(query string) string {
+	if 1 > len(Conf.Search.Tokenizers) {
+		return ""
+	}
+
+	tokensQuery := search.TokenizeQuery(query, Conf.Search.Tokenizers)
+	if "" == tokensQuery {
+		return ""
+	}
+	return " OR tokens:(" + tokensQuery + ")"
+}
+
 func columnConcat() string {
 	buf := bytes.Buffer{}
 	buf.WriteString("content")
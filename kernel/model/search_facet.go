@@ -0,0 +1,270 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/search"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+)
+
+const searchFacetLimit = 64 // 每个分面最多返回的取值数
+
+// SearchFacets 描述了搜索结果的分面统计，统计范围为所有命中的块。
+type SearchFacets struct {
+	Boxes   []*SearchFacet            `json:"boxes"`   // 按笔记本
+	Docs    []*SearchFacet            `json:"docs"`    // 按文档
+	Types   []*SearchFacet            `json:"types"`   // 按块类型
+	Tags    []*SearchFacet            `json:"tags"`    // 按标签
+	Created []*SearchFacet            `json:"created"` // 按创建时间所在月份
+	Updated []*SearchFacet            `json:"updated"` // 按更新时间所在月份
+	Attrs   map[string][]*SearchFacet `json:"attrs"`   // 按自定义属性值，键为属性名
+}
+
+// SearchFacet 描述了分面中的一个取值。
+type SearchFacet struct {
+	Value string `json:"value"` // 取值，作为筛选条件回传
+	Label string `json:"label"` // 显示名称
+	Count int    `json:"count"` // 命中的块数
+}
+
+// SearchFacetFilter 描述了分面筛选条件，同一分面的多个取值之间为 OR，不同分面之间为 AND。
+type SearchFacetFilter struct {
+	Boxes   []string            `json:"boxes"`   // 笔记本 ID
+	Docs    []string            `json:"docs"`    // 文档 ID
+	Types   []string            `json:"types"`   // 块类型缩写，比如 p、h
+	Tags    []string            `json:"tags"`    // 标签
+	Created []string            `json:"created"` // 创建时间所在月份，格式为 yyyyMM
+	Updated []string            `json:"updated"` // 更新时间所在月份，格式为 yyyyMM
+	Attrs   map[string][]string `json:"attrs"`   // 自定义属性值，键为属性名
+}
+
+// SearchBlockFacets 统计搜索结果的分面，参数和 FullTextSearchBlock 一致。
+//
+// SQL 和语义搜索的命中范围无法用表达式描述，不支持分面统计。
+func SearchBlockFacets(c *gin.Context, query string, boxes, paths []string, types map[string]bool, facetFilter *SearchFacetFilter, method int) (ret *SearchFacets) {
+	ret = &SearchFacets{Boxes: []*SearchFacet{}, Docs: []*SearchFacet{}, Types: []*SearchFacet{}, Tags: []*SearchFacet{}, Created: []*SearchFacet{}, Updated: []*SearchFacet{}, Attrs: map[string][]*SearchFacet{}}
+	query = strings.TrimSpace(query)
+	if "" == query {
+		return
+	}
+
+	table, matchClause, hasType := buildSearchMatchClause(c, query, method)
+	if "" == matchClause {
+		return
+	}
+	boxes = restrictAPITokenBoxes(c, GetTenant(c).RestrictBoxes(boxes))
+
+	where := " WHERE " + matchClause + search.BoxesFilter(boxes) + buildPathsFilter(paths) + buildSearchIgnoreFilter() + buildFacetFilter(facetFilter) + buildACLFilter(c)
+	if !hasType {
+		where += " AND type IN " + buildTypeFilter(types)
+	}
+	limit := " ORDER BY count DESC LIMIT " + strconv.Itoa(searchFacetLimit)
+
+	ret.Boxes = querySearchFacets("SELECT box AS value, COUNT(*) AS count FROM " + table + where + " GROUP BY box" + limit)
+	var boxIDs []string
+	for _, facet := range ret.Boxes {
+		boxIDs = append(boxIDs, facet.Value)
+	}
+	boxNames := Conf.BoxNames(c, boxIDs)
+	for _, facet := range ret.Boxes {
+		facet.Label = boxNames[facet.Value]
+	}
+
+	ret.Docs = querySearchFacets("SELECT root_id AS value, COUNT(*) AS count FROM " + table + where + " GROUP BY root_id" + limit)
+	var rootIDs []string
+	for _, facet := range ret.Docs {
+		rootIDs = append(rootIDs, facet.Value)
+	}
+	roots := map[string]string{}
+	for _, root := range sql.GetBlocks(rootIDs) {
+		if nil != root {
+			roots[root.ID] = root.Content
+		}
+	}
+	for _, facet := range ret.Docs {
+		facet.Label = roots[facet.Value]
+	}
+
+	ret.Types = querySearchFacets("SELECT type AS value, COUNT(*) AS count FROM " + table + where + " GROUP BY type" + limit)
+	for _, facet := range ret.Types {
+		facet.Label = treenode.FromAbbrType(facet.Value)
+	}
+
+	ret.Created = querySearchFacets("SELECT substr(created, 1, 6) AS value, COUNT(*) AS count FROM " + table + where + " GROUP BY value" + limit)
+	ret.Updated = querySearchFacets("SELECT substr(updated, 1, 6) AS value, COUNT(*) AS count FROM " + table + where + " GROUP BY value" + limit)
+	for _, facet := range append(ret.Created, ret.Updated...) {
+		if 6 == len(facet.Value) {
+			facet.Label = facet.Value[:4] + "-" + facet.Value[4:]
+		}
+	}
+
+	ret.Tags = searchTagFacets("SELECT tag FROM " + table + where + " AND tag != ''")
+
+	stmt := "SELECT name, value, COUNT(*) AS count FROM attributes WHERE name LIKE 'custom-%' AND block_id IN (SELECT id FROM " + table + where + ")" +
+		" GROUP BY name, value ORDER BY count DESC LIMIT " + strconv.Itoa(searchFacetLimit*4)
+	result, _ := sql.QueryNoLimit(stmt)
+	for _, row := range result {
+		name, _ := row["name"].(string)
+		value, _ := row["value"].(string)
+		count, _ := row["count"].(int64)
+		if searchFacetLimit <= len(ret.Attrs[name]) {
+			continue
+		}
+		ret.Attrs[name] = append(ret.Attrs[name], &SearchFacet{Value: value, Label: value, Count: int(count)})
+	}
+	return
+}
+
+// buildSearchMatchClause 返回搜索方式对应的查询表和命中条件，hasType 表示命中条件中已经指定了块类型。
+func buildSearchMatchClause(c *gin.Context, query string, method int) (table, matchClause string, hasType bool) {
+	query = filterQueryInvisibleChars(query)
+	if ast.IsNodeIDPattern(query) {
+		return "blocks", "id = '" + query + "'", false
+	}
+
+	table = "blocks_fts" // 大小写敏感
+	if !Conf.Search.CaseSensitive {
+		table = "blocks_fts_case_insensitive"
+	}
+
+	switch method {
+	case 0, 1: // 关键字、查询语法，文档全文搜索模式下统计同时包含所有关键字的块
+		matchClause = "(`" + table + "` MATCH '" + columnFilter() + ":(" + stringQuery(query) + ")" + tokensFilter(query) + "')"
+	case 3: // 正则表达式
+		table = "blocks"
+		matchClause = fieldRegexp(query)
+	case 5: // 模糊
+		if match, _ := buildFuzzyMatch(query); "" != match {
+			matchClause = "(`" + table + "` MATCH '" + columnFilter() + ":(" + match + ")')"
+		}
+	case 6: // 结构化查询
+		if q, err := buildStructuredQuery(c, query); err == nil {
+			table, matchClause = structuredQueryMatchClause(q)
+			matchClause += q.filter
+			hasType = q.hasType
+		}
+	}
+	return
+}
+
+// buildFacetFilter 将分面筛选条件转换为查询条件。
+func buildFacetFilter(facetFilter *SearchFacetFilter) string {
+	if nil == facetFilter {
+		return ""
+	}
+
+	var boxes []string
+	for _, box := range facetFilter.Boxes {
+		if ast.IsNodeIDPattern(box) {
+			boxes = append(boxes, box)
+		}
+	}
+
+	buf := bytes.Buffer{}
+	buf.WriteString(search.BoxesFilter(boxes))
+	buf.WriteString(buildFacetInFilter("root_id", facetFilter.Docs))
+	buf.WriteString(buildFacetInFilter("type", facetFilter.Types))
+	buf.WriteString(buildFacetInFilter("substr(created, 1, 6)", facetFilter.Created))
+	buf.WriteString(buildFacetInFilter("substr(updated, 1, 6)", facetFilter.Updated))
+
+	if 0 < len(facetFilter.Tags) {
+		var tagFilters []string
+		for _, tag := range facetFilter.Tags {
+			tagFilters = append(tagFilters, "instr(tag, '#"+escapeSQLStr(tag)+"#') > 0")
+		}
+		buf.WriteString(" AND (" + strings.Join(tagFilters, " OR ") + ")")
+	}
+
+	var names []string
+	for name := range facetFilter.Attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		values := facetFilter.Attrs[name]
+		if 1 > len(values) {
+			continue
+		}
+		buf.WriteString(" AND id IN (SELECT block_id FROM attributes WHERE name = '" + escapeSQLStr(name) + "'" + buildFacetInFilter("value", values) + ")")
+	}
+	return buf.String()
+}
+
+func buildFacetInFilter(field string, values []string) string {
+	if 1 > len(values) {
+		return ""
+	}
+
+	var quoted []string
+	for _, v := range values {
+		quoted = append(quoted, "'"+escapeSQLStr(v)+"'")
+	}
+	return " AND " + field + " IN (" + strings.Join(quoted, ", ") + ")"
+}
+
+func querySearchFacets(stmt string) (ret []*SearchFacet) {
+	ret = []*SearchFacet{}
+	result, _ := sql.QueryNoLimit(stmt)
+	for _, row := range result {
+		value, _ := row["value"].(string)
+		count, _ := row["count"].(int64)
+		ret = append(ret, &SearchFacet{Value: value, Label: value, Count: int(count)})
+	}
+	return
+}
+
+func searchTagFacets(stmt string) (ret []*SearchFacet) {
+	ret = []*SearchFacet{}
+	result, _ := sql.QueryNoLimit(stmt)
+	counts := map[string]int{}
+	for _, row := range result {
+		tag, _ := row["tag"].(string)
+		tag = strings.TrimSuffix(strings.TrimPrefix(tag, "#"), "#")
+		for _, t := range gulu.Str.RemoveDuplicatedElem(strings.Split(tag, "# #")) {
+			if "" != t {
+				counts[t]++
+			}
+		}
+	}
+
+	for tag, count := range counts {
+		ret = append(ret, &SearchFacet{Value: tag, Label: tag, Count: count})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Count == ret[j].Count {
+			return ret[i].Value < ret[j].Value
+		}
+		return ret[i].Count > ret[j].Count
+	})
+	if searchFacetLimit < len(ret) {
+		ret = ret[:searchFacetLimit]
+	}
+	return
+}
+
+func escapeSQLStr(str string) string {
+	return strings.ReplaceAll(str, "'", "''")
+}
@@ -0,0 +1,95 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/siyuan/kernel/search"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+)
+
+const fuzzyTermsLimit = 8 // 每个关键字最多扩展的模糊匹配词项数
+
+// fullTextSearchByFuzzy 将关键字扩展为索引中编辑距离相近的词项后进行全文搜索，用于容忍拼写错误。
+func fullTextSearchByFuzzy(query, boxFilter, pathFilter, typeFilter, ignoreFilter, orderBy string, beforeLen, page, pageSize int) (ret []*Block, matchedBlockCount, matchedRootCount int) {
+	ret = []*Block{}
+	match, terms := buildFuzzyMatch(filterQueryInvisibleChars(query))
+	if "" == match {
+		return
+	}
+
+	table := "blocks_fts" // 大小写敏感
+	if !Conf.Search.CaseSensitive {
+		table = "blocks_fts_case_insensitive"
+	}
+	stmt := "SELECT id, parent_id, root_id, hash, box, path, hpath, name, alias, memo, tag, content, fcontent, markdown, length, type, subtype, ial, sort, created, updated FROM " + table + " WHERE (`" + table + "` MATCH '" + columnFilter() + ":(" + match + ")'"
+	stmt += ") AND type IN " + typeFilter
+	stmt += boxFilter + pathFilter + ignoreFilter + " " + orderBy
+	stmt += " LIMIT " + strconv.Itoa(pageSize) + " OFFSET " + strconv.Itoa((page-1)*pageSize)
+	blocks := sql.SelectBlocksRawStmt(stmt, page, pageSize)
+	// 模糊匹配到的词项和关键字不同，不能使用 FTS snippet() 高亮，这里将所有词项交给 search.MarkText 高亮
+	ret = fromSQLBlocks(&blocks, strings.Join(terms, search.TermSep), beforeLen)
+	if 1 > len(ret) {
+		ret = []*Block{}
+	}
+
+	matchedBlockCount, matchedRootCount = fullTextSearchCountByFTS(columnFilter()+":("+match+")", boxFilter, pathFilter, typeFilter, ignoreFilter)
+	return
+}
+
+// fullTextSearchRefBlockByFuzzy 在块引搜索没有结果时使用模糊搜索。
+func fullTextSearchRefBlockByFuzzy(keyword, boxFilter, pathFilter string, beforeLen int, onlyDoc bool) (ret []*Block) {
+	typeFilter := Conf.Search.TypeFilter()
+	if onlyDoc {
+		typeFilter = "('d')"
+	}
+
+	var ignoreFilter string
+	if ignoreLines := getRefSearchIgnoreLines(); 0 < len(ignoreLines) {
+		buf := bytes.Buffer{}
+		for _, line := range ignoreLines {
+			buf.WriteString(" AND ")
+			buf.WriteString(line)
+		}
+		ignoreFilter = buf.String()
+	}
+
+	ret, _, _ = fullTextSearchByFuzzy(keyword, boxFilter, pathFilter, typeFilter, ignoreFilter, "ORDER BY rank", beforeLen, 1, Conf.Search.Limit)
+	return
+}
+
+// buildFuzzyMatch 构造 FTS MATCH 表达式，每个关键字和它的模糊匹配词项之间为 OR，关键字之间为 AND。
+func buildFuzzyMatch(query string) (match string, terms []string) {
+	var groups []string
+	for _, word := range strings.Fields(query) {
+		words := append([]string{word}, sql.GetFuzzyTerms(word, Conf.Search.FuzzyMaxDistance, fuzzyTermsLimit)...)
+		words = gulu.Str.RemoveDuplicatedElem(words)
+
+		var group []string
+		for _, w := range words {
+			group = append(group, stringQuery(w))
+		}
+		groups = append(groups, "("+strings.Join(group, " OR ")+")")
+		terms = append(terms, words...)
+	}
+	match = strings.Join(groups, " AND ")
+	return
+}
@@ -0,0 +1,246 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/88250/lute/ast"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/siyuan/kernel/search"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// structuredQuery 描述了结构化查询转换后的查询条件。
+type structuredQuery struct {
+	match   string // FTS MATCH 表达式，没有全文关键字时为空
+	filter  string // 字段筛选条件，以 AND 开头
+	hasType bool   // 是否通过 type 字段指定了块类型，指定时不再使用搜索设置中的块类型
+}
+
+// searchQueryTypes 为 type 字段支持的块类型名称，和搜索设置中的块类型一致。
+var searchQueryTypes = map[string]string{
+	"document":      "d",
+	"heading":       "h",
+	"list":          "l",
+	"listItem":      "i",
+	"codeBlock":     "c",
+	"mathBlock":     "m",
+	"table":         "t",
+	"blockquote":    "b",
+	"superBlock":    "s",
+	"paragraph":     "p",
+	"htmlBlock":     "html",
+	"embedBlock":    "query_embed",
+	"databaseBlock": "av",
+	"audioBlock":    "audio",
+	"videoBlock":    "video",
+	"iframeBlock":   "iframe",
+	"widgetBlock":   "widget",
+}
+
+// ParseSearchQuery 校验结构化查询，用于保存搜索条件前检查。
+func ParseSearchQuery(c *gin.Context, query string) (err error) {
+	_, err = buildStructuredQuery(c, query)
+	return
+}
+
+// fullTextSearchByStructuredQuery 使用结构化查询搜索块，全文关键字通过 FTS 匹配，字段转换为查询条件。
+func fullTextSearchByStructuredQuery(c *gin.Context, query, boxFilter, pathFilter, typeFilter, ignoreFilter, orderBy string, beforeLen, page, pageSize int) (ret []*Block, matchedBlockCount, matchedRootCount int) {
+	ret = []*Block{}
+	q, err := buildStructuredQuery(c, filterQueryInvisibleChars(query))
+	if err != nil {
+		util.PushErrMsg(fmt.Sprintf(Conf.Language(254), err), 5000)
+		return
+	}
+
+	table, matchClause := structuredQueryMatchClause(q)
+	where := " WHERE " + matchClause + q.filter + boxFilter + pathFilter + ignoreFilter
+	if !q.hasType {
+		where += " AND type IN " + typeFilter
+	}
+
+	projections := "*"
+	if "blocks" != table {
+		projections = "id, parent_id, root_id, hash, box, path, " +
+			"snippet(" + table + ", 6, '" + search.SearchMarkLeft + "', '" + search.SearchMarkRight + "', '...', 512) AS hpath, " +
+			"snippet(" + table + ", 7, '" + search.SearchMarkLeft + "', '" + search.SearchMarkRight + "', '...', 512) AS name, " +
+			"snippet(" + table + ", 8, '" + search.SearchMarkLeft + "', '" + search.SearchMarkRight + "', '...', 512) AS alias, " +
+			"snippet(" + table + ", 9, '" + search.SearchMarkLeft + "', '" + search.SearchMarkRight + "', '...', 512) AS memo, " +
+			"snippet(" + table + ", 10, '" + search.SearchMarkLeft + "', '" + search.SearchMarkRight + "', '...', 64) AS tag, " +
+			"snippet(" + table + ", 11, '" + search.SearchMarkLeft + "', '" + search.SearchMarkRight + "', '...', 512) AS content, " +
+			"fcontent, markdown, length, type, subtype, ial, sort, created, updated"
+	} else if strings.Contains(orderBy, "rank") {
+		// 没有全文关键字时没有相关度
+		orderBy = "ORDER BY sort ASC, updated DESC"
+	}
+
+	stmt := "SELECT " + projections + " FROM " + table + where + " " + orderBy
+	stmt += " LIMIT " + strconv.Itoa(pageSize) + " OFFSET " + strconv.Itoa((page-1)*pageSize)
+	blocks := sql.SelectBlocksRawStmt(stmt, page, pageSize)
+	ret = fromSQLBlocks(&blocks, "", beforeLen)
+	if 1 > len(ret) {
+		ret = []*Block{}
+	}
+
+	result, _ := sql.QueryNoLimit("SELECT COUNT(id) AS `matches`, COUNT(DISTINCT(root_id)) AS `docs` FROM " + table + where)
+	if 1 > len(result) {
+		return
+	}
+	matchedBlockCount = int(result[0]["matches"].(int64))
+	matchedRootCount = int(result[0]["docs"].(int64))
+	return
+}
+
+// structuredQueryMatchClause 返回结构化查询的查询表和全文匹配条件。
+func structuredQueryMatchClause(q *structuredQuery) (table, matchClause string) {
+	if "" == q.match {
+		return "blocks", "1 = 1"
+	}
+
+	table = "blocks_fts" // 大小写敏感
+	if !Conf.Search.CaseSensitive {
+		table = "blocks_fts_case_insensitive"
+	}
+	matchClause = "(`" + table + "` MATCH '" + columnFilter() + ":(" + q.match + ")')"
+	return
+}
+
+func buildStructuredQuery(c *gin.Context, query string) (ret *structuredQuery, err error) {
+	terms, err := search.ParseQuery(query)
+	if err != nil {
+		return
+	}
+
+	ret = &structuredQuery{}
+	var includes, excludes []string
+	filter := bytes.Buffer{}
+	for _, term := range terms {
+		if "" == term.Field {
+			if term.Negated {
+				excludes = append(excludes, phraseQuery(term.Value))
+			} else {
+				includes = append(includes, phraseQuery(term.Value))
+			}
+			continue
+		}
+
+		var condition string
+		if condition, err = buildStructuredQueryCondition(c, term); err != nil {
+			return
+		}
+		if "type" == term.Field && !term.Negated {
+			ret.hasType = true
+		}
+		if term.Negated {
+			condition = "NOT (" + condition + ")"
+		}
+		filter.WriteString(" AND " + condition)
+	}
+
+	if 0 < len(includes) {
+		ret.match = "(" + strings.Join(includes, " ") + ")"
+		if 0 < len(excludes) {
+			ret.match += " NOT (" + strings.Join(excludes, " OR ") + ")"
+		}
+	} else if 0 < len(excludes) {
+		// 只有排除的关键字时无法使用 FTS NOT，改为排除匹配的块
+		table := "blocks_fts" // 大小写敏感
+		if !Conf.Search.CaseSensitive {
+			table = "blocks_fts_case_insensitive"
+		}
+		filter.WriteString(" AND id NOT IN (SELECT id FROM " + table + " WHERE `" + table + "` MATCH '" + columnFilter() + ":(" + strings.Join(excludes, " OR ") + ")')")
+	}
+	ret.filter = filter.String()
+	return
+}
+
+// phraseQuery 将关键字作为一个 FTS 短语。
+func phraseQuery(keyword string) string {
+	keyword = strings.ReplaceAll(keyword, "\"", "\"\"")
+	keyword = strings.ReplaceAll(keyword, "'", "''")
+	return "\"" + keyword + "\""
+}
+
+// buildStructuredQueryCondition 将字段项转换为查询条件。
+func buildStructuredQueryCondition(c *gin.Context, term *search.QueryTerm) (ret string, err error) {
+	value := escapeSQLStr(term.Value)
+	switch term.Field {
+	case "tag":
+		ret = "instr(tag, '#" + value + "#') > 0"
+	case "type":
+		var abbrs []string
+		for _, typ := range strings.Split(term.Value, ",") {
+			typ = strings.TrimSpace(typ)
+			if "" != treenode.FromAbbrType(typ) {
+				abbrs = append(abbrs, "'"+escapeSQLStr(typ)+"'")
+			} else if abbr := searchQueryTypes[typ]; "" != abbr {
+				abbrs = append(abbrs, "'"+abbr+"'")
+			} else {
+				err = fmt.Errorf("unknown block type [%s]", typ)
+				return
+			}
+		}
+		ret = "type IN (" + strings.Join(abbrs, ", ") + ")"
+	case "box":
+		var boxIDs []string
+		for _, box := range Conf.GetBoxes(c) {
+			if box.ID == term.Value || strings.EqualFold(box.Name, term.Value) {
+				boxIDs = append(boxIDs, box.ID)
+			}
+		}
+		if 1 > len(boxIDs) {
+			err = fmt.Errorf("notebook [%s] not found", term.Value)
+			return
+		}
+		ret = strings.TrimPrefix(search.BoxesFilter(boxIDs), " AND ")
+	case "path":
+		if !strings.HasPrefix(value, "/") {
+			value = "/" + value
+		}
+		ret = "hpath LIKE '" + value + "%'"
+	case "created", "updated":
+		var conditions []string
+		if "" != term.From {
+			conditions = append(conditions, term.Field+" >= '"+term.From+"'")
+		}
+		if "" != term.To {
+			conditions = append(conditions, term.Field+" < '"+term.To+"'")
+		}
+		ret = strings.Join(conditions, " AND ")
+	case "name", "alias", "memo":
+		ret = term.Field + " LIKE '%" + value + "%'"
+	case "id": // 块本身或者文档下的块
+		if !ast.IsNodeIDPattern(term.Value) {
+			err = fmt.Errorf("invalid block ID [%s]", term.Value)
+			return
+		}
+		ret = "(id = '" + value + "' OR root_id = '" + value + "')"
+	default: // 自定义属性，值为 * 时匹配存在该属性的块
+		ret = "id IN (SELECT block_id FROM attributes WHERE name = '" + escapeSQLStr(term.Field) + "'"
+		if "*" != term.Value {
+			ret += " AND value = '" + value + "'"
+		}
+		ret += ")"
+	}
+	return
+}
@@ -0,0 +1,598 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute"
+	"github.com/88250/lute/ast"
+	"github.com/88250/lute/parse"
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/treenode"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const (
+	replacePreviewMaxMatches = 2048           // 预览最多返回的匹配数
+	replaceContextLen        = 32             // 预览中匹配前后上下文的字符数
+	replaceRecordName        = "replace.json" // 替换记录文件名，保存在替换操作的历史目录下
+)
+
+// ReplaceMatch 描述了查找替换中的一处匹配。
+type ReplaceMatch struct {
+	ID            string `json:"id"`            // 匹配 ID，应用替换时用于选择匹配，块内容变化后失效
+	BlockID       string `json:"blockID"`       // 匹配所在块 ID
+	RootID        string `json:"rootID"`        // 匹配所在文档 ID
+	Type          string `json:"type"`          // 替换类型，和 replaceTypes 的键一致
+	Before        string `json:"before"`        // 替换前的文本
+	After         string `json:"after"`         // 替换后的文本
+	ContextBefore string `json:"contextBefore"` // 匹配前的上下文
+	ContextAfter  string `json:"contextAfter"`  // 匹配后的上下文
+}
+
+// ReplaceDocSummary 描述了文档中的匹配（或替换）情况。
+type ReplaceDocSummary struct {
+	RootID string `json:"rootID"` // 文档 ID
+	Box    string `json:"box"`    // 笔记本 ID
+	Path   string `json:"path"`   // 文档路径
+	HPath  string `json:"hPath"`  // 文档可读路径
+	Title  string `json:"title"`  // 文档标题
+	Count  int    `json:"count"`  // 匹配数或者替换数
+}
+
+// ReplacePreview 描述了查找替换预览的结果。
+type ReplacePreview struct {
+	Matches   []*ReplaceMatch      `json:"matches"`   // 匹配列表
+	Docs      []*ReplaceDocSummary `json:"docs"`      // 按文档汇总的匹配数
+	Truncated bool                 `json:"truncated"` // 匹配数超过上限时被截断，汇总中的匹配数不受影响
+}
+
+// ReplaceResult 描述了应用替换的结果。
+type ReplaceResult struct {
+	ReplaceID string               `json:"replaceID"` // 替换记录 ID，用于撤销本次替换
+	Docs      []*ReplaceDocSummary `json:"docs"`      // 按文档汇总的替换数
+	Skipped   []string             `json:"skipped"`   // 块内容已经变化或者不存在的匹配 ID
+}
+
+// replaceRecord 描述了一次替换操作，替换前的文档保存在同一个历史目录下。
+type replaceRecord struct {
+	ID          string               `json:"id"`
+	Keyword     string               `json:"keyword"`
+	Replacement string               `json:"replacement"`
+	Created     int64                `json:"created"`
+	Docs        []*ReplaceDocSummary `json:"docs"`
+}
+
+// FindReplacePreview 预览查找替换，返回每一处匹配替换前后的文本和上下文，不修改文档。
+//
+// 参数和 FindReplace 一致，ids 为空时使用搜索结果中的所有块。
+func FindReplacePreview(keyword, replacement string, replaceTypes map[string]bool, ids []string, paths, boxes []string, types map[string]bool, method, orderBy, groupBy int) (ret *ReplacePreview, err error) {
+	ret = &ReplacePreview{Matches: []*ReplaceMatch{}, Docs: []*ReplaceDocSummary{}}
+	rep, err := newFindReplacer(keyword, replacement, method, groupBy)
+	if err != nil || nil == rep {
+		return
+	}
+
+	ids = gulu.Str.RemoveDuplicatedElem(ids)
+	if 1 > len(ids) {
+		blocks, _, _, _, _ := FullTextSearchBlock(nil, keyword, boxes, paths, types, nil, method, orderBy, groupBy, 1, math.MaxInt)
+		for _, block := range blocks {
+			ids = append(ids, block.ID)
+		}
+	}
+
+	luteEngine := util.NewLute()
+	cachedTrees := map[string]*parse.Tree{}
+	docs := map[string]*ReplaceDocSummary{}
+	visited := map[string]bool{}
+	for _, id := range ids {
+		tree := loadFindReplaceTree(id, cachedTrees)
+		if nil == tree {
+			continue
+		}
+
+		node := treenode.GetNodeInTree(tree, id)
+		if nil == node {
+			continue
+		}
+
+		// 容器块的子块也可能出现在搜索结果中，匹配归属于最近的块，这里按块去重
+		ast.Walk(node, func(n *ast.Node, entering bool) ast.WalkStatus {
+			if !entering || "" == n.ID || !n.IsBlock() {
+				return ast.WalkContinue
+			}
+			if visited[n.ID] {
+				return ast.WalkSkipChildren
+			}
+			visited[n.ID] = true
+
+			for fieldIndex, field := range collectReplaceFields(n, replaceTypes, luteEngine, nil) {
+				for matchIndex, loc := range rep.find(field) {
+					doc := docs[tree.ID]
+					if nil == doc {
+						doc = newReplaceDocSummary(tree)
+						docs[tree.ID] = doc
+						ret.Docs = append(ret.Docs, doc)
+					}
+					doc.Count++
+
+					if replacePreviewMaxMatches <= len(ret.Matches) {
+						ret.Truncated = true
+						continue
+					}
+
+					ret.Matches = append(ret.Matches, &ReplaceMatch{
+						ID:            replaceMatchID(n.ID, fieldIndex, matchIndex, field.text),
+						BlockID:       n.ID,
+						RootID:        tree.ID,
+						Type:          field.typ,
+						Before:        field.text[loc[0]:loc[1]],
+						After:         rep.expand(field, loc),
+						ContextBefore: subStrTail(field.text[:loc[0]], replaceContextLen),
+						ContextAfter:  gulu.Str.SubStr(field.text[loc[1]:], replaceContextLen),
+					})
+				}
+			}
+
+			if ast.NodeDocument == n.Type {
+				// 文档块仅替换标题
+				return ast.WalkSkipChildren
+			}
+			return ast.WalkContinue
+		})
+	}
+	return
+}
+
+// ReplaceMatches 替换选中的匹配，matchIDs 为 FindReplacePreview 返回的匹配 ID。
+//
+// 所有修改的文档在替换前保存到同一个历史目录下，写入文档或者重命名文档标题失败时回滚已经修改的文档，替换成功后可以通过 UndoReplace 一次撤销。
+func ReplaceMatches(c *gin.Context, keyword, replacement string, replaceTypes map[string]bool, matchIDs []string, method int) (ret *ReplaceResult, err error) {
+	ret = &ReplaceResult{Docs: []*ReplaceDocSummary{}, Skipped: []string{}}
+	rep, err := newFindReplacer(keyword, replacement, method, 0)
+	if err != nil || nil == rep {
+		return
+	}
+
+	matchIDs = gulu.Str.RemoveDuplicatedElem(matchIDs)
+	selected := map[string]bool{}
+	var blockIDs []string
+	for _, matchID := range matchIDs {
+		selected[matchID] = true
+		if idx := strings.Index(matchID, "/"); 0 < idx {
+			blockIDs = append(blockIDs, matchID[:idx])
+		}
+	}
+	blockIDs = gulu.Str.RemoveDuplicatedElem(blockIDs)
+
+	luteEngine := util.NewLute()
+	cachedTrees := map[string]*parse.Tree{}
+	docs := map[string]*ReplaceDocSummary{}
+	renameTitles := map[string]string{}
+	applied := map[string]bool{}
+	var trees []*parse.Tree
+	for _, blockID := range blockIDs {
+		tree := loadFindReplaceTree(blockID, cachedTrees)
+		if nil == tree {
+			continue
+		}
+
+		node := treenode.GetNodeInTree(tree, blockID)
+		if nil == node {
+			continue
+		}
+
+		var unlinks []*ast.Node
+		for fieldIndex, field := range collectReplaceFields(node, replaceTypes, luteEngine, &unlinks) {
+			buf := strings.Builder{}
+			last, count := 0, 0
+			for matchIndex, loc := range rep.find(field) {
+				matchID := replaceMatchID(node.ID, fieldIndex, matchIndex, field.text)
+				if !selected[matchID] {
+					continue
+				}
+
+				buf.WriteString(field.text[last:loc[0]])
+				buf.WriteString(rep.expand(field, loc))
+				last = loc[1]
+				count++
+				applied[matchID] = true
+			}
+			if 1 > count {
+				continue
+			}
+			buf.WriteString(field.text[last:])
+
+			if "docTitle" == field.typ {
+				renameTitles[tree.ID] = buf.String()
+			} else {
+				field.set(buf.String())
+			}
+
+			doc := docs[tree.ID]
+			if nil == doc {
+				doc = newReplaceDocSummary(tree)
+				docs[tree.ID] = doc
+				ret.Docs = append(ret.Docs, doc)
+				trees = append(trees, tree)
+			}
+			doc.Count += count
+		}
+
+		for _, unlink := range unlinks {
+			unlink.Unlink()
+		}
+	}
+
+	for _, matchID := range matchIDs {
+		if !applied[matchID] {
+			ret.Skipped = append(ret.Skipped, matchID)
+		}
+	}
+	if 1 > len(trees) {
+		return
+	}
+
+	FlushTxQueue()
+
+	historyDir, err := getHistoryDir(HistoryOpReplace, time.Now())
+	if err != nil {
+		logging.LogErrorf("get history dir failed: %s", err)
+		return
+	}
+	for _, tree := range trees {
+		if err = copyDocToHistoryDir(historyDir, tree); err != nil {
+			return
+		}
+	}
+	indexHistoryDir(filepath.Base(historyDir), luteEngine)
+
+	// 写入或者重命名失败时回滚已经修改的文档，保证替换要么全部生效要么全部不生效
+	for i, tree := range trees {
+		if err = writeTreeUpsertQueue(tree); err != nil {
+			rollbackReplacedDocs(historyDir, trees[:i])
+			return
+		}
+
+		if title, ok := renameTitles[tree.ID]; ok {
+			if err = RenameDoc(c, tree.Box, tree.Path, title); err != nil {
+				logging.LogErrorf("rename doc [%s] failed: %s", tree.ID, err)
+				rollbackReplacedDocs(historyDir, trees[:i+1])
+				return
+			}
+		}
+
+		util.PushEndlessProgress(fmt.Sprintf(Conf.Language(206), i+1, len(trees)))
+	}
+
+	record := &replaceRecord{
+		ID:          filepath.Base(historyDir),
+		Keyword:     keyword,
+		Replacement: replacement,
+		Created:     time.Now().UnixMilli(),
+		Docs:        ret.Docs,
+	}
+	data, err := gulu.JSON.MarshalIndentJSON(record, "", "  ")
+	if err != nil {
+		logging.LogErrorf("marshal replace record failed: %s", err)
+		return
+	}
+	if err = gulu.File.WriteFileSafer(filepath.Join(historyDir, replaceRecordName), data, 0644); err != nil {
+		logging.LogErrorf("write replace record failed: %s", err)
+		return
+	}
+	ret.ReplaceID = record.ID
+
+	sql.FlushQueue()
+	for _, tree := range trees {
+		refreshProtyle(tree.ID)
+	}
+	util.PushClearProgress()
+	return
+}
+
+// UndoReplace 撤销一次 ReplaceMatches 替换，将替换涉及的所有文档回滚到替换前的版本。
+func UndoReplace(c *gin.Context, replaceID string) (err error) {
+	if "" == replaceID || filepath.Base(replaceID) != replaceID || !strings.HasSuffix(replaceID, "-"+HistoryOpReplace) {
+		err = fmt.Errorf(Conf.Language(257), replaceID)
+		return
+	}
+
+	historyDir, err := GetTenant(c).ResolveHistory(replaceID)
+	if err != nil {
+		err = fmt.Errorf(Conf.Language(257), replaceID)
+		return
+	}
+	recordPath := filepath.Join(historyDir, replaceRecordName)
+	if !gulu.File.IsExist(recordPath) {
+		err = fmt.Errorf(Conf.Language(257), replaceID)
+		return
+	}
+
+	data, err := os.ReadFile(recordPath)
+	if err != nil {
+		logging.LogErrorf("read replace record [%s] failed: %s", recordPath, err)
+		return
+	}
+	record := &replaceRecord{}
+	if err = gulu.JSON.UnmarshalJSON(data, record); err != nil {
+		logging.LogErrorf("unmarshal replace record [%s] failed: %s", recordPath, err)
+		return
+	}
+
+	for _, doc := range record.Docs {
+		if err = RollbackDocHistory(doc.Box, filepath.Join(historyDir, doc.Box, doc.Path)); err != nil {
+			logging.LogErrorf("rollback doc [%s] failed: %s", doc.RootID, err)
+			return
+		}
+	}
+	return
+}
+
+// findReplacer 按替换类型查找匹配并生成替换文本。
+type findReplacer struct {
+	method      int
+	replacement string
+	re          *regexp.Regexp // 默认的匹配表达式
+	textRe      *regexp.Regexp // 文本元素的匹配表达式，关键字搜索不区分大小写时忽略大小写
+	tagRe       *regexp.Regexp // 标签的匹配表达式，关键字搜索时去掉关键字首尾的 #
+}
+
+// newFindReplacer 检查查找替换参数，无需替换时返回 nil。
+func newFindReplacer(keyword, replacement string, method, groupBy int) (ret *findReplacer, err error) {
+	if 0 != method && 3 != method {
+		err = errors.New(Conf.Language(132))
+		return
+	}
+
+	if 0 != groupBy {
+		err = errors.New(Conf.Language(221))
+		return
+	}
+
+	if "" == keyword || keyword == replacement {
+		return
+	}
+
+	ret = &findReplacer{method: method, replacement: replacement}
+	if 3 == method {
+		if ret.re, err = regexp.Compile(keyword); err != nil {
+			return nil, err
+		}
+		ret.textRe, ret.tagRe = ret.re, ret.re
+		return
+	}
+
+	ret.re = regexp.MustCompile(regexp.QuoteMeta(keyword))
+	ret.textRe = ret.re
+	if !Conf.Search.CaseSensitive {
+		ret.textRe = regexp.MustCompile("(?i)" + regexp.QuoteMeta(keyword))
+	}
+	ret.tagRe = regexp.MustCompile(regexp.QuoteMeta(strings.TrimSuffix(strings.TrimPrefix(keyword, "#"), "#")))
+	return
+}
+
+func (rep *findReplacer) find(field *replaceField) (ret [][]int) {
+	re := rep.re
+	switch field.typ {
+	case "text":
+		re = rep.textRe
+	case "tag":
+		re = rep.tagRe
+	}
+
+	for _, loc := range re.FindAllStringSubmatchIndex(field.text, -1) {
+		if loc[0] < loc[1] {
+			ret = append(ret, loc)
+		}
+	}
+	return
+}
+
+func (rep *findReplacer) expand(field *replaceField, loc []int) (ret string) {
+	ret = rep.replacement
+	if 3 == rep.method {
+		ret = string(rep.re.ExpandString(nil, rep.replacement, field.text, loc))
+	}
+	if "docTitle" == field.typ {
+		ret = strings.ReplaceAll(ret, "/", "")
+	}
+	return
+}
+
+// replaceField 描述了块中一处可以替换的文本。
+type replaceField struct {
+	typ  string       // 替换类型
+	text string       // 文本
+	set  func(string) // 设置替换后的文本，文档标题需要通过重命名文档修改，为 nil
+}
+
+// collectReplaceFields 按遍历顺序收集块中可以替换的文本，不包括子块中的文本。
+//
+// 替换文本元素时会插入重新解析后的节点，原节点追加到 unlinks 中由调用方移除。
+func collectReplaceFields(block *ast.Node, replaceTypes map[string]bool, luteEngine *lute.Lute, unlinks *[]*ast.Node) (ret []*replaceField) {
+	add := func(typ, text string, set func(string)) {
+		if replaceTypes[typ] {
+			ret = append(ret, &replaceField{typ: typ, text: text, set: set})
+		}
+	}
+
+	if ast.NodeDocument == block.Type {
+		add("docTitle", block.IALAttr("title"), nil)
+		return
+	}
+
+	ast.Walk(block, func(n *ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.WalkContinue
+		}
+		if n != block && "" != n.ID && n.IsBlock() {
+			return ast.WalkSkipChildren
+		}
+
+		setTokens := func(v string) { n.Tokens = []byte(v) }
+		setTextMarkTextContent := func(v string) { n.TextMarkTextContent = v }
+		switch n.Type {
+		case ast.NodeText:
+			add("text", string(n.Tokens), func(v string) {
+				// Supports replacing text elements with other elements https://github.com/siyuan-note/siyuan/issues/11058
+				if tree := parse.Inline("", []byte(v), luteEngine.ParseOptions); nil != tree.Root.FirstChild {
+					parse.NestedInlines2FlattedSpans(tree, false)
+					var replaceNodes []*ast.Node
+					for rNode := tree.Root.FirstChild.FirstChild; nil != rNode; rNode = rNode.Next {
+						replaceNodes = append(replaceNodes, rNode)
+					}
+					for _, rNode := range replaceNodes {
+						n.InsertBefore(rNode)
+					}
+				}
+				*unlinks = append(*unlinks, n)
+			})
+		case ast.NodeLinkDest:
+			add("imgSrc", string(n.Tokens), setTokens)
+		case ast.NodeLinkText:
+			add("imgText", string(n.Tokens), setTokens)
+		case ast.NodeLinkTitle:
+			add("imgTitle", string(n.Tokens), setTokens)
+		case ast.NodeCodeBlockCode:
+			add("codeBlock", string(n.Tokens), setTokens)
+		case ast.NodeMathBlockContent:
+			add("mathBlock", string(n.Tokens), setTokens)
+		case ast.NodeHTMLBlock:
+			add("htmlBlock", string(n.Tokens), setTokens)
+		case ast.NodeTextMark:
+			if n.IsTextMarkType("code") {
+				// 行内代码中的内容是转义过的
+				add("code", util.UnescapeHTML(n.TextMarkTextContent), func(v string) { n.TextMarkTextContent = util.EscapeHTML(v) })
+			} else if n.IsTextMarkType("a") {
+				add("aText", n.TextMarkTextContent, setTextMarkTextContent)
+				add("aTitle", n.TextMarkATitle, func(v string) { n.TextMarkATitle = v })
+				add("aHref", n.TextMarkAHref, func(v string) { n.TextMarkAHref = v })
+			} else if n.IsTextMarkType("em") {
+				add("em", n.TextMarkTextContent, setTextMarkTextContent)
+			} else if n.IsTextMarkType("strong") {
+				add("strong", n.TextMarkTextContent, setTextMarkTextContent)
+			} else if n.IsTextMarkType("kbd") {
+				add("kbd", n.TextMarkTextContent, setTextMarkTextContent)
+			} else if n.IsTextMarkType("mark") {
+				add("mark", n.TextMarkTextContent, setTextMarkTextContent)
+			} else if n.IsTextMarkType("s") {
+				add("s", n.TextMarkTextContent, setTextMarkTextContent)
+			} else if n.IsTextMarkType("sub") {
+				add("sub", n.TextMarkTextContent, setTextMarkTextContent)
+			} else if n.IsTextMarkType("sup") {
+				add("sup", n.TextMarkTextContent, setTextMarkTextContent)
+			} else if n.IsTextMarkType("tag") {
+				add("tag", n.TextMarkTextContent, setTextMarkTextContent)
+			} else if n.IsTextMarkType("u") {
+				add("u", n.TextMarkTextContent, setTextMarkTextContent)
+			} else if n.IsTextMarkType("inline-math") {
+				add("inlineMath", n.TextMarkInlineMathContent, func(v string) { n.TextMarkInlineMathContent = v })
+			} else if n.IsTextMarkType("inline-memo") {
+				add("inlineMemo", n.TextMarkInlineMemoContent, func(v string) { n.TextMarkInlineMemoContent = v })
+			} else if n.IsTextMarkType("text") {
+				add("text", n.TextMarkTextContent, setTextMarkTextContent)
+			} else if n.IsTextMarkType("block-ref") {
+				add("blockRef", n.TextMarkTextContent, func(v string) {
+					n.TextMarkTextContent = v
+					n.TextMarkBlockRefSubtype = "s"
+				})
+			} else if n.IsTextMarkType("file-annotation-ref") {
+				add("fileAnnotationRef", n.TextMarkTextContent, setTextMarkTextContent)
+			}
+		}
+		return ast.WalkContinue
+	})
+	return
+}
+
+// replaceMatchID 生成匹配 ID，包含文本的 hash，块内容变化后 ID 失效以免替换错位置。
+func replaceMatchID(blockID string, fieldIndex, matchIndex int, text string) string {
+	h := fnv.New32a()
+	h.Write([]byte(text))
+	return fmt.Sprintf("%s/%d/%d/%x", blockID, fieldIndex, matchIndex, h.Sum32())
+}
+
+func subStrTail(str string, length int) string {
+	runes := []rune(str)
+	if length < len(runes) {
+		return string(runes[len(runes)-length:])
+	}
+	return str
+}
+
+func newReplaceDocSummary(tree *parse.Tree) *ReplaceDocSummary {
+	return &ReplaceDocSummary{RootID: tree.ID, Box: tree.Box, Path: tree.Path, HPath: tree.HPath, Title: tree.Root.IALAttr("title")}
+}
+
+func loadFindReplaceTree(id string, cachedTrees map[string]*parse.Tree) (ret *parse.Tree) {
+	bt := treenode.GetBlockTree(id)
+	if nil == bt {
+		return
+	}
+
+	if ret = cachedTrees[bt.RootID]; nil != ret {
+		return
+	}
+
+	ret, _ = LoadTreeByBlockID(id)
+	if nil != ret {
+		cachedTrees[bt.RootID] = ret
+	}
+	return
+}
+
+func rollbackReplacedDocs(historyDir string, trees []*parse.Tree) {
+	for _, tree := range trees {
+		if err := RollbackDocHistory(tree.Box, filepath.Join(historyDir, tree.Box, tree.Path)); err != nil {
+			logging.LogErrorf("rollback doc [%s] failed: %s", tree.ID, err)
+		}
+	}
+}
+
+func copyDocToHistoryDir(historyDir string, tree *parse.Tree) (err error) {
+	historyPath := filepath.Join(historyDir, tree.Box, tree.Path)
+	if err = os.MkdirAll(filepath.Dir(historyPath), 0755); err != nil {
+		logging.LogErrorf("generate history failed: %s", err)
+		return
+	}
+
+	var data []byte
+	if data, err = filelock.ReadFile(filepath.Join(util.DataDir, tree.Box, tree.Path)); err != nil {
+		logging.LogErrorf("generate history failed: %s", err)
+		return
+	}
+
+	if err = gulu.File.WriteFileSafer(historyPath, data, 0644); err != nil {
+		logging.LogErrorf("generate history failed: %s", err)
+		return
+	}
+	return
+}
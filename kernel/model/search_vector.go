@@ -0,0 +1,223 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+const (
+	vectorIndexBatchSize    = 64   // 一次向量化请求的块数
+	vectorIndexBackfillSize = 256  // 一次任务中补全向量的块数
+	vectorTextMaxLen        = 2048 // 生成向量时块内容的最大长度
+	semanticCandidateSize   = 1024 // 参与语义相似度排序的全文搜索候选结果数
+)
+
+var (
+	indexBlockVectorLock = sync.Mutex{}
+	lastVectorIndexErr   string // 上次生成向量失败的原因，相同的错误只提示一次
+)
+
+// IndexBlockVectorJob 为内容发生变化的块和还没有向量的块生成向量。
+func IndexBlockVectorJob() {
+	sql.VectorIndexEnabled = isEmbeddingEnabled()
+	if !sql.VectorIndexEnabled {
+		return
+	}
+
+	if !indexBlockVectorLock.TryLock() {
+		return
+	}
+	defer indexBlockVectorLock.Unlock()
+
+	provider := newEmbeddingProvider()
+	var err error
+	defer func() {
+		if nil == err {
+			lastVectorIndexErr = ""
+			return
+		}
+		if msg := err.Error(); msg != lastVectorIndexErr {
+			lastVectorIndexErr = msg
+			util.PushErrMsg(fmt.Sprintf(Conf.Language(270), msg), 7000)
+		}
+	}()
+
+	// 先处理数据库索引队列中内容发生变化的块
+	for {
+		ids := sql.PopVectorQueue(vectorIndexBatchSize)
+		if 1 > len(ids) {
+			break
+		}
+
+		blocks := sql.GetBlocksWithoutVector(ids, vectorIndexBatchSize)
+		if err = indexBlockVectors(provider, blocks); err != nil {
+			return
+		}
+	}
+
+	// 再补全历史数据（比如重建索引后）中还没有向量的块，向量写入数据库前查询结果不变，所以每次任务只处理一批
+	blocks := sql.GetBlocksWithoutVector(nil, vectorIndexBackfillSize)
+	for i := 0; i < len(blocks); i += vectorIndexBatchSize {
+		end := min(i+vectorIndexBatchSize, len(blocks))
+		if err = indexBlockVectors(provider, blocks[i:end]); err != nil {
+			return
+		}
+	}
+}
+
+func indexBlockVectors(provider util.EmbeddingProvider, blocks []*sql.Block) (err error) {
+	if 1 > len(blocks) {
+		return
+	}
+
+	var texts []string
+	for _, b := range blocks {
+		texts = append(texts, gulu.Str.SubStr(b.Content, vectorTextMaxLen))
+	}
+
+	embeddings, err := embed(provider, texts)
+	if err != nil {
+		logging.LogErrorf("index block vectors failed: %s", err)
+		return
+	}
+
+	if len(embeddings) != len(blocks) {
+		err = fmt.Errorf("got [%d] embeddings for [%d] blocks", len(embeddings), len(blocks))
+		logging.LogErrorf("index block vectors failed: %s", err)
+		return
+	}
+
+	var vectors []*sql.BlockVector
+	for i, b := range blocks {
+		vectors = append(vectors, &sql.BlockVector{ID: b.ID, RootID: b.RootID, Hash: b.Hash, Vector: util.NormalizeEmbedding(embeddings[i])})
+	}
+	sql.UpsertBlockVectorsQueue(vectors)
+	return
+}
+
+// fullTextSearchBySemantic 混合语义相似度和全文搜索相关度对全文搜索的候选结果重新排序。
+func fullTextSearchBySemantic(query, boxFilter, pathFilter, typeFilter, ignoreFilter string, beforeLen, page, pageSize int) (ret []*Block, matchedBlockCount, matchedRootCount int) {
+	ret = []*Block{}
+	if !isEmbeddingEnabled() {
+		util.PushErrMsg(Conf.Language(252), 5000)
+		return
+	}
+
+	embeddings, err := embed(newEmbeddingProvider(), []string{query})
+	if err != nil {
+		util.PushErrMsg(fmt.Sprintf(Conf.Language(253), err), 5000)
+		return
+	}
+	queryVector := util.NormalizeEmbedding(embeddings[0])
+
+	// 全文搜索相关度，使用最佳结果的 rank 归一化到 (0, 1]
+	filter := "type IN " + typeFilter + boxFilter + pathFilter + ignoreFilter
+	ranks := fullTextSearchRanks(query, filter)
+	var candidateIDs []string
+	for id := range ranks {
+		candidateIDs = append(candidateIDs, id)
+	}
+
+	// 语义相似度，仅计算候选结果，向量已经归一化，点积即为余弦相似度
+	similarities := map[string]float64{}
+	for id, vector := range sql.GetBlockVectors(candidateIDs) {
+		similarities[id] = util.CosineSimilarity(vector, queryVector)
+	}
+
+	ids := util.HybridRank(similarities, ranks, Conf.AI.Embedding.HybridWeight)
+
+	var sqlBlocks []*sql.Block
+	roots := map[string]bool{}
+	for _, b := range sql.GetBlocks(ids) {
+		if nil != b {
+			sqlBlocks = append(sqlBlocks, b)
+			roots[b.RootID] = true
+		}
+	}
+	matchedBlockCount, matchedRootCount = len(sqlBlocks), len(roots)
+
+	start := (page - 1) * pageSize
+	if start >= len(sqlBlocks) {
+		return
+	}
+	pageBlocks := sqlBlocks[start:min(start+pageSize, len(sqlBlocks))]
+	ret = fromSQLBlocks(&pageBlocks, "", beforeLen)
+	if 1 > len(ret) {
+		ret = []*Block{}
+	}
+	return
+}
+
+func fullTextSearchRanks(query, filter string) (ret map[string]float64) {
+	ret = map[string]float64{}
+	table := "blocks_fts" // 大小写敏感
+	if !Conf.Search.CaseSensitive {
+		table = "blocks_fts_case_insensitive"
+	}
+
+	stmt := "SELECT id, rank FROM " + table + " WHERE (`" + table + "` MATCH '" + columnFilter() + ":(" + stringQuery(query) + ")" + tokensFilter(query) + "') AND " + filter
+	stmt += " ORDER BY rank LIMIT " + strconv.Itoa(semanticCandidateSize)
+	result, err := sql.QueryNoLimit(stmt)
+	if err != nil || 1 > len(result) {
+		return
+	}
+
+	// FTS5 的 rank 为负数，越小越相关
+	best, _ := result[0]["rank"].(float64)
+	if 0 <= best {
+		return
+	}
+	for _, row := range result {
+		id, _ := row["id"].(string)
+		rank, _ := row["rank"].(float64)
+		ret[id] = rank / best
+	}
+	return
+}
+
+func embed(provider util.EmbeddingProvider, texts []string) (ret [][]float32, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(Conf.AI.Embedding.APITimeout)*time.Second)
+	defer cancel()
+	return provider.Embed(ctx, texts)
+}
+
+func isEmbeddingEnabled() bool {
+	embedding := Conf.AI.Embedding
+	if nil == embedding || !embedding.Enabled || "" == embedding.APIBaseURL {
+		return false
+	}
+	return "Local" == embedding.APIProvider || "" != embedding.APIKey
+}
+
+func newEmbeddingProvider() util.EmbeddingProvider {
+	embedding := Conf.AI.Embedding
+	timeout := time.Duration(embedding.APITimeout) * time.Second
+	if "Local" == embedding.APIProvider {
+		return util.NewLocalEmbeddingProvider(embedding.APIBaseURL, embedding.APIModel, embedding.APIProxy, embedding.APIUserAgent, timeout)
+	}
+	return util.NewOpenAIEmbeddingProvider(embedding.APIKey, embedding.APIProxy, embedding.APIBaseURL, embedding.APIUserAgent, embedding.APIVersion, embedding.APIProvider, embedding.APIModel, timeout)
+}
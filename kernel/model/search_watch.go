@@ -0,0 +1,210 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/filelock"
+	"github.com/siyuan-note/httpclient"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/sql"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// criterionWatchState 记录了监视的搜索条件上一次运行的结果。
+type criterionWatchState struct {
+	Query   string            `json:"query"`   // 搜索方式和关键字，变化后重新记录结果
+	Updated int64             `json:"updated"` // 上一次运行的时间（毫秒）
+	Blocks  map[string]string `json:"blocks"`  // 块 ID 到块 hash，hash 变化说明块内容发生了变化
+}
+
+const criterionWatchLimit = 1024 // 监视的搜索条件最多比较的结果数
+
+var criteriaWatchLock = sync.Mutex{}
+
+// WatchCriteriaJob 运行到期的监视搜索条件，和上一次的结果相比有新增或者变化的块时推送通知并回调。
+func WatchCriteriaJob() {
+	if !criteriaWatchLock.TryLock() {
+		return
+	}
+	defer criteriaWatchLock.Unlock()
+
+	states := getCriteriaWatchStates()
+	watching := map[string]bool{}
+	now := time.Now()
+	changed := false
+	for _, criterion := range GetCriteria() {
+		if !criterion.Watch || "" == strings.TrimSpace(criterion.K) {
+			continue
+		}
+		watching[criterion.Name] = true
+
+		query := fmt.Sprintf("%d:%s", criterion.Method, criterion.K)
+		state := states[criterion.Name]
+		interval := time.Duration(max(criterion.WatchInterval, 1)) * time.Minute
+		if nil != state && state.Query == query && now.Sub(time.UnixMilli(state.Updated)) < interval {
+			continue
+		}
+
+		blocks := runWatchedCriterion(criterion)
+		changed = true
+		if nil == state || state.Query != query {
+			// 首次运行或者搜索条件变化时仅记录结果
+			states[criterion.Name] = &criterionWatchState{Query: query, Updated: now.UnixMilli(), Blocks: blocks}
+			continue
+		}
+
+		var added, updated []string
+		for id, hash := range blocks {
+			if lastHash, ok := state.Blocks[id]; !ok {
+				added = append(added, id)
+			} else if lastHash != hash {
+				updated = append(updated, id)
+			}
+		}
+		state.Updated, state.Blocks = now.UnixMilli(), blocks
+		if 0 < len(added) || 0 < len(updated) {
+			notifyCriterionMatched(criterion, added, updated)
+		}
+	}
+
+	for name := range states {
+		if !watching[name] {
+			delete(states, name)
+			changed = true
+		}
+	}
+	if changed {
+		setCriteriaWatchStates(states)
+	}
+}
+
+func runWatchedCriterion(criterion *Criterion) (ret map[string]string) {
+	ret = map[string]string{}
+
+	var boxes, paths []string
+	for _, p := range criterion.IDPath {
+		box := strings.TrimSpace(strings.Split(p, "/")[0])
+		if "" != box {
+			boxes = append(boxes, box)
+		}
+		p = strings.TrimSpace(strings.TrimPrefix(p, box))
+		if "" != p {
+			paths = append(paths, p)
+		}
+	}
+	boxes = gulu.Str.RemoveDuplicatedElem(boxes)
+	paths = gulu.Str.RemoveDuplicatedElem(paths)
+
+	var types map[string]bool
+	if nil != criterion.Types {
+		if data, err := gulu.JSON.MarshalJSON(criterion.Types); err == nil {
+			gulu.JSON.UnmarshalJSON(data, &types)
+		}
+	}
+
+	blocks, _, _, _, _ := FullTextSearchBlock(nil, criterion.K, boxes, paths, types, nil, criterion.Method, criterion.Sort, 0, 1, criterionWatchLimit)
+	var ids []string
+	for _, b := range blocks {
+		ids = append(ids, b.ID)
+	}
+	for _, b := range sql.GetBlocks(ids) {
+		if nil != b {
+			ret[b.ID] = b.Hash
+		}
+	}
+	return
+}
+
+func notifyCriterionMatched(criterion *Criterion, added, updated []string) {
+	msg := fmt.Sprintf(Conf.Language(255), util.EscapeHTML(criterion.Name), len(added)+len(updated))
+	data := map[string]interface{}{"name": criterion.Name, "added": added, "updated": updated}
+	util.PushMsg(msg, 7000)
+	util.BroadcastByType("main", "criterionMatched", 0, msg, data)
+
+	if "" != criterion.Webhook && isLocalWebhook(criterion.Webhook) {
+		go func() {
+			resp, err := httpclient.NewBrowserRequest().SetBodyJsonMarshal(data).Post(criterion.Webhook)
+			if err != nil {
+				logging.LogErrorf("post saved search [%s] webhook [%s] failed: %s", criterion.Name, criterion.Webhook, err)
+				return
+			}
+			if 200 > resp.StatusCode || 300 <= resp.StatusCode {
+				logging.LogWarnf("post saved search [%s] webhook [%s] responded [%d]", criterion.Name, criterion.Webhook, resp.StatusCode)
+			}
+		}()
+	}
+}
+
+// isLocalWebhook 判断回调地址是否为本地地址，避免把搜索结果发送到外部。
+func isLocalWebhook(webhook string) bool {
+	u, err := url.Parse(webhook)
+	if err != nil || ("http" != u.Scheme && "https" != u.Scheme) {
+		return false
+	}
+
+	host := u.Hostname()
+	if "localhost" == strings.ToLower(host) {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return nil != ip && ip.IsLoopback()
+}
+
+func getCriteriaWatchStates() (ret map[string]*criterionWatchState) {
+	ret = map[string]*criterionWatchState{}
+	dataPath := filepath.Join(util.TempDir, "criteria-watch.json")
+	if !filelock.IsExist(dataPath) {
+		return
+	}
+
+	data, err := filelock.ReadFile(dataPath)
+	if err != nil {
+		logging.LogErrorf("read criteria watch states failed: %s", err)
+		return
+	}
+	if err = gulu.JSON.UnmarshalJSON(data, &ret); err != nil {
+		logging.LogErrorf("unmarshal criteria watch states failed: %s", err)
+		ret = map[string]*criterionWatchState{}
+	}
+	return
+}
+
+func setCriteriaWatchStates(states map[string]*criterionWatchState) {
+	if err := os.MkdirAll(util.TempDir, 0755); err != nil {
+		logging.LogErrorf("create temp dir failed: %s", err)
+		return
+	}
+
+	data, err := gulu.JSON.MarshalJSON(states)
+	if err != nil {
+		logging.LogErrorf("marshal criteria watch states failed: %s", err)
+		return
+	}
+	if err = filelock.WriteFile(filepath.Join(util.TempDir, "criteria-watch.json"), data); err != nil {
+		logging.LogErrorf("write criteria watch states failed: %s", err)
+	}
+}
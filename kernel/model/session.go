@@ -30,6 +30,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/tenant"
 	"github.com/siyuan-note/siyuan/kernel/util"
 	"github.com/steambap/captcha"
 )
@@ -43,7 +44,7 @@ func LogoutAuth(c *gin.Context) {
 	ret := gulu.Ret.NewResult()
 	defer c.JSON(http.StatusOK, ret)
 
-	if "" == Conf.AccessAuthCode {
+	if "" == Conf.AccessAuthCode && !isOIDCEnabled() {
 		ret.Code = -1
 		ret.Msg = Conf.Language(86)
 		ret.Data = map[string]interface{}{"closeTimeout": 5000}
@@ -177,15 +178,25 @@ func CheckReadonly(c *gin.Context) {
 func CheckAuth(c *gin.Context) {
 	//已通过psd3的用户账号换取
 	if user := GetGinContextUser(c); IsValidUser(user) {
-		c.Next()
+		if authTenant(c, user) {
+			c.Next()
+		}
 		return
 	}
 
-	if oauthUser := c.GetHeader("LSAuthorization"); "" != oauthUser {
+	if oauthToken := c.GetHeader("LSAuthorization"); "" != oauthToken {
 		//尝试根据psd3的token进行解析
-		c.Set(UserContextKey, oauthUser)
+		oauthUser, err := tenant.ParseUserToken(oauthToken)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, map[string]interface{}{"code": -1, "msg": "Auth failed [header: LSAuthorization]"})
+			c.Abort()
+			return
+		}
+
 		c.Set(RoleContextKey, RoleAdministrator)
-		c.Next()
+		if authTenant(c, oauthUser) {
+			c.Next()
+		}
 		return
 	}
 
@@ -199,6 +210,17 @@ func CheckAuth(c *gin.Context) {
 		return
 	}
 
+	// 已通过 OpenID Connect 登录
+	oidcSession := util.GetWorkspaceSession(util.GetSession(c))
+	if role, ok := oidcSessionRole(oidcSession); ok {
+		c.Set(RoleContextKey, role)
+		c.Set(AccountContextKey, ACLAccountOIDC+oidcSession.OIDCSubject)
+		if authACL(c) {
+			c.Next()
+		}
+		return
+	}
+
 	//logging.LogInfof("check auth for [%s]", c.Request.RequestURI)
 	localhost := util.IsLocalHost(c.Request.RemoteAddr)
 
@@ -222,6 +244,11 @@ func CheckAuth(c *gin.Context) {
 			("" != host && !util.IsLocalHost(host)) ||
 			("" != origin && !util.IsLocalOrigin(origin) && !strings.HasPrefix(origin, "chrome-extension://")) ||
 			("" != forwardedHost && !util.IsLocalHost(forwardedHost)) {
+			if isOIDCEnabled() {
+				redirectOIDCLogin(c)
+				return
+			}
+
 			c.JSON(http.StatusUnauthorized, map[string]interface{}{"code": -1, "msg": "Auth failed: for security reasons, please set [Access authorization code] when using non-127.0.0.1 access\n\n为安全起见，使用非 127.0.0.1 访问时请设置 [访问授权码]"})
 			c.Abort()
 			return
@@ -300,28 +327,18 @@ func CheckAuth(c *gin.Context) {
 		}
 
 		if "" != token {
-			if Conf.Api.Token == token {
-				c.Set(RoleContextKey, RoleAdministrator)
+			if authAPIToken(c, token, "header: Authorization") {
 				c.Next()
-				return
 			}
-
-			c.JSON(http.StatusUnauthorized, map[string]interface{}{"code": -1, "msg": "Auth failed [header: Authorization]"})
-			c.Abort()
 			return
 		}
 	}
 
 	// 通过 API token (query-params: token)
 	if token := c.Query("token"); "" != token {
-		if Conf.Api.Token == token {
-			c.Set(RoleContextKey, RoleAdministrator)
+		if authAPIToken(c, token, "query: token") {
 			c.Next()
-			return
 		}
-
-		c.JSON(http.StatusUnauthorized, map[string]interface{}{"code": -1, "msg": "Auth failed [query: token]"})
-		c.Abort()
 		return
 	}
 
@@ -341,6 +358,12 @@ func CheckAuth(c *gin.Context) {
 	if workspaceSession.AccessAuthCode != Conf.AccessAuthCode {
 		userAgentHeader := c.GetHeader("User-Agent")
 		if strings.HasPrefix(userAgentHeader, "SiYuan/") || strings.HasPrefix(userAgentHeader, "Mozilla/") {
+			// 启用 OpenID Connect 后跳转到身份提供方登录，访问授权码仍然可以通过 /check-auth 使用
+			if isOIDCEnabled() {
+				redirectOIDCLogin(c)
+				return
+			}
+
 			if "GET" != c.Request.Method || c.IsWebsocket() {
 				c.JSON(http.StatusUnauthorized, map[string]interface{}{"code": -1, "msg": Conf.Language(156)})
 				c.Abort()
@@ -386,6 +409,18 @@ func CheckEditRole(c *gin.Context) {
 	}
 }
 
+// CheckHistoryRole 检查数据历史接口的角色：管理员可以访问，设置了访问控制规则的编辑者和读者也可以访问，返回的数据历史按照规则过滤。
+func CheckHistoryRole(c *gin.Context) {
+	if IsAdminRoleContext(c) || (IsACLRestricted(c) && IsValidRole(GetGinContextRole(c), []Role{
+		RoleEditor,
+		RoleReader,
+	})) {
+		c.Next()
+	} else {
+		c.AbortWithStatus(http.StatusForbidden)
+	}
+}
+
 func CheckReadRole(c *gin.Context) {
 	if IsValidRole(GetGinContextRole(c), []Role{
 		RoleAdministrator,
@@ -18,6 +18,7 @@ package model
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path"
 	"path/filepath"
@@ -25,6 +26,7 @@ import (
 
 	"github.com/88250/gulu"
 	"github.com/88250/lute/parse"
+	"github.com/gin-gonic/gin"
 	"github.com/siyuan-note/filelock"
 	"github.com/siyuan-note/logging"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
@@ -165,13 +167,17 @@ type Criterion struct {
 	Sort         int                    `json:"sort"`       // 0：按块类型（默认），1：按创建时间升序，2：按创建时间降序，3：按更新时间升序，4：按更新时间降序，5：按内容顺序（仅在按文档分组时）
 	Group        int                    `json:"group"`      // 0：不分组，1：按文档分组
 	HasReplace   bool                   `json:"hasReplace"` // 是否有替换
-	Method       int                    `json:"method"`     // 0：文本，1：查询语法，2：SQL，3：正则表达式
+	Method       int                    `json:"method"`     // 0：文本，1：查询语法，2：SQL，3：正则表达式，4：语义，5：模糊，6：结构化查询
 	HPath        string                 `json:"hPath"`
 	IDPath       []string               `json:"idPath"`
 	K            string                 `json:"k"`            // 搜索关键字
 	R            string                 `json:"r"`            // 替换关键字
 	Types        *CriterionTypes        `json:"types"`        // 类型过滤选项
 	ReplaceTypes *CriterionReplaceTypes `json:"replaceTypes"` // 替换类型过滤选项
+
+	Watch         bool   `json:"watch"`         // 是否定时运行并在有新增或者变化的结果时通知
+	WatchInterval int    `json:"watchInterval"` // 定时运行的间隔（分钟）
+	Webhook       string `json:"webhook"`       // 有新增或者变化的结果时回调的本地地址，为空时不回调
 }
 
 type CriterionTypes struct {
@@ -244,11 +250,27 @@ func RemoveCriterion(name string) (err error) {
 	return
 }
 
-func SetCriterion(criterion *Criterion) (err error) {
+func SetCriterion(c *gin.Context, criterion *Criterion) (err error) {
 	if "" == criterion.Name {
 		return errors.New(Conf.Language(142))
 	}
 
+	if 6 == criterion.Method {
+		// 保存前检查结构化查询，避免保存无法解析的搜索条件
+		if err = ParseSearchQuery(c, criterion.K); err != nil {
+			return fmt.Errorf(Conf.Language(254), err)
+		}
+	}
+
+	if criterion.Watch {
+		if 1 > criterion.WatchInterval {
+			criterion.WatchInterval = 10
+		}
+		if "" != criterion.Webhook && !isLocalWebhook(criterion.Webhook) {
+			return errors.New(Conf.Language(256))
+		}
+	}
+
 	criteriaLock.Lock()
 	defer criteriaLock.Unlock()
 
@@ -0,0 +1,80 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package model
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/tenant"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// InitTenants 使用当前工作空间初始化多用户模式的用户工作空间路径，用户 token 的校验密钥通过环境变量 SIYUAN_USER_TOKEN_SECRET 设置。
+func InitTenants() {
+	tenant.SetWorkspace(util.DataDir, util.HistoryDir, util.TempDir)
+	tenant.SetUserTokenSecret(os.Getenv("SIYUAN_USER_TOKEN_SECRET"))
+}
+
+// authTenant 将请求绑定到 userNo 对应的用户工作空间，并检查请求参数中涉及的笔记本是否都属于该用户，检查失败时中断请求并返回 false。
+//
+// 多用户共用块树、数据库索引、属性视图和闪卡，所以按笔记本所属的用户目录隔离：用户只能访问自己数据目录下的笔记本，
+// 并且只能使用 tenant.IsRouteAllowed 允许的接口，其中通过笔记本确定访问范围的接口必须在参数中指定自己的笔记本或者块，
+// 参数中也不能涉及属性视图和闪卡（见 tenant.HasSharedDataArg）。
+func authTenant(c *gin.Context, userNo string) bool {
+	t, err := tenant.Get(userNo)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, map[string]interface{}{"code": -1, "msg": "Auth failed [user]"})
+		c.Abort()
+		return false
+	}
+
+	if err = t.Init(); err != nil {
+		logging.LogErrorf("init user [%s] workspace failed: %s", userNo, err)
+		c.JSON(http.StatusInternalServerError, map[string]interface{}{"code": -1, "msg": err.Error()})
+		c.Abort()
+		return false
+	}
+
+	path := c.Request.URL.Path
+	if !t.IsRouteAllowed(path) {
+		c.JSON(http.StatusForbidden, map[string]interface{}{"code": -1, "msg": "Auth failed [user scope]"})
+		c.Abort()
+		return false
+	}
+
+	arg, ok := requestJSONArg(c)
+	if !ok || (!t.IsDefault() && tenant.HasSharedDataArg(arg)) {
+		c.JSON(http.StatusForbidden, map[string]interface{}{"code": -1, "msg": "Auth failed [user scope]"})
+		c.Abort()
+		return false
+	}
+
+	boxIDs, ok := requestBoxIDs(c)
+	if !ok || !t.CanAccessBoxes(boxIDs) || (tenant.IsBoxScopedRoute(path) && 1 > len(boxIDs)) {
+		c.JSON(http.StatusForbidden, map[string]interface{}{"code": -1, "msg": "Auth failed [user scope]"})
+		c.Abort()
+		return false
+	}
+
+	c.Set(UserContextKey, userNo)
+	c.Set(TenantContextKey, t)
+	c.Set(AccountContextKey, ACLAccountUser+userNo)
+	return authACL(c)
+}
@@ -99,6 +99,10 @@ func flushTx(tx *Transaction) {
 			return
 		case TxErrCodeDataIsSyncing:
 			util.PushMsg(Conf.Language(222), 5000)
+		case TxErrAttributeViewValidation:
+			// 违反数据库字段校验规则，事务已经回滚，推送违反的规则给前端提示
+			util.PushTxErr(txErr.msg, txErr.code, map[string]interface{}{"avID": txErr.id, "violations": txErr.data})
+			return
 		default:
 			txData, _ := gulu.JSON.MarshalJSON(tx)
 			logging.LogFatalf(logging.ExitCodeFatal, "transaction failed [%d]: %s\n  tx [%s]", txErr.code, txErr.msg, txData)
@@ -121,16 +125,18 @@ func PerformTransactions(transactions *[]*Transaction) {
 }
 
 const (
-	TxErrCodeBlockNotFound  = 0
-	TxErrCodeDataIsSyncing  = 1
-	TxErrCodeWriteTree      = 2
-	TxErrWriteAttributeView = 3
+	TxErrCodeBlockNotFound       = 0
+	TxErrCodeDataIsSyncing       = 1
+	TxErrCodeWriteTree           = 2
+	TxErrWriteAttributeView      = 3
+	TxErrAttributeViewValidation = 4
 )
 
 type TxErr struct {
 	code int
 	msg  string
 	id   string
+	data interface{}
 }
 
 func performTx(tx *Transaction) (ret *TxErr) {
@@ -252,6 +258,12 @@ func performTx(tx *Transaction) (ret *TxErr) {
 			ret = tx.doReplaceAttrViewBlock(op)
 		case "updateAttrViewColTemplate":
 			ret = tx.doUpdateAttrViewColTemplate(op)
+		case "updateAttrViewColFormula":
+			ret = tx.doUpdateAttrViewColFormula(op)
+		case "updateAttrViewColUniqueIDPrefix":
+			ret = tx.doUpdateAttrViewColUniqueIDPrefix(op)
+		case "setAttrViewColValidation":
+			ret = tx.doSetAttrViewColValidation(op)
 		case "addAttrViewView":
 			ret = tx.doAddAttrViewView(op)
 		case "removeAttrViewView":
@@ -278,6 +290,16 @@ func performTx(tx *Transaction) (ret *TxErr) {
 			ret = tx.doUnbindAttrViewBlock(op)
 		case "duplicateAttrViewKey":
 			ret = tx.doDuplicateAttrViewKey(op)
+		case "setAttrViewBoardGroupKey":
+			ret = tx.doSetAttrViewBoardGroupKey(op)
+		case "moveAttrViewCard":
+			ret = tx.doMoveAttrViewCard(op)
+		case "setAttrViewDateKeys":
+			ret = tx.doSetAttrViewDateKeys(op)
+		case "setAttrViewGalleryCover":
+			ret = tx.doSetAttrViewGalleryCover(op)
+		case "setAttrViewGroupBy":
+			ret = tx.doSetAttrViewGroupBy(op)
 		}
 
 		if nil != ret {
@@ -1464,6 +1486,8 @@ type Operation struct {
 	IsTwoWay            bool                     `json:"isTwoWay"`          // 属性视图关联列是否是双向关系
 	BackRelationKeyID   string                   `json:"backRelationKeyID"` // 属性视图关联列回链关联列的 ID
 	RemoveDest          bool                     `json:"removeDest"`        // 属性视图删除关联目标
+	SrcLaneID           string                   `json:"srcLaneID"`         // 属性视图看板卡片移动前所在泳道 ID
+	LaneID              string                   `json:"laneID"`            // 属性视图看板卡片移动后所在泳道 ID
 }
 
 type Transaction struct {
@@ -1471,6 +1495,9 @@ type Transaction struct {
 	DoOperations   []*Operation `json:"doOperations"`
 	UndoOperations []*Operation `json:"undoOperations"`
 
+	App     string `json:"-"` // 发起事务的应用 ID
+	Session string `json:"-"` // 发起事务的会话 ID
+
 	trees map[string]*parse.Tree
 	nodes map[string]*ast.Node
 
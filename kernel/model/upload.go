@@ -118,23 +118,31 @@ func Upload(c *gin.Context) {
 		ret.Msg = err.Error()
 		return
 	}
-	assetsDirPath := filepath.Join(util.DataDir, "assets")
+	t := GetTenant(c)
+	assetsDirPath := t.AssetsDir
 	if nil != form.Value["id"] {
 		id := form.Value["id"][0]
 		bt := treenode.GetBlockTree(id)
-		if nil == bt {
+		if nil == bt || !t.CanAccessBoxes([]string{bt.BoxID}) {
 			ret.Code = -1
 			ret.Msg = Conf.Language(71)
 			return
 		}
-		docDirLocalPath := filepath.Join(util.DataDir, bt.BoxID, path.Dir(bt.Path))
-		assetsDirPath = getAssetsDir(filepath.Join(util.DataDir, bt.BoxID), docDirLocalPath)
+		docDirLocalPath := filepath.Join(t.DataDir, bt.BoxID, path.Dir(bt.Path))
+		assetsDirPath = getAssetsDir(filepath.Join(t.DataDir, bt.BoxID), docDirLocalPath)
+		if filepath.Join(util.DataDir, "assets") == assetsDirPath {
+			assetsDirPath = t.AssetsDir
+		}
 	}
 
 	relAssetsDirPath := "assets"
 	if nil != form.Value["assetsDirPath"] {
 		relAssetsDirPath = form.Value["assetsDirPath"][0]
-		assetsDirPath = filepath.Join(util.DataDir, relAssetsDirPath)
+		if assetsDirPath, err = t.Resolve(relAssetsDirPath); err != nil {
+			ret.Code = -1
+			ret.Msg = err.Error()
+			return
+		}
 	}
 	if !gulu.File.IsExist(assetsDirPath) {
 		if err = os.MkdirAll(assetsDirPath, 0755); err != nil {
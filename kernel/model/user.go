@@ -18,11 +18,12 @@ package model
 
 import (
 	"github.com/gin-gonic/gin"
-	"github.com/siyuan-note/siyuan/kernel/util"
+	"github.com/siyuan-note/siyuan/kernel/tenant"
 )
 
 const (
-	UserContextKey = "userNo"
+	UserContextKey   = "userNo"
+	TenantContextKey = "tenant"
 )
 
 func IsValidUser(user string) bool {
@@ -44,14 +45,16 @@ func GetGinContextUser(c *gin.Context) string {
 	}
 }
 
-func GetDataDir(c *gin.Context) string {
+// GetTenant 返回当前请求用户的工作空间，没有用户时返回默认用户。
+func GetTenant(c *gin.Context) *tenant.Tenant {
 	if c != nil {
-		if user, exists := c.Get(UserContextKey); exists {
-			return util.DataDir + user.(string)
-		} else {
-			return util.DataDir
+		if t, exists := c.Get(TenantContextKey); exists {
+			return t.(*tenant.Tenant)
 		}
-	} else {
-		return util.DataDir
 	}
+	return tenant.Default()
+}
+
+func GetDataDir(c *gin.Context) string {
+	return GetTenant(c).DataDir
 }
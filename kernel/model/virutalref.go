@@ -29,6 +29,7 @@ import (
 	"github.com/88250/lute/parse"
 	"github.com/ClarkThan/ahocorasick"
 	"github.com/dgraph-io/ristretto"
+	"github.com/gin-gonic/gin"
 	"github.com/siyuan-note/siyuan/kernel/search"
 	"github.com/siyuan-note/siyuan/kernel/sql"
 	"github.com/siyuan-note/siyuan/kernel/task"
@@ -118,32 +119,34 @@ func ResetVirtualBlockRefCache() {
 	virtualBlockRefCache.Set("virtual_ref", keywords, 1)
 }
 
-func AddVirtualBlockRefInclude(keyword []string) {
+func AddVirtualBlockRefInclude(c *gin.Context, keyword []string) {
 	if 1 > len(keyword) {
 		return
 	}
 
-	include := strings.ReplaceAll(Conf.Editor.VirtualBlockRefInclude, "\\,", "__comma@sep__")
+	userConf := GetUserConf(c)
+	include := strings.ReplaceAll(userConf.Editor.VirtualBlockRefInclude, "\\,", "__comma@sep__")
 	includes := strings.Split(include, ",")
 	includes = append(includes, keyword...)
 	includes = gulu.Str.RemoveDuplicatedElem(includes)
-	Conf.Editor.VirtualBlockRefInclude = strings.Join(includes, ",")
-	Conf.Save()
+	userConf.Editor.VirtualBlockRefInclude = strings.Join(includes, ",")
+	userConf.Save()
 
 	ResetVirtualBlockRefCache()
 }
 
-func AddVirtualBlockRefExclude(keyword []string) {
+func AddVirtualBlockRefExclude(c *gin.Context, keyword []string) {
 	if 1 > len(keyword) {
 		return
 	}
 
-	exclude := strings.ReplaceAll(Conf.Editor.VirtualBlockRefExclude, "\\,", "__comma@sep__")
+	userConf := GetUserConf(c)
+	exclude := strings.ReplaceAll(userConf.Editor.VirtualBlockRefExclude, "\\,", "__comma@sep__")
 	excludes := strings.Split(exclude, ",")
 	excludes = append(excludes, keyword...)
 	excludes = gulu.Str.RemoveDuplicatedElem(excludes)
-	Conf.Editor.VirtualBlockRefExclude = strings.Join(excludes, ",")
-	Conf.Save()
+	userConf.Editor.VirtualBlockRefExclude = strings.Join(excludes, ",")
+	userConf.Save()
 
 	ResetVirtualBlockRefCache()
 }
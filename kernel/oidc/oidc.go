@@ -0,0 +1,328 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package oidc 实现了 OpenID Connect 授权码流程（使用 PKCE）的客户端。
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// jwksRefreshInterval 为重新获取 JWKS 的最小间隔，避免携带未知 kid 的令牌频繁触发请求身份提供方。
+const jwksRefreshInterval = time.Minute
+
+// Config 描述了在身份提供方注册的客户端。
+type Config struct {
+	ClientID     string   // 客户端 ID
+	ClientSecret string   // 客户端密钥，公共客户端时为空
+	RedirectURL  string   // 回调地址
+	Scopes       []string // 除 openid 以外请求的 scope
+}
+
+// Provider 描述了通过发现文档获取的身份提供方端点。
+type Provider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+
+	client        *http.Client
+	keys          map[string]interface{} // 签名公钥，kid -> *rsa.PublicKey 或者 *ecdsa.PublicKey
+	keysRefreshed time.Time              // 最近一次获取 JWKS 的时间
+	keysLock      sync.Mutex
+}
+
+// Discover 通过 {issuer}/.well-known/openid-configuration 获取身份提供方端点，发现文档中的 issuer 必须和 issuer 一致。
+func Discover(ctx context.Context, client *http.Client, issuer string) (ret *Provider, err error) {
+	issuer = strings.TrimSuffix(issuer, "/")
+	ret = &Provider{client: client}
+	if err = getJSON(ctx, client, issuer+"/.well-known/openid-configuration", ret); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSuffix(ret.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("issuer mismatch: expected [%s], got [%s]", issuer, ret.Issuer)
+	}
+	if "" == ret.AuthorizationEndpoint || "" == ret.TokenEndpoint || "" == ret.JWKSURI {
+		return nil, errors.New("incomplete discovery document")
+	}
+	return
+}
+
+// RandString 返回用于 state、nonce 和 PKCE code verifier 的随机字符串。
+func RandString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// CodeChallenge 返回 PKCE code verifier 对应的 S256 code challenge。
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL 返回跳转到身份提供方进行登录的地址。
+func (p *Provider) AuthCodeURL(config *Config, state, nonce, verifier string) string {
+	scopes := []string{"openid"}
+	for _, scope := range config.Scopes {
+		if "" != scope && "openid" != scope {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", config.ClientID)
+	params.Set("redirect_uri", config.RedirectURL)
+	params.Set("scope", strings.Join(scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", CodeChallenge(verifier))
+	params.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(p.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.AuthorizationEndpoint + sep + params.Encode()
+}
+
+// Exchange 使用授权码和 PKCE code verifier 换取 ID token。
+func (p *Provider) Exchange(ctx context.Context, config *Config, code, verifier string) (idToken string, err error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", config.RedirectURL)
+	form.Set("client_id", config.ClientID)
+	form.Set("code_verifier", verifier)
+	if "" != config.ClientSecret {
+		form.Set("client_secret", config.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	result := &struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, result); err != nil {
+		return "", fmt.Errorf("parse token response failed [%d]: %s", resp.StatusCode, err)
+	}
+	if "" != result.Error {
+		return "", fmt.Errorf("token request failed: %s %s", result.Error, result.ErrorDescription)
+	}
+	if http.StatusOK != resp.StatusCode || "" == result.IDToken {
+		return "", fmt.Errorf("token request failed [%d]", resp.StatusCode)
+	}
+	return result.IDToken, nil
+}
+
+// Verify 校验 ID token 的签名、签发方、受众、有效期和 nonce，返回其中的声明。
+func (p *Provider) Verify(ctx context.Context, config *Config, idToken, nonce string) (ret jwt.MapClaims, err error) {
+	ret = jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(idToken, ret, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(config.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if n, _ := ret["nonce"].(string); n != nonce {
+		return nil, errors.New("nonce mismatch")
+	}
+	return
+}
+
+// ClaimStrings 返回声明的字符串值，声明可以是字符串或者字符串数组，name 支持使用 . 访问嵌套声明，比如 realm_access.roles。
+func ClaimStrings(claims jwt.MapClaims, name string) (ret []string) {
+	var val interface{} = map[string]interface{}(claims)
+	for _, key := range strings.Split(name, ".") {
+		m, ok := val.(map[string]interface{})
+		if !ok {
+			return
+		}
+		val = m[key]
+	}
+
+	switch v := val.(type) {
+	case string:
+		ret = append(ret, v)
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				ret = append(ret, s)
+			}
+		}
+	}
+	return
+}
+
+// key 返回 kid 对应的签名公钥，找不到时重新获取 JWKS，以支持身份提供方轮换密钥。
+//
+// 每个 jwksRefreshInterval 内最多重新获取一次，间隔内找不到的 kid 直接返回错误。
+func (p *Provider) key(ctx context.Context, kid string) (ret interface{}, err error) {
+	p.keysLock.Lock()
+	defer p.keysLock.Unlock()
+
+	if ret = p.findKey(kid); nil != ret {
+		return
+	}
+
+	if time.Since(p.keysRefreshed) < jwksRefreshInterval {
+		err = fmt.Errorf("signing key [%s] not found", kid)
+		return
+	}
+	p.keysRefreshed = time.Now()
+
+	jwks := &struct {
+		Keys []*jsonWebKey `json:"keys"`
+	}{}
+	if err = getJSON(ctx, p.client, p.JWKSURI, jwks); err != nil {
+		return
+	}
+
+	p.keys = map[string]interface{}{}
+	for _, k := range jwks.Keys {
+		if "" != k.Use && "sig" != k.Use {
+			continue
+		}
+		if publicKey, parseErr := k.publicKey(); nil == parseErr {
+			p.keys[k.Kid] = publicKey
+		}
+	}
+
+	if ret = p.findKey(kid); nil == ret {
+		err = fmt.Errorf("signing key [%s] not found", kid)
+	}
+	return
+}
+
+func (p *Provider) findKey(kid string) interface{} {
+	if "" == kid && 1 == len(p.keys) {
+		for _, k := range p.keys {
+			return k
+		}
+	}
+	return p.keys[kid]
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k *jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve [%s]", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type [%s]", k.Kty)
+}
+
+func getJSON(ctx context.Context, client *http.Client, u string, v interface{}) (err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if http.StatusOK != resp.StatusCode {
+		return fmt.Errorf("request [%s] failed [%d]", u, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1024*1024)).Decode(v)
+}
@@ -0,0 +1,231 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockServer 是一个本地的 OpenID Connect 身份提供方，只签发一个授权码。
+type mockServer struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	issuer    string // 发现文档中的 issuer，为空时使用服务地址
+	code      string
+	challenge string
+	nonce     string
+	audience  string
+	jwksCount int // JWKS 被获取的次数
+}
+
+func newMockServer(t *testing.T) (ret *mockServer) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key failed: %s", err)
+	}
+
+	ret = &mockServer{key: key, code: "test-code", audience: "siyuan"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		issuer := ret.issuer
+		if "" == issuer {
+			issuer = ret.URL
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": ret.URL + "/authorize",
+			"token_endpoint":         ret.URL + "/token",
+			"jwks_uri":               ret.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		ret.jwksCount++
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if ret.code != r.PostForm.Get("code") || ret.challenge != CodeChallenge(r.PostForm.Get("code_verifier")) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":    ret.URL,
+			"sub":    "alice",
+			"aud":    ret.audience,
+			"exp":    time.Now().Add(time.Minute).Unix(),
+			"nonce":  ret.nonce,
+			"groups": []string{"staff", "siyuan-editors"},
+			"realm_access": map[string]interface{}{
+				"roles": []string{"admin"},
+			},
+		})
+		token.Header["kid"] = "k1"
+		idToken, _ := token.SignedString(key)
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idToken, "token_type": "Bearer"})
+	})
+	ret.Server = httptest.NewServer(mux)
+	t.Cleanup(ret.Close)
+	return
+}
+
+// authorize 模拟用户在身份提供方登录，记录登录地址中的 code challenge 和 nonce。
+func (s *mockServer) authorize(t *testing.T, authURL string) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse auth url failed: %s", err)
+	}
+	query := u.Query()
+	if "S256" != query.Get("code_challenge_method") || "openid profile" != query.Get("scope") || "code" != query.Get("response_type") {
+		t.Fatalf("invalid auth url [%s]", authURL)
+	}
+	s.challenge = query.Get("code_challenge")
+	s.nonce = query.Get("nonce")
+}
+
+func TestAuthCodeFlow(t *testing.T) {
+	server := newMockServer(t)
+	ctx := context.Background()
+	config := &Config{ClientID: "siyuan", RedirectURL: "http://127.0.0.1:6806/auth/oidc/callback", Scopes: []string{"openid", "profile"}}
+
+	provider, err := Discover(ctx, server.Client(), server.URL+"/")
+	if err != nil {
+		t.Fatalf("discover failed: %s", err)
+	}
+
+	verifier, nonce := RandString(), RandString()
+	server.authorize(t, provider.AuthCodeURL(config, RandString(), nonce, verifier))
+
+	if _, err = provider.Exchange(ctx, config, server.code, RandString()); err == nil {
+		t.Fatalf("exchange with a wrong code verifier should fail")
+	}
+
+	idToken, err := provider.Exchange(ctx, config, server.code, verifier)
+	if err != nil {
+		t.Fatalf("exchange failed: %s", err)
+	}
+
+	claims, err := provider.Verify(ctx, config, idToken, nonce)
+	if err != nil {
+		t.Fatalf("verify failed: %s", err)
+	}
+	if "alice" != claims["sub"] {
+		t.Fatalf("invalid subject [%v]", claims["sub"])
+	}
+	if groups := ClaimStrings(claims, "groups"); 2 != len(groups) || "siyuan-editors" != groups[1] {
+		t.Fatalf("invalid groups %v", groups)
+	}
+	if roles := ClaimStrings(claims, "realm_access.roles"); 1 != len(roles) || "admin" != roles[0] {
+		t.Fatalf("invalid roles %v", roles)
+	}
+
+	if _, err = provider.Verify(ctx, config, idToken, RandString()); err == nil {
+		t.Fatalf("verify with a wrong nonce should fail")
+	}
+	if _, err = provider.Verify(ctx, &Config{ClientID: "other"}, idToken, nonce); err == nil {
+		t.Fatalf("verify with a wrong audience should fail")
+	}
+}
+
+func TestVerifyRejectsForgedToken(t *testing.T) {
+	server := newMockServer(t)
+	ctx := context.Background()
+	config := &Config{ClientID: "siyuan"}
+
+	provider, err := Discover(ctx, server.Client(), server.URL)
+	if err != nil {
+		t.Fatalf("discover failed: %s", err)
+	}
+
+	forgedKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"iss": server.URL, "sub": "mallory", "aud": "siyuan", "exp": time.Now().Add(time.Minute).Unix()})
+	token.Header["kid"] = "k1"
+	forged, _ := token.SignedString(forgedKey)
+	if _, err = provider.Verify(ctx, config, forged, ""); err == nil {
+		t.Fatalf("verify a token signed by another key should fail")
+	}
+
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{"iss": server.URL, "sub": "mallory", "aud": "siyuan", "exp": time.Now().Add(time.Minute).Unix()}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if _, err = provider.Verify(ctx, config, unsigned, ""); err == nil {
+		t.Fatalf("verify an unsigned token should fail")
+	}
+}
+
+func TestVerifyUnknownKidRefreshInterval(t *testing.T) {
+	server := newMockServer(t)
+	ctx := context.Background()
+	config := &Config{ClientID: "siyuan"}
+
+	provider, err := Discover(ctx, server.Client(), server.URL)
+	if err != nil {
+		t.Fatalf("discover failed: %s", err)
+	}
+
+	claims := jwt.MapClaims{"iss": server.URL, "sub": "alice", "aud": "siyuan", "exp": time.Now().Add(time.Minute).Unix()}
+	sign := func(kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = kid
+		ret, _ := token.SignedString(server.key)
+		return ret
+	}
+
+	if _, err = provider.Verify(ctx, config, sign("k1"), ""); err != nil {
+		t.Fatalf("verify failed: %s", err)
+	}
+
+	// 间隔内未知的 kid 不会再次获取 JWKS
+	for _, kid := range []string{"k2", "k3", "k2"} {
+		if _, err = provider.Verify(ctx, config, sign(kid), ""); err == nil {
+			t.Fatalf("verify a token with unknown kid [%s] should fail", kid)
+		}
+	}
+	if 1 != server.jwksCount {
+		t.Fatalf("expected JWKS fetched once, got [%d]", server.jwksCount)
+	}
+
+	provider.keysRefreshed = time.Now().Add(-jwksRefreshInterval)
+	if _, err = provider.Verify(ctx, config, sign("k2"), ""); err == nil || 2 != server.jwksCount {
+		t.Fatalf("expected JWKS fetched again after the interval, got [%d]", server.jwksCount)
+	}
+}
+
+func TestDiscoverRejectsIssuerMismatch(t *testing.T) {
+	server := newMockServer(t)
+	server.issuer = "https://idp.example.com"
+	if _, err := Discover(context.Background(), server.Client(), server.URL); err == nil {
+		t.Fatalf("discover should fail")
+	}
+}
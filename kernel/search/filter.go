@@ -0,0 +1,46 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package search
+
+import (
+	"bytes"
+	"strings"
+)
+
+// BoxesFilter 返回将搜索范围限制在笔记本 boxes 内的 SQL 条件，boxes 为空时不限制。
+func BoxesFilter(boxes []string) string {
+	if 1 > len(boxes) {
+		return ""
+	}
+
+	builder := bytes.Buffer{}
+	builder.WriteString(" AND (")
+	for i, box := range boxes {
+		builder.WriteString("box = '")
+		builder.WriteString(escapeSQLStr(box))
+		builder.WriteString("'")
+		if i < len(boxes)-1 {
+			builder.WriteString(" OR ")
+		}
+	}
+	builder.WriteString(")")
+	return builder.String()
+}
+
+func escapeSQLStr(str string) string {
+	return strings.ReplaceAll(str, "'", "''")
+}
@@ -0,0 +1,263 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package search
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/88250/lute/lex"
+)
+
+// QueryTerm 描述了结构化查询中的一项。
+//
+// 结构化查询由空格分隔的项组成，比如 tag:work type:h updated:>2024-01-01 box:"Projects" -draft "exact phrase" custom-status:open：
+//   - 不带字段的项为全文关键字，使用双引号包裹时作为短语匹配
+//   - 带字段的项为筛选条件，字段值包含空格时使用双引号包裹
+//   - 项前面加 - 表示排除
+type QueryTerm struct {
+	Field   string // 字段名，为空时表示全文关键字
+	Value   string // 关键字或者字段值
+	Phrase  bool   // 是否使用双引号包裹
+	Negated bool   // 是否排除
+	From    string // created、updated 的起始时间（包含），格式为 yyyyMMddHHmmss
+	To      string // created、updated 的结束时间（不包含），格式为 yyyyMMddHHmmss
+}
+
+// QueryParseError 描述了结构化查询的解析错误。
+type QueryParseError struct {
+	Pos int    // 出错位置，从 1 开始按字符计数
+	Msg string // 错误信息
+}
+
+func (err *QueryParseError) Error() string {
+	return fmt.Sprintf("%s (at position %d)", err.Msg, err.Pos)
+}
+
+// QueryFields 是结构化查询支持的字段，另外还支持以 custom- 开头的自定义属性。
+var QueryFields = []string{"tag", "type", "box", "path", "created", "updated", "name", "alias", "memo", "id"}
+
+const customAttrPrefix = "custom-"
+
+// ParseQuery 解析结构化查询。
+func ParseQuery(query string) (ret []*QueryTerm, err error) {
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		start := i
+		term := &QueryTerm{}
+		if '-' == runes[i] && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+			term.Negated = true
+			i++
+		}
+
+		if '"' == runes[i] {
+			term.Value, i, err = readQuoted(runes, i)
+			if err != nil {
+				return
+			}
+			if "" == strings.TrimSpace(term.Value) {
+				err = &QueryParseError{Pos: start + 1, Msg: "empty phrase"}
+				return
+			}
+			term.Phrase = true
+			ret = append(ret, term)
+			continue
+		}
+
+		tokenStart := i
+		for i < len(runes) && !unicode.IsSpace(runes[i]) && ':' != runes[i] && '"' != runes[i] {
+			i++
+		}
+		if i < len(runes) && ':' == runes[i] && isFieldName(runes[tokenStart:i]) {
+			term.Field = strings.ToLower(string(runes[tokenStart:i]))
+			if !isQueryField(term.Field) {
+				err = &QueryParseError{Pos: tokenStart + 1, Msg: fmt.Sprintf("unknown field [%s], wrap it in quotes to search it as text", term.Field)}
+				return
+			}
+
+			i++ // 跳过 :
+			valueStart := i
+			if i < len(runes) && '"' == runes[i] {
+				term.Value, i, err = readQuoted(runes, i)
+				if err != nil {
+					return
+				}
+				term.Phrase = true
+			} else {
+				for i < len(runes) && !unicode.IsSpace(runes[i]) {
+					i++
+				}
+				term.Value = string(runes[valueStart:i])
+			}
+			term.Value = strings.TrimSpace(term.Value)
+			if "" == term.Value {
+				err = &QueryParseError{Pos: valueStart + 1, Msg: fmt.Sprintf("missing value for field [%s]", term.Field)}
+				return
+			}
+
+			if "created" == term.Field || "updated" == term.Field {
+				if term.From, term.To, err = parseQueryTimeRange(term.Value); err != nil {
+					err = &QueryParseError{Pos: valueStart + 1, Msg: fmt.Sprintf("invalid time [%s] for field [%s]: %s", term.Value, term.Field, err)}
+					return
+				}
+			}
+			ret = append(ret, term)
+			continue
+		}
+
+		for i < len(runes) && !unicode.IsSpace(runes[i]) {
+			if '"' == runes[i] {
+				err = &QueryParseError{Pos: i + 1, Msg: "unexpected quote inside a keyword"}
+				return
+			}
+			i++
+		}
+		term.Value = string(runes[tokenStart:i])
+		ret = append(ret, term)
+	}
+
+	if 1 > len(ret) {
+		err = &QueryParseError{Pos: 1, Msg: "query is empty"}
+	}
+	return
+}
+
+// isFieldName 判断冒号前的内容是否为字段名的形式，不是的话（比如 12:30）作为关键字处理。
+func isFieldName(name []rune) bool {
+	if 1 > len(name) || !lex.IsASCIILetter(byte(name[0])) || unicode.MaxASCII < name[0] {
+		return false
+	}
+	for _, r := range name {
+		if unicode.MaxASCII < r || (!lex.IsASCIILetterNum(byte(r)) && '-' != r && '_' != r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isQueryField(field string) bool {
+	if strings.HasPrefix(field, customAttrPrefix) && len(customAttrPrefix) < len(field) {
+		return true
+	}
+	for _, f := range QueryFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// readQuoted 读取从 start 处的双引号开始到对应的双引号结束的内容，\" 表示双引号本身。
+func readQuoted(runes []rune, start int) (ret string, end int, err error) {
+	buf := strings.Builder{}
+	for i := start + 1; i < len(runes); i++ {
+		if '\\' == runes[i] && i+1 < len(runes) && '"' == runes[i+1] {
+			buf.WriteRune('"')
+			i++
+			continue
+		}
+		if '"' == runes[i] {
+			return buf.String(), i + 1, nil
+		}
+		buf.WriteRune(runes[i])
+	}
+	err = &QueryParseError{Pos: start + 1, Msg: "unterminated quote"}
+	return
+}
+
+// parseQueryTimeRange 解析时间条件，支持 2024-01-01、>2024-01、<=2024、2024-01-01..2024-03-31 等形式，返回 [from, to) 区间。
+func parseQueryTimeRange(value string) (from, to string, err error) {
+	if before, after, found := strings.Cut(value, ".."); found {
+		var start, end time.Time
+		if start, _, err = parseQueryTime(before); err != nil {
+			return
+		}
+		if _, end, err = parseQueryTime(after); err != nil {
+			return
+		}
+		if !start.Before(end) {
+			err = fmt.Errorf("range start is after range end")
+			return
+		}
+		return formatQueryTime(start), formatQueryTime(end), nil
+	}
+
+	op := ""
+	for _, prefix := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(value, prefix) {
+			op = prefix
+			value = value[len(prefix):]
+			break
+		}
+	}
+
+	start, end, err := parseQueryTime(value)
+	if err != nil {
+		return
+	}
+	switch op {
+	case ">":
+		from = formatQueryTime(end)
+	case ">=":
+		from = formatQueryTime(start)
+	case "<":
+		to = formatQueryTime(start)
+	case "<=":
+		to = formatQueryTime(end)
+	default:
+		from, to = formatQueryTime(start), formatQueryTime(end)
+	}
+	return
+}
+
+// parseQueryTime 解析年、月或者日，返回该时间段的起止时间。
+func parseQueryTime(value string) (start, end time.Time, err error) {
+	layouts := []struct {
+		layout string
+		years  int
+		months int
+		days   int
+	}{
+		{"2006-01-02", 0, 0, 1},
+		{"20060102", 0, 0, 1},
+		{"2006-01", 0, 1, 0},
+		{"200601", 0, 1, 0},
+		{"2006", 1, 0, 0},
+	}
+	for _, l := range layouts {
+		if len(l.layout) != len(value) {
+			continue
+		}
+		if start, err = time.ParseInLocation(l.layout, value, time.Local); err == nil {
+			end = start.AddDate(l.years, l.months, l.days)
+			return
+		}
+	}
+	err = fmt.Errorf("expected yyyy, yyyy-MM or yyyy-MM-dd")
+	return
+}
+
+func formatQueryTime(t time.Time) string {
+	return t.Format("20060102150405")
+}
@@ -0,0 +1,317 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/88250/gulu"
+	"golang.org/x/text/unicode/norm"
+)
+
+// 分词流水线中的步骤。
+//
+// 全文索引默认使用单字分词（tokenize="siyuan"），分词流水线生成的词元写入索引的 tokens 列，用于补充单字分词无法覆盖的匹配。
+const (
+	TokenizerCJKBigram      = "cjkBigram"      // 中日韩文字按相邻两个字切分
+	TokenizerIdentifier     = "identifier"     // 拆分 camelCase 和字母数字混合的标识符，snake_case 总是按下划线拆分
+	TokenizerFoldDiacritics = "foldDiacritics" // 去除变音符号，比如 café 和 cafe 视为相同
+	TokenizerStemEnglish    = "stemEnglish"    // 英语轻量词干提取
+	TokenizerStemGerman     = "stemGerman"     // 德语轻量词干提取
+	TokenizerStemFrench     = "stemFrench"     // 法语轻量词干提取
+)
+
+// Tokenizers 为所有支持的分词步骤，分词时按该顺序执行。
+var Tokenizers = []string{TokenizerCJKBigram, TokenizerIdentifier, TokenizerFoldDiacritics, TokenizerStemEnglish, TokenizerStemGerman, TokenizerStemFrench}
+
+// NormalizeTokenizers 去掉不支持和重复的步骤，并按执行顺序排列。
+func NormalizeTokenizers(steps []string) (ret []string) {
+	ret = []string{}
+	for _, step := range Tokenizers {
+		if gulu.Str.Contains(step, steps) {
+			ret = append(ret, step)
+		}
+	}
+	return
+}
+
+// Tokenize 使用分词流水线切分 text，返回每个词元的所有形式（原形和各语言的词干）。
+func Tokenize(text string, steps []string) (ret [][]string) {
+	if 1 > len(steps) {
+		return
+	}
+
+	bigram := gulu.Str.Contains(TokenizerCJKBigram, steps)
+	var run []rune
+	runIsCJK := false
+	flush := func() {
+		if 1 > len(run) {
+			return
+		}
+
+		if runIsCJK {
+			if bigram {
+				for _, gram := range cjkBigrams(run) {
+					ret = append(ret, []string{gram})
+				}
+			}
+		} else {
+			parts := []string{string(run)}
+			if gulu.Str.Contains(TokenizerIdentifier, steps) {
+				parts = splitIdentifier(run)
+			}
+			for _, part := range parts {
+				ret = append(ret, wordForms(part, steps))
+			}
+		}
+		run = nil
+	}
+
+	for _, r := range text {
+		isCJK := isCJKRune(r)
+		if !isCJK && !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.Is(unicode.Mn, r) {
+			flush()
+			continue
+		}
+
+		if isCJK != runIsCJK {
+			flush()
+			runIsCJK = isCJK
+		}
+		run = append(run, r)
+	}
+	flush()
+	return
+}
+
+// TokenizeText 返回写入索引 tokens 列的文本。
+//
+// 索引使用单字分词，词元之间和首尾都使用空格分隔，查询时将词元连同两侧的空格作为短语匹配，从而按整个词元匹配。
+func TokenizeText(text string, steps []string) string {
+	tokens := Tokenize(text, steps)
+	if 1 > len(tokens) {
+		return ""
+	}
+
+	buf := strings.Builder{}
+	buf.WriteString(" ")
+	for _, forms := range tokens {
+		for _, form := range forms {
+			buf.WriteString(form)
+			buf.WriteString(" ")
+		}
+	}
+	return buf.String()
+}
+
+// TokenizeQuery 将查询关键字转换为 tokens 列上的 FTS5 匹配表达式，同一个词元的多个形式之间为 OR，词元之间为 AND。
+func TokenizeQuery(query string, steps []string) string {
+	var groups []string
+	for _, forms := range Tokenize(query, steps) {
+		var phrases []string
+		for _, form := range forms {
+			phrases = append(phrases, "\" "+form+" \"")
+		}
+		groups = append(groups, "("+strings.Join(phrases, " OR ")+")")
+	}
+	return strings.Join(groups, " AND ")
+}
+
+func wordForms(word string, steps []string) (ret []string) {
+	word = strings.ToLower(word)
+	if gulu.Str.Contains(TokenizerFoldDiacritics, steps) {
+		word = foldDiacritics(word)
+	}
+	ret = append(ret, word)
+
+	for _, step := range steps {
+		var stem string
+		switch step {
+		case TokenizerStemEnglish:
+			stem = stemEnglish(word)
+		case TokenizerStemGerman:
+			stem = stemGerman(word)
+		case TokenizerStemFrench:
+			stem = stemFrench(word)
+		default:
+			continue
+		}
+		if "" != stem && !gulu.Str.Contains(stem, ret) {
+			ret = append(ret, stem)
+		}
+	}
+	return
+}
+
+func isCJKRune(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+func cjkBigrams(runes []rune) (ret []string) {
+	if 2 > len(runes) {
+		return []string{string(runes)}
+	}
+
+	for i := 0; i+2 <= len(runes); i++ {
+		ret = append(ret, string(runes[i:i+2]))
+	}
+	return
+}
+
+// splitIdentifier 在小写和大写之间（parseJson）、连续大写和首字母大写的单词之间（JSONParser）以及字母和数字之间拆分标识符。
+func splitIdentifier(runes []rune) (ret []string) {
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		split := unicode.IsLower(prev) && unicode.IsUpper(cur) ||
+			unicode.IsUpper(prev) && unicode.IsUpper(cur) && i+1 < len(runes) && unicode.IsLower(runes[i+1]) ||
+			unicode.IsDigit(prev) != unicode.IsDigit(cur)
+		if split {
+			ret = append(ret, string(runes[start:i]))
+			start = i
+		}
+	}
+	ret = append(ret, string(runes[start:]))
+	return
+}
+
+var diacriticsReplacer = strings.NewReplacer("ß", "ss", "æ", "ae", "œ", "oe", "ø", "o", "đ", "d", "ł", "l")
+
+func foldDiacritics(word string) string {
+	word = diacriticsReplacer.Replace(word)
+	buf := strings.Builder{}
+	for _, r := range norm.NFD.String(word) {
+		if !unicode.Is(unicode.Mn, r) {
+			buf.WriteRune(r)
+		}
+	}
+	return buf.String()
+}
+
+// stemEnglish 去掉英语复数、进行时和过去式后缀以及结尾的 e。
+func stemEnglish(word string) string {
+	s := []rune(word)
+	n := len(s)
+	if 2 < n && 's' == s[n-1] {
+		switch s[n-2] {
+		case 'u', 's':
+		case 'e':
+			if 3 < n && 'i' == s[n-3] && 'a' != s[n-4] && 'e' != s[n-4] {
+				s[n-3] = 'y'
+				n -= 2
+			} else if !strings.ContainsRune("iaoe", s[n-3]) {
+				n--
+			}
+		default:
+			n--
+		}
+	}
+
+	for _, suffix := range []string{"ing", "ed"} {
+		l := len(suffix)
+		if n-l < 3 || string(s[n-l:n]) != suffix || !strings.ContainsAny(string(s[:n-l]), "aeiouy") {
+			continue
+		}
+
+		n -= l
+		if s[n-1] == s[n-2] && !strings.ContainsRune("aeiouylsz", s[n-1]) {
+			n--
+		}
+		break
+	}
+
+	if 3 < n && 'e' == s[n-1] {
+		n--
+	}
+	return string(s[:n])
+}
+
+// stemGerman 德语轻量词干提取（Savoy 算法）。
+func stemGerman(word string) string {
+	s := []rune(word)
+	for i, r := range s {
+		switch r {
+		case 'ä', 'à', 'á', 'â':
+			s[i] = 'a'
+		case 'ö', 'ò', 'ó', 'ô':
+			s[i] = 'o'
+		case 'ï', 'ì', 'í', 'î':
+			s[i] = 'i'
+		case 'ü', 'ù', 'ú', 'û':
+			s[i] = 'u'
+		}
+	}
+
+	stEnding := func(r rune) bool { return strings.ContainsRune("bdfghklmnt", r) }
+	endsWith := func(n int, suffix string) bool {
+		l := len([]rune(suffix))
+		return l <= n && string(s[n-l:n]) == suffix
+	}
+
+	n := len(s)
+	switch {
+	case 5 < n && endsWith(n, "ern"):
+		n -= 3
+	case 4 < n && (endsWith(n, "em") || endsWith(n, "en") || endsWith(n, "er") || endsWith(n, "es")):
+		n -= 2
+	case 3 < n && endsWith(n, "e"):
+		n--
+	case 3 < n && endsWith(n, "s") && stEnding(s[n-2]):
+		n--
+	}
+
+	switch {
+	case 5 < n && endsWith(n, "est"):
+		n -= 3
+	case 4 < n && (endsWith(n, "er") || endsWith(n, "en") || endsWith(n, "st") && stEnding(s[n-3])):
+		n -= 2
+	}
+	return string(s[:n])
+}
+
+// stemFrench 法语轻量词干提取，去掉复数和阴性等常见词尾。
+func stemFrench(word string) string {
+	s := []rune(word)
+	n := len(s)
+	if 6 > n {
+		return word
+	}
+
+	if 'x' == s[n-1] {
+		if 'a' == s[n-3] && 'u' == s[n-2] && 'e' != s[n-4] {
+			s[n-2] = 'l'
+		}
+		n--
+	}
+	if 's' == s[n-1] {
+		n--
+	}
+	if 'r' == s[n-1] {
+		n--
+	}
+	if 'e' == s[n-1] {
+		n--
+	}
+	if 'é' == s[n-1] {
+		n--
+	}
+	if s[n-1] == s[n-2] {
+		n--
+	}
+	return string(s[:n])
+}
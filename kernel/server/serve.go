@@ -396,6 +396,8 @@ func serveAppearance(ginServer *gin.Engine) {
 
 func serveCheckAuth(ginServer *gin.Engine) {
 	ginServer.GET("/check-auth", serveAuthPage)
+	ginServer.GET("/auth/oidc/login", model.OIDCLogin)
+	ginServer.GET("/auth/oidc/callback", model.OIDCCallback)
 }
 
 func serveAuthPage(c *gin.Context) {
@@ -466,6 +468,17 @@ func serveAssets(ginServer *gin.Engine) {
 	ginServer.GET("/assets/*path", model.CheckAuth, func(context *gin.Context) {
 		requestPath := context.Param("path")
 		relativePath := path.Join("assets", requestPath)
+		if t := model.GetTenant(context); !t.IsDefault() {
+			// 多用户模式下只能访问自己数据目录下的资源文件
+			p, err := t.Resolve(relativePath)
+			if err != nil || !gulu.File.IsExist(p) {
+				context.Status(http.StatusNotFound)
+				return
+			}
+			http.ServeFile(context.Writer, context.Request, p)
+			return
+		}
+
 		p, err := model.GetAssetAbsPath(relativePath)
 		if err != nil {
 			if strings.Contains(strings.TrimPrefix(requestPath, "/"), "/") {
@@ -485,7 +498,11 @@ func serveAssets(ginServer *gin.Engine) {
 		return
 	})
 	ginServer.GET("/history/*path", model.CheckAuth, model.CheckAdminRole, func(context *gin.Context) {
-		p := filepath.Join(util.HistoryDir, context.Param("path"))
+		p, err := model.GetTenant(context).ResolveHistory(context.Param("path"))
+		if err != nil {
+			context.Status(http.StatusNotFound)
+			return
+		}
 		http.ServeFile(context.Writer, context.Request, p)
 		return
 	})
@@ -536,27 +553,12 @@ func serveWebSocket(ginServer *gin.Engine) {
 		//logging.LogInfof("ws check auth for [%s]", s.Request.RequestURI)
 		authOk := true
 
-		if "" != model.Conf.AccessAuthCode {
-			session, err := sessionStore.Get(s.Request, "siyuan")
-			if err != nil {
-				authOk = false
-				logging.LogErrorf("get cookie failed: %s", err)
-			} else {
-				val := session.Values["data"]
-				if nil == val {
-					authOk = false
-				} else {
-					sess := &util.SessionData{}
-					err = gulu.JSON.UnmarshalJSON([]byte(val.(string)), sess)
-					if err != nil {
-						authOk = false
-						logging.LogErrorf("unmarshal cookie failed: %s", err)
-					} else {
-						workspaceSess := util.GetWorkspaceSession(sess)
-						authOk = workspaceSess.AccessAuthCode == model.Conf.AccessAuthCode
-					}
-				}
-			}
+		workspaceSess := getWebSocketWorkspaceSession(s.Request)
+		if account, ok := model.GetOIDCSessionAccount(workspaceSess); ok {
+			// 通过 OpenID Connect 登录的会话按账号的访问控制规则过滤推送的消息
+			s.Set("account", account)
+		} else if "" != model.Conf.AccessAuthCode {
+			authOk = nil != workspaceSess && workspaceSess.AccessAuthCode == model.Conf.AccessAuthCode
 		}
 
 		// REF: https://github.com/siyuan-note/siyuan/issues/11364
@@ -662,6 +664,27 @@ func serveWebSocket(ginServer *gin.Engine) {
 	})
 }
 
+// getWebSocketWorkspaceSession 从 Cookie 中读取 WebSocket 连接请求的工作空间会话，读取失败时返回 nil。
+func getWebSocketWorkspaceSession(r *http.Request) *util.WorkspaceSession {
+	session, err := sessionStore.Get(r, "siyuan")
+	if err != nil {
+		logging.LogErrorf("get cookie failed: %s", err)
+		return nil
+	}
+
+	val := session.Values["data"]
+	if nil == val {
+		return nil
+	}
+
+	sess := &util.SessionData{}
+	if err = gulu.JSON.UnmarshalJSON([]byte(val.(string)), sess); err != nil {
+		logging.LogErrorf("unmarshal cookie failed: %s", err)
+		return nil
+	}
+	return util.GetWorkspaceSession(sess)
+}
+
 func serveWebDAV(ginServer *gin.Engine) {
 	// REF: https://github.com/fungaren/gin-webdav
 	handler := webdav.Handler{
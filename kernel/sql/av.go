@@ -32,6 +32,11 @@ import (
 )
 
 func RenderAttributeViewTable(attrView *av.AttributeView, view *av.View, query string) (ret *av.Table) {
+	if av.LayoutTypeTable != view.LayoutType || nil == view.Table {
+		// 非表格布局（比如导出和模板预览时）按照表格渲染
+		view = getTableView(attrView, view)
+	}
+
 	ret = &av.Table{
 		ID:               view.ID,
 		Icon:             view.Icon,
@@ -73,6 +78,7 @@ func RenderAttributeViewTable(attrView *av.AttributeView, view *av.View, query s
 			Options:      key.Options,
 			NumberFormat: key.NumberFormat,
 			Template:     key.Template,
+			Formula:      key.Formula,
 			Relation:     key.Relation,
 			Rollup:       key.Rollup,
 			Date:         key.Date,
@@ -161,6 +167,8 @@ func RenderAttributeViewTable(attrView *av.AttributeView, view *av.View, query s
 				}
 			case av.KeyTypeTemplate: // 渲染模板列
 				tableCell.Value = &av.Value{ID: tableCell.ID, KeyID: col.ID, BlockID: rowID, Type: av.KeyTypeTemplate, Template: &av.ValueTemplate{Content: col.Template}}
+			case av.KeyTypeFormula: // 填充公式列值，后面再计算
+				tableCell.Value = &av.Value{ID: tableCell.ID, KeyID: col.ID, BlockID: rowID, Type: av.KeyTypeFormula, Formula: &av.ValueFormula{}}
 			case av.KeyTypeCreated: // 填充创建时间列值，后面再渲染
 				tableCell.Value = &av.Value{ID: tableCell.ID, KeyID: col.ID, BlockID: rowID, Type: av.KeyTypeCreated}
 			case av.KeyTypeUpdated: // 填充更新时间列值，后面再渲染
@@ -352,6 +360,9 @@ func RenderAttributeViewTable(attrView *av.AttributeView, view *av.View, query s
 		util.PushErrMsg(fmt.Sprintf(util.Langs[util.Lang][44], util.EscapeHTML(renderTemplateErr.Error())), 30000)
 	}
 
+	// 公式列可以引用包括模板列在内的所有列，所以最后计算
+	renderFormulaCols(attrView, ret)
+
 	// 根据搜索条件过滤
 	query = strings.TrimSpace(query)
 	if "" != query {
@@ -405,6 +416,159 @@ func RenderAttributeViewTable(attrView *av.AttributeView, view *av.View, query s
 	return
 }
 
+func RenderAttributeViewBoard(attrView *av.AttributeView, view *av.View, query string) (ret *av.Board) {
+	ret = &av.Board{
+		ID:               view.ID,
+		Icon:             view.Icon,
+		Name:             view.Name,
+		Desc:             view.Desc,
+		HideAttrViewName: view.HideAttrViewName,
+		GroupKeyID:       view.Board.GroupKeyID,
+		Filters:          view.Board.Filters,
+		Sorts:            view.Board.Sorts,
+		Fields:           []*av.TableColumn{},
+		Lanes:            []*av.BoardLane{},
+		Rows:             []*av.TableRow{},
+	}
+
+	// 卡片字段复用表格列的渲染
+	table := RenderAttributeViewTable(attrView, getTableView(attrView, view), query)
+	ret.Fields = table.Columns
+	ret.Rows = table.Rows
+	return
+}
+
+func RenderAttributeViewCalendar(attrView *av.AttributeView, view *av.View, query string) (ret *av.Calendar) {
+	layout := view.GetCalendarLayout()
+	ret = &av.Calendar{
+		LayoutType:       view.LayoutType,
+		ID:               view.ID,
+		Icon:             view.Icon,
+		Name:             view.Name,
+		Desc:             view.Desc,
+		HideAttrViewName: view.HideAttrViewName,
+		StartKeyID:       layout.StartKeyID,
+		EndKeyID:         layout.EndKeyID,
+		Unit:             layout.Unit,
+		Filters:          layout.Filters,
+		Sorts:            layout.Sorts,
+		Fields:           []*av.TableColumn{},
+		Buckets:          []*av.CalendarBucket{},
+		Undated:          []*av.TableRow{},
+		Rows:             []*av.TableRow{},
+	}
+
+	// 条目字段复用表格列的渲染
+	table := RenderAttributeViewTable(attrView, getTableView(attrView, view), query)
+	ret.Fields = table.Columns
+	ret.Rows = table.Rows
+	return
+}
+
+func RenderAttributeViewGallery(attrView *av.AttributeView, view *av.View, query string) (ret *av.Gallery) {
+	ret = &av.Gallery{
+		ID:               view.ID,
+		Icon:             view.Icon,
+		Name:             view.Name,
+		Desc:             view.Desc,
+		HideAttrViewName: view.HideAttrViewName,
+		CoverFrom:        view.Gallery.CoverFrom,
+		CoverKeyID:       view.Gallery.CoverKeyID,
+		Filters:          view.Gallery.Filters,
+		Sorts:            view.Gallery.Sorts,
+		Fields:           []*av.TableColumn{},
+		Cards:            []*av.GalleryCard{},
+		Rows:             []*av.TableRow{},
+	}
+
+	// 卡片字段复用表格列的渲染
+	table := RenderAttributeViewTable(attrView, getTableView(attrView, view), query)
+	ret.Fields = table.Columns
+	ret.Rows = table.Rows
+	return
+}
+
+func renderFormulaCols(attrView *av.AttributeView, table *av.Table) {
+	var keys []*av.Key
+	hasFormula := false
+	for _, col := range table.Columns {
+		key, _ := attrView.GetKey(col.ID)
+		if nil == key {
+			key = &av.Key{ID: col.ID, Name: col.Name, Type: col.Type}
+		}
+		keys = append(keys, key)
+		if av.KeyTypeFormula == key.Type {
+			hasFormula = true
+		}
+	}
+	if !hasFormula {
+		return
+	}
+
+	for _, row := range table.Rows {
+		var values []*av.Value
+		for _, cell := range row.Cells {
+			values = append(values, cell.Value)
+		}
+		av.EvalRowFormulas(keys, values)
+	}
+}
+
+// getTableView 将其他布局的视图转换为表格视图，视图中未设置的字段作为隐藏列追加在最后，以便过滤和排序。
+func getTableView(attrView *av.AttributeView, view *av.View) (ret *av.View) {
+	ret = &av.View{
+		ID:               view.ID,
+		Icon:             view.Icon,
+		Name:             view.Name,
+		Desc:             view.Desc,
+		HideAttrViewName: view.HideAttrViewName,
+		LayoutType:       av.LayoutTypeTable,
+		Table:            &av.LayoutTable{Columns: []*av.ViewTableColumn{}, Filters: []*av.ViewFilter{}, Sorts: []*av.ViewSort{}},
+	}
+
+	cols := map[string]bool{}
+	switch view.LayoutType {
+	case av.LayoutTypeBoard:
+		view.Board.Fields = appendTableViewColumns(attrView, ret, view.Board.Fields, cols)
+		ret.Table.Filters = view.Board.Filters
+		ret.Table.Sorts = view.Board.Sorts
+		ret.Table.PageSize = view.Board.PageSize
+	case av.LayoutTypeCalendar, av.LayoutTypeTimeline:
+		layout := view.GetCalendarLayout()
+		layout.Fields = appendTableViewColumns(attrView, ret, layout.Fields, cols)
+		ret.Table.Filters = layout.Filters
+		ret.Table.Sorts = layout.Sorts
+		ret.Table.PageSize = layout.PageSize
+	case av.LayoutTypeGallery:
+		view.Gallery.Fields = appendTableViewColumns(attrView, ret, view.Gallery.Fields, cols)
+		ret.Table.Filters = view.Gallery.Filters
+		ret.Table.Sorts = view.Gallery.Sorts
+		ret.Table.PageSize = view.Gallery.PageSize
+	}
+
+	for _, kv := range attrView.KeyValues {
+		if !cols[kv.Key.ID] {
+			ret.Table.Columns = append(ret.Table.Columns, &av.ViewTableColumn{ID: kv.Key.ID, Hidden: true})
+		}
+	}
+	return
+}
+
+// appendTableViewColumns 将视图字段追加为表格列，返回删除了不存在和重复字段后的视图字段。
+func appendTableViewColumns(attrView *av.AttributeView, tableView *av.View, fields []*av.ViewField, cols map[string]bool) (ret []*av.ViewField) {
+	for _, field := range fields {
+		if _, getErr := attrView.GetKey(field.ID); nil != getErr || cols[field.ID] {
+			// 找不到字段则在视图中删除
+			continue
+		}
+
+		cols[field.ID] = true
+		ret = append(ret, field)
+		tableView.Table.Columns = append(tableView.Table.Columns, &av.ViewTableColumn{ID: field.ID, Hidden: field.Hidden})
+	}
+	return
+}
+
 func RenderTemplateCol(ial map[string]string, rowValues []*av.KeyValues, tplContent string) (ret string, err error) {
 	if "" == ial["id"] {
 		block := getRowBlockValue(rowValues)
@@ -506,6 +670,11 @@ func RenderTemplateCol(ial map[string]string, rowValues []*av.KeyValues, tplCont
 					dataModel[rowValue.Key.Name] = contents
 				}
 			}
+		} else if av.KeyTypeUniqueID == v.Type {
+			if nil != v.UniqueID && 0 < v.UniqueID.Number {
+				dataModel[rowValue.Key.Name] = v.UniqueID.Content
+				dataModel[rowValue.Key.Name+"_number"] = v.UniqueID.Number
+			}
 		} else if av.KeyTypeRelation == v.Type {
 			if 0 < len(v.Relation.Contents) {
 				var contents []string
@@ -605,6 +774,14 @@ func FillAttributeViewTableCellNilValue(tableCell *av.TableCell, rowID, colID st
 		if nil == tableCell.Value.Rollup {
 			tableCell.Value.Rollup = &av.ValueRollup{}
 		}
+	case av.KeyTypeFormula:
+		if nil == tableCell.Value.Formula {
+			tableCell.Value.Formula = &av.ValueFormula{}
+		}
+	case av.KeyTypeUniqueID:
+		if nil == tableCell.Value.UniqueID {
+			tableCell.Value.UniqueID = &av.ValueUniqueID{}
+		}
 	}
 }
 
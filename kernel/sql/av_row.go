@@ -0,0 +1,276 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/siyuan-note/siyuan/kernel/av"
+)
+
+// AvRow 描述了属性视图中的一行，对应 av_rows 表。
+type AvRow struct {
+	ID         string // 行 ID
+	AvID       string // 属性视图 ID
+	BlockID    string // 绑定的块 ID，未绑定块时为空
+	IsDetached bool   // 是否未绑定块
+	Content    string // 主键内容
+	Created    string
+	Updated    string
+}
+
+// AvValue 描述了属性视图中的一个单元格，对应 av_values 表。
+type AvValue struct {
+	ID      string   // 值 ID
+	AvID    string   // 属性视图 ID
+	RowID   string   // 行 ID
+	KeyID   string   // 字段 ID
+	KeyName string   // 字段名
+	KeyType string   // 字段类型
+	Content string   // 文本内容
+	Number  *float64 // 数字、日期（毫秒）、勾选框（0/1）和唯一 ID 字段的数值，其他字段为 NULL
+	Created string
+	Updated string
+}
+
+const (
+	AvRowsPlaceholder   = "(?, ?, ?, ?, ?, ?, ?)"
+	AvValuesPlaceholder = "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+)
+
+func indexAttributeView(tx *sql.Tx, avID string) (err error) {
+	if err = deleteAttributeView(tx, avID); err != nil {
+		return
+	}
+
+	if !av.IsAttributeViewExist(avID) {
+		return
+	}
+
+	attrView, err := av.ParseAttributeView(avID)
+	if err != nil {
+		return
+	}
+
+	rows, values := avRowsValues(attrView)
+	if err = insertAvRows(tx, rows); err != nil {
+		return
+	}
+	err = insertAvValues(tx, values)
+	return
+}
+
+func deleteAttributeView(tx *sql.Tx, avID string) (err error) {
+	stmt := "DELETE FROM av_rows WHERE av_id = ?"
+	if err = execStmtTx(tx, stmt, avID); err != nil {
+		return
+	}
+	stmt = "DELETE FROM av_values WHERE av_id = ?"
+	err = execStmtTx(tx, stmt, avID)
+	return
+}
+
+func avRowsValues(attrView *av.AttributeView) (rows []*AvRow, values []*AvValue) {
+	blockValues := attrView.GetBlockKeyValues()
+	if nil == blockValues {
+		return
+	}
+
+	rowIDs := map[string]bool{}
+	for _, blockValue := range blockValues.Values {
+		rowIDs[blockValue.BlockID] = true
+		row := &AvRow{
+			ID:         blockValue.BlockID,
+			AvID:       attrView.ID,
+			IsDetached: blockValue.IsDetached,
+			Created:    formatAvTime(blockValue.CreatedAt),
+			Updated:    formatAvTime(blockValue.UpdatedAt),
+		}
+		if !blockValue.IsDetached {
+			row.BlockID = blockValue.BlockID
+		}
+		if nil != blockValue.Block {
+			row.Content = blockValue.Block.Content
+		}
+		rows = append(rows, row)
+	}
+
+	for _, kv := range attrView.KeyValues {
+		switch kv.Key.Type {
+		case av.KeyTypeTemplate, av.KeyTypeRollup, av.KeyTypeFormula, av.KeyTypeLineNumber, av.KeyTypeCreated, av.KeyTypeUpdated:
+			// 渲染时才计算的值不入库，创建时间和更新时间使用 av_rows 中的字段
+			continue
+		}
+
+		for _, v := range kv.Values {
+			if !rowIDs[v.BlockID] {
+				continue
+			}
+
+			v.Type = kv.Key.Type
+			values = append(values, &AvValue{
+				ID:      v.ID,
+				AvID:    attrView.ID,
+				RowID:   v.BlockID,
+				KeyID:   kv.Key.ID,
+				KeyName: kv.Key.Name,
+				KeyType: string(kv.Key.Type),
+				Content: avValueContent(v),
+				Number:  avValueNumber(v),
+				Created: formatAvTime(v.CreatedAt),
+				Updated: formatAvTime(v.UpdatedAt),
+			})
+		}
+	}
+	return
+}
+
+func avValueContent(value *av.Value) string {
+	switch value.Type {
+	case av.KeyTypeRelation:
+		// 关联字段存储时不保存关联内容，这里使用关联的块 ID，方便和 blocks 表连接
+		if nil == value.Relation {
+			return ""
+		}
+		return strings.Join(value.Relation.BlockIDs, " ")
+	case av.KeyTypeNumber:
+		if nil == value.Number || !value.Number.IsNotEmpty {
+			return ""
+		}
+		return strconv.FormatFloat(value.Number.Content, 'f', -1, 64)
+	case av.KeyTypeDate:
+		if nil == value.Date || !value.Date.IsNotEmpty {
+			return ""
+		}
+	}
+	return value.String(false)
+}
+
+func avValueNumber(value *av.Value) (ret *float64) {
+	switch value.Type {
+	case av.KeyTypeNumber:
+		if nil != value.Number && value.Number.IsNotEmpty {
+			ret = &value.Number.Content
+		}
+	case av.KeyTypeDate:
+		if nil != value.Date && value.Date.IsNotEmpty {
+			n := float64(value.Date.Content)
+			ret = &n
+		}
+	case av.KeyTypeCheckbox:
+		n := float64(0)
+		if nil != value.Checkbox && value.Checkbox.Checked {
+			n = 1
+		}
+		ret = &n
+	case av.KeyTypeUniqueID:
+		if nil != value.UniqueID && 0 < value.UniqueID.Number {
+			n := float64(value.UniqueID.Number)
+			ret = &n
+		}
+	}
+	return
+}
+
+func formatAvTime(mills int64) string {
+	if 1 > mills {
+		return ""
+	}
+	// 和 blocks 表的 created/updated 字段保持一致
+	return time.UnixMilli(mills).Format("20060102150405")
+}
+
+func insertAvRows(tx *sql.Tx, rows []*AvRow) (err error) {
+	var bulk []*AvRow
+	for _, row := range rows {
+		bulk = append(bulk, row)
+		if 512 > len(bulk) {
+			continue
+		}
+
+		if err = insertAvRows0(tx, bulk); err != nil {
+			return
+		}
+		bulk = []*AvRow{}
+	}
+	if 0 < len(bulk) {
+		err = insertAvRows0(tx, bulk)
+	}
+	return
+}
+
+func insertAvRows0(tx *sql.Tx, bulk []*AvRow) (err error) {
+	valueStrings := make([]string, 0, len(bulk))
+	valueArgs := make([]interface{}, 0, len(bulk)*strings.Count(AvRowsPlaceholder, "?"))
+	for _, row := range bulk {
+		valueStrings = append(valueStrings, AvRowsPlaceholder)
+		valueArgs = append(valueArgs, row.ID)
+		valueArgs = append(valueArgs, row.AvID)
+		valueArgs = append(valueArgs, row.BlockID)
+		valueArgs = append(valueArgs, row.IsDetached)
+		valueArgs = append(valueArgs, row.Content)
+		valueArgs = append(valueArgs, row.Created)
+		valueArgs = append(valueArgs, row.Updated)
+	}
+	stmt := fmt.Sprintf("INSERT INTO av_rows (id, av_id, block_id, is_detached, content, created, updated) VALUES %s", strings.Join(valueStrings, ","))
+	err = prepareExecInsertTx(tx, stmt, valueArgs)
+	return
+}
+
+func insertAvValues(tx *sql.Tx, values []*AvValue) (err error) {
+	var bulk []*AvValue
+	for _, value := range values {
+		bulk = append(bulk, value)
+		if 512 > len(bulk) {
+			continue
+		}
+
+		if err = insertAvValues0(tx, bulk); err != nil {
+			return
+		}
+		bulk = []*AvValue{}
+	}
+	if 0 < len(bulk) {
+		err = insertAvValues0(tx, bulk)
+	}
+	return
+}
+
+func insertAvValues0(tx *sql.Tx, bulk []*AvValue) (err error) {
+	valueStrings := make([]string, 0, len(bulk))
+	valueArgs := make([]interface{}, 0, len(bulk)*strings.Count(AvValuesPlaceholder, "?"))
+	for _, value := range bulk {
+		valueStrings = append(valueStrings, AvValuesPlaceholder)
+		valueArgs = append(valueArgs, value.ID)
+		valueArgs = append(valueArgs, value.AvID)
+		valueArgs = append(valueArgs, value.RowID)
+		valueArgs = append(valueArgs, value.KeyID)
+		valueArgs = append(valueArgs, value.KeyName)
+		valueArgs = append(valueArgs, value.KeyType)
+		valueArgs = append(valueArgs, value.Content)
+		valueArgs = append(valueArgs, value.Number)
+		valueArgs = append(valueArgs, value.Created)
+		valueArgs = append(valueArgs, value.Updated)
+	}
+	stmt := fmt.Sprintf("INSERT INTO av_values (id, av_id, row_id, key_id, key_name, key_type, content, number, created, updated) VALUES %s", strings.Join(valueStrings, ","))
+	err = prepareExecInsertTx(tx, stmt, valueArgs)
+	return
+}
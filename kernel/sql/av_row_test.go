@@ -0,0 +1,146 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/siyuan-note/siyuan/kernel/av"
+)
+
+func newAvRowTestAttrView() *av.AttributeView {
+	blockKey := &av.Key{ID: "key-block", Name: "Name", Type: av.KeyTypeBlock}
+	numberKey := &av.Key{ID: "key-number", Name: "Score", Type: av.KeyTypeNumber}
+	checkboxKey := &av.Key{ID: "key-checkbox", Name: "Done", Type: av.KeyTypeCheckbox}
+	relationKey := &av.Key{ID: "key-relation", Name: "Refs", Type: av.KeyTypeRelation}
+	formulaKey := &av.Key{ID: "key-formula", Name: "Total", Type: av.KeyTypeFormula}
+	created := time.Date(2024, 3, 4, 10, 20, 30, 0, time.Local).UnixMilli()
+	return &av.AttributeView{
+		ID: "av-1",
+		KeyValues: []*av.KeyValues{
+			{Key: blockKey, Values: []*av.Value{
+				{ID: "v1", KeyID: blockKey.ID, BlockID: "row-1", Block: &av.ValueBlock{ID: "row-1", Content: "Bound"}, CreatedAt: created},
+				{ID: "v2", KeyID: blockKey.ID, BlockID: "row-2", IsDetached: true, Block: &av.ValueBlock{ID: "row-2", Content: "Detached"}},
+			}},
+			{Key: numberKey, Values: []*av.Value{
+				{ID: "v3", KeyID: numberKey.ID, BlockID: "row-1", Number: &av.ValueNumber{Content: 42, IsNotEmpty: true}},
+				{ID: "v4", KeyID: numberKey.ID, BlockID: "row-2", Number: &av.ValueNumber{}},
+				{ID: "v5", KeyID: numberKey.ID, BlockID: "row-removed", Number: &av.ValueNumber{Content: 1, IsNotEmpty: true}},
+			}},
+			{Key: checkboxKey, Values: []*av.Value{{ID: "v6", KeyID: checkboxKey.ID, BlockID: "row-1", Checkbox: &av.ValueCheckbox{Checked: true}}}},
+			{Key: relationKey, Values: []*av.Value{{ID: "v7", KeyID: relationKey.ID, BlockID: "row-2", Relation: &av.ValueRelation{BlockIDs: []string{"b1", "b2"}}}}},
+			{Key: formulaKey, Values: []*av.Value{{ID: "v8", KeyID: formulaKey.ID, BlockID: "row-1"}}},
+		},
+	}
+}
+
+func TestAvRowsValues(t *testing.T) {
+	rows, values := avRowsValues(newAvRowTestAttrView())
+	if 2 != len(rows) {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+	if "row-1" != rows[0].BlockID || rows[0].IsDetached || "Bound" != rows[0].Content || "20240304102030" != rows[0].Created || "" != rows[0].Updated {
+		t.Fatalf("unexpected bound row [%+v]", rows[0])
+	}
+	if "" != rows[1].BlockID || !rows[1].IsDetached {
+		t.Fatalf("detached row should not have block ID [%+v]", rows[1])
+	}
+
+	// 主键 2 个、数字 2 个（已删除行的值不入库）、勾选框 1 个、关联 1 个，公式不入库
+	if 6 != len(values) {
+		t.Fatalf("expected 6 values, got %d", len(values))
+	}
+	byID := map[string]*AvValue{}
+	for _, v := range values {
+		byID[v.ID] = v
+	}
+	if v := byID["v3"]; nil == v.Number || 42 != *v.Number || "42" != v.Content || "Score" != v.KeyName || "number" != v.KeyType {
+		t.Fatalf("unexpected number value [%+v]", v)
+	}
+	if v := byID["v4"]; nil != v.Number || "" != v.Content {
+		t.Fatalf("empty number should be NULL, got [%+v]", v)
+	}
+	if v := byID["v6"]; nil == v.Number || 1 != *v.Number {
+		t.Fatalf("unexpected checkbox value [%+v]", v)
+	}
+	if v := byID["v7"]; "b1 b2" != v.Content {
+		t.Fatalf("unexpected relation value [%+v]", v)
+	}
+	if nil != byID["v5"] || nil != byID["v8"] {
+		t.Fatalf("removed row values and formula values should not be indexed")
+	}
+}
+
+func TestInsertAvRowsValues(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, stmt := range []string{
+		"CREATE TABLE av_rows (id, av_id, block_id, is_detached, content, created, updated)",
+		"CREATE TABLE av_values (id, av_id, row_id, key_id, key_name, key_type, content, number, created, updated)",
+	} {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, values := avRowsValues(newAvRowTestAttrView())
+	// 超过单次批量插入的数量
+	for i := 0; i < 600; i++ {
+		values = append(values, &AvValue{ID: "bulk", AvID: "av-1", RowID: "row-1"})
+	}
+	if err = insertAvRows(tx, rows); err != nil {
+		t.Fatal(err)
+	}
+	if err = insertAvValues(tx, values); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	var sum float64
+	if err = db.QueryRow("SELECT SUM(v.number) FROM av_values v JOIN av_rows r ON v.row_id = r.id WHERE r.block_id = 'row-1' AND v.key_type IN ('number', 'checkbox')").Scan(&sum); err != nil {
+		t.Fatal(err)
+	}
+	if 43 != sum {
+		t.Fatalf("expected sum 43, got %v", sum)
+	}
+
+	var count int
+	if err = db.QueryRow("SELECT COUNT(*) FROM av_values WHERE av_id = 'av-1'").Scan(&count); err != nil || 606 != count {
+		t.Fatalf("expected 606 values, got %d: %v", count, err)
+	}
+
+	tx, _ = db.Begin()
+	if err = deleteAttributeView(tx, "av-1"); err != nil {
+		t.Fatal(err)
+	}
+	tx.Commit()
+	if err = db.QueryRow("SELECT (SELECT COUNT(*) FROM av_rows) + (SELECT COUNT(*) FROM av_values)").Scan(&count); err != nil || 0 != count {
+		t.Fatalf("expected index deleted, got %d: %v", count, err)
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/siyuan-note/siyuan/kernel/av"
 	"github.com/siyuan-note/siyuan/kernel/cache"
 	"github.com/siyuan-note/siyuan/kernel/filesys"
+	"github.com/siyuan-note/siyuan/kernel/search"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
@@ -62,13 +63,14 @@ func updateRootContent(tx *sql.Tx, content, updated, id string) (err error) {
 	if err = execStmtTx(tx, stmt, content, content, updated, id); err != nil {
 		return
 	}
-	stmt = "UPDATE blocks_fts SET content = ?, fcontent = ?, updated = ? WHERE id = ?"
-	if err = execStmtTx(tx, stmt, content, content, updated, id); err != nil {
+	tokens := search.TokenizeText(content, tokenizers)
+	stmt = "UPDATE blocks_fts SET content = ?, fcontent = ?, updated = ?, tokens = ? WHERE id = ?"
+	if err = execStmtTx(tx, stmt, content, content, updated, tokens, id); err != nil {
 		return
 	}
 	if !caseSensitive {
-		stmt = "UPDATE blocks_fts_case_insensitive SET content = ?, fcontent = ?, updated = ? WHERE id = ?"
-		if err = execStmtTx(tx, stmt, content, content, updated, id); err != nil {
+		stmt = "UPDATE blocks_fts_case_insensitive SET content = ?, fcontent = ?, updated = ?, tokens = ? WHERE id = ?"
+		if err = execStmtTx(tx, stmt, content, content, updated, tokens, id); err != nil {
 			return
 		}
 	}
@@ -83,14 +85,15 @@ func updateBlockContent(tx *sql.Tx, block *Block) (err error) {
 		tx.Rollback()
 		return
 	}
-	stmt = "UPDATE blocks_fts SET content = ? WHERE id = ?"
-	if err = execStmtTx(tx, stmt, block.Content, block.ID); err != nil {
+	tokens := blockTokens(block)
+	stmt = "UPDATE blocks_fts SET content = ?, tokens = ? WHERE id = ?"
+	if err = execStmtTx(tx, stmt, block.Content, tokens, block.ID); err != nil {
 		tx.Rollback()
 		return
 	}
 	if !caseSensitive {
-		stmt = "UPDATE blocks_fts_case_insensitive SET content = ? WHERE id = ?"
-		if err = execStmtTx(tx, stmt, block.Content, block.ID); err != nil {
+		stmt = "UPDATE blocks_fts_case_insensitive SET content = ?, tokens = ? WHERE id = ?"
+		if err = execStmtTx(tx, stmt, block.Content, tokens, block.ID); err != nil {
 			tx.Rollback()
 			return
 		}
@@ -122,14 +125,15 @@ func indexNode(tx *sql.Tx, id string) (err error) {
 		tx.Rollback()
 		return
 	}
-	stmt = "UPDATE blocks_fts SET content = ? WHERE id = ?"
-	if err = execStmtTx(tx, stmt, content, id); err != nil {
+	tokens := search.TokenizeText(content, tokenizers)
+	stmt = "UPDATE blocks_fts SET content = ?, tokens = ? WHERE id = ?"
+	if err = execStmtTx(tx, stmt, content, tokens, id); err != nil {
 		tx.Rollback()
 		return
 	}
 	if !caseSensitive {
-		stmt = "UPDATE blocks_fts_case_insensitive SET content = ? WHERE id = ?"
-		if err = execStmtTx(tx, stmt, content, id); err != nil {
+		stmt = "UPDATE blocks_fts_case_insensitive SET content = ?, tokens = ? WHERE id = ?"
+		if err = execStmtTx(tx, stmt, content, tokens, id); err != nil {
 			tx.Rollback()
 			return
 		}
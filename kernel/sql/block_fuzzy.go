@@ -0,0 +1,126 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/88250/gulu"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/search"
+)
+
+const fuzzyCandidateLimit = 256 // 通过 trigram 索引查找的候选词项数
+
+var (
+	fuzzyTermsDirty = atomic.Bool{} // 分词表中的词项可能发生了变化，需要同步到 blocks_fts_trigram
+)
+
+// GetFuzzyTerms 返回索引中和 word 编辑距离不超过 maxDistance 的词项，按编辑距离升序排列，最多返回 limit 个。
+//
+// 候选词项通过 blocks_fts_trigram 中和 word 共有的 trigram 查找，所以不足三个字符的 word 不进行模糊匹配。
+func GetFuzzyTerms(word string, maxDistance, limit int) (ret []string) {
+	if !caseSensitive {
+		word = strings.ToLower(word)
+	}
+	grams := search.Trigrams(word)
+	if 1 > len(grams) || 1 > maxDistance {
+		return
+	}
+
+	var matches []string
+	for _, gram := range grams {
+		matches = append(matches, "\""+strings.ReplaceAll(gram, "\"", "\"\"")+"\"")
+	}
+	matches = gulu.Str.RemoveDuplicatedElem(matches)
+	wordLen := len([]rune(word))
+	stmt := "SELECT term FROM blocks_fts_trigram WHERE blocks_fts_trigram MATCH ? AND length(term) BETWEEN ? AND ? ORDER BY rank LIMIT ?"
+	rows, err := query(stmt, strings.Join(matches, " OR "), wordLen-maxDistance, wordLen+maxDistance, fuzzyCandidateLimit)
+	if err != nil {
+		logging.LogErrorf("sql query [%s] failed: %s", stmt, err)
+		return
+	}
+	defer rows.Close()
+
+	distances := map[string]int{}
+	for rows.Next() {
+		var term string
+		if err = rows.Scan(&term); err != nil {
+			logging.LogErrorf("query scan field failed: %s", err)
+			return
+		}
+
+		if distance := search.EditDistance(word, term); distance <= maxDistance {
+			distances[term] = distance
+			ret = append(ret, term)
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool { return distances[ret[i]] < distances[ret[j]] })
+	if limit < len(ret) {
+		ret = ret[:limit]
+	}
+	return
+}
+
+// markFuzzyTermsDirty 在块索引发生变化后调用，数据库队列提交后会同步 blocks_fts_trigram。
+func markFuzzyTermsDirty(action string) {
+	switch action {
+	case "index", "upsert", "delete", "delete_id", "delete_ids", "delete_box", "update_block_content", "rename":
+		fuzzyTermsDirty.Store(true)
+	}
+}
+
+// flushFuzzyTerms 将分词表中的词项变化同步到 blocks_fts_trigram，由数据库队列在提交操作后调用。
+func flushFuzzyTerms() {
+	if !fuzzyTermsDirty.CompareAndSwap(true, false) {
+		return
+	}
+
+	vocab := "blocks_fts_vocab"
+	if !caseSensitive {
+		vocab = "blocks_fts_case_insensitive_vocab"
+	}
+
+	tx, err := beginTx()
+	if err != nil {
+		fuzzyTermsDirty.Store(true)
+		return
+	}
+	if err = syncFuzzyTerms(tx, vocab); err != nil {
+		logging.LogErrorf("sync fuzzy terms failed: %s", err)
+		tx.Rollback()
+		fuzzyTermsDirty.Store(true)
+		return
+	}
+	if err = commitTx(tx); err != nil {
+		fuzzyTermsDirty.Store(true)
+	}
+}
+
+// syncFuzzyTerms 删除 blocks_fts_trigram 中分词表 vocab 已经不存在的词项，并加入分词表中新增的词项。
+func syncFuzzyTerms(tx *sql.Tx, vocab string) (err error) {
+	stmt := "DELETE FROM blocks_fts_trigram WHERE term NOT IN (SELECT term FROM " + vocab + ")"
+	if err = execStmtTx(tx, stmt); err != nil {
+		return
+	}
+	stmt = "INSERT INTO blocks_fts_trigram (term) SELECT term FROM " + vocab + " WHERE length(term) >= 3 AND term NOT IN (SELECT term FROM blocks_fts_trigram)"
+	return execStmtTx(tx, stmt)
+}
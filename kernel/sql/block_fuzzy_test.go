@@ -0,0 +1,96 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+func TestSyncFuzzyTerms(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{
+		"CREATE VIRTUAL TABLE blocks_fts USING fts5(content)",
+		"CREATE VIRTUAL TABLE blocks_fts_vocab USING fts5vocab(blocks_fts, row)",
+		"CREATE VIRTUAL TABLE blocks_fts_trigram USING fts5(term, tokenize=\"trigram\")",
+		"INSERT INTO blocks_fts (content) VALUES ('siyuan notes'), ('an editor')",
+	} {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	terms := func() (ret []string) {
+		rows, err := db.Query("SELECT term FROM blocks_fts_trigram ORDER BY term")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var term string
+			rows.Scan(&term)
+			ret = append(ret, term)
+		}
+		return
+	}
+	sync := func() {
+		tx, _ := db.Begin()
+		if err := syncFuzzyTerms(tx, "blocks_fts_vocab"); err != nil {
+			t.Fatal(err)
+		}
+		tx.Commit()
+	}
+
+	// 不足三个字符的词项不加入
+	sync()
+	if want := []string{"editor", "notes", "siyuan"}; !reflect.DeepEqual(want, terms()) {
+		t.Fatalf("expected %v, got %v", want, terms())
+	}
+
+	// 删除块后分词表中不存在的词项需要清理，重复同步不产生重复词项
+	if _, err = db.Exec("DELETE FROM blocks_fts WHERE content = 'an editor'"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = db.Exec("INSERT INTO blocks_fts (content) VALUES ('siyuan markdown')"); err != nil {
+		t.Fatal(err)
+	}
+	sync()
+	sync()
+	if want := []string{"markdown", "notes", "siyuan"}; !reflect.DeepEqual(want, terms()) {
+		t.Fatalf("expected %v, got %v", want, terms())
+	}
+}
+
+func TestMarkFuzzyTermsDirty(t *testing.T) {
+	fuzzyTermsDirty.Store(false)
+	markFuzzyTermsDirty("upsert_vectors")
+	if fuzzyTermsDirty.Load() {
+		t.Fatalf("vector operation should not change fuzzy terms")
+	}
+	markFuzzyTermsDirty("delete_id")
+	if !fuzzyTermsDirty.Load() {
+		t.Fatalf("expected fuzzy terms dirty after deleting blocks")
+	}
+	fuzzyTermsDirty.Store(false)
+}
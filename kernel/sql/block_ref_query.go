@@ -360,11 +360,16 @@ func QueryRefIDsByDefID(defID string, containChildren bool) (refIDs, refTexts []
 	return
 }
 
-func QueryRefsRecent(onlyDoc bool, typeFilter string, ignoreLines []string) (ret []*Ref) {
+// QueryRefsRecent 返回最近的引用，defBlockFilter 为被引用块需要满足的 SQL 条件（比如限制笔记本），为空时不限制。
+func QueryRefsRecent(onlyDoc bool, typeFilter, defBlockFilter string, ignoreLines []string) (ret []*Ref) {
 	stmt := "SELECT r.* FROM refs AS r, blocks AS b WHERE b.id = r.def_block_id AND b.type IN " + typeFilter
 	if onlyDoc {
 		stmt = "SELECT r.* FROM refs AS r, blocks AS b WHERE b.id = r.def_block_id AND b.type = 'd'"
 	}
+	if "" != defBlockFilter {
+		// refs 和 blocks 都有 box 和 path 字段，通过子查询避免字段歧义
+		stmt += " AND r.def_block_id IN (SELECT id FROM blocks WHERE 1 = 1" + defBlockFilter + ")"
+	}
 	if 0 < len(ignoreLines) {
 		// Support ignore search results https://github.com/siyuan-note/siyuan/issues/10089
 		buf := bytes.Buffer{}
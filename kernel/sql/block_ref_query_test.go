@@ -0,0 +1,107 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/siyuan-note/siyuan/kernel/search"
+	"github.com/siyuan-note/siyuan/kernel/tenant"
+)
+
+const (
+	aliceBox = "20240101120000-alice01"
+	bobBox   = "20240101120000-bob0001"
+)
+
+// setupTenantDB 创建两个用户的笔记本，并在共用的数据库中为每个笔记本索引一个包含 secret 的文档和一个引用该文档的块。
+func setupTenantDB(t *testing.T) (alice, bob *tenant.Tenant) {
+	workspace := t.TempDir()
+	tenant.SetWorkspace(filepath.Join(workspace, "data"), filepath.Join(workspace, "history"), filepath.Join(workspace, "temp"))
+	alice, _ = tenant.Get("alice")
+	bob, _ = tenant.Get("bob")
+	for _, box := range []string{filepath.Join(alice.DataDir, aliceBox), filepath.Join(bob.DataDir, bobBox)} {
+		if err := os.MkdirAll(box, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	testDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testDB.SetMaxOpenConns(1)
+	dbBak := db
+	db = testDB
+	t.Cleanup(func() {
+		db = dbBak
+		testDB.Close()
+	})
+
+	for _, stmt := range []string{
+		"CREATE TABLE blocks (id, parent_id, root_id, hash, box, path, hpath, name, alias, memo, tag, content, fcontent, markdown, length, type, subtype, ial, sort, created, updated)",
+		"CREATE TABLE refs (id, def_block_id, def_block_parent_id, def_block_root_id, def_block_path, block_id, root_id, box, path, content, markdown, type)",
+		"INSERT INTO blocks VALUES ('alice-doc', '', 'alice-doc', '', '" + aliceBox + "', '/alice-doc.sy', '/alice', '', '', '', '', 'alice secret', '', '', 12, 'd', '', '', 0, '', '')",
+		"INSERT INTO blocks VALUES ('bob-doc', '', 'bob-doc', '', '" + bobBox + "', '/bob-doc.sy', '/bob', '', '', '', '', 'bob secret', '', '', 10, 'd', '', '', 0, '', '')",
+		"INSERT INTO refs VALUES ('ref-1', 'alice-doc', '', 'alice-doc', '/alice-doc.sy', 'alice-p', 'alice-doc', '" + aliceBox + "', '/alice-doc.sy', '', '', 'textmark')",
+		"INSERT INTO refs VALUES ('ref-2', 'bob-doc', '', 'bob-doc', '/bob-doc.sy', 'bob-p', 'bob-doc', '" + bobBox + "', '/bob-doc.sy', '', '', 'textmark')",
+	} {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return
+}
+
+func TestQueryRefsRecent(t *testing.T) {
+	alice, bob := setupTenantDB(t)
+
+	if refs := QueryRefsRecent(true, "", "", nil); 2 != len(refs) {
+		t.Fatalf("expected 2 recent refs without filter, got %d", len(refs))
+	}
+
+	// 最近引用只返回被引用块在自己笔记本中的引用
+	if refs := QueryRefsRecent(true, "", search.BoxesFilter(alice.RestrictBoxes(nil)), nil); 1 != len(refs) || "alice-doc" != refs[0].DefBlockID {
+		t.Fatalf("unexpected alice recent refs %v", refs)
+	}
+	if refs := QueryRefsRecent(false, "('d')", search.BoxesFilter(bob.RestrictBoxes(nil)), nil); 1 != len(refs) || "bob-doc" != refs[0].DefBlockID {
+		t.Fatalf("unexpected bob recent refs %v", refs)
+	}
+	if refs := QueryRefsRecent(true, "", search.BoxesFilter(bob.RestrictBoxes([]string{aliceBox})), nil); 0 != len(refs) {
+		t.Fatalf("bob should not see alice recent refs %v", refs)
+	}
+}
+
+func TestSearchBoxesIsolated(t *testing.T) {
+	alice, bob := setupTenantDB(t)
+
+	stmt := "SELECT * FROM blocks WHERE content LIKE '%secret%'"
+	if blocks := SelectBlocksRawStmtNoParse(stmt+search.BoxesFilter(alice.RestrictBoxes(nil)), 32); 1 != len(blocks) || aliceBox != blocks[0].Box {
+		t.Fatalf("unexpected alice search result %v", blocks)
+	}
+
+	// 指定其他用户的笔记本或者通过笔记本参数注入条件都搜索不到结果
+	if blocks := SelectBlocksRawStmtNoParse(stmt+search.BoxesFilter(bob.RestrictBoxes([]string{aliceBox})), 32); 0 != len(blocks) {
+		t.Fatalf("bob should not search alice blocks %v", blocks)
+	}
+	if blocks := SelectBlocksRawStmtNoParse(stmt+search.BoxesFilter([]string{"-' OR '1' = '1"}), 32); 0 != len(blocks) {
+		t.Fatalf("box filter should escape quotes %v", blocks)
+	}
+}
@@ -0,0 +1,190 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emirpasic/gods/sets/hashset"
+	"github.com/siyuan-note/logging"
+)
+
+// BlockVector 描述了块内容的向量，对应 blocks_vector 表。
+type BlockVector struct {
+	ID     string    // 块 ID
+	RootID string    // 文档块 ID
+	Hash   string    // 生成向量时的块内容 hash，和 blocks 表中的 hash 不一致时说明向量已经过期
+	Vector []float32 // 归一化后的向量
+}
+
+// VectorBlockTypes 描述了生成向量的块类型，容器块的内容由子块组成，不需要单独生成向量。
+const VectorBlockTypes = "('d', 'h', 'p', 'c', 'm', 't')"
+
+var (
+	// VectorIndexEnabled 是否启用块向量索引，由语义搜索设置控制。
+	VectorIndexEnabled bool
+
+	vectorQueue     = hashset.New()
+	vectorQueueLock = sync.Mutex{}
+)
+
+// enqueueVectorBlocks 记录内容发生变化的块，后续由向量索引任务生成向量。
+func enqueueVectorBlocks(blocks []*Block) {
+	if !VectorIndexEnabled {
+		return
+	}
+
+	vectorQueueLock.Lock()
+	defer vectorQueueLock.Unlock()
+	for _, b := range blocks {
+		vectorQueue.Add(b.ID)
+	}
+}
+
+// PopVectorQueue 取出最多 limit 个等待生成向量的块 ID。
+func PopVectorQueue(limit int) (ret []string) {
+	vectorQueueLock.Lock()
+	defer vectorQueueLock.Unlock()
+
+	for _, id := range vectorQueue.Values() {
+		if limit <= len(ret) {
+			break
+		}
+		ret = append(ret, id.(string))
+		vectorQueue.Remove(id)
+	}
+	return
+}
+
+// GetBlocksWithoutVector 返回没有向量或者向量已经过期的块，ids 为空时在所有块中查找。
+func GetBlocksWithoutVector(ids []string, limit int) (ret []*Block) {
+	stmt := "SELECT * FROM blocks WHERE type IN " + VectorBlockTypes + " AND content != ''"
+	var args []interface{}
+	if 0 < len(ids) {
+		stmt += " AND id IN (" + strings.Repeat("?,", len(ids)-1) + "?)"
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	stmt += " AND NOT EXISTS (SELECT 1 FROM blocks_vector WHERE blocks_vector.id = blocks.id AND blocks_vector.hash = blocks.hash)"
+	stmt += fmt.Sprintf(" LIMIT %d", limit)
+	rows, err := query(stmt, args...)
+	if err != nil {
+		logging.LogErrorf("sql query [%s] failed: %s", stmt, err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if block := scanBlockRows(rows); nil != block {
+			ret = append(ret, block)
+		}
+	}
+	return
+}
+
+// GetBlockVectors 返回指定块的向量，已经过期的向量不返回。
+func GetBlockVectors(ids []string) (ret map[string][]float32) {
+	ret = map[string][]float32{}
+	if 1 > len(ids) {
+		return
+	}
+
+	stmt := "SELECT v.id, v.vector FROM blocks_vector v JOIN blocks b ON v.id = b.id AND v.hash = b.hash WHERE v.id IN (" + strings.Repeat("?,", len(ids)-1) + "?)"
+	var args []interface{}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := query(stmt, args...)
+	if err != nil {
+		logging.LogErrorf("sql query [%s] failed: %s", stmt, err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var data []byte
+		if err = rows.Scan(&id, &data); err != nil {
+			logging.LogErrorf("query scan field failed: %s", err)
+			return
+		}
+		ret[id] = decodeVector(data)
+	}
+	return
+}
+
+func UpsertBlockVectorsQueue(vectors []*BlockVector) {
+	if 1 > len(vectors) {
+		return
+	}
+
+	dbQueueLock.Lock()
+	defer dbQueueLock.Unlock()
+
+	newOp := &dbQueueOperation{vectors: vectors, inQueueTime: time.Now(), action: "upsert_vectors"}
+	appendOperation(newOp)
+}
+
+func upsertBlockVectors(tx *sql.Tx, vectors []*BlockVector) (err error) {
+	rootIDs := hashset.New()
+	for _, v := range vectors {
+		if 1 > len(v.Vector) {
+			return fmt.Errorf("vector of block [%s] is empty", v.ID)
+		}
+		if err = execStmtTx(tx, "DELETE FROM blocks_vector WHERE id = ?", v.ID); err != nil {
+			return
+		}
+		if err = execStmtTx(tx, "INSERT INTO blocks_vector (id, root_id, hash, vector) VALUES (?, ?, ?, ?)", v.ID, v.RootID, v.Hash, encodeVector(v.Vector)); err != nil {
+			return
+		}
+		rootIDs.Add(v.RootID)
+	}
+
+	// 清理文档中已经删除的块的向量
+	for _, rootID := range rootIDs.Values() {
+		stmt := "DELETE FROM blocks_vector WHERE root_id = ? AND id NOT IN (SELECT id FROM blocks WHERE root_id = ?)"
+		if err = execStmtTx(tx, stmt, rootID, rootID); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func encodeVector(vector []float32) []byte {
+	buf := bytes.Buffer{}
+	buf.Grow(len(vector) * 4)
+	b := make([]byte, 4)
+	for _, v := range vector {
+		binary.LittleEndian.PutUint32(b, math.Float32bits(v))
+		buf.Write(b)
+	}
+	return buf.Bytes()
+}
+
+func decodeVector(data []byte) (ret []float32) {
+	ret = make([]float32, len(data)/4)
+	for i := range ret {
+		ret[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+	return
+}
@@ -0,0 +1,70 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+func TestUpsertBlockVectors(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, stmt := range []string{
+		"CREATE TABLE blocks (id, root_id)",
+		"CREATE TABLE blocks_vector (id, root_id, hash, vector)",
+		"INSERT INTO blocks VALUES ('b1', 'r1'), ('b2', 'r1')",
+		"INSERT INTO blocks_vector VALUES ('b1', 'r1', 'old', NULL), ('b3', 'r1', 'h3', NULL), ('b4', 'r2', 'h4', NULL)",
+	} {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tx, _ := db.Begin()
+	if err = upsertBlockVectors(tx, []*BlockVector{{ID: "b1", RootID: "r1", Hash: "h1", Vector: []float32{1}}, {ID: "b2", RootID: "r1", Hash: "h2", Vector: []float32{0, 1}}}); err != nil {
+		t.Fatal(err)
+	}
+	tx.Commit()
+
+	// 更新过期的向量，清理文档中已经删除的块的向量，其他文档不受影响
+	rows, err := db.Query("SELECT id, hash FROM blocks_vector ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for rows.Next() {
+		var id, hash string
+		rows.Scan(&id, &hash)
+		got = append(got, id+":"+hash)
+	}
+	rows.Close()
+	if !reflect.DeepEqual([]string{"b1:h1", "b2:h2", "b4:h4"}, got) {
+		t.Fatalf("unexpected vectors %v", got)
+	}
+
+	tx, _ = db.Begin()
+	defer tx.Rollback()
+	if err = upsertBlockVectors(tx, []*BlockVector{{ID: "b1", RootID: "r1", Hash: "h1"}}); nil == err {
+		t.Fatalf("expected error for empty vector")
+	}
+}
@@ -42,6 +42,7 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/siyuan-note/eventbus"
 	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/search"
 	"github.com/siyuan-note/siyuan/kernel/treenode"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
@@ -63,6 +64,17 @@ func init() {
 			return conn.RegisterFunc("regexp", regex, true)
 		},
 	})
+
+	// 只读连接，仅用于执行外部传入的查询语句
+	sql.Register("sqlite3_read_only", &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := conn.RegisterFunc("regexp", regex, true); err != nil {
+				return err
+			}
+			conn.RegisterAuthorizer(readOnlyAuthorizer)
+			return nil
+		},
+	})
 }
 
 var initDatabaseLock = sync.Mutex{}
@@ -149,7 +161,7 @@ func initDBTables() {
 	if err != nil {
 		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "drop table [blocks_fts] failed: %s", err)
 	}
-	_, err = db.Exec("CREATE VIRTUAL TABLE blocks_fts USING fts5(id UNINDEXED, parent_id UNINDEXED, root_id UNINDEXED, hash UNINDEXED, box UNINDEXED, path UNINDEXED, hpath, name, alias, memo, tag, content, fcontent, markdown UNINDEXED, length UNINDEXED, type UNINDEXED, subtype UNINDEXED, ial, sort UNINDEXED, created UNINDEXED, updated UNINDEXED, tokenize=\"siyuan\")")
+	_, err = db.Exec("CREATE VIRTUAL TABLE blocks_fts USING fts5(id UNINDEXED, parent_id UNINDEXED, root_id UNINDEXED, hash UNINDEXED, box UNINDEXED, path UNINDEXED, hpath, name, alias, memo, tag, content, fcontent, markdown UNINDEXED, length UNINDEXED, type UNINDEXED, subtype UNINDEXED, ial, sort UNINDEXED, created UNINDEXED, updated UNINDEXED, tokens, tokenize=\"siyuan\")")
 	if err != nil {
 		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create table [blocks_fts] failed: %s", err)
 	}
@@ -158,7 +170,7 @@ func initDBTables() {
 	if err != nil {
 		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "drop table [blocks_fts_case_insensitive] failed: %s", err)
 	}
-	_, err = db.Exec("CREATE VIRTUAL TABLE blocks_fts_case_insensitive USING fts5(id UNINDEXED, parent_id UNINDEXED, root_id UNINDEXED, hash UNINDEXED, box UNINDEXED, path UNINDEXED, hpath, name, alias, memo, tag, content, fcontent, markdown UNINDEXED, length UNINDEXED, type UNINDEXED, subtype UNINDEXED, ial, sort UNINDEXED, created UNINDEXED, updated UNINDEXED, tokenize=\"siyuan case_insensitive\")")
+	_, err = db.Exec("CREATE VIRTUAL TABLE blocks_fts_case_insensitive USING fts5(id UNINDEXED, parent_id UNINDEXED, root_id UNINDEXED, hash UNINDEXED, box UNINDEXED, path UNINDEXED, hpath, name, alias, memo, tag, content, fcontent, markdown UNINDEXED, length UNINDEXED, type UNINDEXED, subtype UNINDEXED, ial, sort UNINDEXED, created UNINDEXED, updated UNINDEXED, tokens, tokenize=\"siyuan case_insensitive\")")
 	if err != nil {
 		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create table [blocks_fts_case_insensitive] failed: %s", err)
 	}
@@ -219,6 +231,90 @@ func initDBTables() {
 	if err != nil {
 		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create table [refs] failed: %s", err)
 	}
+
+	_, err = db.Exec("DROP TABLE IF EXISTS av_rows")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "drop table [av_rows] failed: %s", err)
+	}
+	_, err = db.Exec("CREATE TABLE av_rows (id, av_id, block_id, is_detached, content, created, updated)")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create table [av_rows] failed: %s", err)
+	}
+
+	_, err = db.Exec("CREATE INDEX idx_av_rows_av_id ON av_rows(av_id)")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create index [idx_av_rows_av_id] failed: %s", err)
+	}
+
+	_, err = db.Exec("CREATE INDEX idx_av_rows_block_id ON av_rows(block_id)")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create index [idx_av_rows_block_id] failed: %s", err)
+	}
+
+	_, err = db.Exec("DROP TABLE IF EXISTS av_values")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "drop table [av_values] failed: %s", err)
+	}
+	_, err = db.Exec("CREATE TABLE av_values (id, av_id, row_id, key_id, key_name, key_type, content, number, created, updated)")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create table [av_values] failed: %s", err)
+	}
+
+	_, err = db.Exec("CREATE INDEX idx_av_values_av_id ON av_values(av_id)")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create index [idx_av_values_av_id] failed: %s", err)
+	}
+
+	_, err = db.Exec("CREATE INDEX idx_av_values_row_id ON av_values(row_id)")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create index [idx_av_values_row_id] failed: %s", err)
+	}
+
+	_, err = db.Exec("DROP TABLE IF EXISTS blocks_vector")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "drop table [blocks_vector] failed: %s", err)
+	}
+	_, err = db.Exec("CREATE TABLE blocks_vector (id, root_id, hash, vector)")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create table [blocks_vector] failed: %s", err)
+	}
+
+	_, err = db.Exec("CREATE INDEX idx_blocks_vector_id ON blocks_vector(id)")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create index [idx_blocks_vector_id] failed: %s", err)
+	}
+
+	_, err = db.Exec("CREATE INDEX idx_blocks_vector_root_id ON blocks_vector(root_id)")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create index [idx_blocks_vector_root_id] failed: %s", err)
+	}
+
+	_, err = db.Exec("DROP TABLE IF EXISTS blocks_fts_vocab")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "drop table [blocks_fts_vocab] failed: %s", err)
+	}
+	_, err = db.Exec("CREATE VIRTUAL TABLE blocks_fts_vocab USING fts5vocab(blocks_fts, row)")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create table [blocks_fts_vocab] failed: %s", err)
+	}
+
+	_, err = db.Exec("DROP TABLE IF EXISTS blocks_fts_case_insensitive_vocab")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "drop table [blocks_fts_case_insensitive_vocab] failed: %s", err)
+	}
+	_, err = db.Exec("CREATE VIRTUAL TABLE blocks_fts_case_insensitive_vocab USING fts5vocab(blocks_fts_case_insensitive, row)")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create table [blocks_fts_case_insensitive_vocab] failed: %s", err)
+	}
+
+	_, err = db.Exec("DROP TABLE IF EXISTS blocks_fts_trigram")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "drop table [blocks_fts_trigram] failed: %s", err)
+	}
+	_, err = db.Exec("CREATE VIRTUAL TABLE blocks_fts_trigram USING fts5(term, tokenize=\"trigram\")")
+	if err != nil {
+		logging.LogFatalf(logging.ExitCodeReadOnlyDatabase, "create table [blocks_fts_trigram] failed: %s", err)
+	}
 }
 
 func initDBConnection() {
@@ -243,6 +339,8 @@ func initDBConnection() {
 	db.SetMaxIdleConns(20)
 	db.SetMaxOpenConns(20)
 	db.SetConnMaxLifetime(365 * 24 * time.Hour)
+
+	initReadOnlyDBConnection()
 }
 
 var initHistoryDatabaseLock = sync.Mutex{}
@@ -358,6 +456,7 @@ func initAssetContentDBTables() {
 var (
 	caseSensitive  bool
 	indexAssetPath bool
+	tokenizers     []string // 分词流水线，生成的词元写入 blocks_fts 的 tokens 列
 )
 
 func SetCaseSensitive(b bool) {
@@ -373,6 +472,11 @@ func SetIndexAssetPath(b bool) {
 	indexAssetPath = b
 }
 
+// SetTokenizers 设置分词流水线，已经索引的数据需要重建索引后才会生效。
+func SetTokenizers(steps []string) {
+	tokenizers = search.NormalizeTokenizers(steps)
+}
+
 func refsFromTree(tree *parse.Tree) (refs []*Ref, fileAnnotationRefs []*FileAnnotationRef) {
 	ast.Walk(tree.Root, func(n *ast.Node, entering bool) ast.WalkStatus {
 		if entering {
@@ -1161,6 +1265,10 @@ func deleteByRootID(tx *sql.Tx, rootID string, context map[string]interface{}) (
 	if err = execStmtTx(tx, stmt, rootID); err != nil {
 		return
 	}
+	stmt = "DELETE FROM blocks_vector WHERE root_id = ?"
+	if err = execStmtTx(tx, stmt, rootID); err != nil {
+		return
+	}
 	ClearCache()
 	eventbus.Publish(eventbus.EvtSQLDeleteBlocks, context, rootID)
 	return
@@ -1207,6 +1315,10 @@ func batchDeleteByRootIDs(tx *sql.Tx, rootIDs []string, context map[string]inter
 	if err = execStmtTx(tx, stmt); err != nil {
 		return
 	}
+	stmt = "DELETE FROM blocks_vector WHERE root_id IN " + ids
+	if err = execStmtTx(tx, stmt); err != nil {
+		return
+	}
 	ClearCache()
 	eventbus.Publish(eventbus.EvtSQLDeleteBlocks, context, fmt.Sprintf("%d", len(rootIDs)))
 	return
@@ -1486,6 +1598,7 @@ func closeDatabase() (err error) {
 		return
 	}
 
+	closeReadOnlyDatabase()
 	err = db.Close()
 	debug.FreeOSMemory()
 	runtime.GC() // 没有这句的话文件句柄不会释放，后面就无法删除文件
@@ -0,0 +1,220 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/util"
+)
+
+// ReadOnlyQueryResult 描述了只读查询的结果。
+type ReadOnlyQueryResult struct {
+	Columns   []*ReadOnlyQueryColumn   `json:"columns"`   // 列信息
+	Rows      []map[string]interface{} `json:"rows"`      // 行数据
+	Truncated bool                     `json:"truncated"` // 结果行数超过上限时被截断
+}
+
+// ReadOnlyQueryColumn 描述了只读查询结果中的一列。
+type ReadOnlyQueryColumn struct {
+	Name string `json:"name"` // 列名
+	Type string `json:"type"` // 列类型：INTEGER/REAL/TEXT/BLOB/NULL，表中声明了类型时使用声明的类型
+}
+
+const (
+	ReadOnlyQueryDefaultTimeout = 5 * time.Second  // 只读查询默认超时时间
+	ReadOnlyQueryMaxTimeout     = 60 * time.Second // 只读查询最大超时时间
+	ReadOnlyQueryMaxLimit       = 10240            // 只读查询最多返回的行数
+
+	// SQLITE_RECURSIVE 在驱动中没有导出，WITH RECURSIVE 语句需要授权
+	sqliteRecursive = 33
+)
+
+var (
+	readOnlyDB *sql.DB
+
+	ErrReadOnlyStmt = errors.New("only SELECT and WITH statements are allowed")
+)
+
+// readOnlyAuthorizer 仅允许读取数据，其他操作（包括 PRAGMA、ATTACH 等）都会被拒绝。
+func readOnlyAuthorizer(op int, arg1, arg2, arg3 string) int {
+	switch op {
+	case sqlite3.SQLITE_SELECT, sqlite3.SQLITE_READ, sqlite3.SQLITE_FUNCTION, sqliteRecursive:
+		return sqlite3.SQLITE_OK
+	}
+	return sqlite3.SQLITE_DENY
+}
+
+func initReadOnlyDBConnection() {
+	if nil != readOnlyDB {
+		readOnlyDB.Close()
+	}
+
+	dsn := util.DBPath + "?_query_only=true" +
+		"&_mmap_size=2684354560" +
+		"&_cache_size=-20480" +
+		"&_busy_timeout=7000" +
+		"&_case_sensitive_like=OFF"
+	var err error
+	readOnlyDB, err = sql.Open("sqlite3_read_only", dsn)
+	if err != nil {
+		logging.LogErrorf("open read-only database failed: %s", err)
+		return
+	}
+	readOnlyDB.SetMaxIdleConns(4)
+	readOnlyDB.SetMaxOpenConns(4)
+	readOnlyDB.SetConnMaxLifetime(365 * 24 * time.Hour)
+}
+
+func closeReadOnlyDatabase() {
+	if nil == readOnlyDB {
+		return
+	}
+
+	readOnlyDB.Close()
+	readOnlyDB = nil
+}
+
+// QueryReadOnly 使用只读连接执行 SELECT/WITH 语句，args 为绑定参数，最多返回 limit 行，超过 timeout 后中断查询。
+func QueryReadOnly(stmt string, args []interface{}, limit int, timeout time.Duration) (ret *ReadOnlyQueryResult, err error) {
+	stmt = strings.TrimSpace(stmt)
+	if "" == stmt {
+		err = errors.New("statement is empty")
+		return
+	}
+	if !isReadOnlyStmt(stmt) {
+		err = ErrReadOnlyStmt
+		return
+	}
+	if nil == readOnlyDB {
+		err = errors.New("read-only database is not initialized")
+		return
+	}
+	return queryReadOnly(readOnlyDB, stmt, args, limit, timeout)
+}
+
+func queryReadOnly(db *sql.DB, stmt string, args []interface{}, limit int, timeout time.Duration) (ret *ReadOnlyQueryResult, err error) {
+	if 1 > limit || ReadOnlyQueryMaxLimit < limit {
+		limit = ReadOnlyQueryMaxLimit
+	}
+	if 0 >= timeout {
+		timeout = ReadOnlyQueryDefaultTimeout
+	}
+	if ReadOnlyQueryMaxTimeout < timeout {
+		timeout = ReadOnlyQueryMaxTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		err = readOnlyQueryErr(ctx, err, timeout)
+		logging.LogWarnf("read-only sql query [%s] failed: %s", stmt, err)
+		return
+	}
+	defer rows.Close()
+
+	cols, err := rows.ColumnTypes()
+	if err != nil {
+		return
+	}
+
+	ret = &ReadOnlyQueryResult{Columns: []*ReadOnlyQueryColumn{}, Rows: []map[string]interface{}{}}
+	for _, col := range cols {
+		ret.Columns = append(ret.Columns, &ReadOnlyQueryColumn{Name: col.Name(), Type: strings.ToUpper(col.DatabaseTypeName())})
+	}
+
+	for rows.Next() {
+		if limit <= len(ret.Rows) {
+			ret.Truncated = true
+			break
+		}
+
+		columns := make([]interface{}, len(cols))
+		columnPointers := make([]interface{}, len(cols))
+		for i := range columns {
+			columnPointers[i] = &columns[i]
+		}
+
+		if err = rows.Scan(columnPointers...); err != nil {
+			return
+		}
+
+		m := make(map[string]interface{})
+		for i, col := range ret.Columns {
+			m[col.Name] = columns[i]
+			if "" == col.Type && nil != columns[i] {
+				// 表中的列大都没有声明类型，使用第一个非空值的类型
+				col.Type = sqliteValueType(columns[i])
+			}
+		}
+		ret.Rows = append(ret.Rows, m)
+	}
+	if err = rows.Err(); err != nil {
+		err = readOnlyQueryErr(ctx, err, timeout)
+		return
+	}
+
+	for _, col := range ret.Columns {
+		if "" == col.Type {
+			col.Type = "NULL"
+		}
+	}
+	return
+}
+
+func isReadOnlyStmt(stmt string) bool {
+	fields := strings.Fields(stmt)
+	if 1 > len(fields) {
+		return false
+	}
+
+	keyword := strings.ToUpper(strings.TrimLeft(fields[0], "("))
+	return "SELECT" == keyword || "WITH" == keyword
+}
+
+func readOnlyQueryErr(ctx context.Context, err error, timeout time.Duration) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("query timed out after [%s]", timeout)
+	}
+	if strings.Contains(err.Error(), "not authorized") {
+		return ErrReadOnlyStmt
+	}
+	return err
+}
+
+func sqliteValueType(val interface{}) string {
+	switch val.(type) {
+	case int64:
+		return "INTEGER"
+	case float64:
+		return "REAL"
+	case []byte:
+		return "BLOB"
+	case string, time.Time:
+		return "TEXT"
+	}
+	return "NULL"
+}
@@ -0,0 +1,119 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package sql
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newReadOnlyTestDB(t *testing.T) *sql.DB {
+	dbPath := filepath.Join(t.TempDir(), "siyuan.db")
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE blocks (id, type, content, hpath INTEGER)",
+		"INSERT INTO blocks VALUES ('b1', 'p', 'foo', 1), ('b2', 'h', 'bar', 2), ('b3', 'p', 'baz', NULL)",
+	} {
+		if _, err = db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	readOnly, err := sql.Open("sqlite3_read_only", dbPath+"?_query_only=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { readOnly.Close() })
+	return readOnly
+}
+
+func TestQueryReadOnlyAuthorizer(t *testing.T) {
+	db := newReadOnlyTestDB(t)
+
+	if _, err := queryReadOnly(db, "DELETE FROM blocks", nil, 0, 0); ErrReadOnlyStmt != err {
+		t.Fatalf("expected write rejected, got %v", err)
+	}
+	if _, err := queryReadOnly(db, "WITH t AS (SELECT 1) DELETE FROM blocks", nil, 0, 0); ErrReadOnlyStmt != err {
+		t.Fatalf("expected write in WITH rejected, got %v", err)
+	}
+	if _, err := queryReadOnly(db, "SELECT * FROM pragma_table_info('blocks') UNION SELECT load_extension('x')", nil, 0, 0); nil == err {
+		t.Fatalf("expected extension loading rejected")
+	}
+
+	ret, err := queryReadOnly(db, "WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 3) SELECT i FROM n", nil, 0, 0)
+	if err != nil || 3 != len(ret.Rows) {
+		t.Fatalf("expected WITH RECURSIVE allowed, got %v", err)
+	}
+
+	var count int
+	if err = db.QueryRow("SELECT COUNT(*) FROM blocks").Scan(&count); err != nil || 3 != count {
+		t.Fatalf("expected blocks untouched, got %d: %v", count, err)
+	}
+}
+
+func TestQueryReadOnly(t *testing.T) {
+	db := newReadOnlyTestDB(t)
+
+	// 绑定参数
+	ret, err := queryReadOnly(db, "SELECT id, content FROM blocks WHERE type = ? ORDER BY id", []interface{}{"p"}, 0, 0)
+	if err != nil || 2 != len(ret.Rows) || "b1" != ret.Rows[0]["id"] || "b3" != ret.Rows[1]["id"] || ret.Truncated {
+		t.Fatalf("unexpected bound args result %v: %v", ret, err)
+	}
+	if ret, err = queryReadOnly(db, "SELECT id FROM blocks WHERE type = ?", []interface{}{"p' OR '1' = '1"}, 0, 0); err != nil || 0 != len(ret.Rows) {
+		t.Fatalf("expected bound args not interpolated, got %v: %v", ret, err)
+	}
+
+	// 返回行数上限
+	ret, err = queryReadOnly(db, "SELECT id FROM blocks ORDER BY id", nil, 2, 0)
+	if err != nil || 2 != len(ret.Rows) || !ret.Truncated {
+		t.Fatalf("expected result truncated to 2 rows, got %v: %v", ret, err)
+	}
+
+	// 列信息：声明了类型的列使用声明的类型，否则使用第一个非空值的类型
+	ret, err = queryReadOnly(db, "SELECT id, hpath, 1.5 AS score, NULL AS empty FROM blocks ORDER BY id", nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, col := range ret.Columns {
+		types = append(types, col.Name+":"+col.Type)
+	}
+	if "id:TEXT,hpath:INTEGER,score:REAL,empty:NULL" != strings.Join(types, ",") {
+		t.Fatalf("unexpected columns %v", types)
+	}
+}
+
+func TestQueryReadOnlyTimeout(t *testing.T) {
+	db := newReadOnlyTestDB(t)
+
+	stmt := "WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n) SELECT COUNT(*) FROM n"
+	start := time.Now()
+	_, err := queryReadOnly(db, stmt, nil, 0, 50*time.Millisecond)
+	if nil == err || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if 5*time.Second < time.Since(start) {
+		t.Fatalf("query not interrupted in time")
+	}
+}
@@ -40,17 +40,19 @@ var (
 
 type dbQueueOperation struct {
 	inQueueTime                   time.Time
-	action                        string      // upsert/delete/delete_id/rename/rename_sub_tree/delete_box/delete_box_refs/index/delete_ids/update_block_content/delete_assets
-	indexTree                     *parse.Tree // index
-	upsertTree                    *parse.Tree // upsert/update_refs/delete_refs
-	removeTreeBox, removeTreePath string      // delete
-	removeTreeID                  string      // delete_id
-	removeTreeIDs                 []string    // delete_ids
-	box                           string      // delete_box/delete_box_refs/index
-	renameTree                    *parse.Tree // rename/rename_sub_tree
-	block                         *Block      // update_block_content
-	id                            string      // index_node
-	removeAssetHashes             []string    // delete_assets
+	action                        string         // upsert/delete/delete_id/rename/rename_sub_tree/delete_box/delete_box_refs/index/delete_ids/update_block_content/delete_assets/index_av/upsert_vectors
+	indexTree                     *parse.Tree    // index
+	upsertTree                    *parse.Tree    // upsert/update_refs/delete_refs
+	removeTreeBox, removeTreePath string         // delete
+	removeTreeID                  string         // delete_id
+	removeTreeIDs                 []string       // delete_ids
+	box                           string         // delete_box/delete_box_refs/index
+	renameTree                    *parse.Tree    // rename/rename_sub_tree
+	block                         *Block         // update_block_content
+	id                            string         // index_node
+	removeAssetHashes             []string       // delete_assets
+	avID                          string         // index_av
+	vectors                       []*BlockVector // upsert_vectors
 }
 
 func FlushTxJob() {
@@ -110,6 +112,7 @@ func FlushQueue() {
 			logging.LogErrorf("commit tx failed: %s", err)
 			continue
 		}
+		markFuzzyTermsDirty(op.action)
 
 		if 16 < i && 0 == i%128 {
 			debug.FreeOSMemory()
@@ -120,6 +123,8 @@ func FlushQueue() {
 		debug.FreeOSMemory()
 	}
 
+	flushFuzzyTerms()
+
 	elapsed := time.Now().Sub(start).Milliseconds()
 	if 7000 < elapsed {
 		logging.LogInfof("database op tx [%dms]", elapsed)
@@ -165,6 +170,10 @@ func execOp(op *dbQueueOperation, tx *sql.Tx, context map[string]interface{}) (e
 		err = deleteAssetsByHashes(tx, op.removeAssetHashes)
 	case "index_node":
 		err = indexNode(tx, op.id)
+	case "index_av":
+		err = indexAttributeView(tx, op.avID)
+	case "upsert_vectors":
+		err = upsertBlockVectors(tx, op.vectors)
 	default:
 		msg := fmt.Sprintf("unknown operation [%s]", op.action)
 		logging.LogErrorf(msg)
@@ -187,6 +196,21 @@ func IndexNodeQueue(id string) {
 	appendOperation(newOp)
 }
 
+// IndexAttributeViewQueue 重建属性视图的 av_rows 和 av_values 索引，属性视图文件不存在时仅删除索引。
+func IndexAttributeViewQueue(avID string) {
+	dbQueueLock.Lock()
+	defer dbQueueLock.Unlock()
+
+	newOp := &dbQueueOperation{avID: avID, inQueueTime: time.Now(), action: "index_av"}
+	for i, op := range operationQueue {
+		if "index_av" == op.action && op.avID == avID {
+			operationQueue[i] = newOp
+			return
+		}
+	}
+	appendOperation(newOp)
+}
+
 func BatchRemoveAssetsQueue(hashes []string) {
 	if 1 > len(hashes) {
 		return
@@ -33,6 +33,7 @@ import (
 	ignore "github.com/sabhiram/go-gitignore"
 	"github.com/siyuan-note/eventbus"
 	"github.com/siyuan-note/logging"
+	"github.com/siyuan-note/siyuan/kernel/search"
 	"github.com/siyuan-note/siyuan/kernel/util"
 )
 
@@ -44,9 +45,10 @@ func init() {
 
 const (
 	BlocksInsert                   = "INSERT INTO blocks (id, parent_id, root_id, hash, box, path, hpath, name, alias, memo, tag, content, fcontent, markdown, length, type, subtype, ial, sort, created, updated) VALUES %s"
-	BlocksFTSInsert                = "INSERT INTO blocks_fts (id, parent_id, root_id, hash, box, path, hpath, name, alias, memo, tag, content, fcontent, markdown, length, type, subtype, ial, sort, created, updated) VALUES %s"
-	BlocksFTSCaseInsensitiveInsert = "INSERT INTO blocks_fts_case_insensitive (id, parent_id, root_id, hash, box, path, hpath, name, alias, memo, tag, content, fcontent, markdown, length, type, subtype, ial, sort, created, updated) VALUES %s"
+	BlocksFTSInsert                = "INSERT INTO blocks_fts (id, parent_id, root_id, hash, box, path, hpath, name, alias, memo, tag, content, fcontent, markdown, length, type, subtype, ial, sort, created, updated, tokens) VALUES %s"
+	BlocksFTSCaseInsensitiveInsert = "INSERT INTO blocks_fts_case_insensitive (id, parent_id, root_id, hash, box, path, hpath, name, alias, memo, tag, content, fcontent, markdown, length, type, subtype, ial, sort, created, updated, tokens) VALUES %s"
 	BlocksPlaceholder              = "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	BlocksFTSPlaceholder           = "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
 
 	SpansInsert      = "INSERT INTO spans (id, block_id, root_id, box, path, content, markdown, type, ial) VALUES %s"
 	SpansPlaceholder = "(?, ?, ?, ?, ?, ?, ?, ?, ?)"
@@ -85,9 +87,12 @@ func insertBlocks(tx *sql.Tx, blocks []*Block, context map[string]interface{}) (
 func insertBlocks0(tx *sql.Tx, bulk []*Block, context map[string]interface{}) (err error) {
 	valueStrings := make([]string, 0, len(bulk))
 	valueArgs := make([]interface{}, 0, len(bulk)*strings.Count(BlocksPlaceholder, "?"))
+	ftsValueStrings := make([]string, 0, len(bulk))
+	ftsValueArgs := make([]interface{}, 0, len(bulk)*strings.Count(BlocksFTSPlaceholder, "?"))
 	hashBuf := bytes.Buffer{}
 	for _, b := range bulk {
 		valueStrings = append(valueStrings, BlocksPlaceholder)
+		argsStart := len(valueArgs)
 		valueArgs = append(valueArgs, b.ID)
 		valueArgs = append(valueArgs, b.ParentID)
 		valueArgs = append(valueArgs, b.RootID)
@@ -109,6 +114,9 @@ func insertBlocks0(tx *sql.Tx, bulk []*Block, context map[string]interface{}) (e
 		valueArgs = append(valueArgs, b.Sort)
 		valueArgs = append(valueArgs, b.Created)
 		valueArgs = append(valueArgs, b.Updated)
+		ftsValueStrings = append(ftsValueStrings, BlocksFTSPlaceholder)
+		ftsValueArgs = append(ftsValueArgs, valueArgs[argsStart:]...)
+		ftsValueArgs = append(ftsValueArgs, blockTokens(b))
 		putBlockCache(b)
 
 		hashBuf.WriteString(b.Hash)
@@ -123,14 +131,14 @@ func insertBlocks0(tx *sql.Tx, bulk []*Block, context map[string]interface{}) (e
 	// 使用下面的 EvtSQLInsertBlocksFTS 就可以了
 	//eventbus.Publish(eventbus.EvtSQLInsertBlocks, context, current, total, len(bulk), evtHash)
 
-	stmt = fmt.Sprintf(BlocksFTSInsert, strings.Join(valueStrings, ","))
-	if err = prepareExecInsertTx(tx, stmt, valueArgs); err != nil {
+	stmt = fmt.Sprintf(BlocksFTSInsert, strings.Join(ftsValueStrings, ","))
+	if err = prepareExecInsertTx(tx, stmt, ftsValueArgs); err != nil {
 		return
 	}
 
 	if !caseSensitive {
-		stmt = fmt.Sprintf(BlocksFTSCaseInsensitiveInsert, strings.Join(valueStrings, ","))
-		if err = prepareExecInsertTx(tx, stmt, valueArgs); err != nil {
+		stmt = fmt.Sprintf(BlocksFTSCaseInsensitiveInsert, strings.Join(ftsValueStrings, ","))
+		if err = prepareExecInsertTx(tx, stmt, ftsValueArgs); err != nil {
 			return
 		}
 	}
@@ -140,6 +148,14 @@ func insertBlocks0(tx *sql.Tx, bulk []*Block, context map[string]interface{}) (e
 	return
 }
 
+// blockTokens 使用分词流水线切分块的内容、命名、别名、备注和标签，未配置分词流水线时返回空。
+func blockTokens(b *Block) string {
+	if 1 > len(tokenizers) {
+		return ""
+	}
+	return search.TokenizeText(strings.Join([]string{b.Content, b.Name, b.Alias, b.Memo, b.Tag}, " "), tokenizers)
+}
+
 func insertAttributes(tx *sql.Tx, attributes []*Attribute) (err error) {
 	if 1 > len(attributes) {
 		return
@@ -392,7 +408,10 @@ func insertFileAnnotationRefs0(tx *sql.Tx, bulk []*FileAnnotationRef) (err error
 func indexTree(tx *sql.Tx, tree *parse.Tree, context map[string]interface{}) (err error) {
 	blocks, spans, assets, attributes := fromTree(tree.Root, tree)
 	refs, fileAnnotationRefs := refsFromTree(tree)
-	err = insertTree0(tx, tree, context, blocks, spans, assets, attributes, refs, fileAnnotationRefs)
+	if err = insertTree0(tx, tree, context, blocks, spans, assets, attributes, refs, fileAnnotationRefs); err != nil {
+		return
+	}
+	enqueueVectorBlocks(blocks)
 	return
 }
 
@@ -449,6 +468,8 @@ func upsertTree(tx *sql.Tx, tree *parse.Tree, context map[string]interface{}) (e
 	if err = insertTree0(tx, tree, context, blocks, spans, assets, attributes, refs, fileAnnotationRefs); err != nil {
 		return
 	}
+	// 仅内容发生变化的块需要重新生成向量
+	enqueueVectorBlocks(blocks)
 	return err
 }
 
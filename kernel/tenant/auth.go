@@ -0,0 +1,68 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tenant
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUserTokenDisabled = errors.New("user token secret is not set")
+	ErrInvalidUserToken  = errors.New("invalid user token")
+)
+
+var (
+	userTokenSecret     []byte
+	userTokenSecretLock = sync.RWMutex{}
+)
+
+// SetUserTokenSecret 设置校验用户 token 的 HMAC 密钥，密钥为空时不能通过用户 token 进入多用户模式。
+func SetUserTokenSecret(secret string) {
+	userTokenSecretLock.Lock()
+	defer userTokenSecretLock.Unlock()
+
+	userTokenSecret = []byte(secret)
+}
+
+// ParseUserToken 校验用户 token 并返回其中的 userNo。
+//
+// 用户 token 为使用 HS256 签名的 JWT，sub 为 userNo，必须设置过期时间 exp。
+func ParseUserToken(token string) (userNo string, err error) {
+	userTokenSecretLock.RLock()
+	secret := userTokenSecret
+	userTokenSecretLock.RUnlock()
+	if 1 > len(secret) {
+		err = ErrUserTokenDisabled
+		return
+	}
+
+	parsed, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		return secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		err = ErrInvalidUserToken
+		return
+	}
+
+	if userNo, err = parsed.Claims.GetSubject(); err != nil || !IsValidUserNo(userNo) {
+		return "", ErrInvalidUserToken
+	}
+	return
+}
@@ -0,0 +1,64 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tenant
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func signUserToken(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+	ret, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("sign user token failed: %s", err)
+	}
+	return ret
+}
+
+func TestParseUserToken(t *testing.T) {
+	secret := []byte("user-token-secret")
+	exp := time.Now().Add(time.Hour).Unix()
+	valid := signUserToken(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"sub": "alice", "exp": exp})
+
+	SetUserTokenSecret("")
+	if _, err := ParseUserToken(valid); ErrUserTokenDisabled != err {
+		t.Fatalf("expected user token disabled, got %v", err)
+	}
+
+	SetUserTokenSecret(string(secret))
+	defer SetUserTokenSecret("")
+	if userNo, err := ParseUserToken(valid); err != nil || "alice" != userNo {
+		t.Fatalf("expected user alice, got [%s]: %v", userNo, err)
+	}
+
+	tests := map[string]string{
+		"plain userNo":   "alice",
+		"wrong secret":   signUserToken(t, jwt.SigningMethodHS256, []byte("other-secret"), jwt.MapClaims{"sub": "alice", "exp": exp}),
+		"expired":        signUserToken(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(-time.Minute).Unix()}),
+		"no expiration":  signUserToken(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"sub": "alice"}),
+		"none algorithm": signUserToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, jwt.MapClaims{"sub": "alice", "exp": exp}),
+		"invalid userNo": signUserToken(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"sub": "../bob", "exp": exp}),
+		"no userNo":      signUserToken(t, jwt.SigningMethodHS256, secret, jwt.MapClaims{"exp": exp}),
+	}
+	for name, token := range tests {
+		if userNo, err := ParseUserToken(token); ErrInvalidUserToken != err {
+			t.Fatalf("[%s] expected invalid user token, got [%s]: %v", name, userNo, err)
+		}
+	}
+}
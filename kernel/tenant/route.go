@@ -0,0 +1,103 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tenant
+
+import (
+	"github.com/88250/gulu"
+)
+
+var (
+	// boxScopedRoutes 为通过参数中的笔记本 ID 或者块 ID 确定访问范围的接口，请求时必须在参数中指定笔记本或者块
+	boxScopedRoutes = []string{
+		"/api/transactions",
+		"/api/notebook/openNotebook", "/api/notebook/closeNotebook", "/api/notebook/getNotebookConf", "/api/notebook/setNotebookConf",
+		"/api/notebook/removeNotebook", "/api/notebook/renameNotebook", "/api/notebook/setNotebookIcon", "/api/notebook/getNotebookInfo",
+		"/api/filetree/listDocsByPath", "/api/filetree/listDocTree", "/api/filetree/getDoc", "/api/filetree/getDocCreateSavePath", "/api/filetree/getRefCreateSavePath",
+		"/api/filetree/changeSort", "/api/filetree/createDocWithMd", "/api/filetree/createDailyNote", "/api/filetree/createDoc", "/api/filetree/renameDoc",
+		"/api/filetree/renameDocByID", "/api/filetree/removeDoc", "/api/filetree/duplicateDoc", "/api/filetree/getHPathByPath", "/api/filetree/getHPathByID",
+		"/api/filetree/getPathByID", "/api/filetree/getFullHPathByID", "/api/filetree/getIDsByHPath",
+		"/api/outline/getDocOutline",
+		"/api/block/getBlockInfo", "/api/block/getBlockDOM", "/api/block/getBlockKramdown", "/api/block/getChildBlocks", "/api/block/getTailChildBlocks",
+		"/api/block/getBlockBreadcrumb", "/api/block/getBlockIndex", "/api/block/getBlocksIndexes", "/api/block/getRefText", "/api/block/getDOMText",
+		"/api/block/getTreeStat", "/api/block/getBlocksWordCount", "/api/block/getDocInfo", "/api/block/getDocsInfo", "/api/block/checkBlockExist",
+		"/api/block/checkBlockFold", "/api/block/getBlockSiblingID", "/api/block/getBlockTreeInfos", "/api/block/getHeadingChildrenIDs", "/api/block/getHeadingChildrenDOM",
+		"/api/block/insertBlock", "/api/block/prependBlock", "/api/block/appendBlock", "/api/block/appendDailyNoteBlock", "/api/block/prependDailyNoteBlock",
+		"/api/block/updateBlock", "/api/block/deleteBlock", "/api/block/moveBlock", "/api/block/foldBlock", "/api/block/unfoldBlock",
+		"/api/attr/getBlockAttrs", "/api/attr/batchGetBlockAttrs", "/api/attr/setBlockAttrs", "/api/attr/batchSetBlockAttrs", "/api/attr/resetBlockAttrs",
+	}
+
+	// boxFilteredRoutes 为在返回结果时按照可以访问的笔记本过滤的接口，参数中可以不指定笔记本
+	boxFilteredRoutes = []string{"/api/notebook/lsNotebooks", "/api/search/fullTextSearchBlock", "/api/search/searchRefBlock"}
+
+	// userRoutes 为多用户模式下的用户还可以访问的接口，这些接口不读取已有笔记本的数据，或者只读写用户自己的工作空间和用户级配置
+	userRoutes = []string{
+		"/api/notebook/createNotebook", "/api/asset/upload", "/api/system/version", "/api/system/currentTime", "/api/system/getConf",
+		"/api/setting/setEditor", "/api/setting/setEditorReadOnly", "/api/setting/setAppearance", "/api/setting/setSearch", "/api/setting/setFlashcard",
+		"/api/setting/setExport", "/api/setting/setKeymap", "/api/setting/setAI", "/api/setting/setEmoji",
+		"/api/setting/addVirtualBlockRefExclude", "/api/setting/addVirtualBlockRefInclude",
+	}
+)
+
+// sharedDataArgKeys 为请求参数中表示整个工作空间共用数据的键，比如事务中操作属性视图和闪卡的参数
+var sharedDataArgKeys = []string{"avID", "deckID"}
+
+// HasSharedDataArg 判断请求参数中是否涉及属性视图、闪卡等整个工作空间共用的数据，用户不能通过事务等接口读写这些数据。
+func HasSharedDataArg(arg interface{}) bool {
+	return hasSharedDataArg(arg, 0)
+}
+
+func hasSharedDataArg(arg interface{}, depth int) bool {
+	if 8 < depth {
+		return false
+	}
+
+	switch v := arg.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			if gulu.Str.Contains(key, sharedDataArgKeys) || hasSharedDataArg(val, depth+1) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, val := range v {
+			if hasSharedDataArg(val, depth+1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsBoxScopedRoute 判断接口是否通过参数中的笔记本 ID 或者块 ID 确定访问范围。
+func IsBoxScopedRoute(path string) bool {
+	return gulu.Str.Contains(path, boxScopedRoutes)
+}
+
+// IsBoxFilteredRoute 判断接口是否在返回结果时按照可以访问的笔记本过滤。
+func IsBoxFilteredRoute(path string) bool {
+	return gulu.Str.Contains(path, boxFilteredRoutes)
+}
+
+// IsRouteAllowed 判断用户是否可以访问接口，默认用户可以访问所有接口。
+//
+// 其他用户只能访问通过笔记本确定访问范围的接口、按笔记本过滤结果的接口以及 userRoutes 中的接口，没有列出的接口都不能访问。
+func (t *Tenant) IsRouteAllowed(path string) bool {
+	if t.IsDefault() {
+		return true
+	}
+	return IsBoxScopedRoute(path) || IsBoxFilteredRoute(path) || gulu.Str.Contains(path, userRoutes)
+}
@@ -0,0 +1,71 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tenant
+
+import (
+	"testing"
+)
+
+func TestIsRouteAllowed(t *testing.T) {
+	alice, _ := setupWorkspace(t)
+
+	for _, path := range []string{"/api/block/getBlockKramdown", "/api/filetree/listDocsByPath", "/api/search/fullTextSearchBlock", "/api/search/searchRefBlock", "/api/notebook/createNotebook",
+		"/api/system/getConf", "/api/setting/setEditor", "/api/setting/setSearch"} {
+		if !alice.IsRouteAllowed(path) {
+			t.Fatalf("route [%s] should be allowed", path)
+		}
+	}
+
+	// 可以跨笔记本读取数据的接口和没有列出的接口都不能访问
+	for _, path := range []string{"/api/query/sql", "/api/av/renderAttributeView", "/api/av/getAttributeView", "/api/riff/getRiffDecks", "/api/history/searchHistory",
+		"/api/search/getSearchHistory", "/api/file/getFile", "/api/export/exportMdContent", "/api/system/setAPIToken", "/api/setting/setSync", "/api/block/getBlockKramdownX", "/api/unknown"} {
+		if alice.IsRouteAllowed(path) {
+			t.Fatalf("route [%s] should not be allowed", path)
+		}
+	}
+
+	// 默认用户不受限制
+	if !Default().IsRouteAllowed("/api/query/sql") || !Default().IsRouteAllowed("/api/av/renderAttributeView") {
+		t.Fatalf("default user should access all routes")
+	}
+
+	if !IsBoxScopedRoute("/api/block/getBlockKramdown") || IsBoxScopedRoute("/api/search/fullTextSearchBlock") || !IsBoxFilteredRoute("/api/search/fullTextSearchBlock") {
+		t.Fatalf("unexpected route scope")
+	}
+}
+
+func TestHasSharedDataArg(t *testing.T) {
+	tx := func(op map[string]interface{}) interface{} {
+		return map[string]interface{}{"transactions": []interface{}{
+			map[string]interface{}{"doOperations": []interface{}{map[string]interface{}{"action": "update", "id": "20210808180117-6v0mkxr"}, op}},
+		}}
+	}
+
+	// 属性视图和闪卡由整个工作空间共用，事务中不能操作
+	for _, op := range []map[string]interface{}{
+		{"action": "updateAttrViewCell", "avID": "20210808180117-czj9bvb", "rowID": "20210808180117-abcdefg"},
+		{"action": "addFlashcards", "deckID": "20230218211946-2kw8jgx", "blockIDs": []interface{}{"20210808180117-6v0mkxr"}},
+	} {
+		if !HasSharedDataArg(tx(op)) {
+			t.Fatalf("expected shared data in op %v", op)
+		}
+	}
+
+	if HasSharedDataArg(tx(map[string]interface{}{"action": "delete", "id": "20210808180117-abcdefg"})) || HasSharedDataArg(nil) {
+		t.Fatalf("expected no shared data")
+	}
+}
@@ -0,0 +1,228 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+// Package tenant 实现了多用户模式下的用户工作空间隔离。
+//
+// 多用户模式下每个用户（userNo）拥有独立的数据目录 data/<userNo>/、历史目录 history/<userNo>/ 和临时目录 temp/users/<userNo>/，
+// 笔记本、资源文件和数据历史通过 Tenant 解析路径。没有 userNo 时为默认用户，路径和单用户模式一致。
+//
+// 块树、数据库索引、属性视图和闪卡仍然由整个工作空间共用，没有按用户拆分，用户之间的隔离通过限制可以访问的接口实现：
+// 用户只能访问通过笔记本确定访问范围或者按笔记本过滤结果的接口（见 IsRouteAllowed），不能访问属性视图、闪卡、SQL 查询等可以跨笔记本读取数据的接口，
+// 事务等接口的参数中也不能涉及属性视图和闪卡（见 HasSharedDataArg）。
+package tenant
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/88250/gulu"
+	"github.com/88250/lute/ast"
+)
+
+// NoBox 为一个不存在的笔记本 ID，限制搜索范围后没有可以访问的笔记本时使用，保证搜索不到结果。
+const NoBox = "-"
+
+var (
+	ErrInvalidUserNo = errors.New("invalid user no")
+	ErrOutsideTenant = errors.New("path is outside of the user workspace")
+)
+
+var (
+	dataDir, historyDir, tempDir string
+	workspaceLock                = sync.RWMutex{}
+
+	userNoPattern = regexp.MustCompile("^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$")
+
+	// 数据目录下的保留目录，用户目录不能和它们重名
+	reservedNames = []string{"assets", "emojis", "plugins", "public", "snippets", "storage", "templates", "widgets"}
+)
+
+// Tenant 描述了一个用户的工作空间。
+type Tenant struct {
+	UserNo string // 用户编号，为空时为默认用户
+
+	DataDir    string // 数据目录，笔记本直接存放在该目录下
+	AssetsDir  string // 资源文件目录
+	StorageDir string // 存储目录
+	HistoryDir string // 数据历史目录
+	TempDir    string // 临时目录
+	ConfPath   string // 用户级配置文件路径，默认用户使用工作空间配置文件 conf/conf.json，不使用该路径
+}
+
+// SetWorkspace 设置工作空间的数据目录、历史目录和临时目录，切换工作空间后需要重新设置。
+func SetWorkspace(data, history, temp string) {
+	workspaceLock.Lock()
+	defer workspaceLock.Unlock()
+
+	dataDir, historyDir, tempDir = data, history, temp
+}
+
+// IsValidUserNo 判断 userNo 是否可以作为用户目录名。
+//
+// userNo 只能包含字母、数字、下划线和减号，并且不能是块 ID（避免和默认用户的笔记本目录冲突）或者数据目录下的保留目录名。
+func IsValidUserNo(userNo string) bool {
+	if !userNoPattern.MatchString(userNo) || ast.IsNodeIDPattern(userNo) {
+		return false
+	}
+	return !gulu.Str.Contains(strings.ToLower(userNo), reservedNames)
+}
+
+// Get 返回 userNo 对应的用户工作空间，userNo 为空时返回默认用户。
+func Get(userNo string) (ret *Tenant, err error) {
+	if "" != userNo && !IsValidUserNo(userNo) {
+		err = ErrInvalidUserNo
+		return
+	}
+
+	workspaceLock.RLock()
+	defer workspaceLock.RUnlock()
+
+	ret = &Tenant{
+		UserNo:     userNo,
+		DataDir:    filepath.Join(dataDir, userNo),
+		HistoryDir: filepath.Join(historyDir, userNo),
+		TempDir:    tempDir,
+	}
+	if "" != userNo {
+		ret.TempDir = filepath.Join(tempDir, "users", userNo)
+	}
+
+	ret.AssetsDir = filepath.Join(ret.DataDir, "assets")
+	ret.StorageDir = filepath.Join(ret.DataDir, "storage")
+	ret.ConfPath = filepath.Join(ret.StorageDir, "conf.json")
+	return
+}
+
+// Default 返回默认用户的工作空间。
+func Default() *Tenant {
+	ret, _ := Get("")
+	return ret
+}
+
+// IsDefault 判断是否是默认用户，默认用户不受多用户隔离限制。
+func (t *Tenant) IsDefault() bool {
+	return "" == t.UserNo
+}
+
+// Init 创建用户工作空间的目录。
+func (t *Tenant) Init() (err error) {
+	for _, dir := range []string{t.DataDir, t.AssetsDir, t.StorageDir, t.HistoryDir, t.TempDir} {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Resolve 返回数据目录下相对路径 relPath 的绝对路径，relPath 不能通过 .. 等方式跳出数据目录。
+func (t *Tenant) Resolve(relPath string) (ret string, err error) {
+	ret = filepath.Join(t.DataDir, filepath.FromSlash(relPath))
+	if !t.contains(ret) {
+		return "", ErrOutsideTenant
+	}
+	return
+}
+
+// ResolveHistory 返回历史目录下相对路径 relPath 的绝对路径，relPath 不能通过 .. 等方式跳出历史目录。
+func (t *Tenant) ResolveHistory(relPath string) (ret string, err error) {
+	ret = filepath.Join(t.HistoryDir, filepath.FromSlash(relPath))
+	if ret != t.HistoryDir && !strings.HasPrefix(ret, t.HistoryDir+string(filepath.Separator)) {
+		return "", ErrOutsideTenant
+	}
+	return
+}
+
+// OwnsBox 判断笔记本是否属于该用户，即笔记本目录是否在该用户的数据目录下。
+func (t *Tenant) OwnsBox(boxID string) bool {
+	if !ast.IsNodeIDPattern(boxID) {
+		return false
+	}
+	return gulu.File.IsDir(filepath.Join(t.DataDir, boxID))
+}
+
+// Boxes 返回该用户的所有笔记本 ID。
+func (t *Tenant) Boxes() (ret []string) {
+	ret = []string{}
+	entries, err := os.ReadDir(t.DataDir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() && ast.IsNodeIDPattern(entry.Name()) {
+			ret = append(ret, entry.Name())
+		}
+	}
+	return
+}
+
+// CanAccessBoxes 判断该用户是否可以访问所有笔记本，默认用户总是可以访问。
+func (t *Tenant) CanAccessBoxes(boxIDs []string) bool {
+	if t.IsDefault() {
+		return true
+	}
+
+	for _, boxID := range boxIDs {
+		if !t.OwnsBox(boxID) {
+			return false
+		}
+	}
+	return true
+}
+
+// RestrictBoxes 将搜索的笔记本范围限制在该用户的笔记本内，boxes 为空时表示该用户的所有笔记本。
+//
+// 结果为空时返回 NoBox，因为调用方将空的笔记本范围视为不限制。默认用户不受限制，直接返回 boxes。
+func (t *Tenant) RestrictBoxes(boxes []string) (ret []string) {
+	if t.IsDefault() {
+		return boxes
+	}
+
+	if 1 > len(boxes) {
+		ret = t.Boxes()
+	} else {
+		ret = []string{}
+		for _, box := range boxes {
+			if t.OwnsBox(box) {
+				ret = append(ret, box)
+			}
+		}
+	}
+	if 1 > len(ret) {
+		ret = append(ret, NoBox)
+	}
+	return
+}
+
+func (t *Tenant) contains(absPath string) bool {
+	if absPath == t.DataDir {
+		return true
+	}
+	if !strings.HasPrefix(absPath, t.DataDir+string(filepath.Separator)) {
+		return false
+	}
+	if !t.IsDefault() {
+		return true
+	}
+
+	// 默认用户的数据目录包含了其他用户的目录
+	first := strings.Split(strings.TrimPrefix(absPath, t.DataDir+string(filepath.Separator)), string(filepath.Separator))[0]
+	return !IsValidUserNo(first) || !gulu.File.IsDir(filepath.Join(t.DataDir, first))
+}
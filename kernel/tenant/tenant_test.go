@@ -0,0 +1,164 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package tenant
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	aliceBox   = "20240101120000-alice01"
+	bobBox     = "20240101120000-bob0001"
+	defaultBox = "20240101120000-default"
+)
+
+func setupWorkspace(t *testing.T) (alice, bob *Tenant) {
+	workspace := t.TempDir()
+	SetWorkspace(filepath.Join(workspace, "data"), filepath.Join(workspace, "history"), filepath.Join(workspace, "temp"))
+
+	var err error
+	if alice, err = Get("alice"); err != nil {
+		t.Fatalf("get tenant failed: %s", err)
+	}
+	if bob, err = Get("bob"); err != nil {
+		t.Fatalf("get tenant failed: %s", err)
+	}
+	for _, tenant := range []*Tenant{alice, bob, Default()} {
+		if err = tenant.Init(); err != nil {
+			t.Fatalf("init tenant failed: %s", err)
+		}
+	}
+
+	for _, box := range []string{filepath.Join(alice.DataDir, aliceBox), filepath.Join(bob.DataDir, bobBox), filepath.Join(Default().DataDir, defaultBox)} {
+		if err = os.MkdirAll(box, 0755); err != nil {
+			t.Fatalf("create box failed: %s", err)
+		}
+	}
+	return
+}
+
+func TestIsValidUserNo(t *testing.T) {
+	for _, userNo := range []string{"alice", "user_01", "A-1"} {
+		if !IsValidUserNo(userNo) {
+			t.Fatalf("user no [%s] should be valid", userNo)
+		}
+	}
+
+	for _, userNo := range []string{"", "..", "../bob", "a/b", "a\\b", ".siyuan", "assets", "Storage", aliceBox, strings.Repeat("a", 65)} {
+		if IsValidUserNo(userNo) {
+			t.Fatalf("user no [%s] should be invalid", userNo)
+		}
+		if _, err := Get(userNo); "" != userNo && ErrInvalidUserNo != err {
+			t.Fatalf("get tenant [%s] should fail", userNo)
+		}
+	}
+}
+
+func TestPathsIsolated(t *testing.T) {
+	alice, bob := setupWorkspace(t)
+
+	alicePaths := []string{alice.DataDir, alice.AssetsDir, alice.StorageDir, alice.HistoryDir, alice.TempDir, alice.ConfPath}
+	bobPaths := []string{bob.DataDir, bob.AssetsDir, bob.StorageDir, bob.HistoryDir, bob.TempDir, bob.ConfPath}
+	def := Default()
+	defaultPaths := []string{def.AssetsDir, def.StorageDir, def.HistoryDir, def.TempDir, def.ConfPath}
+	for i, alicePath := range alicePaths {
+		bobPath := bobPaths[i]
+		if alicePath == bobPath || strings.HasPrefix(alicePath, bobPath+string(filepath.Separator)) || strings.HasPrefix(bobPath, alicePath+string(filepath.Separator)) {
+			t.Fatalf("paths [%s] and [%s] are not isolated", alicePath, bobPath)
+		}
+		for _, defaultPath := range defaultPaths {
+			if alicePath == defaultPath || bobPath == defaultPath {
+				t.Fatalf("path [%s] is shared with the default user", defaultPath)
+			}
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	alice, _ := setupWorkspace(t)
+
+	p, err := alice.Resolve("assets/image.png")
+	if err != nil || filepath.Join(alice.AssetsDir, "image.png") != p {
+		t.Fatalf("resolve failed: %s, %s", p, err)
+	}
+
+	for _, relPath := range []string{"../bob/" + bobBox + "/doc.sy", "../../conf/conf.json", "/../../bob/assets/image.png"} {
+		if p, err = alice.Resolve(relPath); ErrOutsideTenant != err {
+			t.Fatalf("path [%s] resolved outside of the user workspace: %s", relPath, p)
+		}
+	}
+
+	if p, err = alice.ResolveHistory("../bob/2024-01-01-120000-update"); ErrOutsideTenant != err {
+		t.Fatalf("history path resolved outside of the user workspace: %s", p)
+	}
+
+	// 默认用户也不能访问其他用户的目录
+	if p, err = Default().Resolve("bob/" + bobBox + "/doc.sy"); ErrOutsideTenant != err {
+		t.Fatalf("default user resolved a user path: %s", p)
+	}
+	if _, err = Default().Resolve(defaultBox + "/doc.sy"); err != nil {
+		t.Fatalf("resolve failed: %s", err)
+	}
+}
+
+func TestBoxOwnership(t *testing.T) {
+	alice, bob := setupWorkspace(t)
+
+	if !alice.OwnsBox(aliceBox) || alice.OwnsBox(bobBox) || alice.OwnsBox(defaultBox) {
+		t.Fatalf("alice owns wrong boxes")
+	}
+	if !bob.CanAccessBoxes([]string{bobBox}) || bob.CanAccessBoxes([]string{bobBox, aliceBox}) || bob.CanAccessBoxes([]string{defaultBox}) {
+		t.Fatalf("bob can access wrong boxes")
+	}
+	if boxes := alice.Boxes(); 1 != len(boxes) || aliceBox != boxes[0] {
+		t.Fatalf("alice boxes are %v", boxes)
+	}
+	if boxes := Default().Boxes(); 1 != len(boxes) || defaultBox != boxes[0] {
+		t.Fatalf("default boxes are %v", boxes)
+	}
+}
+
+func TestRestrictBoxes(t *testing.T) {
+	alice, bob := setupWorkspace(t)
+
+	// 不指定笔记本时只搜索自己的笔记本
+	if boxes := bob.RestrictBoxes(nil); 1 != len(boxes) || bobBox != boxes[0] {
+		t.Fatalf("bob searches boxes %v", boxes)
+	}
+
+	// 指定其他用户的笔记本时搜索不到结果
+	if boxes := bob.RestrictBoxes([]string{aliceBox}); 1 != len(boxes) || NoBox != boxes[0] {
+		t.Fatalf("bob searches boxes %v", boxes)
+	}
+	if boxes := alice.RestrictBoxes([]string{aliceBox, bobBox, defaultBox}); 1 != len(boxes) || aliceBox != boxes[0] {
+		t.Fatalf("alice searches boxes %v", boxes)
+	}
+
+	// 没有笔记本的用户搜索不到结果
+	carol, _ := Get("carol")
+	if boxes := carol.RestrictBoxes(nil); 1 != len(boxes) || NoBox != boxes[0] {
+		t.Fatalf("carol searches boxes %v", boxes)
+	}
+
+	// 默认用户不受限制
+	if boxes := Default().RestrictBoxes(nil); 0 != len(boxes) {
+		t.Fatalf("default user searches boxes %v", boxes)
+	}
+}
@@ -0,0 +1,223 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/88250/gulu"
+	"github.com/sashabaranov/go-openai"
+	"github.com/siyuan-note/logging"
+)
+
+// EmbeddingProvider 将文本转换为向量，返回的向量和 texts 一一对应。
+type EmbeddingProvider interface {
+	Embed(ctx context.Context, texts []string) (ret [][]float32, err error)
+}
+
+// OpenAIEmbeddingProvider 使用 OpenAI 兼容接口（包括 Azure）生成向量。
+type OpenAIEmbeddingProvider struct {
+	c     *openai.Client
+	model string
+}
+
+// NewOpenAIEmbeddingProvider 创建 OpenAI 兼容接口的向量化服务，timeout 为单次 HTTP 请求的超时时间。
+func NewOpenAIEmbeddingProvider(apiKey, apiProxy, apiBaseURL, apiUserAgent, apiVersion, apiProvider, model string, timeout time.Duration) *OpenAIEmbeddingProvider {
+	config := newOpenAIClientConfig(apiKey, apiProxy, apiBaseURL, apiUserAgent, apiVersion, apiProvider)
+	config.HTTPClient.(*http.Client).Timeout = timeout
+	return &OpenAIEmbeddingProvider{c: openai.NewClientWithConfig(config), model: model}
+}
+
+func (provider *OpenAIEmbeddingProvider) Embed(ctx context.Context, texts []string) (ret [][]float32, err error) {
+	resp, err := provider.c.CreateEmbeddings(ctx, openai.EmbeddingRequest{Input: texts, Model: openai.EmbeddingModel(provider.model)})
+	if err != nil {
+		logging.LogErrorf("create embeddings failed: %s", err)
+		return
+	}
+
+	if len(resp.Data) != len(texts) {
+		err = fmt.Errorf("embedding API returned [%d] embeddings for [%d] texts", len(resp.Data), len(texts))
+		return
+	}
+	ret = make([][]float32, len(texts))
+	for _, data := range resp.Data {
+		if 0 > data.Index || len(ret) <= data.Index {
+			continue
+		}
+		ret[data.Index] = data.Embedding
+	}
+	err = checkEmbeddings(ret)
+	return
+}
+
+// LocalEmbeddingProvider 使用本地 HTTP 服务生成向量。
+//
+// 请求体为 {"model": "", "input": ["text"]}，响应体兼容 OpenAI 的 {"data": [{"index": 0, "embedding": []}]}
+// 和 Ollama 的 {"embeddings": [[]]} 两种格式。
+type LocalEmbeddingProvider struct {
+	endpoint string
+	model    string
+	client   *http.Client
+}
+
+// NewLocalEmbeddingProvider 创建本地向量化服务，timeout 为单次 HTTP 请求的超时时间。
+func NewLocalEmbeddingProvider(endpoint, model, apiProxy, apiUserAgent string, timeout time.Duration) *LocalEmbeddingProvider {
+	transport := &http.Transport{}
+	if "" != apiProxy {
+		proxyUrl, err := url.Parse(apiProxy)
+		if err != nil {
+			logging.LogErrorf("embedding API proxy failed: %v", err)
+		} else {
+			transport.Proxy = http.ProxyURL(proxyUrl)
+		}
+	}
+	return &LocalEmbeddingProvider{
+		endpoint: endpoint,
+		model:    model,
+		client:   &http.Client{Transport: newAddHeaderTransport(transport, apiUserAgent), Timeout: timeout},
+	}
+}
+
+func (provider *LocalEmbeddingProvider) Embed(ctx context.Context, texts []string) (ret [][]float32, err error) {
+	body, err := gulu.JSON.MarshalJSON(map[string]interface{}{"model": provider.model, "input": texts})
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, provider.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := provider.client.Do(req)
+	if err != nil {
+		logging.LogErrorf("request local embedding service [%s] failed: %s", provider.endpoint, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	if http.StatusOK != resp.StatusCode {
+		err = fmt.Errorf("local embedding service [%s] responded [%d]", provider.endpoint, resp.StatusCode)
+		logging.LogErrorf("%s: %s", err, data)
+		return
+	}
+
+	result := &struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+		Embeddings [][]float32 `json:"embeddings"`
+	}{}
+	if err = gulu.JSON.UnmarshalJSON(data, result); err != nil {
+		logging.LogErrorf("unmarshal local embedding response failed: %s", err)
+		return
+	}
+
+	ret = result.Embeddings
+	if 1 > len(ret) {
+		ret = make([][]float32, len(texts))
+		for _, d := range result.Data {
+			if 0 > d.Index || len(ret) <= d.Index {
+				continue
+			}
+			ret[d.Index] = d.Embedding
+		}
+	}
+	if len(ret) != len(texts) {
+		err = fmt.Errorf("local embedding service returned [%d] embeddings for [%d] texts", len(ret), len(texts))
+		return
+	}
+	err = checkEmbeddings(ret)
+	return
+}
+
+// NormalizeEmbedding 将向量归一化为单位向量，归一化后的向量点积即为余弦相似度。
+func NormalizeEmbedding(vector []float32) []float32 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if 0 == sum {
+		return vector
+	}
+
+	norm := float32(math.Sqrt(sum))
+	ret := make([]float32, len(vector))
+	for i, v := range vector {
+		ret[i] = v / norm
+	}
+	return ret
+}
+
+// CosineSimilarity 计算两个归一化向量的余弦相似度，维度不一致（比如更换模型后的旧向量）时返回 0。
+func CosineSimilarity(a, b []float32) (ret float64) {
+	if len(a) != len(b) {
+		return
+	}
+
+	for i, v := range a {
+		ret += float64(v) * float64(b[i])
+	}
+	return
+}
+
+// HybridRank 按照 weight*语义相似度 + (1-weight)*全文搜索相关度 对块排序，得分相同时按 ID 倒序。
+func HybridRank(similarities, ranks map[string]float64, weight float64) (ret []string) {
+	scores := map[string]float64{}
+	for id, similarity := range similarities {
+		if 0 < similarity {
+			scores[id] += weight * similarity
+		}
+	}
+	for id, rank := range ranks {
+		scores[id] += (1 - weight) * rank
+	}
+
+	for id := range scores {
+		ret = append(ret, id)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if scores[ret[i]] == scores[ret[j]] {
+			return ret[i] > ret[j]
+		}
+		return scores[ret[i]] > scores[ret[j]]
+	})
+	return
+}
+
+func checkEmbeddings(embeddings [][]float32) error {
+	for _, embedding := range embeddings {
+		if 1 > len(embedding) {
+			return errors.New("embedding is empty")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// SiYuan - Refactor your thinking
+// Copyright (c) 2020-present, b3log.org
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package util
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHybridRank(t *testing.T) {
+	similarities := map[string]float64{"a": 0.9, "b": 0.1, "c": -0.5}
+	ranks := map[string]float64{"b": 1, "c": 0.5, "d": 0.2}
+
+	tests := []struct {
+		weight float64
+		want   []string
+	}{
+		{1, []string{"a", "b", "d", "c"}}, // 仅语义相似度，负相似度不加分
+		{0, []string{"b", "c", "d", "a"}}, // 仅全文搜索相关度
+		{0.5, []string{"b", "a", "c", "d"}},
+	}
+	for _, test := range tests {
+		if got := HybridRank(similarities, ranks, test.weight); !reflect.DeepEqual(test.want, got) {
+			t.Fatalf("weight [%v] expected %v, got %v", test.weight, test.want, got)
+		}
+	}
+}
+
+func TestLocalEmbeddingProvider(t *testing.T) {
+	var lastBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		lastBody = string(body)
+		switch r.URL.Path {
+		case "/openai":
+			w.Write([]byte(`{"data": [{"index": 1, "embedding": [0, 1]}, {"index": 0, "embedding": [1, 0]}]}`))
+		case "/ollama":
+			w.Write([]byte(`{"embeddings": [[1, 0], [0, 1]]}`))
+		case "/short":
+			w.Write([]byte(`{"embeddings": [[1, 0]]}`))
+		case "/empty":
+			w.Write([]byte(`{"data": [{"index": 0, "embedding": []}, {"index": 1, "embedding": [1]}]}`))
+		case "/slow":
+			time.Sleep(time.Second)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	texts := []string{"foo", "bar"}
+	for _, path := range []string{"/openai", "/ollama"} {
+		provider := NewLocalEmbeddingProvider(server.URL+path, "m", "", "SiYuan", 5*time.Second)
+		ret, err := provider.Embed(context.Background(), texts)
+		if err != nil || !reflect.DeepEqual([][]float32{{1, 0}, {0, 1}}, ret) {
+			t.Fatalf("path [%s] unexpected embeddings %v: %v", path, ret, err)
+		}
+	}
+	if !strings.Contains(lastBody, `"model":"m"`) || !strings.Contains(lastBody, `"input":["foo","bar"]`) {
+		t.Fatalf("unexpected request body [%s]", lastBody)
+	}
+
+	for _, path := range []string{"/short", "/empty", "/error", "/slow"} {
+		provider := NewLocalEmbeddingProvider(server.URL+path, "m", "", "SiYuan", 200*time.Millisecond)
+		if _, err := provider.Embed(context.Background(), texts); nil == err {
+			t.Fatalf("path [%s] expected error", path)
+		}
+	}
+}
+
+func TestOpenAIEmbeddingProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if "/embeddings" != r.URL.Path || "Bearer key" != r.Header.Get("Authorization") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"object": "list", "data": [{"object": "embedding", "index": 0, "embedding": [0.5, 0.5]}], "model": "m"}`))
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIEmbeddingProvider("key", "", server.URL, "SiYuan", "", "OpenAI", "m", 5*time.Second)
+	ret, err := provider.Embed(context.Background(), []string{"foo"})
+	if err != nil || !reflect.DeepEqual([][]float32{{0.5, 0.5}}, ret) {
+		t.Fatalf("unexpected embeddings %v: %v", ret, err)
+	}
+
+	// 返回的向量数量和文本数量不一致
+	if _, err = provider.Embed(context.Background(), []string{"foo", "bar"}); nil == err {
+		t.Fatalf("expected error for missing embeddings")
+	}
+
+	provider = NewOpenAIEmbeddingProvider("wrong", "", server.URL, "SiYuan", "", "OpenAI", "m", 5*time.Second)
+	if _, err = provider.Embed(context.Background(), []string{"foo"}); nil == err {
+		t.Fatalf("expected error for unauthorized request")
+	}
+}
@@ -77,6 +77,11 @@ func ChatGPT(msg string, contextMsgs []string, c *openai.Client, model string, m
 }
 
 func NewOpenAIClient(apiKey, apiProxy, apiBaseURL, apiUserAgent, apiVersion, apiProvider string) *openai.Client {
+	config := newOpenAIClientConfig(apiKey, apiProxy, apiBaseURL, apiUserAgent, apiVersion, apiProvider)
+	return openai.NewClientWithConfig(config)
+}
+
+func newOpenAIClientConfig(apiKey, apiProxy, apiBaseURL, apiUserAgent, apiVersion, apiProvider string) openai.ClientConfig {
 	config := openai.DefaultConfig(apiKey)
 	if "Azure" == apiProvider {
 		config = openai.DefaultAzureConfig(apiKey, apiBaseURL)
@@ -94,7 +99,7 @@ func NewOpenAIClient(apiKey, apiProxy, apiBaseURL, apiUserAgent, apiVersion, api
 	}
 	config.HTTPClient = &http.Client{Transport: newAddHeaderTransport(transport, apiUserAgent)}
 	config.BaseURL = apiBaseURL
-	return openai.NewClientWithConfig(config)
+	return config
 }
 
 type AddHeaderTransport struct {
@@ -79,7 +79,7 @@ var IsExiting = atomic.Bool{}
 var MobileOSVer string
 
 // DatabaseVer 数据库版本。修改表结构的话需要修改这里。
-const DatabaseVer = "20220501"
+const DatabaseVer = "20261020"
 
 func logBootInfo() {
 	plat := GetOSPlatform()
@@ -419,4 +419,6 @@ const (
 
 	EvtSQLHistoryRebuild      = "sql.history.rebuild"
 	EvtSQLAssetContentRebuild = "sql.assetContent.rebuild"
+
+	EvtAttributeViewSaved = "av.saved"
 )
@@ -37,6 +37,15 @@ type SessionData struct {
 type WorkspaceSession struct {
 	AccessAuthCode string
 	Captcha        string
+
+	OIDCIssuer  string // 通过 OpenID Connect 登录时的身份提供方
+	OIDCSubject string // 通过 OpenID Connect 登录的用户标识（sub 声明），为空时未通过 OpenID Connect 登录
+	OIDCRole    uint   // 通过 OpenID Connect 登录后映射的角色
+
+	OIDCState    string // 登录中的 state，用于校验回调请求
+	OIDCNonce    string // 登录中的 nonce，用于校验 ID token
+	OIDCVerifier string // 登录中的 PKCE code verifier
+	OIDCTo       string // 登录成功后跳转的地址
 }
 
 // Save saves the current session of the specified context.
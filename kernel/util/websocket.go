@@ -30,6 +30,9 @@ var (
 
 	// map[string]map[string]*melody.Session{}
 	sessions = sync.Map{} // {appId, {sessionId, session}}
+
+	// PushFilter 用于过滤推送给设置了账号（account）的会话的消息，返回 false 时不推送
+	PushFilter func(account string, msg []byte) bool
 )
 
 func BroadcastByTypeAndApp(typ, app, cmd string, code int, msg string, data interface{}) {
@@ -46,7 +49,7 @@ func BroadcastByTypeAndApp(typ, app, cmd string, code int, msg string, data inte
 			event.Code = code
 			event.Msg = msg
 			event.Data = data
-			session.Write(event.Bytes())
+			writeSession(session, event.Bytes())
 		}
 		return true
 	})
@@ -61,7 +64,7 @@ func BroadcastByType(typ, cmd string, code int, msg string, data interface{}) {
 		event.Code = code
 		event.Msg = msg
 		event.Data = data
-		sess.Write(event.Bytes())
+		writeSession(sess, event.Bytes())
 	}
 }
 
@@ -316,7 +319,7 @@ func single(msg []byte, appId, sid string) {
 		appSessions.Range(func(key, value interface{}) bool {
 			session := value.(*melody.Session)
 			if id, _ := session.Get("id"); id == sid {
-				session.Write(msg)
+				writeSession(session, msg)
 			}
 			return true
 		})
@@ -329,7 +332,7 @@ func Broadcast(msg []byte) {
 		appSessions := value.(*sync.Map)
 		appSessions.Range(func(key, value interface{}) bool {
 			session := value.(*melody.Session)
-			session.Write(msg)
+			writeSession(session, msg)
 			return true
 		})
 		return true
@@ -344,7 +347,7 @@ func broadcastOtherApps(msg []byte, excludeApp string) {
 			if app, _ := session.Get("app"); app == excludeApp {
 				return true
 			}
-			session.Write(msg)
+			writeSession(session, msg)
 			return true
 		})
 		return true
@@ -364,7 +367,7 @@ func broadcastOtherAppMains(msg []byte, excludeApp string) {
 				return true
 			}
 
-			session.Write(msg)
+			writeSession(session, msg)
 			return true
 		})
 		return true
@@ -379,7 +382,7 @@ func broadcastApp(msg []byte, app string) {
 			if sessionApp, _ := session.Get("app"); sessionApp != app {
 				return true
 			}
-			session.Write(msg)
+			writeSession(session, msg)
 			return true
 		})
 		return true
@@ -394,13 +397,20 @@ func broadcastOthers(msg []byte, excludeSID string) {
 			if id, _ := session.Get("id"); id == excludeSID {
 				return true
 			}
-			session.Write(msg)
+			writeSession(session, msg)
 			return true
 		})
 		return true
 	})
 }
 
+func writeSession(session *melody.Session, msg []byte) {
+	if account, _ := session.Get("account"); nil != account && nil != PushFilter && !PushFilter(account.(string), msg) {
+		return
+	}
+	session.Write(msg)
+}
+
 func CountSessions() (ret int) {
 	sessions.Range(func(key, value interface{}) bool {
 		ret++
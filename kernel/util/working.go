@@ -428,14 +428,6 @@ func initPathDir() {
 	}
 }
 
-func InitPathUserDir(userNo string) {
-	userPath := filepath.Join(DataDir, userNo)
-
-	if err := os.MkdirAll(userPath, 0755); err != nil && !os.IsExist(err) {
-		logging.LogFatalf(logging.ExitCodeInitWorkspaceErr, "create user data folder [%s] failed: %s", userPath, err)
-	}
-}
-
 func initMime() {
 	// 在某版本的 Windows 10 操作系统上界面样式异常问题
 	// https://github.com/siyuan-note/siyuan/issues/247